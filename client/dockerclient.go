@@ -11,6 +11,7 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/sockets"
 	"github.com/ehazlett/interlock/version"
 )
 
@@ -38,7 +39,7 @@ func GetTLSConfig(caCert, cert, key []byte, allowInsecure bool) (*tls.Config, er
 	return &tlsConfig, nil
 }
 
-func GetDockerClient(dockerUrl, tlsCaCert, tlsCert, tlsKey string, allowInsecure bool) (*client.Client, error) {
+func GetDockerClient(dockerUrl, tlsCaCert, tlsCert, tlsKey string, allowInsecure bool) (*Client, error) {
 	// check environment for docker client config
 	envDockerHost := os.Getenv("DOCKER_HOST")
 	if dockerUrl == "" && envDockerHost != "" {
@@ -87,6 +88,19 @@ func GetDockerClient(dockerUrl, tlsCaCert, tlsCert, tlsKey string, allowInsecure
 		}
 	}
 
+	if httpClient == nil {
+		proto, addr, _, err := client.ParseHost(dockerUrl)
+		if err != nil {
+			return nil, err
+		}
+
+		transport := new(http.Transport)
+		sockets.ConfigureTransport(transport, proto, addr)
+		httpClient = &http.Client{
+			Transport: transport,
+		}
+	}
+
 	log.Debugf("docker client: url=%s", dockerUrl)
 
 	defaultHeaders := map[string]string{"User-Agent": fmt.Sprintf("interlock-%s", version.Version)}
@@ -95,5 +109,5 @@ func GetDockerClient(dockerUrl, tlsCaCert, tlsCert, tlsKey string, allowInsecure
 		return nil, err
 	}
 
-	return c, nil
+	return NewClient(c, APILatency), nil
 }
@@ -0,0 +1,196 @@
+package client
+
+import (
+	"io"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	volumetypes "github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"golang.org/x/net/context"
+)
+
+// Client is the Docker engine client used by interlock.  The API calls
+// interlock makes are timed for the API latency stats; the other methods
+// are those of the engine client.
+type Client struct {
+	*client.Client
+	// recorder is nil to not record the latency
+	recorder *LatencyRecorder
+}
+
+// NewClient returns the engine client recording the latency of the API
+// calls to the recorder
+func NewClient(c *client.Client, recorder *LatencyRecorder) *Client {
+	return &Client{
+		Client:   c,
+		recorder: recorder,
+	}
+}
+
+// record adds the call to the latency stats; missing objects are not
+// counted as errors
+func (c *Client) record(method, endpoint string, start time.Time, err error) {
+	if c.recorder == nil {
+		return
+	}
+
+	failed := err != nil && !client.IsErrNotFound(err)
+	c.recorder.Record(method, endpoint, time.Since(start), failed)
+}
+
+func (c *Client) Info(ctx context.Context) (types.Info, error) {
+	start := time.Now()
+	info, err := c.Client.Info(ctx)
+	c.record("GET", "/info", start, err)
+
+	return info, err
+}
+
+func (c *Client) DiskUsage(ctx context.Context) (types.DiskUsage, error) {
+	start := time.Now()
+	du, err := c.Client.DiskUsage(ctx)
+	c.record("GET", "/system/df", start, err)
+
+	return du, err
+}
+
+func (c *Client) ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+	start := time.Now()
+	containers, err := c.Client.ContainerList(ctx, options)
+	c.record("GET", "/containers/json", start, err)
+
+	return containers, err
+}
+
+func (c *Client) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	start := time.Now()
+	cnt, err := c.Client.ContainerInspect(ctx, containerID)
+	c.record("GET", "/containers/{id}/json", start, err)
+
+	return cnt, err
+}
+
+// ContainerStats is timed until the stats stream is opened
+func (c *Client) ContainerStats(ctx context.Context, containerID string, stream bool) (types.ContainerStats, error) {
+	start := time.Now()
+	stats, err := c.Client.ContainerStats(ctx, containerID, stream)
+	c.record("GET", "/containers/{id}/stats", start, err)
+
+	return stats, err
+}
+
+func (c *Client) ContainerRestart(ctx context.Context, containerID string, timeout *time.Duration) error {
+	start := time.Now()
+	err := c.Client.ContainerRestart(ctx, containerID, timeout)
+	c.record("POST", "/containers/{id}/restart", start, err)
+
+	return err
+}
+
+func (c *Client) ContainerKill(ctx context.Context, containerID, signal string) error {
+	start := time.Now()
+	err := c.Client.ContainerKill(ctx, containerID, signal)
+	c.record("POST", "/containers/{id}/kill", start, err)
+
+	return err
+}
+
+func (c *Client) CopyToContainer(ctx context.Context, container, path string, content io.Reader, options types.CopyToContainerOptions) error {
+	start := time.Now()
+	err := c.Client.CopyToContainer(ctx, container, path, content, options)
+	c.record("PUT", "/containers/{id}/archive", start, err)
+
+	return err
+}
+
+func (c *Client) ContainerExecCreate(ctx context.Context, container string, config types.ExecConfig) (types.IDResponse, error) {
+	start := time.Now()
+	resp, err := c.Client.ContainerExecCreate(ctx, container, config)
+	c.record("POST", "/containers/{id}/exec", start, err)
+
+	return resp, err
+}
+
+func (c *Client) ContainerExecStart(ctx context.Context, execID string, config types.ExecStartCheck) error {
+	start := time.Now()
+	err := c.Client.ContainerExecStart(ctx, execID, config)
+	c.record("POST", "/exec/{id}/start", start, err)
+
+	return err
+}
+
+// ContainerExecAttach is timed until the connection is hijacked
+func (c *Client) ContainerExecAttach(ctx context.Context, execID string, config types.ExecConfig) (types.HijackedResponse, error) {
+	start := time.Now()
+	resp, err := c.Client.ContainerExecAttach(ctx, execID, config)
+	c.record("POST", "/exec/{id}/start", start, err)
+
+	return resp, err
+}
+
+func (c *Client) ContainerExecInspect(ctx context.Context, execID string) (types.ContainerExecInspect, error) {
+	start := time.Now()
+	resp, err := c.Client.ContainerExecInspect(ctx, execID)
+	c.record("GET", "/exec/{id}/json", start, err)
+
+	return resp, err
+}
+
+func (c *Client) ImageList(ctx context.Context, options types.ImageListOptions) ([]types.ImageSummary, error) {
+	start := time.Now()
+	images, err := c.Client.ImageList(ctx, options)
+	c.record("GET", "/images/json", start, err)
+
+	return images, err
+}
+
+func (c *Client) ImageInspectWithRaw(ctx context.Context, imageID string) (types.ImageInspect, []byte, error) {
+	start := time.Now()
+	image, raw, err := c.Client.ImageInspectWithRaw(ctx, imageID)
+	c.record("GET", "/images/{id}/json", start, err)
+
+	return image, raw, err
+}
+
+func (c *Client) NetworkList(ctx context.Context, options types.NetworkListOptions) ([]types.NetworkResource, error) {
+	start := time.Now()
+	networks, err := c.Client.NetworkList(ctx, options)
+	c.record("GET", "/networks", start, err)
+
+	return networks, err
+}
+
+func (c *Client) NetworkInspect(ctx context.Context, networkID string, verbose bool) (types.NetworkResource, error) {
+	start := time.Now()
+	n, err := c.Client.NetworkInspect(ctx, networkID, verbose)
+	c.record("GET", "/networks/{id}", start, err)
+
+	return n, err
+}
+
+func (c *Client) NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error {
+	start := time.Now()
+	err := c.Client.NetworkConnect(ctx, networkID, containerID, config)
+	c.record("POST", "/networks/{id}/connect", start, err)
+
+	return err
+}
+
+func (c *Client) NetworkDisconnect(ctx context.Context, networkID, containerID string, force bool) error {
+	start := time.Now()
+	err := c.Client.NetworkDisconnect(ctx, networkID, containerID, force)
+	c.record("POST", "/networks/{id}/disconnect", start, err)
+
+	return err
+}
+
+func (c *Client) VolumeList(ctx context.Context, filter filters.Args) (volumetypes.VolumesListOKBody, error) {
+	start := time.Now()
+	volumes, err := c.Client.VolumeList(ctx, filter)
+	c.record("GET", "/volumes", start, err)
+
+	return volumes, err
+}
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"golang.org/x/net/context"
+)
+
+func TestClientLatency(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1.26/info":
+			http.Error(w, "error", http.StatusInternalServerError)
+		case "/v1.26/containers/abc/json":
+			http.Error(w, "no such container", http.StatusNotFound)
+		default:
+			w.Write([]byte("[]"))
+		}
+	}))
+	defer srv.Close()
+
+	cl, err := client.NewClient("tcp://"+strings.TrimPrefix(srv.URL, "http://"), apiVersion, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewLatencyRecorder()
+	c := NewClient(cl, r)
+
+	if _, err := c.Info(context.Background()); err == nil {
+		t.Fatal("expected info error")
+	}
+
+	if _, err := c.ContainerInspect(context.Background(), "abc"); !client.IsErrNotFound(err) {
+		t.Fatalf("expected not found error; received %v", err)
+	}
+
+	if _, err := c.ContainerList(context.Background(), types.ContainerListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := r.Snapshot()
+	if len(stats) != 3 {
+		t.Fatalf("expected 3 endpoints; received %+v", stats)
+	}
+
+	if stats[0].Endpoint != "/containers/json" || stats[0].Method != "GET" || stats[0].Errors != 0 {
+		t.Fatalf("unexpected stat: %+v", stats[0])
+	}
+
+	// missing objects are not errors
+	if stats[1].Endpoint != "/containers/{id}/json" || stats[1].Errors != 0 {
+		t.Fatalf("unexpected stat: %+v", stats[1])
+	}
+
+	if stats[2].Endpoint != "/info" || stats[2].Errors != 1 {
+		t.Fatalf("unexpected stat: %+v", stats[2])
+	}
+}
+
+// TestExecAttachTLS checks the hijacked connections (i.e. the nginx config
+// test before a reload) use the tls config of the client
+func TestExecAttachTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1.26/exec/abc/start" {
+			http.NotFound(w, r)
+			return
+		}
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\nsyntax is ok\n"))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "interlock-tls-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certs := map[string]string{
+		"ca.pem":   TestCACert,
+		"cert.pem": TestCert,
+		"key.pem":  TestKey,
+	}
+	for name, data := range certs {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := GetDockerClient(
+		"tcp://"+strings.TrimPrefix(srv.URL, "https://"),
+		filepath.Join(dir, "ca.pem"),
+		filepath.Join(dir, "cert.pem"),
+		filepath.Join(dir, "key.pem"),
+		true,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := c.ContainerExecAttach(context.Background(), "abc", types.ExecConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Close()
+
+	line, err := bufio.NewReader(resp.Reader).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if line != "syntax is ok\n" {
+		t.Fatalf("unexpected exec output: %q", line)
+	}
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// APILatency records the Docker API calls made by the clients
+	// returned from GetDockerClient
+	APILatency = NewLatencyRecorder()
+)
+
+// LatencyStat is the latency of an API endpoint since the last snapshot
+type LatencyStat struct {
+	Method   string
+	Endpoint string
+	Count    int
+	Errors   int
+	Total    time.Duration
+	Max      time.Duration
+}
+
+// Average returns the mean latency of the requests
+func (s LatencyStat) Average() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+
+	return s.Total / time.Duration(s.Count)
+}
+
+// LatencyRecorder aggregates the latency of API requests by endpoint
+type LatencyRecorder struct {
+	lock  sync.Mutex
+	stats map[string]*LatencyStat
+}
+
+func NewLatencyRecorder() *LatencyRecorder {
+	return &LatencyRecorder{
+		stats: map[string]*LatencyStat{},
+	}
+}
+
+// Record adds a request to the endpoint stats
+func (r *LatencyRecorder) Record(method, endpoint string, d time.Duration, failed bool) {
+	key := method + " " + endpoint
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	s, ok := r.stats[key]
+	if !ok {
+		s = &LatencyStat{
+			Method:   method,
+			Endpoint: endpoint,
+		}
+		r.stats[key] = s
+	}
+
+	s.Count++
+	s.Total += d
+	if d > s.Max {
+		s.Max = d
+	}
+	if failed {
+		s.Errors++
+	}
+}
+
+// Snapshot returns the stats recorded since the last snapshot sorted by
+// endpoint and method and resets the recorder
+func (r *LatencyRecorder) Snapshot() []LatencyStat {
+	r.lock.Lock()
+	stats := r.stats
+	r.stats = map[string]*LatencyStat{}
+	r.lock.Unlock()
+
+	snapshot := []LatencyStat{}
+	for _, s := range stats {
+		snapshot = append(snapshot, *s)
+	}
+
+	sort.Sort(latencyStats(snapshot))
+
+	return snapshot
+}
+
+type latencyStats []LatencyStat
+
+func (s latencyStats) Len() int      { return len(s) }
+func (s latencyStats) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s latencyStats) Less(i, j int) bool {
+	if s[i].Endpoint != s[j].Endpoint {
+		return s[i].Endpoint < s[j].Endpoint
+	}
+	return s[i].Method < s[j].Method
+}
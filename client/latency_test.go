@@ -0,0 +1,31 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyRecorder(t *testing.T) {
+	r := NewLatencyRecorder()
+	r.Record("GET", "/info", time.Millisecond*10, false)
+	r.Record("GET", "/info", time.Millisecond*30, true)
+	r.Record("GET", "/containers/json", time.Millisecond*5, false)
+
+	stats := r.Snapshot()
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 endpoints; received %d", len(stats))
+	}
+
+	s := stats[1]
+	if s.Endpoint != "/info" || s.Count != 2 || s.Errors != 1 {
+		t.Fatalf("unexpected stat: %+v", s)
+	}
+
+	if s.Average() != time.Millisecond*20 || s.Max != time.Millisecond*30 {
+		t.Fatalf("unexpected latency: avg=%s max=%s", s.Average(), s.Max)
+	}
+
+	if len(r.Snapshot()) != 0 {
+		t.Fatal("expected snapshot to reset the recorder")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/codegangsta/cli"
+	"github.com/ehazlett/interlock/ext/lb"
+	"github.com/ehazlett/interlock/version"
+)
+
+var cmdAgent = cli.Command{
+	Name:   "agent",
+	Usage:  "apply the proxy config published to the key value store to a local proxy",
+	Action: agentAction,
+	Flags: append([]cli.Flag{
+		cli.StringFlag{
+			Name:  "extension, e",
+			Usage: "extension of the proxy (haproxy, nginx)",
+			Value: "nginx",
+		},
+		cli.StringFlag{
+			Name:  "label-prefix",
+			Usage: "label prefix of the extension publishing the config",
+			Value: "interlock.",
+		},
+		cli.StringFlag{
+			Name:  "base-dir",
+			Usage: "directory the config and the ssl files are written to; the published paths outside of it are rejected (required)",
+			Value: "",
+		},
+		cli.StringFlag{
+			Name:  "config-path",
+			Usage: "path of the proxy config (defaults to the ConfigPath of the extension)",
+			Value: "",
+		},
+		cli.StringFlag{
+			Name:  "validate-cmd",
+			Usage: "command validating the new config; {config} is replaced by its path (i.e. nginx -t -c {config})",
+			Value: "",
+		},
+		cli.StringFlag{
+			Name:  "reload-cmd",
+			Usage: "command reloading the proxy (i.e. nginx -s reload)",
+			Value: "",
+		},
+		cli.DurationFlag{
+			Name:  "poll-interval",
+			Usage: "poll interval for the key value stores without watch support",
+			Value: time.Second * 5,
+		},
+	}, discoveryFlags...),
+}
+
+func agentAction(c *cli.Context) {
+	log.Infof("interlock agent %s", version.FullVersion())
+
+	kv := discoveryStore(c)
+	if kv == nil {
+		log.Fatal("the agent requires a discovery key value store (--discovery)")
+	}
+
+	agent, err := lb.NewAgent(kv, &lb.AgentConfig{
+		Extension:       c.String("extension"),
+		LabelPrefix:     c.String("label-prefix"),
+		BaseDir:         c.String("base-dir"),
+		ConfigPath:      c.String("config-path"),
+		ValidateCommand: c.String("validate-cmd"),
+		ReloadCommand:   c.String("reload-cmd"),
+		PollInterval:    c.Duration("poll-interval"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := agent.Run(nil); err != nil {
+		log.Fatal(err)
+	}
+}
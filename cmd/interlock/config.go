@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/codegangsta/cli"
+	"github.com/ehazlett/interlock/config"
+)
+
+var cmdConfig = cli.Command{
+	Name:  "config",
+	Usage: "manage the configuration",
+	Subcommands: []cli.Command{
+		{
+			Name:   "validate",
+			Usage:  "validate the configuration",
+			Action: configValidateAction,
+			Flags:  configFlags,
+		},
+	},
+}
+
+func configValidateAction(c *cli.Context) {
+	data := loadConfig(c, discoveryStore(c))
+
+	result, err := config.Validate(data, loadOverrides(c)...)
+	if err != nil {
+		log.Fatalf("unable to parse config: %s", err)
+	}
+
+	for _, w := range result.Warnings {
+		fmt.Printf("warning: %s\n", w)
+	}
+
+	for _, e := range result.Errors {
+		fmt.Printf("error: %s\n", e)
+	}
+
+	if len(result.Errors) > 0 {
+		os.Exit(1)
+	}
+
+	fmt.Println("config is valid")
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/codegangsta/cli"
+	"github.com/ehazlett/interlock/config"
+	"github.com/ehazlett/interlock/ext/lb"
+	"github.com/ehazlett/interlock/pkg/compose"
+	"golang.org/x/net/context"
+)
+
+var projectName = regexp.MustCompile(`[^a-z0-9]`)
+
+var cmdImport = cli.Command{
+	Name:      "import",
+	Usage:     "report the hosts and upstreams of a compose file without deploying it",
+	ArgsUsage: "<compose file>",
+	Action:    importAction,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "config, c",
+			Usage: "path to config file (the default options of the extension if empty)",
+			Value: "",
+		},
+		cli.StringFlag{
+			Name:  "extension, e",
+			Usage: "extension generating the proxy config (haproxy, nginx)",
+			Value: "nginx",
+		},
+		cli.StringFlag{
+			Name:  "project, p",
+			Usage: "project name (defaults to the name of the compose file directory)",
+			Value: "",
+		},
+		cli.StringSliceFlag{
+			Name:  "set, s",
+			Usage: "override a config option (i.e. Extensions.0.SSLPort=8443)",
+			Value: &cli.StringSlice{},
+		},
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "print the routes as json",
+		},
+	},
+}
+
+func importAction(c *cli.Context) {
+	if len(c.Args()) != 1 {
+		log.Fatal("a compose file is required")
+	}
+
+	path := c.Args().First()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	project := c.String("project")
+	if project == "" {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		project = projectName.ReplaceAllString(strings.ToLower(filepath.Base(filepath.Dir(abs))), "")
+	}
+
+	p, err := compose.Parse(data, project)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	extCfg, err := importExtension(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	routes, err := lb.PreviewRoutes(context.Background(), extCfg, p.Containers())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if c.Bool("json") {
+		if err := json.NewEncoder(os.Stdout).Encode(routes); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tALIASES\tCONTEXT ROOTS\tUPSTREAMS")
+	for _, r := range routes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Host, column(r.Aliases), column(r.ContextRoots), column(r.Upstreams))
+	}
+	w.Flush()
+}
+
+// importExtension returns the config of the extension; a config with only
+// the extension is used unless a config is set
+func importExtension(c *cli.Context) (*config.ExtensionConfig, error) {
+	name := c.String("extension")
+
+	data := fmt.Sprintf("[[Extensions]]\n  Name = %q\n", name)
+	if c.String("config") != "" || os.Getenv("INTERLOCK_CONFIG") != "" {
+		data = loadConfig(c, nil)
+	}
+
+	cfg, err := config.ParseConfig(data, loadOverrides(c)...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, x := range cfg.Extensions {
+		if x.Name == name {
+			return x, nil
+		}
+	}
+
+	return nil, fmt.Errorf("extension %s is not configured", name)
+}
+
+func column(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+
+	return strings.Join(values, ",")
+}
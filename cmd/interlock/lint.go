@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/codegangsta/cli"
+	"github.com/ehazlett/interlock/ext/lb/utils"
+)
+
+var cmdLint = cli.Command{
+	Name:      "lint",
+	Usage:     "report the problems of the interlock labels of a running container",
+	ArgsUsage: "<container>",
+	Action:    lintAction,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "addr, a",
+			Usage: "address of the interlock api",
+			Value: "http://127.0.0.1:8080",
+		},
+	},
+}
+
+func lintAction(c *cli.Context) {
+	if len(c.Args()) != 1 {
+		log.Fatal("a container is required")
+	}
+
+	addr := strings.TrimRight(c.String("addr"), "/")
+	resp, err := http.Get(fmt.Sprintf("%s/lint/%s", addr, url.PathEscape(c.Args().First())))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		log.Fatal(strings.TrimSpace(string(body)))
+	}
+
+	var reports []*utils.Report
+	if err := json.NewDecoder(resp.Body).Decode(&reports); err != nil {
+		log.Fatal(err)
+	}
+
+	errors := 0
+	for _, r := range reports {
+		for _, d := range r.Diagnostics {
+			fmt.Printf("%s (%s): %s\n", r.Name, r.Extension, d)
+		}
+		errors += r.Errors()
+	}
+
+	if errors > 0 {
+		os.Exit(1)
+	}
+
+	if len(reports) == 0 || allClean(reports) {
+		fmt.Println("no problems found")
+	}
+}
+
+func allClean(reports []*utils.Report) bool {
+	for _, r := range reports {
+		if len(r.Diagnostics) > 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -24,6 +24,10 @@ func main() {
 	app.Commands = []cli.Command{
 		cmdSpec,
 		cmdRun,
+		cmdConfig,
+		cmdAgent,
+		cmdImport,
+		cmdLint,
 	}
 	app.Before = func(c *cli.Context) error {
 		if c.Bool("debug") {
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestBinary builds and runs the binary; the vendored packages are
+// initialized before main so this catches panics the package tests miss
+func TestBinary(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping binary build in short mode")
+	}
+
+	dir, err := ioutil.TempDir("", "interlock-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bin := filepath.Join(dir, "interlock")
+	if out, err := exec.Command("go", "build", "-o", bin, ".").CombinedOutput(); err != nil {
+		t.Fatalf("error building binary: %s: %s", err, out)
+	}
+
+	for _, args := range [][]string{
+		{"--version"},
+		{"spec", "--extension", "haproxy"},
+	} {
+		out, err := exec.Command(bin, args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("error running interlock %s: %s: %s", strings.Join(args, " "), err, out)
+		}
+
+		if !strings.Contains(string(out), "interlock") {
+			t.Fatalf("unexpected output of interlock %s: %s", strings.Join(args, " "), out)
+		}
+	}
+}
@@ -1,20 +1,24 @@
 package main
 
 import (
+	"crypto/tls"
 	"io/ioutil"
-	"net/url"
 	"os"
-	"strings"
+	"os/signal"
+	"syscall"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/codegangsta/cli"
-	"github.com/docker/libkv"
 	kvstore "github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
 	"github.com/docker/libkv/store/consul"
 	"github.com/docker/libkv/store/etcd"
+	"github.com/docker/libkv/store/zookeeper"
 	"github.com/ehazlett/interlock/config"
+	"github.com/ehazlett/interlock/pkg/discovery"
 	"github.com/ehazlett/interlock/pkg/tlsconfig"
+	"github.com/ehazlett/interlock/pkg/vault"
 	"github.com/ehazlett/interlock/server"
 	"github.com/ehazlett/interlock/version"
 )
@@ -27,19 +31,12 @@ EnableMetrics = true
 	kvConfigKey = "interlock/v1/config"
 )
 
-var cmdRun = cli.Command{
-	Name:   "run",
-	Usage:  "run interlock",
-	Action: runAction,
-	Flags: []cli.Flag{
-		cli.StringFlag{
-			Name:  "config, c",
-			Usage: "path to config file",
-			Value: "",
-		},
+var (
+	// discoveryFlags configure the key value store
+	discoveryFlags = []cli.Flag{
 		cli.StringFlag{
 			Name:  "discovery, k",
-			Usage: "discovery address",
+			Usage: "discovery address (consul://, etcd://, zk://, boltdb:// or vault://)",
 			Value: "",
 		},
 		cli.StringFlag{
@@ -57,86 +54,158 @@ var cmdRun = cli.Command{
 			Usage: "discovery tls key",
 			Value: "",
 		},
-	},
+		cli.StringFlag{
+			Name:   "vault-token",
+			Usage:  "vault token",
+			Value:  "",
+			EnvVar: "VAULT_TOKEN",
+		},
+		cli.StringFlag{
+			Name:   "vault-role-id",
+			Usage:  "vault approle role id (used if no token is set)",
+			Value:  "",
+			EnvVar: "VAULT_ROLE_ID",
+		},
+		cli.StringFlag{
+			Name:   "vault-secret-id",
+			Usage:  "vault approle secret id",
+			Value:  "",
+			EnvVar: "VAULT_SECRET_ID",
+		},
+	}
+
+	configFlags = append(append([]cli.Flag{
+		cli.StringFlag{
+			Name:  "config, c",
+			Usage: "path to config file",
+			Value: "",
+		},
+	}, discoveryFlags...),
+		cli.StringFlag{
+			Name:   "vault-addr",
+			Usage:  "vault address for the vault:// secret references (i.e. https://127.0.0.1:8200)",
+			Value:  "",
+			EnvVar: "VAULT_ADDR",
+		},
+		cli.StringFlag{
+			Name:   "vault-ca-cert",
+			Usage:  "vault tls ca certificate",
+			Value:  "",
+			EnvVar: "VAULT_CACERT",
+		},
+		cli.StringSliceFlag{
+			Name:  "set, s",
+			Usage: "override a config option (i.e. Extensions.0.SSLPort=8443)",
+			Value: &cli.StringSlice{},
+		},
+	)
+)
+
+var cmdRun = cli.Command{
+	Name:   "run",
+	Usage:  "run interlock",
+	Action: runAction,
+	Flags:  configFlags,
 }
 
 func init() {
 	consul.Register()
 	etcd.Register()
+	zookeeper.Register()
+	boltdb.Register()
 }
 
-func getKVStore(addr string, options *kvstore.Config) (kvstore.Store, error) {
-	u, err := url.Parse(addr)
-	if err != nil {
-		return nil, err
-
+// vaultAuth returns the vault authentication of the flags
+func vaultAuth(c *cli.Context) *vault.Auth {
+	return &vault.Auth{
+		Token:    c.String("vault-token"),
+		RoleID:   c.String("vault-role-id"),
+		SecretID: c.String("vault-secret-id"),
 	}
+}
 
-	kvType := strings.ToLower(u.Scheme)
-	kvHost := u.Host
-	var backend kvstore.Backend
-
-	switch kvType {
-	case "consul":
-		backend = kvstore.CONSUL
-	case "etcd":
-		backend = kvstore.ETCD
+// configureVault sets the vault client used to resolve the vault://
+// secret references of the config
+func configureVault(c *cli.Context) {
+	addr := c.String("vault-addr")
+	if addr == "" {
+		return
 	}
 
-	kv, err := libkv.NewStore(
-		backend,
-		[]string{kvHost},
-		options,
-	)
+	var tlsConfig *tls.Config
+	if caCert := c.String("vault-ca-cert"); caCert != "" {
+		cfg, err := tlsconfig.Client(tlsconfig.Options{
+			CAFile: caCert,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		tlsConfig = cfg
+	}
 
+	client, err := vault.NewClient(addr, vaultAuth(c), tlsConfig)
 	if err != nil {
-		return nil, err
+		log.Fatal(err)
 	}
 
-	return kv, nil
+	log.Debugf("resolving vault secrets: addr=%s", addr)
+	config.SetSecretStore(client)
 }
 
-func runAction(c *cli.Context) {
-	log.Infof("interlock %s", version.FullVersion())
+// discoveryStore returns the key value store of the discovery flags or nil
+// if discovery is not configured
+func discoveryStore(c *cli.Context) kvstore.Store {
+	dURL := c.String("discovery")
+	if dURL == "" {
+		return nil
+	}
 
-	var data string
+	// init kv
+	kvOpts := &kvstore.Config{
+		ConnectionTimeout: time.Second * 10,
+	}
 
-	if envCfg := os.Getenv("INTERLOCK_CONFIG"); envCfg != "" {
-		log.Debug("loading config from environment")
+	dTLSCACert := c.String("discovery-tls-ca-cert")
+	dTLSCert := c.String("discovery-tls-cert")
+	dTLSKey := c.String("discovery-tls-key")
 
-		data = envCfg
+	if dTLSCACert != "" && dTLSCert != "" && dTLSKey != "" {
+		tlsConfig, err := tlsconfig.Client(tlsconfig.Options{
+			CAFile:   dTLSCACert,
+			CertFile: dTLSCert,
+			KeyFile:  dTLSKey,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		log.Debug("configuring TLS for KV")
+		kvOpts.TLS = tlsConfig
 	}
 
-	if dURL := c.String("discovery"); dURL != "" {
-		log.Debugf("loading config from key value store: addr=%s", dURL)
+	kv, err := discovery.NewStore(dURL, kvOpts, vaultAuth(c))
+	if err != nil {
+		log.Fatal(err)
+	}
 
-		// init kv
-		kvOpts := &kvstore.Config{
-			ConnectionTimeout: time.Second * 10,
-		}
+	return kv
+}
 
-		dTLSCACert := c.String("discovery-tls-ca-cert")
-		dTLSCert := c.String("discovery-tls-cert")
-		dTLSKey := c.String("discovery-tls-key")
+// loadConfig returns the raw config from the environment, the key value
+// store (if not nil) or the config file in that order of precedence
+func loadConfig(c *cli.Context, kv kvstore.Store) string {
+	var data string
 
-		if dTLSCACert != "" && dTLSCert != "" && dTLSKey != "" {
-			tlsConfig, err := tlsconfig.Client(tlsconfig.Options{
-				CAFile:   dTLSCACert,
-				CertFile: dTLSCert,
-				KeyFile:  dTLSKey,
-			})
-			if err != nil {
-				log.Fatal(err)
-			}
+	configureVault(c)
 
-			log.Debug("configuring TLS for KV")
-			kvOpts.TLS = tlsConfig
-		}
+	if envCfg := os.Getenv("INTERLOCK_CONFIG"); envCfg != "" {
+		log.Debug("loading config from environment")
 
-		kv, err := getKVStore(dURL, kvOpts)
-		if err != nil {
-			log.Fatal(err)
-		}
+		data = envCfg
+	}
+
+	if kv != nil && data == "" {
+		log.Debugf("loading config from key value store: addr=%s", c.String("discovery"))
 
 		// get config from kv
 		exists, err := kv.Exists(kvConfigKey)
@@ -183,17 +252,57 @@ func runAction(c *cli.Context) {
 		log.Fatal("You must specify a config from a file, environment variable, or key value store")
 	}
 
-	config, err := config.ParseConfig(data)
+	return data
+}
+
+// loadOverrides returns the config overrides of the INTERLOCK_ environment
+// variables followed by the overrides of the flags
+func loadOverrides(c *cli.Context) []*config.Override {
+	overrides := config.EnvOverrides(os.Environ())
+
+	for _, s := range c.StringSlice("set") {
+		o, err := config.ParseOverride(s)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		overrides = append(overrides, o)
+	}
+
+	return overrides
+}
+
+func runAction(c *cli.Context) {
+	log.Infof("interlock %s", version.FullVersion())
+
+	kv := discoveryStore(c)
+	data := loadConfig(c, kv)
+
+	config, err := config.ParseConfig(data, loadOverrides(c)...)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	srv, err := server.NewServer(config)
+	srv, err := server.NewServer(config, kv)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	// stop on docker stop so the pending spans are exported
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	stopped := make(chan struct{})
+	go func() {
+		log.Infof("received %s; stopping", <-sig)
+		if err := srv.Stop(); err != nil {
+			log.Errorf("error stopping: %s", err)
+		}
+		close(stopped)
+	}()
+
 	if err := srv.Run(); err != nil {
 		log.Fatal(err)
 	}
+
+	<-stopped
 }
@@ -1,68 +1,145 @@
 package config
 
-// TODO: Rules allow for filtering metrics
+// Rule filters the containers monitored by the Beacon extension
 // Note: for use with the Beacon extension only
 type Rule struct {
-	Type  string
-	Regex string
+	Type    string `validate:"oneof=label name image compose_project compose_service" help:"label, name, image, compose_project, compose_service"`
+	Regex   string `validate:"regex" help:"regex matched against the value"`
+	Key     string `validate:"regex" help:"label rules: regex matched against the label key"`
+	Exclude bool   `help:"exclude matching containers instead of including them"`
 }
 
-// ExtensionConfig has all options for all load balancer extensions
-// the extension itself will use whichever options needed
+// Alert is a threshold rule evaluated by the Beacon extension
+// Note: for use with the Beacon extension only
+type Alert struct {
+	Expr       string  `help:"metric, operator and threshold (i.e. mem_usage_percent > 90)"`
+	For        string  `validate:"duration" help:"duration the condition must hold before firing"`
+	Selector   string  `help:"containers to evaluate (i.e. image=~api)"`
+	Hysteresis float64 `help:"margin the value must recover by before resolving"`
+	Webhook    string  `validate:"url" help:"notification url" secret:"value"`
+	Format     string  `validate:"oneof=generic slack alertmanager" help:"generic, slack, alertmanager"`
+}
+
+// ExtensionConfig is the configuration of an extension
+// the options of the extension are in the section for the extension
+// (i.e. [Extensions.HAProxy]); the ext tag lists the extensions supporting
+// the option, the validate tag the check made by Validate and the secret tag
+// the options which can be set to a secret reference (see ResolveSecrets)
 type ExtensionConfig struct {
-	Name                          string           // extension name
-	ConfigPath                    string           // config file path
-	ConfigBasePath                string           `toml:"-"` // internal
-	PidPath                       string           // haproxy, nginx
-	TemplatePath                  string           // template file path
-	BackendOverrideAddress        string           // haproxy, nginx
-	ConnectTimeout                int              // haproxy
-	ServerTimeout                 int              // haproxy
-	ClientTimeout                 int              // haproxy
-	MaxConn                       int              // haproxy, nginx
-	Port                          int              // haproxy, nginx
-	SyslogAddr                    string           // haproxy
-	AdminUser                     string           // haproxy
-	AdminPass                     string           // haproxy
-	SSLCertPath                   string           // haproxy, nginx
-	SSLCert                       string           // haproxy
-	SSLPort                       int              // haproxy, nginx
-	SSLOpts                       string           // haproxy
-	SSLDefaultDHParam             int              // haproxy
-	SSLServerVerify               string           // haproxy
-	DHParam                       bool             // nginx
-	DHParamPath                   string           // nginx
-	NginxPlusEnabled              bool             // nginx
-	User                          string           // nginx
-	WorkerProcesses               int              // nginx
-	RLimitNoFile                  int              // nginx
-	ProxyConnectTimeout           int              // nginx
-	ProxySendTimeout              int              // nginx
-	ProxyReadTimeout              int              // nginx
-	SendTimeout                   int              // nginx
-	SSLCiphers                    string           // nginx
-	SSLProtocols                  string           // nginx
-	StatsInterval                 string           // beacon
-	StatsBackendType              string           // beacon (influxdb, prometheus)
-	StatsPrometheusPushGatewayURL string           // beacon (prometheus)
-	StatsInfluxDBAddress          string           // beacon (influxdb)
-	StatsInfluxDBUser             string           // beacon (influxdb user)
-	StatsInfluxDBPassword         string           // beacon (influxdb password)
-	StatsInfluxDBDatabase         string           // beacon (influxdb)
-	StatsInfluxDBPrecision        string           // beacon (influxdb)
-	Rules                         map[string]*Rule // beacon FIXME: this isn't loaded properly from toml; we set it as a hack now
+	Name           string         `help:"extension name (haproxy, nginx, beacon)"`
+	ConfigPath     string         `help:"config file path"`
+	ConfigBasePath string         `toml:"-"` // internal
+	HAProxy        *HAProxyConfig `ext:"haproxy"`
+	Nginx          *NginxConfig   `ext:"nginx"`
+	Beacon         *BeaconConfig  `ext:"beacon"`
+
+	// secret references by option
+	secrets map[string]string
+}
+
+// Proxy returns the options shared by the load balancer extensions or nil
+// if the extension is not a load balancer
+func (c *ExtensionConfig) Proxy() *ProxyConfig {
+	switch {
+	case c.Name == "haproxy" && c.HAProxy != nil:
+		return &c.HAProxy.ProxyConfig
+	case c.Name == "nginx" && c.Nginx != nil:
+		return &c.Nginx.ProxyConfig
+	}
+
+	return nil
+}
+
+// ProxyConfig has the options shared by the load balancer extensions
+type ProxyConfig struct {
+	PidPath                string `help:"proxy pid file path"`
+	TemplatePath           string `help:"proxy config template path (empty for the built in template)"`
+	BackendOverrideAddress string `help:"address used for the upstreams instead of the container address"`
+	MaxConn                int    `help:"max connections"`
+	Port                   int    `help:"proxy port"`
+	SSLCertPath            string `help:"ssl certificate path"`
+	SSLPort                int    `help:"proxy ssl port"`
+	HSTSMaxAge             *int   `help:"max age of the Strict-Transport-Security header (0 to revoke the policy)"`
+	CacheZoneSize          string `help:"size of the response cache zone"`
+	GzipTypes              string `help:"content types compressed when gzip is enabled"`
+	ProxyStatsInterval     string `validate:"duration" help:"interval of the proxy traffic stats (empty to disable)"`
+	PublishConfig          bool   `help:"publish the proxy config to the discovery key value store for interlock agents"`
+	PublishSSLFiles        bool   `help:"also publish the ssl certificates and keys of the config (stored in plaintext in the key value store)"`
+	LabelPrefix            string `validate:"labelprefix" help:"prefix of the container labels (i.e. public.interlock.; empty for interlock.)"`
+	LabelSelector          string `validate:"selector" help:"label selector of the containers (i.e. env=prod,tier!=internal)"`
+	ImageLabels            bool   `help:"also read the labels of the container images; the container labels take precedence"`
+	ConflictPolicy         string `validate:"oneof=first oldest majority strict" help:"host settings used when the containers of a host disagree (first, oldest, majority, strict)"`
+}
+
+// HAProxyConfig has the options of the haproxy extension
+type HAProxyConfig struct {
+	ProxyConfig
+	ConnectTimeout    int    `help:"connect timeout in milliseconds"`
+	ServerTimeout     int    `help:"server timeout in milliseconds"`
+	ClientTimeout     int    `help:"client timeout in milliseconds"`
+	SyslogAddr        string `help:"syslog address for the proxy logs"`
+	AdminUser         string `help:"stats admin user" secret:"value"`
+	AdminPass         string `help:"stats admin password" secret:"value"`
+	SSLCert           string `help:"ssl certificate name in the SSLCertPath"`
+	SSLOpts           string `help:"ssl bind options"`
+	SSLDefaultDHParam int    `help:"max size of the Diffie-Hellman parameters"`
+	SSLServerVerify   string `help:"ssl server verification (required, none)"`
+}
+
+// NginxConfig has the options of the nginx extension
+type NginxConfig struct {
+	ProxyConfig
+	CacheMaxSize        string `help:"max size of the response cache"`
+	CachePath           string `help:"response cache path"`
+	DHParam             bool   `help:"use the Diffie-Hellman parameters in DHParamPath"`
+	DHParamPath         string `help:"Diffie-Hellman parameters path"`
+	NginxPlusEnabled    bool   `help:"use the nginx plus template and status api"`
+	User                string `help:"worker user"`
+	WorkerProcesses     int    `help:"worker processes"`
+	RLimitNoFile        int    `help:"max open files of the workers"`
+	ProxyConnectTimeout int    `help:"upstream connect timeout in seconds"`
+	ProxySendTimeout    int    `help:"upstream send timeout in seconds"`
+	ProxyReadTimeout    int    `help:"upstream read timeout in seconds"`
+	SendTimeout         int    `help:"client send timeout in seconds"`
+	SSLCiphers          string `help:"ssl ciphers"`
+	SSLProtocols        string `help:"ssl protocols"`
+}
+
+// BeaconConfig has the options of the beacon extension
+type BeaconConfig struct {
+	StatsInterval                 string            `validate:"duration" help:"interval of the container stats"`
+	StatsWorkers                  int               `help:"workers collecting the container stats"`
+	StatsBackendType              string            `help:"stats backends (influxdb, prometheus, statsd, graphite, opentsdb, otlp; comma separated)"`
+	StatsMetricPrefix             string            `help:"metric prefix (statsd, graphite, opentsdb, otlp)"`
+	StatsStatsDAddress            string            `validate:"hostport" help:"statsd address"`
+	StatsDogStatsD                bool              `help:"use the dogstatsd tag format"`
+	StatsGraphiteAddress          string            `validate:"hostport" help:"graphite address"`
+	StatsOpenTSDBAddress          string            `validate:"hostport" help:"opentsdb address"`
+	StatsOTLPEndpoint             string            `validate:"url" help:"otlp/http metrics endpoint"`
+	StatsPrometheusPushGatewayURL string            `validate:"url" help:"prometheus push gateway url"`
+	StatsInfluxDBAddress          string            `validate:"url" help:"influxdb address"`
+	StatsInfluxDBUser             string            `help:"influxdb user" secret:"value"`
+	StatsInfluxDBPassword         string            `help:"influxdb password" secret:"value"`
+	StatsInfluxDBDatabase         string            `help:"influxdb database"`
+	StatsInfluxDBPrecision        string            `help:"influxdb precision"`
+	Rules                         map[string]*Rule  `help:"containers monitored"`
+	Alerts                        map[string]*Alert `help:"threshold alerts"`
 }
 
 // Config is the top level configuration
 type Config struct {
-	ListenAddr    string
-	DockerURL     string
-	TLSCACert     string
-	TLSCert       string
-	TLSKey        string
-	AllowInsecure bool
-	EnableMetrics bool
-	PollInterval  string
-	Extensions    []*ExtensionConfig
-	Rules         map[string]*Rule // beacon TODO: move to ExtensionConfig
+	ListenAddr      string             `validate:"hostport" help:"address of the metrics api"`
+	DockerURL       string             `validate:"url" help:"docker url"`
+	TLSCACert       string             `help:"docker tls ca certificate path" secret:"path"`
+	TLSCert         string             `help:"docker tls certificate path" secret:"path"`
+	TLSKey          string             `help:"docker tls key path" secret:"path"`
+	AllowInsecure   bool               `help:"skip the docker tls verification"`
+	EnableMetrics   bool               `help:"expose the prometheus metrics on /metrics"`
+	PollInterval    string             `validate:"duration" help:"poll docker at the interval instead of using the event stream (empty to use the event stream)"`
+	TracingEndpoint string             `validate:"url" help:"OTLP/HTTP traces endpoint (i.e. http://collector:4318/v1/traces)"`
+	Extensions      []*ExtensionConfig `help:"extensions"`
+	Rules           map[string]*Rule   `deprecated:"move the rules to the beacon extension (Extensions.Beacon.Rules)"`
+
+	// secret references by option
+	secrets map[string]string
 }
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	FormatTOML = "toml"
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+// DetectFormat returns the format of the raw config.  JSON configs are
+// objects; configs which are not valid TOML but are a YAML mapping are YAML.
+func DetectFormat(data string) string {
+	if strings.HasPrefix(strings.TrimSpace(data), "{") {
+		return FormatJSON
+	}
+
+	var raw map[string]interface{}
+	if _, err := toml.Decode(data, &raw); err == nil {
+		return FormatTOML
+	}
+
+	var m map[string]interface{}
+	if err := yaml.Unmarshal([]byte(data), &m); err == nil && m != nil {
+		return FormatYAML
+	}
+
+	return FormatTOML
+}
+
+// toTOML converts the raw YAML or JSON config to TOML; TOML configs are
+// returned as is
+func toTOML(data string) (string, string, error) {
+	format := DetectFormat(data)
+
+	var raw map[string]interface{}
+	switch format {
+	case FormatJSON:
+		d := json.NewDecoder(strings.NewReader(data))
+		d.UseNumber()
+		if err := d.Decode(&raw); err != nil {
+			return "", format, fmt.Errorf("invalid json config: %s", err)
+		}
+	case FormatYAML:
+		if err := yaml.Unmarshal([]byte(data), &raw); err != nil {
+			return "", format, fmt.Errorf("invalid yaml config: %s", err)
+		}
+	default:
+		return data, format, nil
+	}
+
+	v, err := normalize(raw)
+	if err != nil {
+		return "", format, fmt.Errorf("invalid %s config: %s", format, err)
+	}
+
+	s, err := encodeTOML(v.(map[string]interface{}))
+	if err != nil {
+		return "", format, fmt.Errorf("invalid %s config: %s", format, err)
+	}
+
+	return s, format, nil
+}
+
+// normalize converts the decoded YAML and JSON values to the values of the
+// TOML decoder (i.e. int64 for integers and []map[string]interface{} for
+// arrays of tables); null values are removed as TOML has no null
+func normalize(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		for k, e := range val {
+			if e == nil {
+				continue
+			}
+			n, err := normalize(e)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = n
+		}
+		return m, nil
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for k, e := range val {
+			m[fmt.Sprint(k)] = e
+		}
+		return normalize(m)
+	case []interface{}:
+		values := []interface{}{}
+		tables := []map[string]interface{}{}
+		for _, e := range val {
+			n, err := normalize(e)
+			if err != nil {
+				return nil, err
+			}
+			if t, ok := n.(map[string]interface{}); ok {
+				tables = append(tables, t)
+			}
+			values = append(values, n)
+		}
+		if len(val) > 0 && len(tables) == len(val) {
+			return tables, nil
+		}
+		return values, nil
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i, nil
+		}
+		return val.Float64()
+	case int:
+		return int64(val), nil
+	case uint64:
+		return int64(val), nil
+	case string, bool, int64, float64:
+		return val, nil
+	}
+
+	return nil, fmt.Errorf("unsupported value %v", v)
+}
+
+func encodeTOML(raw map[string]interface{}) (string, error) {
+	buf := bytes.NewBuffer(nil)
+	if err := toml.NewEncoder(buf).Encode(raw); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"reflect"
+
+	"github.com/BurntSushi/toml"
+)
+
+// legacyExtensionConfig has the flat extension options used before the
+// per extension sections.  They are still read and moved to the section of
+// the extension by ParseConfig.
+type legacyExtensionConfig struct {
+	PidPath                       string            `ext:"haproxy,nginx"`
+	TemplatePath                  string            `ext:"haproxy,nginx"`
+	BackendOverrideAddress        string            `ext:"haproxy,nginx"`
+	ConnectTimeout                int               `ext:"haproxy"`
+	ServerTimeout                 int               `ext:"haproxy"`
+	ClientTimeout                 int               `ext:"haproxy"`
+	MaxConn                       int               `ext:"haproxy,nginx"`
+	Port                          int               `ext:"haproxy,nginx"`
+	SyslogAddr                    string            `ext:"haproxy"`
+	AdminUser                     string            `ext:"haproxy"`
+	AdminPass                     string            `ext:"haproxy"`
+	SSLCertPath                   string            `ext:"haproxy,nginx"`
+	SSLCert                       string            `ext:"haproxy"`
+	SSLPort                       int               `ext:"haproxy,nginx"`
+	HSTSMaxAge                    *int              `ext:"haproxy,nginx"`
+	CacheZoneSize                 string            `ext:"haproxy,nginx"`
+	CacheMaxSize                  string            `ext:"nginx"`
+	CachePath                     string            `ext:"nginx"`
+	GzipTypes                     string            `ext:"haproxy,nginx"`
+	ProxyStatsInterval            string            `ext:"haproxy,nginx" validate:"duration"`
+	SSLOpts                       string            `ext:"haproxy"`
+	SSLDefaultDHParam             int               `ext:"haproxy"`
+	SSLServerVerify               string            `ext:"haproxy"`
+	DHParam                       bool              `ext:"nginx"`
+	DHParamPath                   string            `ext:"nginx"`
+	NginxPlusEnabled              bool              `ext:"nginx"`
+	User                          string            `ext:"nginx"`
+	WorkerProcesses               int               `ext:"nginx"`
+	RLimitNoFile                  int               `ext:"nginx"`
+	ProxyConnectTimeout           int               `ext:"nginx"`
+	ProxySendTimeout              int               `ext:"nginx"`
+	ProxyReadTimeout              int               `ext:"nginx"`
+	SendTimeout                   int               `ext:"nginx"`
+	SSLCiphers                    string            `ext:"nginx"`
+	SSLProtocols                  string            `ext:"nginx"`
+	StatsInterval                 string            `ext:"beacon" validate:"duration"`
+	StatsWorkers                  int               `ext:"beacon"`
+	StatsBackendType              string            `ext:"beacon"`
+	StatsMetricPrefix             string            `ext:"beacon"`
+	StatsStatsDAddress            string            `ext:"beacon" validate:"hostport"`
+	StatsDogStatsD                bool              `ext:"beacon"`
+	StatsGraphiteAddress          string            `ext:"beacon" validate:"hostport"`
+	StatsOpenTSDBAddress          string            `ext:"beacon" validate:"hostport"`
+	StatsOTLPEndpoint             string            `ext:"beacon" validate:"url"`
+	StatsPrometheusPushGatewayURL string            `ext:"beacon" validate:"url"`
+	StatsInfluxDBAddress          string            `ext:"beacon" validate:"url"`
+	StatsInfluxDBUser             string            `ext:"beacon"`
+	StatsInfluxDBPassword         string            `ext:"beacon"`
+	StatsInfluxDBDatabase         string            `ext:"beacon"`
+	StatsInfluxDBPrecision        string            `ext:"beacon"`
+	Rules                         map[string]*Rule  `ext:"beacon"`
+	Alerts                        map[string]*Alert `ext:"beacon"`
+}
+
+// sectionName returns the section of the extension (i.e. HAProxy)
+func sectionName(extension string) string {
+	switch extension {
+	case "haproxy":
+		return "HAProxy"
+	case "nginx":
+		return "Nginx"
+	case "beacon":
+		return "Beacon"
+	}
+
+	return ""
+}
+
+// migrateLegacyConfig moves the flat options set in the raw config to the
+// section of each extension.  Options set in the section take precedence.
+func migrateLegacyConfig(data string, cfg *Config) error {
+	var raw struct {
+		Extensions []map[string]interface{}
+	}
+	if _, err := toml.Decode(data, &raw); err != nil {
+		return err
+	}
+
+	var legacy struct {
+		Extensions []*legacyExtensionConfig
+	}
+	if _, err := toml.Decode(data, &legacy); err != nil {
+		return err
+	}
+
+	for i, ext := range cfg.Extensions {
+		if i >= len(raw.Extensions) || i >= len(legacy.Extensions) {
+			break
+		}
+
+		section := sectionName(ext.Name)
+		if section == "" {
+			continue
+		}
+
+		src := reflect.ValueOf(legacy.Extensions[i]).Elem()
+		dst := reflect.ValueOf(ext).Elem().FieldByName(section)
+
+		for k := range raw.Extensions[i] {
+			f, ok := src.Type().FieldByName(k)
+			if !ok {
+				continue
+			}
+
+			if dst.IsNil() {
+				dst.Set(reflect.New(dst.Type().Elem()))
+			}
+
+			d := dst.Elem().FieldByName(f.Name)
+			if !d.IsValid() {
+				// not supported by the extension; reported by Validate
+				continue
+			}
+
+			if isZero(d) {
+				d.Set(src.FieldByIndex(f.Index))
+			}
+		}
+	}
+
+	return nil
+}
+
+func isZero(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
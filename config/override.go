@@ -0,0 +1,240 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	envPrefix    = "INTERLOCK_"
+	envConfigVar = "INTERLOCK_CONFIG"
+)
+
+// Override sets a single option on top of the config (i.e. from an
+// environment variable or a flag)
+type Override struct {
+	// Source is where the override comes from (i.e. INTERLOCK_LISTENADDR)
+	Source string
+	// Key is the path of the option (i.e. Extensions, 0, SSLPort); names
+	// are matched case insensitively
+	Key   []string
+	Value string
+	// Lenient overrides of unknown options are ignored with a warning
+	// instead of failing (i.e. unrelated INTERLOCK_ environment variables)
+	Lenient bool
+}
+
+// unknownOptionError is returned when the key of an override is not an
+// option of the config
+type unknownOptionError string
+
+func (e unknownOptionError) Error() string {
+	return "unknown option " + string(e)
+}
+
+// EnvOverrides returns the overrides of the INTERLOCK_ environment
+// variables (i.e. INTERLOCK_EXTENSIONS_0_SSLPORT=8443) sorted by name.
+// INTERLOCK_CONFIG is the config itself and is not an override.  The
+// overrides are lenient as other variables may use the prefix.
+func EnvOverrides(environ []string) []*Override {
+	overrides := []*Override{}
+	for _, e := range environ {
+		parts := strings.SplitN(e, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], envPrefix) || parts[0] == envConfigVar {
+			continue
+		}
+
+		overrides = append(overrides, &Override{
+			Source:  parts[0],
+			Key:     strings.Split(strings.TrimPrefix(parts[0], envPrefix), "_"),
+			Value:   parts[1],
+			Lenient: true,
+		})
+	}
+
+	sort.Sort(overridesBySource(overrides))
+
+	return overrides
+}
+
+// ParseOverride returns the override of an option set as key=value where
+// the key is dot separated (i.e. Extensions.0.SSLPort=8443)
+func ParseOverride(s string) (*Override, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return nil, fmt.Errorf("invalid override %q; expected key=value", s)
+	}
+
+	return &Override{
+		Source: parts[0],
+		Key:    strings.Split(parts[0], "."),
+		Value:  parts[1],
+	}, nil
+}
+
+type overridesBySource []*Override
+
+func (o overridesBySource) Len() int           { return len(o) }
+func (o overridesBySource) Swap(i, j int)      { o[i], o[j] = o[j], o[i] }
+func (o overridesBySource) Less(i, j int) bool { return o[i].Source < o[j].Source }
+
+// apply sets the override in the raw config and returns the key and field
+// of the option along with the value set
+func (o *Override) apply(raw map[string]interface{}) (toml.Key, reflect.StructField, interface{}, error) {
+	var field reflect.StructField
+	key := toml.Key{}
+	cur := raw
+	t := reflect.TypeOf(Config{})
+
+	for i := 0; i < len(o.Key); i++ {
+		p := o.Key[i]
+		last := i == len(o.Key)-1
+
+		f, _, ok := structField(t, p)
+		if !ok && t == reflect.TypeOf(ExtensionConfig{}) {
+			// flat options (i.e. INTERLOCK_EXTENSIONS_0_SSLPORT) are set in
+			// the section of the extension
+			name, _ := cur["Name"].(string)
+			if section := sectionName(strings.ToLower(name)); section != "" {
+				sf, _ := t.FieldByName(section)
+				if f, _, ok = structField(sf.Type.Elem(), p); ok {
+					key = append(key, section)
+					cur = childTable(cur, section)
+				}
+			}
+
+			// options of the other extensions are not unknown
+			for _, other := range extensionNames {
+				sf, _ := t.FieldByName(sectionName(other))
+				if of, _, found := structField(sf.Type.Elem(), p); !ok && found {
+					return nil, field, nil, fmt.Errorf("%s is not supported by the %s extension", of.Name, strings.ToLower(name))
+				}
+			}
+		}
+		if !ok {
+			return nil, field, nil, unknownOptionError(strings.Join(o.Key[:i+1], "."))
+		}
+
+		if supported := f.Tag.Get("ext"); supported != "" && t == reflect.TypeOf(ExtensionConfig{}) {
+			name, _ := cur["Name"].(string)
+			if name = strings.ToLower(name); !contains(strings.Split(supported, ","), name) {
+				return nil, field, nil, fmt.Errorf("%s is not supported by the %s extension", f.Name, name)
+			}
+		}
+
+		field = f
+		key = append(key, f.Name)
+		t = f.Type
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		switch t.Kind() {
+		case reflect.Struct:
+			if last {
+				return nil, field, nil, fmt.Errorf("%s is a table", key)
+			}
+			cur = childTable(cur, f.Name)
+			continue
+		case reflect.Slice:
+			// array of tables (i.e. Extensions)
+			if last {
+				return nil, field, nil, fmt.Errorf("%s is an array of tables", key)
+			}
+			i++
+			idx, err := strconv.Atoi(o.Key[i])
+			tables, _ := cur[f.Name].([]map[string]interface{})
+			if err != nil || idx < 0 || idx >= len(tables) {
+				return nil, field, nil, fmt.Errorf("%s: no table %s", key, o.Key[i])
+			}
+			cur = tables[idx]
+			t = elemType(t)
+			if i == len(o.Key)-1 {
+				return nil, field, nil, fmt.Errorf("%s is a table", key)
+			}
+			continue
+		case reflect.Map:
+			// tables by name (i.e. Rules); existing names are matched case
+			// insensitively
+			if last || i+1 == len(o.Key)-1 {
+				return nil, field, nil, fmt.Errorf("%s is a table", key)
+			}
+			i++
+			m := childTable(cur, f.Name)
+			name := o.Key[i]
+			for k := range m {
+				if strings.EqualFold(k, name) {
+					name = k
+				}
+			}
+			key = append(key, name)
+			cur = childTable(m, name)
+			t = elemType(t.Elem())
+			continue
+		}
+
+		if !last {
+			return nil, field, nil, unknownOptionError(strings.Join(o.Key[:i+2], "."))
+		}
+
+		val, err := parseValue(t, o.Value)
+		if err != nil {
+			return key, field, nil, err
+		}
+		for k := range cur {
+			if strings.EqualFold(k, f.Name) {
+				delete(cur, k)
+			}
+		}
+		cur[f.Name] = val
+
+		return key, field, val, nil
+	}
+
+	return nil, field, nil, fmt.Errorf("missing option")
+}
+
+// childTable returns the table of the key creating it if needed
+func childTable(m map[string]interface{}, key string) map[string]interface{} {
+	if t, ok := m[key].(map[string]interface{}); ok {
+		return t
+	}
+
+	// the key may be set with another case in the config
+	for k, v := range m {
+		if t, ok := v.(map[string]interface{}); ok && strings.EqualFold(k, key) {
+			return t
+		}
+	}
+
+	t := map[string]interface{}{}
+	m[key] = t
+	return t
+}
+
+// parseValue converts the value to the TOML value of the type
+func parseValue(t reflect.Type, s string) (interface{}, error) {
+	switch t.Kind() {
+	case reflect.String:
+		return s, nil
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b, nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, nil
+		}
+	}
+
+	return nil, fmt.Errorf("expected %s; found %q", typeName(t), s)
+}
@@ -0,0 +1,261 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+const (
+	secretScheme = "secret://"
+	fileScheme   = "file://"
+	envScheme    = "env://"
+	vaultScheme  = "vault://"
+)
+
+var (
+	// SecretsPath is the directory of the secret:// references
+	SecretsPath = "/run/secrets"
+
+	secretStore SecretStore
+)
+
+// SecretStore resolves the vault:// references (i.e.
+// vault://secret/interlock/haproxy#admin_pass)
+type SecretStore interface {
+	// Secret returns the field of the secret at the path
+	Secret(path, field string) (string, error)
+}
+
+// SetSecretStore sets the store of the vault:// references
+func SetSecretStore(s SecretStore) {
+	secretStore = s
+}
+
+// ResolveSecrets resolves the secret references of the options tagged as
+// secrets (i.e. AdminPass = "secret://haproxy_admin") and of the
+// extensions.  The references are kept so the secrets can be read again
+// (see SecretValues).
+func (c *Config) ResolveSecrets() error {
+	if c.secrets == nil {
+		c.secrets = map[string]string{}
+	}
+
+	if err := resolveSecrets(reflect.ValueOf(c).Elem(), "", c.secrets); err != nil {
+		return err
+	}
+
+	for _, ext := range c.Extensions {
+		if err := ext.ResolveSecrets(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ResolveSecrets resolves the secret references of the extension options
+func (c *ExtensionConfig) ResolveSecrets() error {
+	if c.secrets == nil {
+		c.secrets = map[string]string{}
+	}
+
+	return resolveSecrets(reflect.ValueOf(c).Elem(), "Extensions.", c.secrets)
+}
+
+// SecretValues reads the secrets of the extension options again without
+// changing the options and returns the values by option.  The options
+// which cannot be resolved are left out and reported by the error so they
+// keep their last value when the values are set with SetSecretValues.
+func (c *ExtensionConfig) SecretValues() (map[string]string, error) {
+	values := map[string]string{}
+	errs := []string{}
+
+	walkSecrets(reflect.ValueOf(c).Elem(), "Extensions.", c.secrets, func(key, ref, kind string, fv reflect.Value) {
+		s, err := resolveSecret(ref, kind)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: unable to resolve %s: %s", key, ref, err))
+			return
+		}
+		values[key] = s
+	})
+
+	if len(errs) > 0 {
+		return values, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return values, nil
+}
+
+// SetSecretValues sets the options to the values returned by SecretValues
+func (c *ExtensionConfig) SetSecretValues(values map[string]string) {
+	walkSecrets(reflect.ValueOf(c).Elem(), "Extensions.", c.secrets, func(key, ref, kind string, fv reflect.Value) {
+		if s, ok := values[key]; ok {
+			fv.SetString(s)
+		}
+	})
+}
+
+// resolveSecrets sets the options tagged as secrets to the resolved value
+// of their reference; refs has the references by option and is updated
+// with the references found
+func resolveSecrets(v reflect.Value, prefix string, refs map[string]string) error {
+	var resolveErr error
+
+	walkSecrets(v, prefix, refs, func(key, ref, kind string, fv reflect.Value) {
+		if resolveErr != nil {
+			return
+		}
+		refs[key] = ref
+
+		s, err := resolveSecret(ref, kind)
+		if err != nil {
+			resolveErr = fmt.Errorf("%s: unable to resolve %s: %s", key, ref, err)
+			return
+		}
+
+		fv.SetString(s)
+	})
+
+	return resolveErr
+}
+
+// walkSecrets calls fn for the options tagged as secrets set to a
+// reference with the option key, the reference and the kind of secret;
+// the references already resolved are read from refs
+func walkSecrets(v reflect.Value, prefix string, refs map[string]string, fn func(key, ref, kind string, fv reflect.Value)) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			walkSecrets(v.Elem(), prefix, refs, fn)
+		}
+		return
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			walkSecrets(v.MapIndex(k), fmt.Sprintf("%s%v.", prefix, k), refs, fn)
+		}
+		return
+	case reflect.Struct:
+	default:
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fv := v.Field(i)
+
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if f.Anonymous {
+			walkSecrets(fv, prefix, refs, fn)
+			continue
+		}
+
+		kind := f.Tag.Get("secret")
+		if kind == "" || fv.Kind() != reflect.String {
+			walkSecrets(fv, prefix+f.Name+".", refs, fn)
+			continue
+		}
+
+		key := prefix + f.Name
+		ref, ok := refs[key]
+		if !ok {
+			ref = fv.String()
+		}
+
+		if isSecretRef(ref) {
+			fn(key, ref, kind, fv)
+		}
+	}
+}
+
+func isSecretRef(s string) bool {
+	for _, scheme := range []string{secretScheme, fileScheme, envScheme, vaultScheme} {
+		if strings.HasPrefix(s, scheme) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// secretSource returns the file, environment variable or vault path of the
+// reference along with its scheme
+func secretSource(ref string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(ref, secretScheme):
+		name := strings.TrimPrefix(ref, secretScheme)
+		if name == "" || strings.Contains(name, "/") {
+			return "", "", fmt.Errorf("invalid secret name %q", name)
+		}
+		return filepath.Join(SecretsPath, name), fileScheme, nil
+	case strings.HasPrefix(ref, fileScheme):
+		path := strings.TrimPrefix(ref, fileScheme)
+		if !filepath.IsAbs(path) {
+			return "", "", fmt.Errorf("file path %q must be absolute (i.e. file:///path)", path)
+		}
+		return path, fileScheme, nil
+	case strings.HasPrefix(ref, envScheme):
+		name := strings.TrimPrefix(ref, envScheme)
+		if name == "" {
+			return "", "", fmt.Errorf("missing environment variable name")
+		}
+		return name, envScheme, nil
+	case strings.HasPrefix(ref, vaultScheme):
+		path := strings.TrimPrefix(ref, vaultScheme)
+		parts := strings.SplitN(path, "#", 2)
+		if len(parts) != 2 || parts[1] == "" || !strings.Contains(strings.Trim(parts[0], "/"), "/") {
+			return "", "", fmt.Errorf("invalid vault reference %q (i.e. vault://secret/interlock#password)", path)
+		}
+		return path, vaultScheme, nil
+	}
+
+	return "", "", fmt.Errorf("unknown reference %q", ref)
+}
+
+// resolveSecret returns the value of the reference; for path options the
+// path of the secret file is returned instead of its content
+func resolveSecret(ref, kind string) (string, error) {
+	src, scheme, err := secretSource(ref)
+	if err != nil {
+		return "", err
+	}
+
+	switch scheme {
+	case envScheme:
+		v, ok := os.LookupEnv(src)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", src)
+		}
+		return v, nil
+	case vaultScheme:
+		if kind == "path" {
+			return "", fmt.Errorf("vault references are not supported for paths")
+		}
+		if secretStore == nil {
+			return "", fmt.Errorf("vault is not configured")
+		}
+		parts := strings.SplitN(src, "#", 2)
+		return secretStore.Secret(parts[0], parts[1])
+	}
+
+	if kind == "path" {
+		if _, err := os.Stat(src); err != nil {
+			return "", err
+		}
+		return src, nil
+	}
+
+	d, err := ioutil.ReadFile(src)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(d), "\r\n"), nil
+}
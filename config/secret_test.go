@@ -0,0 +1,225 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "interlock-secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(p string) { SecretsPath = p }(SecretsPath)
+	SecretsPath = dir
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "haproxy_admin"), []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "docker_key")
+	if err := ioutil.WriteFile(keyPath, []byte("key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	influxPath := filepath.Join(dir, "influxdb")
+	if err := ioutil.WriteFile(influxPath, []byte("influx"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("INTERLOCK_TEST_INFLUXDB_USER", "interlock")
+	defer os.Unsetenv("INTERLOCK_TEST_INFLUXDB_USER")
+
+	data := `
+TLSKey = "secret://docker_key"
+[[Extensions]]
+  Name = "haproxy"
+  [Extensions.HAProxy]
+    AdminPass = "secret://haproxy_admin"
+[[Extensions]]
+  Name = "beacon"
+  [Extensions.Beacon]
+    StatsInfluxDBUser = "env://INTERLOCK_TEST_INFLUXDB_USER"
+    StatsInfluxDBPassword = "file://` + influxPath + `"
+`
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		t.Fatalf("error parsing config: %s", err)
+	}
+
+	if cfg.TLSKey != keyPath {
+		t.Fatalf("expected tls key path %s; received %s", keyPath, cfg.TLSKey)
+	}
+
+	haproxy := cfg.Extensions[0]
+	if haproxy.HAProxy.AdminPass != "s3cret" {
+		t.Fatalf("expected resolved admin pass; received %q", haproxy.HAProxy.AdminPass)
+	}
+
+	beacon := cfg.Extensions[1].Beacon
+	if beacon.StatsInfluxDBUser != "interlock" || beacon.StatsInfluxDBPassword != "influx" {
+		t.Fatalf("unexpected influxdb credentials: %s %s", beacon.StatsInfluxDBUser, beacon.StatsInfluxDBPassword)
+	}
+
+	// rotated secrets are read again
+	if err := ioutil.WriteFile(filepath.Join(dir, "haproxy_admin"), []byte("rotated"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := haproxy.ResolveSecrets(); err != nil {
+		t.Fatal(err)
+	}
+
+	if haproxy.HAProxy.AdminPass != "rotated" {
+		t.Fatalf("expected rotated admin pass; received %q", haproxy.HAProxy.AdminPass)
+	}
+}
+
+func TestSecretValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "interlock-secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(p string) { SecretsPath = p }(SecretsPath)
+	SecretsPath = dir
+
+	for name, value := range map[string]string{"haproxy_user": "admin", "haproxy_admin": "s3cret"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(value), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data := `
+[[Extensions]]
+  Name = "haproxy"
+  [Extensions.HAProxy]
+    AdminUser = "secret://haproxy_user"
+    AdminPass = "secret://haproxy_admin"
+`
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	haproxy := cfg.Extensions[0]
+
+	// the options are not changed until the values are set
+	if err := ioutil.WriteFile(filepath.Join(dir, "haproxy_user"), []byte("rotated"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "haproxy_admin")); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := haproxy.SecretValues()
+	if err == nil || !strings.Contains(err.Error(), "Extensions.HAProxy.AdminPass") {
+		t.Fatalf("expected the missing secret to be reported; received %v", err)
+	}
+
+	if haproxy.HAProxy.AdminUser != "admin" {
+		t.Fatalf("expected the admin user to be unchanged; received %q", haproxy.HAProxy.AdminUser)
+	}
+
+	// the secrets which cannot be resolved keep their last value
+	haproxy.SetSecretValues(values)
+	if haproxy.HAProxy.AdminUser != "rotated" || haproxy.HAProxy.AdminPass != "s3cret" {
+		t.Fatalf("unexpected admin credentials: %q %q", haproxy.HAProxy.AdminUser, haproxy.HAProxy.AdminPass)
+	}
+}
+
+func TestParseConfigMissingSecret(t *testing.T) {
+	defer func(p string) { SecretsPath = p }(SecretsPath)
+	SecretsPath = "/nonexistent"
+
+	data := `
+[[Extensions]]
+  Name = "haproxy"
+  [Extensions.HAProxy]
+    AdminPass = "secret://haproxy_admin"
+`
+	_, err := ParseConfig(data)
+	if err == nil {
+		t.Fatal("expected error for missing secret")
+	}
+
+	if !strings.Contains(err.Error(), "Extensions.HAProxy.AdminPass") {
+		t.Fatalf("expected the option in the error; received %s", err)
+	}
+}
+
+func TestValidateSecretReferences(t *testing.T) {
+	data := `
+[[Extensions]]
+  Name = "haproxy"
+  [Extensions.HAProxy]
+    AdminUser = "file://relative/path"
+    AdminPass = "secret://"
+    SyslogAddr = "env://NOT_A_SECRET"
+`
+	result, err := Validate(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Errors) != 2 {
+		t.Fatalf("expected 2 errors; received %v", result.Errors)
+	}
+
+	for _, p := range result.Errors {
+		if !strings.Contains(p.Message, "invalid secret reference") {
+			t.Fatalf("unexpected problem: %s", p)
+		}
+	}
+}
+
+type testSecretStore map[string]string
+
+func (s testSecretStore) Secret(path, field string) (string, error) {
+	v, ok := s[path+"#"+field]
+	if !ok {
+		return "", fmt.Errorf("secret not found")
+	}
+
+	return v, nil
+}
+
+func TestParseConfigVaultSecrets(t *testing.T) {
+	defer SetSecretStore(nil)
+
+	data := `
+[[Extensions]]
+  Name = "haproxy"
+  [Extensions.HAProxy]
+    AdminPass = "vault://secret/interlock/haproxy#admin_pass"
+`
+	if _, err := ParseConfig(data); err == nil || !strings.Contains(err.Error(), "vault is not configured") {
+		t.Fatalf("expected vault is not configured error; received %v", err)
+	}
+
+	SetSecretStore(testSecretStore{
+		"secret/interlock/haproxy#admin_pass": "s3cret",
+	})
+
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		t.Fatalf("error parsing config: %s", err)
+	}
+
+	if p := cfg.Extensions[0].HAProxy.AdminPass; p != "s3cret" {
+		t.Fatalf("expected resolved admin pass; received %q", p)
+	}
+
+	result, err := Validate(`TLSKey = "vault://secret"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Errors) != 1 {
+		t.Fatalf("expected invalid vault reference; received %v", result.Errors)
+	}
+}
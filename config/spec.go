@@ -0,0 +1,125 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Spec returns an example config for the extension with the default values
+// and a comment for each option
+func Spec(extension string) (string, error) {
+	ext := &ExtensionConfig{
+		Name: extension,
+	}
+
+	switch extension {
+	case "haproxy":
+		ext.ConfigPath = "/usr/local/etc/haproxy/haproxy.cfg"
+		ext.HAProxy = &HAProxyConfig{}
+		ext.HAProxy.PidPath = "/var/run/haproxy.pid"
+	case "nginx":
+		ext.ConfigPath = "/etc/nginx/nginx.conf"
+		ext.Nginx = &NginxConfig{}
+		ext.Nginx.PidPath = "/var/run/nginx.pid"
+	case "beacon":
+		ext.Beacon = &BeaconConfig{}
+	default:
+		return "", fmt.Errorf("unknown extension %q; expected one of %s", extension, strings.Join(extensionNames, ", "))
+	}
+
+	if err := SetConfigDefaults(ext); err != nil {
+		return "", err
+	}
+
+	cfg := &Config{
+		ListenAddr:    ":8080",
+		DockerURL:     "unix:///var/run/docker.sock",
+		EnableMetrics: true,
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "# interlock config for the %s extension\n", extension)
+	writeSpecOptions(buf, "", reflect.ValueOf(cfg).Elem())
+
+	fmt.Fprintf(buf, "\n[[Extensions]]\n")
+	writeSpecOptions(buf, "  ", reflect.ValueOf(ext).Elem())
+
+	section := sectionName(extension)
+	fmt.Fprintf(buf, "\n  [Extensions.%s]\n", section)
+	writeSpecOptions(buf, "    ", reflect.ValueOf(ext).Elem().FieldByName(section).Elem())
+
+	// the tables of the section (i.e. beacon rules) are commented examples
+	t := reflect.ValueOf(ext).Elem().FieldByName(section).Elem().Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Type.Kind() != reflect.Map {
+			continue
+		}
+
+		fmt.Fprintf(buf, "\n  # %s\n", f.Tag.Get("help"))
+		fmt.Fprintf(buf, "  # [Extensions.%s.%s.example]\n", section, f.Name)
+		writeSpecOptions(buf, "  #   ", reflect.New(f.Type.Elem().Elem()).Elem())
+	}
+
+	return buf.String(), nil
+}
+
+// writeSpecOptions writes the value options with their help preceded by a
+// blank line; tables, deprecated and internal options are skipped
+func writeSpecOptions(buf *bytes.Buffer, indent string, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		if f.Anonymous {
+			writeSpecOptions(buf, indent, v.Field(i))
+			continue
+		}
+
+		if f.Tag.Get("toml") == "-" || f.Tag.Get("deprecated") != "" {
+			continue
+		}
+
+		value, ok := specValue(v.Field(i))
+		if !ok {
+			continue
+		}
+
+		if help := f.Tag.Get("help"); help != "" {
+			fmt.Fprintf(buf, "%s# %s\n", indent, help)
+		}
+		if f.Tag.Get("secret") != "" {
+			fmt.Fprintf(buf, "%s# (secret://name, file:///path or env://VAR references are resolved)\n", indent)
+		}
+		fmt.Fprintf(buf, "%s%s = %s\n", indent, f.Name, value)
+	}
+}
+
+// specValue returns the TOML value of the option or false if it is a table
+func specValue(v reflect.Value) (string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return strconv.Quote(v.String()), true
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Float32, reflect.Float64:
+		s := strconv.FormatFloat(v.Float(), 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s, true
+	case reflect.Ptr:
+		// optional values (i.e. HSTSMaxAge) are set by the defaults
+		if v.IsNil() || v.Elem().Kind() == reflect.Struct {
+			return "", false
+		}
+		return specValue(v.Elem())
+	}
+
+	return "", false
+}
@@ -5,29 +5,92 @@ import (
 	log "github.com/Sirupsen/logrus"
 )
 
-// ParseConfig returns a Config object from a raw string config TOML
-func ParseConfig(data string) (*Config, error) {
+// ParseConfig returns a Config object from a raw string config in TOML,
+// YAML or JSON with the overrides applied in order
+// The config is validated first; all of the problems found are returned
+// as a ValidationError.  The secret references are resolved last.
+func ParseConfig(data string, overrides ...*Override) (*Config, error) {
+	v, err := validateConfig(data, overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	result := v.result
+
+	for _, w := range result.Warnings {
+		log.Warn(w)
+	}
+
+	if err := result.Err(); err != nil {
+		return nil, err
+	}
+
+	data = v.data
+	if len(overrides) > 0 {
+		if data, err = encodeTOML(v.raw); err != nil {
+			return nil, err
+		}
+	}
+
 	var cfg Config
 	if _, err := toml.Decode(data, &cfg); err != nil {
 		return nil, err
 	}
 
+	// move the flat extension options to the extension sections
+	if err := migrateLegacyConfig(data, &cfg); err != nil {
+		return nil, err
+	}
+
 	for _, ext := range cfg.Extensions {
 		// setup defaults for missing config entries
 		if err := SetConfigDefaults(ext); err != nil {
 			return nil, err
 		}
 
-		// FIXME: toml isn't being parse right so we hack the rules in like so
-		ext.Rules = cfg.Rules
+		// fallback to the deprecated top level rules
+		if ext.Beacon != nil && len(ext.Beacon.Rules) == 0 && len(cfg.Rules) > 0 {
+			ext.Beacon.Rules = cfg.Rules
+		}
+	}
+
+	if err := cfg.ResolveSecrets(); err != nil {
+		return nil, err
 	}
 
 	return &cfg, nil
 }
 
-// SetConfigDefaults sets default values if not present
+// SetConfigDefaults creates the section of the extension and sets default
+// values if not present
 // ExtensionConfig.Name must be set before calling this function
 func SetConfigDefaults(c *ExtensionConfig) error {
+	switch c.Name {
+	case "haproxy":
+		if c.HAProxy == nil {
+			c.HAProxy = &HAProxyConfig{}
+		}
+		SetHAProxyConfigDefaults(c.HAProxy)
+	case "nginx":
+		if c.Nginx == nil {
+			c.Nginx = &NginxConfig{}
+		}
+		SetNginxConfigDefaults(c.Nginx)
+	case "beacon":
+		if c.Beacon == nil {
+			c.Beacon = &BeaconConfig{}
+		}
+		SetBeaconConfigDefaults(c.Beacon)
+	default:
+		log.Debugf("unknown extension %q; not loading config defaults", c.Name)
+	}
+
+	return nil
+}
+
+// SetProxyConfigDefaults sets the defaults shared by the load balancer
+// extensions
+func SetProxyConfigDefaults(c *ProxyConfig) {
 	if c.MaxConn == 0 {
 		c.MaxConn = 1024
 	}
@@ -36,21 +99,28 @@ func SetConfigDefaults(c *ExtensionConfig) error {
 		c.Port = 80
 	}
 
-	switch c.Name {
-	case "haproxy":
-		SetHAProxyConfigDefaults(c)
-	case "nginx":
-		SetNginxConfigDefaults(c)
-	case "beacon":
-		SetBeaconConfigDefaults(c)
-	default:
-		log.Debugf("unknown extension %q; not loading config defaults", c.Name)
+	// a max age of 0 is kept to revoke the policy
+	if c.HSTSMaxAge == nil {
+		maxAge := 16000000
+		c.HSTSMaxAge = &maxAge
 	}
 
-	return nil
+	if c.CacheZoneSize == "" {
+		c.CacheZoneSize = "10m"
+	}
+
+	if c.GzipTypes == "" {
+		c.GzipTypes = "text/plain text/css text/xml application/json application/javascript application/xml image/svg+xml"
+	}
+
+	if c.ConflictPolicy == "" {
+		c.ConflictPolicy = "first"
+	}
 }
 
-func SetHAProxyConfigDefaults(c *ExtensionConfig) {
+func SetHAProxyConfigDefaults(c *HAProxyConfig) {
+	SetProxyConfigDefaults(&c.ProxyConfig)
+
 	if c.ConnectTimeout == 0 {
 		c.ConnectTimeout = 5000
 	}
@@ -80,7 +150,9 @@ func SetHAProxyConfigDefaults(c *ExtensionConfig) {
 	}
 }
 
-func SetNginxConfigDefaults(c *ExtensionConfig) {
+func SetNginxConfigDefaults(c *NginxConfig) {
+	SetProxyConfigDefaults(&c.ProxyConfig)
+
 	if c.User == "" {
 		c.User = "www-data"
 	}
@@ -116,13 +188,33 @@ func SetNginxConfigDefaults(c *ExtensionConfig) {
 	if c.SSLProtocols == "" {
 		c.SSLProtocols = "SSLv3 TLSv1 TLSv1.1 TLSv1.2"
 	}
+
+	if c.CacheMaxSize == "" {
+		c.CacheMaxSize = "1g"
+	}
+
+	if c.CachePath == "" {
+		c.CachePath = "/var/cache/nginx"
+	}
 }
 
-func SetBeaconConfigDefaults(c *ExtensionConfig) {
+func SetBeaconConfigDefaults(c *BeaconConfig) {
 	if c.StatsInterval == "" {
 		c.StatsInterval = "30s"
 	}
 
+	if c.StatsWorkers == 0 {
+		c.StatsWorkers = 10
+	}
+
+	if c.StatsBackendType == "" {
+		c.StatsBackendType = "prometheus"
+	}
+
+	if c.StatsMetricPrefix == "" {
+		c.StatsMetricPrefix = "beacon"
+	}
+
 	if c.StatsInfluxDBPrecision == "" {
 		c.StatsInfluxDBPrecision = "s"
 	}
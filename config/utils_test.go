@@ -27,14 +27,16 @@ func TestParseConfig(t *testing.T) {
 }
 
 func TestSetConfigDefaults(t *testing.T) {
-	cfg := &ExtensionConfig{
+	ext := &ExtensionConfig{
 		Name: "nginx",
 	}
 
-	if err := SetConfigDefaults(cfg); err != nil {
+	if err := SetConfigDefaults(ext); err != nil {
 		t.Fatal(err)
 	}
 
+	cfg := ext.Nginx
+
 	if cfg.MaxConn != 1024 {
 		t.Fatalf("expected default max connections of 1024; received %d", cfg.MaxConn)
 	}
@@ -45,16 +47,18 @@ func TestSetConfigDefaults(t *testing.T) {
 }
 
 func TestSetNginxConfigDefaults(t *testing.T) {
-	cfg := &ExtensionConfig{
+	ext := &ExtensionConfig{
 		Name: "nginx",
 	}
 
-	if err := SetConfigDefaults(cfg); err != nil {
+	if err := SetConfigDefaults(ext); err != nil {
 		t.Fatal(err)
 	}
 
+	cfg := ext.Nginx
+
 	if cfg.User != "www-data" {
-		t.Fatalf("expected default user of www-data; received %d", cfg.User)
+		t.Fatalf("expected default user of www-data; received %s", cfg.User)
 	}
 
 	if cfg.WorkerProcesses != 2 {
@@ -82,23 +86,25 @@ func TestSetNginxConfigDefaults(t *testing.T) {
 	}
 
 	if cfg.SSLCiphers != "HIGH:!aNULL:!MD5" {
-		t.Fatalf("expected default SSL ciphers of HIGH:!aNULL:!MD5; received %d", cfg.SSLCiphers)
+		t.Fatalf("expected default SSL ciphers of HIGH:!aNULL:!MD5; received %s", cfg.SSLCiphers)
 	}
 
 	if cfg.SSLProtocols != "SSLv3 TLSv1 TLSv1.1 TLSv1.2" {
-		t.Fatalf("expected default SSL protocols of SSLv3 TLSv1 TLSv1.1 TLSv1.2; received %d", cfg.SSLProtocols)
+		t.Fatalf("expected default SSL protocols of SSLv3 TLSv1 TLSv1.1 TLSv1.2; received %s", cfg.SSLProtocols)
 	}
 }
 
 func TestSetHAProxyConfigDefaults(t *testing.T) {
-	cfg := &ExtensionConfig{
+	ext := &ExtensionConfig{
 		Name: "haproxy",
 	}
 
-	if err := SetConfigDefaults(cfg); err != nil {
+	if err := SetConfigDefaults(ext); err != nil {
 		t.Fatal(err)
 	}
 
+	cfg := ext.HAProxy
+
 	if cfg.ConnectTimeout != 5000 {
 		t.Fatalf("expected default connect timeout of 5000; received %d", cfg.ConnectTimeout)
 	}
@@ -112,11 +118,11 @@ func TestSetHAProxyConfigDefaults(t *testing.T) {
 	}
 
 	if cfg.AdminUser != "admin" {
-		t.Fatalf("expected default admin user of admin; received %d", cfg.AdminUser)
+		t.Fatalf("expected default admin user of admin; received %s", cfg.AdminUser)
 	}
 
 	if cfg.AdminPass != "" {
-		t.Fatalf("expected default admin password of \"\"; received %d", cfg.AdminPass)
+		t.Fatalf("expected default admin password of \"\"; received %s", cfg.AdminPass)
 	}
 
 	if cfg.SSLDefaultDHParam != 1024 {
@@ -124,6 +130,283 @@ func TestSetHAProxyConfigDefaults(t *testing.T) {
 	}
 
 	if cfg.SSLServerVerify != "required" {
-		t.Fatalf("expected default SSL server verify of required; received %d", cfg.SSLServerVerify)
+		t.Fatalf("expected default SSL server verify of required; received %s", cfg.SSLServerVerify)
+	}
+}
+
+func TestParseConfigExtensionRules(t *testing.T) {
+	data := `
+[[Extensions]]
+  Name = "beacon"
+  [Extensions.Rules.web]
+    Type = "image"
+    Regex = "nginx"
+
+[[Extensions]]
+  Name = "beacon"
+  [Extensions.Rules.db]
+    Type = "label"
+    Key = "com.example.tier"
+    Regex = "db"
+    Exclude = true
+`
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		t.Fatalf("error parsing config: %s", err)
+	}
+
+	if len(cfg.Extensions) != 2 {
+		t.Fatalf("expected 2 extensions; received %d", len(cfg.Extensions))
+	}
+
+	web, ok := cfg.Extensions[0].Beacon.Rules["web"]
+	if !ok {
+		t.Fatal("expected rule web for first extension")
+	}
+
+	if web.Type != "image" || web.Regex != "nginx" {
+		t.Fatalf("unexpected rule: %+v", web)
+	}
+
+	if _, ok := cfg.Extensions[1].Beacon.Rules["web"]; ok {
+		t.Fatal("expected rules to be scoped per extension")
+	}
+
+	db, ok := cfg.Extensions[1].Beacon.Rules["db"]
+	if !ok {
+		t.Fatal("expected rule db for second extension")
+	}
+
+	if db.Key != "com.example.tier" || !db.Exclude {
+		t.Fatalf("unexpected rule: %+v", db)
+	}
+}
+
+func TestParseConfigTopLevelRules(t *testing.T) {
+	data := `
+[[Extensions]]
+  Name = "beacon"
+
+[Rules.web]
+  Type = "image"
+  Regex = "nginx"
+`
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		t.Fatalf("error parsing config: %s", err)
+	}
+
+	if _, ok := cfg.Extensions[0].Beacon.Rules["web"]; !ok {
+		t.Fatal("expected top level rules to be used for extension")
+	}
+}
+
+func TestParseConfigSections(t *testing.T) {
+	data := `
+[[Extensions]]
+  Name = "haproxy"
+  ConfigPath = "/usr/local/etc/haproxy/haproxy.cfg"
+  [Extensions.HAProxy]
+    Port = 8080
+    AdminUser = "stats"
+`
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		t.Fatalf("error parsing config: %s", err)
+	}
+
+	ext := cfg.Extensions[0]
+	if ext.HAProxy == nil {
+		t.Fatal("expected haproxy section")
+	}
+
+	if ext.HAProxy.Port != 8080 || ext.HAProxy.AdminUser != "stats" {
+		t.Fatalf("unexpected haproxy config: %+v", ext.HAProxy)
+	}
+
+	// defaults
+	if ext.HAProxy.MaxConn != 1024 || ext.HAProxy.ConnectTimeout != 5000 {
+		t.Fatalf("expected defaults to be set: %+v", ext.HAProxy)
+	}
+
+	if p := ext.Proxy(); p == nil || p.Port != 8080 {
+		t.Fatalf("expected proxy options of the haproxy section; received %+v", p)
+	}
+}
+
+func TestParseConfigLegacyOptions(t *testing.T) {
+	data := `
+[[Extensions]]
+  Name = "nginx"
+  Port = 8080
+  User = "nginx"
+  [Extensions.Nginx]
+    Port = 9090
+
+[[Extensions]]
+  Name = "beacon"
+  StatsInterval = "10s"
+`
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		t.Fatalf("error parsing config: %s", err)
+	}
+
+	nginx := cfg.Extensions[0].Nginx
+	if nginx.User != "nginx" {
+		t.Fatalf("expected flat option to be migrated; received %q", nginx.User)
+	}
+
+	if nginx.Port != 9090 {
+		t.Fatalf("expected section option to take precedence; received %d", nginx.Port)
+	}
+
+	if s := cfg.Extensions[1].Beacon.StatsInterval; s != "10s" {
+		t.Fatalf("expected flat beacon option to be migrated; received %q", s)
+	}
+}
+
+func TestParseConfigHSTSMaxAge(t *testing.T) {
+	data := `
+[[Extensions]]
+  Name = "nginx"
+  [Extensions.Nginx]
+    HSTSMaxAge = 0
+
+[[Extensions]]
+  Name = "haproxy"
+  HSTSMaxAge = 0
+
+[[Extensions]]
+  Name = "nginx"
+`
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		t.Fatalf("error parsing config: %s", err)
+	}
+
+	// a max age of 0 revokes the policy and is not replaced by the default
+	for i, expected := range []int{0, 0, 16000000} {
+		p := cfg.Extensions[i].Proxy()
+		if p.HSTSMaxAge == nil || *p.HSTSMaxAge != expected {
+			t.Fatalf("expected hsts max age %d for extension %d; received %v", expected, i, p.HSTSMaxAge)
+		}
+	}
+}
+
+func TestParseConfigYAML(t *testing.T) {
+	data := `
+ListenAddr: ":8080"
+DockerURL: unix:///var/run/docker.sock
+Extensions:
+  - Name: haproxy
+    ConfigPath: /usr/local/etc/haproxy/haproxy.cfg
+    HAProxy:
+      Port: 8080
+      AdminPass: ""
+`
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		t.Fatalf("error parsing config: %s", err)
+	}
+
+	if cfg.ListenAddr != ":8080" {
+		t.Fatalf("expected listen addr :8080; received %s", cfg.ListenAddr)
+	}
+
+	if len(cfg.Extensions) != 1 {
+		t.Fatalf("expected 1 extension; received %d", len(cfg.Extensions))
+	}
+
+	if port := cfg.Extensions[0].HAProxy.Port; port != 8080 {
+		t.Fatalf("expected port 8080; received %d", port)
+	}
+}
+
+func TestParseConfigJSON(t *testing.T) {
+	data := `{
+  "ListenAddr": ":8080",
+  "Extensions": [
+    {"Name": "nginx", "Nginx": {"Port": 8080, "DHParam": true}}
+  ]
+}`
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		t.Fatalf("error parsing config: %s", err)
+	}
+
+	nginx := cfg.Extensions[0].Nginx
+	if nginx.Port != 8080 || !nginx.DHParam {
+		t.Fatalf("unexpected nginx config: %+v", nginx)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	formats := map[string]string{
+		sampleConfig:                  FormatTOML,
+		"ListenAddr: \":8080\"\n":     FormatYAML,
+		"{\"ListenAddr\": \":8080\"}": FormatJSON,
+		"ListenAddr = ":               FormatTOML,
+	}
+
+	for data, expected := range formats {
+		if f := DetectFormat(data); f != expected {
+			t.Fatalf("expected format %s for %q; received %s", expected, data, f)
+		}
+	}
+}
+
+func TestParseConfigOverrides(t *testing.T) {
+	data := `
+ListenAddr = ":8080"
+[[Extensions]]
+  Name = "haproxy"
+  [Extensions.HAProxy]
+    SSLPort = 443
+[[Extensions]]
+  Name = "beacon"
+  [Extensions.Beacon.Rules.web]
+    Type = "image"
+`
+	overrides := EnvOverrides([]string{
+		"INTERLOCK_CONFIG=ignored",
+		"INTERLOCK_LISTENADDR=:9090",
+		"INTERLOCK_EXTENSIONS_0_SSLPORT=8443",
+		"INTERLOCK_EXTENSIONS_0_HAPROXY_ADMINPASS=secret",
+		"INTERLOCK_EXTENSIONS_1_BEACON_RULES_WEB_REGEX=^api",
+		"INTERLOCK_VERSION=1",
+		"PATH=/bin",
+	})
+	if len(overrides) != 5 {
+		t.Fatalf("expected 5 overrides; received %d", len(overrides))
+	}
+
+	o, err := ParseOverride("ListenAddr=:7070")
+	if err != nil {
+		t.Fatal(err)
+	}
+	overrides = append(overrides, o)
+
+	cfg, err := ParseConfig(data, overrides...)
+	if err != nil {
+		t.Fatalf("error parsing config: %s", err)
+	}
+
+	if cfg.ListenAddr != ":7070" {
+		t.Fatalf("expected the flag to override the env; received %s", cfg.ListenAddr)
+	}
+
+	haproxy := cfg.Extensions[0].HAProxy
+	if haproxy.SSLPort != 8443 {
+		t.Fatalf("expected ssl port 8443; received %d", haproxy.SSLPort)
+	}
+
+	if haproxy.AdminPass != "secret" {
+		t.Fatalf("expected admin pass secret; received %s", haproxy.AdminPass)
+	}
+
+	rule := cfg.Extensions[1].Beacon.Rules["web"]
+	if rule == nil || rule.Type != "image" || rule.Regex != "^api" {
+		t.Fatalf("unexpected rule: %+v", rule)
 	}
 }
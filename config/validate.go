@@ -0,0 +1,658 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/ehazlett/interlock/ext"
+)
+
+const (
+	minPollInterval = time.Millisecond * 2000
+)
+
+var (
+	extensionNames = []string{"haproxy", "nginx", "beacon"}
+)
+
+// Problem is an issue found in a config
+type Problem struct {
+	Line    int // line of the option (0 if unknown)
+	Key     string
+	Message string
+}
+
+func (p *Problem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", p.Key, p.Message)
+	}
+
+	return fmt.Sprintf("line %d: %s: %s", p.Line, p.Key, p.Message)
+}
+
+// ValidationError has all of the errors found in a config
+type ValidationError struct {
+	Problems []*Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := []string{
+		fmt.Sprintf("invalid config: %d error(s)", len(e.Problems)),
+	}
+	for _, p := range e.Problems {
+		lines = append(lines, p.String())
+	}
+
+	return strings.Join(lines, "\n  ")
+}
+
+// ValidationResult is the result of validating a config
+type ValidationResult struct {
+	Errors   []*Problem
+	Warnings []*Problem
+}
+
+// Err returns a ValidationError with the errors or nil if the config is valid
+func (r *ValidationResult) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+
+	return &ValidationError{
+		Problems: r.Errors,
+	}
+}
+
+// Validate checks the raw config against the Config options.  Unknown
+// options, options set for an extension which does not support them and
+// invalid values are all reported along with their line (TOML configs
+// only).  The overrides are applied in order and checked the same way.  An
+// error is only returned if the config cannot be parsed.
+func Validate(data string, overrides ...*Override) (*ValidationResult, error) {
+	v, err := validateConfig(data, overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.result, nil
+}
+
+// validateConfig validates the config and returns the validator; the raw
+// config of the validator has the overrides applied
+func validateConfig(data string, overrides []*Override) (*validator, error) {
+	tomlData, format, err := toTOML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	md, err := toml.Decode(tomlData, &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	// the lines of YAML and JSON configs are not known
+	lines := map[string][]int{}
+	if format == FormatTOML {
+		lines = keyLines(data)
+	}
+
+	v := &validator{
+		data:     tomlData,
+		raw:      raw,
+		lines:    lines,
+		indexes:  map[string]int{},
+		reported: map[string]bool{},
+		result: &ValidationResult{
+			Errors:   []*Problem{},
+			Warnings: []*Problem{},
+		},
+	}
+
+	for _, key := range md.Keys() {
+		v.validateKey(key)
+	}
+
+	for _, o := range overrides {
+		v.validateOverride(o)
+	}
+
+	return v, nil
+}
+
+type validator struct {
+	// data is the config as TOML
+	data  string
+	raw   map[string]interface{}
+	lines map[string][]int
+	// indexes is the current table of each array of tables (i.e. Extensions)
+	indexes map[string]int
+	// reported keys are skipped to only report a problem once
+	reported map[string]bool
+	lastLine int
+	result   *ValidationResult
+}
+
+func (v *validator) errorf(line int, key, format string, args ...interface{}) {
+	v.result.Errors = append(v.result.Errors, &Problem{
+		Line:    line,
+		Key:     key,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) warnf(line int, key, format string, args ...interface{}) {
+	v.result.Warnings = append(v.result.Warnings, &Problem{
+		Line:    line,
+		Key:     key,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// line returns the line of the next occurrence of the key; keys which are
+// not found (i.e. in inline tables) use the line of the previous key
+func (v *validator) line(key toml.Key) int {
+	k := key.String()
+	if l := v.lines[k]; len(l) > 0 {
+		v.lastLine = l[0]
+		v.lines[k] = l[1:]
+	}
+
+	return v.lastLine
+}
+
+// value returns the raw value of the key using the current table of the
+// arrays of tables
+func (v *validator) value(key toml.Key) interface{} {
+	var cur interface{} = v.raw
+	for i, k := range key {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		cur = m[k]
+		if tables, ok := cur.([]map[string]interface{}); ok {
+			idx := v.indexes[key[:i+1].String()]
+			if idx < 0 || idx >= len(tables) {
+				return nil
+			}
+			cur = tables[idx]
+		}
+	}
+
+	return cur
+}
+
+func (v *validator) validateKey(key toml.Key) {
+	line := v.line(key)
+
+	// a new table of an array of tables (i.e. [[Extensions]])
+	if _, ok := v.rawParent(key)[key[len(key)-1]].([]map[string]interface{}); ok && len(key) == 1 {
+		if _, seen := v.indexes[key[0]]; !seen {
+			v.indexes[key[0]] = -1
+		}
+		v.indexes[key[0]]++
+		if key[0] == "Extensions" {
+			v.validateExtension(line)
+		}
+	}
+
+	for i := range key {
+		if v.reported[key[:i+1].String()] {
+			return
+		}
+	}
+
+	t := reflect.TypeOf(Config{})
+	var field reflect.StructField
+	for i, k := range key {
+		t = elemType(t)
+
+		switch t.Kind() {
+		case reflect.Map:
+			t = t.Elem()
+			continue
+		case reflect.Struct:
+		default:
+			v.reported[key[:i].String()] = true
+			v.errorf(line, key[:i].String(), "expected %s; found a table", typeName(t))
+			return
+		}
+
+		f, exact, ok := structField(t, k)
+		legacy := false
+		if (!ok || !exact) && i == 1 && key[0] == "Extensions" {
+			// flat options are migrated to the extension section
+			lf, lexact, lok := structField(reflect.TypeOf(legacyExtensionConfig{}), k)
+			switch {
+			case lexact:
+				f, exact, ok, legacy = lf, true, true, true
+			case lok && !ok:
+				f, ok = lf, true
+			}
+		}
+
+		if !ok || !exact {
+			v.reported[key[:i+1].String()] = true
+			if ok {
+				v.errorf(line, key[:i+1].String(), "unknown option; did you mean %s?", f.Name)
+			} else {
+				v.errorf(line, key[:i+1].String(), "unknown option")
+			}
+			return
+		}
+
+		if i == 0 {
+			if msg := f.Tag.Get("deprecated"); msg != "" && !v.reported["deprecated."+k] {
+				v.reported["deprecated."+k] = true
+				v.warnf(line, k, "deprecated; %s", msg)
+			}
+		}
+
+		if i == 1 && key[0] == "Extensions" {
+			if !v.validateExtensionOption(line, f) {
+				return
+			}
+
+			if legacy {
+				v.warnLegacyOption(line, f)
+			}
+		}
+
+		field = f
+		t = f.Type
+	}
+
+	v.validateValue(line, key, field, t, v.value(key))
+}
+
+// validateOverride applies the override to the raw config and checks the
+// value; problems are reported with the source of the override
+func (v *validator) validateOverride(o *Override) {
+	key, f, val, err := o.apply(v.raw)
+	if _, ok := err.(unknownOptionError); ok && o.Lenient {
+		v.warnf(0, o.Source, "%s; ignored", err)
+		return
+	}
+	if err != nil {
+		v.errorf(0, o.Source, "%s", err)
+		return
+	}
+
+	errs, warnings := len(v.result.Errors), len(v.result.Warnings)
+	v.validateValue(0, key, f, f.Type, val)
+
+	for _, p := range v.result.Errors[errs:] {
+		p.Key = o.Source
+	}
+	for _, p := range v.result.Warnings[warnings:] {
+		p.Key = o.Source
+	}
+}
+
+// validateExtension checks the name of the current extension
+func (v *validator) validateExtension(line int) {
+	name := v.extensionName()
+	switch {
+	case name == "":
+		v.errorf(line, "Extensions", "Name is required")
+	case !contains(extensionNames, name):
+		v.errorf(line, "Extensions", "unsupported extension %q; expected one of %s", name, strings.Join(extensionNames, ", "))
+	}
+}
+
+// validateExtensionOption checks the option is supported by the current
+// extension; an unsupported option is reported once per extension
+func (v *validator) validateExtensionOption(line int, f reflect.StructField) bool {
+	name := v.extensionName()
+	supported := f.Tag.Get("ext")
+	if supported == "" || !contains(extensionNames, name) || contains(strings.Split(supported, ","), name) {
+		return true
+	}
+
+	k := fmt.Sprintf("Extensions.%d.%s", v.indexes["Extensions"], f.Name)
+	if !v.reported[k] {
+		v.reported[k] = true
+		v.errorf(line, "Extensions."+f.Name, "not supported by the %s extension (supported by %s)", name, strings.Replace(supported, ",", ", ", -1))
+	}
+
+	return false
+}
+
+// warnLegacyOption reports a flat option once per extension
+func (v *validator) warnLegacyOption(line int, f reflect.StructField) {
+	k := fmt.Sprintf("legacy.%d.%s", v.indexes["Extensions"], f.Name)
+	if v.reported[k] {
+		return
+	}
+	v.reported[k] = true
+
+	v.warnf(line, "Extensions."+f.Name, "deprecated; move the option to [Extensions.%s]", sectionName(v.extensionName()))
+}
+
+func (v *validator) extensionName() string {
+	ext, _ := v.value(toml.Key{"Extensions"}).(map[string]interface{})
+	name, _ := ext["Name"].(string)
+	return strings.ToLower(name)
+}
+
+func (v *validator) rawParent(key toml.Key) map[string]interface{} {
+	m, _ := v.value(key[:len(key)-1]).(map[string]interface{})
+	return m
+}
+
+// validateValue checks the type of the value and the validate tag of the
+// field
+func (v *validator) validateValue(line int, key toml.Key, f reflect.StructField, t reflect.Type, val interface{}) {
+	k := key.String()
+
+	if val == nil {
+		return
+	}
+
+	if !hasType(t, val) {
+		v.errorf(line, k, "expected %s; found %s", typeName(t), tomlType(val))
+		return
+	}
+
+	s, ok := val.(string)
+	if !ok || s == "" {
+		return
+	}
+
+	// secret references are resolved after the config is validated
+	if f.Tag.Get("secret") != "" && isSecretRef(s) {
+		if _, _, err := secretSource(s); err != nil {
+			v.errorf(line, k, "invalid secret reference: %s", err)
+		}
+		return
+	}
+
+	check := f.Tag.Get("validate")
+	switch {
+	case check == "duration":
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			v.errorf(line, k, "invalid duration %q (i.e. 10s)", s)
+			return
+		}
+
+		if k == "PollInterval" && d < minPollInterval {
+			v.warnf(line, k, "interval %s is less than %s; %s will be used", d, minPollInterval, minPollInterval)
+		}
+	case check == "url":
+		u, err := url.Parse(s)
+		if err != nil || u.Scheme == "" || (u.Host == "" && u.Path == "") {
+			v.errorf(line, k, "invalid url %q", s)
+		}
+	case check == "hostport":
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			v.errorf(line, k, "invalid address %q (i.e. 127.0.0.1:8125)", s)
+		}
+	case check == "labelprefix":
+		if err := ext.ValidateLabelPrefix(s); err != nil {
+			v.errorf(line, k, "%s", err)
+		}
+	case check == "selector":
+		if _, err := ext.ParseSelector(s); err != nil {
+			v.errorf(line, k, "%s", err)
+		}
+	case check == "regex":
+		if _, err := regexp.Compile(s); err != nil {
+			v.errorf(line, k, "invalid regex: %s", err)
+		}
+	case strings.HasPrefix(check, "oneof="):
+		values := strings.Fields(strings.TrimPrefix(check, "oneof="))
+		if !contains(values, s) {
+			v.errorf(line, k, "invalid value %q; expected one of %s", s, strings.Join(values, ", "))
+		}
+	}
+}
+
+func elemType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	return t
+}
+
+// structField returns the field for the key and whether the key matches
+// the name exactly; keys are otherwise matched case insensitively by the
+// TOML decoder
+func structField(t reflect.Type, key string) (reflect.StructField, bool, bool) {
+	var match reflect.StructField
+	found := false
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		// unexported fields are not options
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := f.Name
+		if tag := f.Tag.Get("toml"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			name = strings.Split(tag, ",")[0]
+		}
+
+		// options of embedded structs (i.e. ProxyConfig)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if ef, exact, ok := structField(f.Type, key); ok && (exact || !found) {
+				if exact {
+					return ef, true, true
+				}
+				match = ef
+				found = true
+			}
+			continue
+		}
+
+		if name == key {
+			return f, true, true
+		}
+
+		if !found && strings.EqualFold(name, key) {
+			match = f
+			found = true
+		}
+	}
+
+	return match, false, found
+}
+
+// hasType returns true if the TOML value can be decoded to the type
+func hasType(t reflect.Type, val interface{}) bool {
+	switch elemType(t).Kind() {
+	case reflect.String:
+		_, ok := val.(string)
+		return ok
+	case reflect.Bool:
+		_, ok := val.(bool)
+		return ok
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		_, ok := val.(int64)
+		return ok
+	case reflect.Float32, reflect.Float64:
+		switch val.(type) {
+		case float64, int64:
+			return true
+		}
+		return false
+	}
+
+	_, ok := val.(map[string]interface{})
+	return ok
+}
+
+func typeName(t reflect.Type) string {
+	switch elemType(t).Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	}
+
+	return "a table"
+}
+
+func tomlType(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "a string"
+	case int64:
+		return "an integer"
+	case float64:
+		return "a float"
+	case bool:
+		return "a boolean"
+	case time.Time:
+		return "a datetime"
+	case map[string]interface{}:
+		return "a table"
+	case []map[string]interface{}:
+		return "an array of tables"
+	case []interface{}:
+		return "an array"
+	}
+
+	return fmt.Sprintf("%T", v)
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+var (
+	tableHeader = regexp.MustCompile(`^\[\[?([^\[\]]+)\]\]?\s*(#.*)?$`)
+	keyValue    = regexp.MustCompile(`^("(?:[^"\\]|\\.)*"|'[^']*'|[A-Za-z0-9_-]+)\s*=`)
+)
+
+// keyLines returns the lines of the keys and tables of the raw TOML config
+// (i.e. Extensions.Name) as the TOML decoder does not report them
+func keyLines(data string) map[string][]int {
+	lines := map[string][]int{}
+	table := toml.Key{}
+	multiline := "" // delimiter closing the current multiline string
+	depth := 0      // open brackets of the current multiline array
+
+	for i, l := range strings.Split(data, "\n") {
+		l = strings.TrimSpace(l)
+
+		switch {
+		case multiline != "":
+			if strings.Contains(l, multiline) {
+				multiline = ""
+			}
+			continue
+		case depth > 0:
+			depth += bracketDepth(l)
+			continue
+		case l == "" || l[0] == '#':
+			continue
+		}
+
+		if m := tableHeader.FindStringSubmatch(l); m != nil {
+			table = splitKey(m[1])
+			lines[table.String()] = append(lines[table.String()], i+1)
+			continue
+		}
+
+		m := keyValue.FindStringSubmatch(l)
+		if m == nil {
+			continue
+		}
+
+		k := append(append(toml.Key{}, table...), unquoteKey(m[1])).String()
+		lines[k] = append(lines[k], i+1)
+
+		value := strings.TrimSpace(l[len(m[0]):])
+		for _, delim := range []string{`"""`, `'''`} {
+			if strings.HasPrefix(value, delim) && !strings.Contains(value[len(delim):], delim) {
+				multiline = delim
+			}
+		}
+
+		if strings.HasPrefix(value, "[") {
+			depth = bracketDepth(value)
+		}
+	}
+
+	return lines
+}
+
+// splitKey splits the table name (i.e. Extensions.Rules."my rule")
+func splitKey(name string) toml.Key {
+	key := toml.Key{}
+	quote := rune(0)
+	start := 0
+	for i, c := range name {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '.':
+			key = append(key, unquoteKey(name[start:i]))
+			start = i + 1
+		}
+	}
+
+	return append(key, unquoteKey(name[start:]))
+}
+
+func unquoteKey(k string) string {
+	k = strings.TrimSpace(k)
+	if len(k) >= 2 && (k[0] == '"' || k[0] == '\'') && k[len(k)-1] == k[0] {
+		return k[1 : len(k)-1]
+	}
+
+	return k
+}
+
+// bracketDepth returns the opened minus the closed array brackets of the
+// line ignoring strings and comments
+func bracketDepth(l string) int {
+	depth := 0
+	quote := rune(0)
+	for _, c := range l {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return depth
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		}
+	}
+
+	return depth
+}
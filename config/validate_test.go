@@ -0,0 +1,353 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	data := `
+ListenAddr = ":8080"
+DockerURL = "unix:///var/run/docker.sock"
+PollInterval = "5s"
+
+[[Extensions]]
+  Name = "haproxy"
+  [Extensions.HAProxy]
+    SSLPort = 443
+    AdminUser = "admin"
+    ProxyStatsInterval = "10s"
+
+[[Extensions]]
+  Name = "beacon"
+  [Extensions.Beacon]
+    StatsInterval = "10s"
+    StatsStatsDAddress = "127.0.0.1:8125"
+  [Extensions.Beacon.Rules.web]
+    Type = "image"
+    Regex = "nginx"
+  [Extensions.Beacon.Alerts.highmem]
+    Expr = "mem_usage_percent > 90"
+    For = "2m"
+    Webhook = "http://alertmanager:9093/api/v2/alerts"
+    Format = "alertmanager"
+`
+	result, err := Validate(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := result.Err(); err != nil {
+		t.Fatalf("expected valid config: %s", err)
+	}
+
+	if len(result.Warnings) != 0 {
+		t.Fatalf("expected no warnings; received %v", result.Warnings)
+	}
+}
+
+func TestValidateProblems(t *testing.T) {
+	data := `ListenAddr = ":8080"
+PollInterval = "soon"
+Listen = ":8081"
+
+[[Extensions]]
+  Name = "nginx"
+  [Extensions.Nginx]
+    SSLPORT = 443
+    AdminUser = "admin"
+    Port = "80"
+
+[[Extensions]]
+  Name = "beacon"
+  [Extensions.Beacon]
+    StatsInterval = 10
+  [Extensions.Beacon.Rules.web]
+    Type = "tag"
+  [Extensions.HAProxy]
+    Port = 80
+
+[[Extensions]]
+  Name = "traefik"
+`
+	result, err := Validate(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`line 2: PollInterval: invalid duration "soon" (i.e. 10s)`,
+		`line 3: Listen: unknown option`,
+		`line 8: Extensions.Nginx.SSLPORT: unknown option; did you mean SSLPort?`,
+		`line 9: Extensions.Nginx.AdminUser: unknown option`,
+		`line 10: Extensions.Nginx.Port: expected an integer; found a string`,
+		`line 15: Extensions.Beacon.StatsInterval: expected a string; found an integer`,
+		`line 17: Extensions.Beacon.Rules.web.Type: invalid value "tag"; expected one of label, name, image, compose_project, compose_service`,
+		`line 18: Extensions.HAProxy: not supported by the beacon extension (supported by haproxy)`,
+		`line 21: Extensions: unsupported extension "traefik"; expected one of haproxy, nginx, beacon`,
+	}
+
+	if len(result.Errors) != len(expected) {
+		t.Fatalf("expected %d errors; received %v", len(expected), result.Errors)
+	}
+
+	for i, p := range result.Errors {
+		if p.String() != expected[i] {
+			t.Fatalf("expected %q; received %q", expected[i], p.String())
+		}
+	}
+}
+
+func TestValidateWarnings(t *testing.T) {
+	data := `PollInterval = "1s"
+
+[Rules.web]
+  Type = "image"
+`
+	result, err := Validate(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := result.Err(); err != nil {
+		t.Fatalf("expected valid config: %s", err)
+	}
+
+	if len(result.Warnings) != 2 {
+		t.Fatalf("expected 2 warnings; received %v", result.Warnings)
+	}
+
+	if w := result.Warnings[1]; w.Key != "Rules" || w.Line != 3 {
+		t.Fatalf("expected deprecation warning for Rules; received %s", w)
+	}
+}
+
+func TestValidateSyntaxError(t *testing.T) {
+	if _, err := Validate("ListenAddr = "); err == nil {
+		t.Fatal("expected error for invalid toml")
+	}
+}
+
+func TestValidateMultilineValues(t *testing.T) {
+	data := `Rules = [
+  "Name = 1",
+]
+DockerURL = """
+Bogus = 2
+"""
+[[Extensions]]
+  Name = "nginx"
+  Bad = 1
+`
+	result, err := Validate(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := map[string]int{}
+	for _, p := range result.Errors {
+		lines[p.Key] = p.Line
+	}
+
+	if lines["Rules"] != 1 || lines["DockerURL"] != 4 || lines["Extensions.Bad"] != 9 {
+		t.Fatalf("unexpected lines: %v", result.Errors)
+	}
+}
+
+func TestValidateLegacyOptions(t *testing.T) {
+	data := `
+[[Extensions]]
+  Name = "nginx"
+  Port = 8080
+  SSLPORT = 443
+  AdminUser = "admin"
+  [Extensions.Rules.web]
+    Type = "image"
+`
+	result, err := Validate(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`line 5: Extensions.SSLPORT: unknown option; did you mean SSLPort?`,
+		`line 6: Extensions.AdminUser: not supported by the nginx extension (supported by haproxy)`,
+		`line 7: Extensions.Rules: not supported by the nginx extension (supported by beacon)`,
+	}
+
+	if len(result.Errors) != len(expected) {
+		t.Fatalf("expected %d errors; received %v", len(expected), result.Errors)
+	}
+
+	for i, p := range result.Errors {
+		if p.String() != expected[i] {
+			t.Fatalf("expected %q; received %q", expected[i], p.String())
+		}
+	}
+
+	if len(result.Warnings) != 1 || result.Warnings[0].String() != "line 4: Extensions.Port: deprecated; move the option to [Extensions.Nginx]" {
+		t.Fatalf("expected deprecation warning for the flat option; received %v", result.Warnings)
+	}
+}
+
+func TestParseConfigInvalid(t *testing.T) {
+	data := `
+[[Extensions]]
+  Name = "haproxy"
+  SSLPORT = 443
+  CachePath = "/tmp"
+`
+	_, err := ParseConfig(data)
+	if err == nil {
+		t.Fatal("expected error for invalid config")
+	}
+
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected validation error; received %T", err)
+	}
+
+	if len(verr.Problems) != 2 {
+		t.Fatalf("expected all problems to be reported; received %v", verr.Problems)
+	}
+
+	if !strings.Contains(err.Error(), "line 4: Extensions.SSLPORT") {
+		t.Fatalf("expected error to contain the line; received %s", err)
+	}
+}
+
+func TestSpec(t *testing.T) {
+	for _, name := range []string{"haproxy", "nginx", "beacon"} {
+		spec, err := Spec(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := Validate(spec)
+		if err != nil {
+			t.Fatalf("%s: invalid toml: %s", name, err)
+		}
+
+		if len(result.Errors) != 0 || len(result.Warnings) != 0 {
+			t.Fatalf("%s: expected valid spec; received %v %v", name, result.Errors, result.Warnings)
+		}
+
+		section := "[Extensions." + sectionName(name) + "]"
+		if !strings.Contains(spec, section) {
+			t.Fatalf("%s: expected %s section", name, section)
+		}
+	}
+
+	if _, err := Spec("traefik"); err == nil {
+		t.Fatal("expected error for unknown extension")
+	}
+}
+
+func TestValidateOverrides(t *testing.T) {
+	data := `
+[[Extensions]]
+  Name = "nginx"
+`
+	overrides := EnvOverrides([]string{
+		"INTERLOCK_EXTENSIONS_0_SSLPORT=https",
+		"INTERLOCK_EXTENSIONS_0_ADMINUSER=admin",
+		"INTERLOCK_EXTENSIONS_1_PORT=80",
+		"INTERLOCK_BOGUS=1",
+		"INTERLOCK_POLLINTERVAL=1s",
+	})
+
+	result, err := Validate(data, overrides...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := map[string]bool{}
+	for _, p := range result.Errors {
+		keys[p.Key] = true
+	}
+
+	for _, k := range []string{"INTERLOCK_EXTENSIONS_0_SSLPORT", "INTERLOCK_EXTENSIONS_0_ADMINUSER", "INTERLOCK_EXTENSIONS_1_PORT"} {
+		if !keys[k] {
+			t.Fatalf("expected error for %s; received %v", k, result.Errors)
+		}
+	}
+
+	if len(result.Errors) != 3 {
+		t.Fatalf("expected 3 errors; received %v", result.Errors)
+	}
+
+	// unknown environment variables are ignored
+	warnings := map[string]bool{}
+	for _, p := range result.Warnings {
+		warnings[p.Key] = true
+	}
+
+	if len(result.Warnings) != 2 || !warnings["INTERLOCK_POLLINTERVAL"] || !warnings["INTERLOCK_BOGUS"] {
+		t.Fatalf("expected poll interval and unknown option warnings; received %v", result.Warnings)
+	}
+
+	o, err := ParseOverride("Bogus=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = Validate(data, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Errors) != 1 || result.Errors[0].Key != "Bogus" {
+		t.Fatalf("expected error for unknown flag option; received %v", result.Errors)
+	}
+}
+
+func TestValidateYAMLProblems(t *testing.T) {
+	data := `
+ListenAddr: ":8080"
+Extensions:
+  - Name: nginx
+    Nginx:
+      SSLPORT: 443
+`
+	result, err := Validate(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Errors) != 1 {
+		t.Fatalf("expected 1 error; received %v", result.Errors)
+	}
+
+	if p := result.Errors[0]; p.Key != "Extensions.Nginx.SSLPORT" || p.Line != 0 {
+		t.Fatalf("unexpected problem: %s", p)
+	}
+}
+
+func TestValidateLabels(t *testing.T) {
+	data := `
+[[Extensions]]
+  Name = "nginx"
+  [Extensions.Nginx]
+    LabelPrefix = "public"
+    LabelSelector = "env=prod,team in (web api)"
+[[Extensions]]
+  Name = "haproxy"
+  [Extensions.HAProxy]
+    LabelPrefix = "interlock.example.com/"
+    LabelSelector = "env=prod,!legacy"
+`
+	result, err := Validate(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Errors) != 2 {
+		t.Fatalf("expected 2 errors; received %v", result.Errors)
+	}
+
+	for i, k := range []string{"Extensions.Nginx.LabelPrefix", "Extensions.Nginx.LabelSelector"} {
+		if result.Errors[i].Key != k {
+			t.Fatalf("expected error for %s; received %s", k, result.Errors[i])
+		}
+	}
+}
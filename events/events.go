@@ -2,6 +2,7 @@ package events
 
 import (
 	etypes "github.com/docker/docker/api/types/events"
+	"github.com/ehazlett/interlock/trace"
 )
 
 type (
@@ -10,6 +11,9 @@ type (
 	}
 	Message struct {
 		etypes.Message
+
+		// Trace is the span of the event dispatch
+		Trace trace.SpanContext
 	}
 )
 
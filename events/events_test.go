@@ -23,7 +23,7 @@ func TestEventHandler(t *testing.T) {
 	}
 
 	testEvent := &Message{
-		etypes.Message{
+		Message: etypes.Message{
 			Type: "testevent",
 		},
 	}
@@ -0,0 +1,399 @@
+package beacon
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ehazlett/interlock/config"
+)
+
+const (
+	alertStatusFiring   = "firing"
+	alertStatusResolved = "resolved"
+
+	alertFormatGeneric      = "generic"
+	alertFormatSlack        = "slack"
+	alertFormatAlertmanager = "alertmanager"
+)
+
+var (
+	alertExprRegex     = regexp.MustCompile(`^\s*([a-z_]+)\s*(>=|<=|>|<)\s*(-?[0-9.]+)\s*$`)
+	alertSelectorRegex = regexp.MustCompile(`^\s*([A-Za-z0-9_.\-]+)\s*(=~|!~|!=|=)\s*(.*?)\s*$`)
+)
+
+// alertMatcher matches a single tag or container label
+type alertMatcher struct {
+	key   string
+	op    string
+	value string
+	regex *regexp.Regexp
+}
+
+func (m *alertMatcher) match(tags map[string]string, labels map[string]string) bool {
+	var v string
+	if strings.HasPrefix(m.key, "label.") {
+		v = labels[strings.TrimPrefix(m.key, "label.")]
+	} else {
+		v = tags[m.key]
+	}
+
+	switch m.op {
+	case "=":
+		return v == m.value
+	case "!=":
+		return v != m.value
+	case "=~":
+		return m.regex.MatchString(v)
+	case "!~":
+		return !m.regex.MatchString(v)
+	}
+
+	return false
+}
+
+// alert is a compiled config.Alert
+type alert struct {
+	name       string
+	metric     string
+	op         string
+	threshold  float64
+	hysteresis float64
+	duration   time.Duration
+	matchers   []*alertMatcher
+	webhook    string
+	format     string
+}
+
+// alertState is the state of an alert for a single series
+type alertState struct {
+	alert   *alert
+	tags    map[string]string
+	value   float64
+	pending time.Time
+	firing  bool
+	firedAt time.Time
+}
+
+// notification is sent when an alert fires or resolves
+type notification struct {
+	Alert     string
+	Status    string
+	Metric    string
+	Operator  string
+	Threshold float64
+	Value     float64
+	Tags      map[string]string
+	StartsAt  time.Time
+	EndsAt    time.Time
+	Webhook   string
+	Format    string
+	// Resend is set for the firing alerts sent again to alertmanager
+	Resend bool
+}
+
+func compileAlerts(alerts map[string]*config.Alert) ([]*alert, error) {
+	names := []string{}
+	for name := range alerts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	compiled := []*alert{}
+	for _, name := range names {
+		a := alerts[name]
+
+		m := alertExprRegex.FindStringSubmatch(a.Expr)
+		if m == nil {
+			return nil, fmt.Errorf("invalid expression for alert %s: %q", name, a.Expr)
+		}
+
+		known := false
+		for _, name := range containerMetrics {
+			if name == m[1] {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown metric for alert %s: %s", name, m[1])
+		}
+
+		threshold, err := strconv.ParseFloat(m[3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold for alert %s: %s", name, err)
+		}
+
+		var d time.Duration
+		if a.For != "" {
+			d, err = time.ParseDuration(a.For)
+			if err != nil {
+				return nil, fmt.Errorf("invalid duration for alert %s: %s", name, err)
+			}
+		}
+
+		if a.Hysteresis < 0 {
+			return nil, fmt.Errorf("hysteresis must be positive for alert %s", name)
+		}
+
+		if a.Webhook == "" {
+			return nil, fmt.Errorf("webhook is required for alert %s", name)
+		}
+
+		format := a.Format
+		switch format {
+		case "":
+			format = alertFormatGeneric
+		case alertFormatGeneric, alertFormatSlack, alertFormatAlertmanager:
+		default:
+			return nil, fmt.Errorf("unknown format for alert %s: %s", name, a.Format)
+		}
+
+		matchers, err := parseAlertSelector(a.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector for alert %s: %s", name, err)
+		}
+
+		compiled = append(compiled, &alert{
+			name:       name,
+			metric:     m[1],
+			op:         m[2],
+			threshold:  threshold,
+			hysteresis: a.Hysteresis,
+			duration:   d,
+			matchers:   matchers,
+			webhook:    a.Webhook,
+			format:     format,
+		})
+	}
+
+	return compiled, nil
+}
+
+// parseAlertSelector parses a comma separated list of matchers
+// (i.e. "image=~api,label.env=prod")
+func parseAlertSelector(selector string) ([]*alertMatcher, error) {
+	matchers := []*alertMatcher{}
+	if strings.TrimSpace(selector) == "" {
+		return matchers, nil
+	}
+
+	for _, s := range strings.Split(selector, ",") {
+		m := alertSelectorRegex.FindStringSubmatch(s)
+		if m == nil {
+			return nil, fmt.Errorf("invalid matcher: %q", s)
+		}
+
+		matcher := &alertMatcher{
+			key:   m[1],
+			op:    m[2],
+			value: m[3],
+		}
+
+		if matcher.op == "=~" || matcher.op == "!~" {
+			regex, err := regexp.Compile(matcher.value)
+			if err != nil {
+				return nil, err
+			}
+			matcher.regex = regex
+		}
+
+		matchers = append(matchers, matcher)
+	}
+
+	return matchers, nil
+}
+
+// exceeded returns true if the value meets the alert condition
+func (a *alert) exceeded(v float64) bool {
+	switch a.op {
+	case ">":
+		return v > a.threshold
+	case ">=":
+		return v >= a.threshold
+	case "<":
+		return v < a.threshold
+	case "<=":
+		return v <= a.threshold
+	}
+
+	return false
+}
+
+// recovered returns true if the value is back within the threshold
+// including the hysteresis margin
+func (a *alert) recovered(v float64) bool {
+	switch a.op {
+	case ">", ">=":
+		return v < a.threshold-a.hysteresis
+	case "<", "<=":
+		return v > a.threshold+a.hysteresis
+	}
+
+	return true
+}
+
+// alerter evaluates the alerts on every collection
+type alerter struct {
+	lock   sync.Mutex
+	alerts []*alert
+	states map[string]*alertState
+}
+
+func newAlerter(alerts []*alert) *alerter {
+	return &alerter{
+		alerts: alerts,
+		states: map[string]*alertState{},
+	}
+}
+
+// setWebhooks updates the webhooks of the alerts (i.e. rotated secrets)
+func (r *alerter) setWebhooks(alerts map[string]*config.Alert) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, a := range r.alerts {
+		if cfg, ok := alerts[a.name]; ok && cfg.Webhook != "" {
+			a.webhook = cfg.Webhook
+		}
+	}
+}
+
+func alertStateKey(a *alert, tags map[string]string) string {
+	return a.name + "\xff" + tags["container"] + "\xff" + tags["network"]
+}
+
+// evaluate updates the alert states with the stats and returns the
+// notifications to send
+func (r *alerter) evaluate(stats []Stat, now time.Time) []*notification {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	notifications := []*notification{}
+
+	for _, stat := range stats {
+		labels := map[string]string{}
+		if stat.ContainerJSON.Config != nil {
+			labels = stat.ContainerJSON.Config.Labels
+		}
+
+		for _, m := range statMetrics(stat) {
+			for _, a := range r.alerts {
+				if a.metric != m.Name {
+					continue
+				}
+
+				matched := true
+				for _, matcher := range a.matchers {
+					if !matcher.match(m.Tags, labels) {
+						matched = false
+						break
+					}
+				}
+				if !matched {
+					continue
+				}
+
+				if n := r.update(a, m, now); n != nil {
+					notifications = append(notifications, n)
+				}
+			}
+		}
+	}
+
+	return notifications
+}
+
+func (r *alerter) update(a *alert, m metric, now time.Time) *notification {
+	key := alertStateKey(a, m.Tags)
+	state, ok := r.states[key]
+	if !ok {
+		state = &alertState{
+			alert: a,
+			tags:  m.Tags,
+		}
+		r.states[key] = state
+	}
+
+	state.value = m.Value
+
+	if state.firing {
+		if a.recovered(m.Value) {
+			delete(r.states, key)
+			return state.notification(alertStatusResolved, now)
+		}
+
+		// alertmanager resolves the alerts which are not sent again
+		// within its resolve_timeout
+		if a.format == alertFormatAlertmanager {
+			n := state.notification(alertStatusFiring, now)
+			n.Resend = true
+			return n
+		}
+
+		return nil
+	}
+
+	if !a.exceeded(m.Value) {
+		delete(r.states, key)
+		return nil
+	}
+
+	if state.pending.IsZero() {
+		state.pending = now
+	}
+
+	if now.Sub(state.pending) >= a.duration {
+		state.firing = true
+		state.firedAt = now
+		return state.notification(alertStatusFiring, now)
+	}
+
+	return nil
+}
+
+// remove drops the states of the container and returns resolved
+// notifications for the alerts that were firing
+func (r *alerter) remove(id string, now time.Time) []*notification {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	notifications := []*notification{}
+	for key, state := range r.states {
+		if state.tags["container"] != id {
+			continue
+		}
+
+		if state.firing {
+			notifications = append(notifications, state.notification(alertStatusResolved, now))
+		}
+		delete(r.states, key)
+	}
+
+	return notifications
+}
+
+func (s *alertState) notification(status string, now time.Time) *notification {
+	n := &notification{
+		Alert:     s.alert.name,
+		Status:    status,
+		Metric:    s.alert.metric,
+		Operator:  s.alert.op,
+		Threshold: s.alert.threshold,
+		Value:     s.value,
+		Tags:      s.tags,
+		StartsAt:  s.firedAt,
+		Webhook:   s.alert.webhook,
+		Format:    s.alert.format,
+	}
+
+	if status == alertStatusResolved {
+		n.EndsAt = now
+	}
+
+	return n
+}
@@ -0,0 +1,280 @@
+package beacon
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ctypes "github.com/docker/docker/api/types/container"
+	"github.com/ehazlett/interlock/config"
+)
+
+func testAlerter(t *testing.T, a *config.Alert) *alerter {
+	alerts, err := compileAlerts(map[string]*config.Alert{
+		"test": a,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newAlerter(alerts)
+}
+
+func testAlertStat(id, image string, memPercent float64) Stat {
+	stat := testContainerStat(id, id)
+	stat.Image = image
+	stat.MemUsagePercent = memPercent
+	stat.ContainerJSON.Config = &ctypes.Config{
+		Labels: map[string]string{
+			"env": "prod",
+		},
+	}
+
+	return stat
+}
+
+func TestCompileAlertsInvalid(t *testing.T) {
+	tests := map[string]*config.Alert{
+		"expr":       {Expr: "mem_usage_percent 90", Webhook: "http://localhost"},
+		"operator":   {Expr: "mem_usage_percent == 90", Webhook: "http://localhost"},
+		"duration":   {Expr: "mem_usage_percent > 90", For: "2", Webhook: "http://localhost"},
+		"webhook":    {Expr: "mem_usage_percent > 90"},
+		"format":     {Expr: "mem_usage_percent > 90", Format: "foo", Webhook: "http://localhost"},
+		"selector":   {Expr: "mem_usage_percent > 90", Selector: "image", Webhook: "http://localhost"},
+		"regex":      {Expr: "mem_usage_percent > 90", Selector: "image=~(api", Webhook: "http://localhost"},
+		"hysteresis": {Expr: "mem_usage_percent > 90", Hysteresis: -1, Webhook: "http://localhost"},
+		"metric":     {Expr: "memory_percent > 90", Webhook: "http://localhost"},
+	}
+
+	for name, a := range tests {
+		if _, err := compileAlerts(map[string]*config.Alert{name: a}); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestAlertForDuration(t *testing.T) {
+	r := testAlerter(t, &config.Alert{
+		Expr:    "mem_usage_percent > 90",
+		For:     "2m",
+		Webhook: "http://localhost",
+	})
+
+	now := time.Now()
+	stats := []Stat{testAlertStat("a", "api", 95)}
+
+	if n := r.evaluate(stats, now); len(n) != 0 {
+		t.Fatal("expected alert to be pending")
+	}
+
+	if n := r.evaluate(stats, now.Add(time.Minute)); len(n) != 0 {
+		t.Fatal("expected alert to be pending")
+	}
+
+	n := r.evaluate(stats, now.Add(time.Minute*2))
+	if len(n) != 1 || n[0].Status != alertStatusFiring {
+		t.Fatalf("expected alert to fire: %+v", n)
+	}
+
+	if n[0].Value != 95 || n[0].Tags["container"] != "a" {
+		t.Fatalf("unexpected notification: %+v", n[0])
+	}
+
+	// only notify once while firing
+	if n := r.evaluate(stats, now.Add(time.Minute*3)); len(n) != 0 {
+		t.Fatal("expected no notification while firing")
+	}
+}
+
+func TestAlertResendAlertmanager(t *testing.T) {
+	r := testAlerter(t, &config.Alert{
+		Expr:    "mem_usage_percent > 90",
+		Webhook: "http://localhost",
+		Format:  "alertmanager",
+	})
+
+	now := time.Now()
+	stats := []Stat{testAlertStat("a", "api", 95)}
+
+	first := r.evaluate(stats, now)
+	if len(first) != 1 || first[0].Status != alertStatusFiring || first[0].Resend {
+		t.Fatalf("expected alert to fire: %+v", first)
+	}
+
+	// firing alerts are sent on every evaluation
+	n := r.evaluate(stats, now.Add(time.Minute))
+	if len(n) != 1 || n[0].Status != alertStatusFiring || !n[0].Resend {
+		t.Fatalf("expected alert to be sent again: %+v", n)
+	}
+
+	if !n[0].StartsAt.Equal(first[0].StartsAt) {
+		t.Fatalf("expected the same start time; received %s", n[0].StartsAt)
+	}
+}
+
+func TestAlertPendingReset(t *testing.T) {
+	r := testAlerter(t, &config.Alert{
+		Expr:    "mem_usage_percent > 90",
+		For:     "2m",
+		Webhook: "http://localhost",
+	})
+
+	now := time.Now()
+	r.evaluate([]Stat{testAlertStat("a", "api", 95)}, now)
+	r.evaluate([]Stat{testAlertStat("a", "api", 50)}, now.Add(time.Minute))
+
+	if n := r.evaluate([]Stat{testAlertStat("a", "api", 95)}, now.Add(time.Minute*2)); len(n) != 0 {
+		t.Fatal("expected pending duration to restart")
+	}
+}
+
+func TestAlertHysteresis(t *testing.T) {
+	r := testAlerter(t, &config.Alert{
+		Expr:       "mem_usage_percent > 90",
+		Hysteresis: 5,
+		Webhook:    "http://localhost",
+	})
+
+	now := time.Now()
+	if n := r.evaluate([]Stat{testAlertStat("a", "api", 95)}, now); len(n) != 1 {
+		t.Fatal("expected alert to fire without duration")
+	}
+
+	// below the threshold but within the hysteresis
+	if n := r.evaluate([]Stat{testAlertStat("a", "api", 88)}, now.Add(time.Second)); len(n) != 0 {
+		t.Fatal("expected alert to keep firing within hysteresis")
+	}
+
+	n := r.evaluate([]Stat{testAlertStat("a", "api", 80)}, now.Add(time.Second*2))
+	if len(n) != 1 || n[0].Status != alertStatusResolved {
+		t.Fatalf("expected alert to resolve: %+v", n)
+	}
+
+	if n[0].EndsAt.IsZero() || n[0].StartsAt.IsZero() {
+		t.Fatalf("expected start and end time: %+v", n[0])
+	}
+}
+
+func TestAlertSelector(t *testing.T) {
+	r := testAlerter(t, &config.Alert{
+		Expr:     "mem_usage_percent >= 90",
+		Selector: "image=~^api, label.env=prod, name!=b",
+		Webhook:  "http://localhost",
+	})
+
+	stats := []Stat{
+		testAlertStat("a", "api:1.0", 95),
+		testAlertStat("b", "api:1.0", 95),
+		testAlertStat("c", "web:1.0", 95),
+	}
+
+	n := r.evaluate(stats, time.Now())
+	if len(n) != 1 || n[0].Tags["container"] != "a" {
+		t.Fatalf("expected only container a to alert: %+v", n)
+	}
+}
+
+func TestAlertRemove(t *testing.T) {
+	r := testAlerter(t, &config.Alert{
+		Expr:    "pids < 10",
+		Webhook: "http://localhost",
+	})
+
+	now := time.Now()
+	if n := r.evaluate([]Stat{testAlertStat("a", "api", 0)}, now); len(n) != 1 {
+		t.Fatal("expected alert to fire")
+	}
+
+	n := r.remove("a", now)
+	if len(n) != 1 || n[0].Status != alertStatusResolved {
+		t.Fatalf("expected resolved notification on remove: %+v", n)
+	}
+
+	if len(r.states) != 0 {
+		t.Fatal("expected states to be removed")
+	}
+}
+
+func TestNotifyFormats(t *testing.T) {
+	bodies := make(chan []byte, 3)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		bodies <- body
+	}))
+	defer srv.Close()
+
+	n := &notification{
+		Alert:     "highmem",
+		Status:    alertStatusFiring,
+		Metric:    "mem_usage_percent",
+		Operator:  ">",
+		Threshold: 90,
+		Value:     95.5,
+		Tags: map[string]string{
+			"container": "abcdef123456",
+			"name":      "api_1",
+			"image":     "api",
+		},
+		StartsAt: time.Now(),
+		Webhook:  srv.URL,
+	}
+
+	n.Format = alertFormatGeneric
+	if err := notify(n); err != nil {
+		t.Fatal(err)
+	}
+
+	var generic genericPayload
+	if err := json.Unmarshal(<-bodies, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if generic.Alert != "highmem" || generic.Status != alertStatusFiring || generic.EndsAt != nil {
+		t.Fatalf("unexpected generic payload: %+v", generic)
+	}
+
+	n.Format = alertFormatSlack
+	if err := notify(n); err != nil {
+		t.Fatal(err)
+	}
+
+	var slack slackPayload
+	if err := json.Unmarshal(<-bodies, &slack); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(slack.Text, "[FIRING] highmem: mem_usage_percent 95.5 > 90 for api_1 (api)") {
+		t.Fatalf("unexpected slack text: %s", slack.Text)
+	}
+
+	n.Format = alertFormatAlertmanager
+	n.Status = alertStatusResolved
+	n.EndsAt = time.Now()
+	if err := notify(n); err != nil {
+		t.Fatal(err)
+	}
+
+	var am []alertmanagerAlert
+	if err := json.Unmarshal(<-bodies, &am); err != nil {
+		t.Fatal(err)
+	}
+	if len(am) != 1 || am[0].Labels["alertname"] != "highmem" || am[0].Labels["container"] != "abcdef123456" || am[0].EndsAt == nil {
+		t.Fatalf("unexpected alertmanager payload: %+v", am)
+	}
+}
+
+func TestNotifyError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "error", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	if err := notify(&notification{Webhook: srv.URL}); err == nil {
+		t.Fatal("expected error")
+	}
+}
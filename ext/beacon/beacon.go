@@ -3,11 +3,12 @@ package beacon
 import (
 	"fmt"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/client"
+	"github.com/ehazlett/interlock/client"
 	"github.com/ehazlett/interlock/config"
 	"github.com/ehazlett/interlock/events"
 	"github.com/ehazlett/interlock/utils"
@@ -17,6 +18,9 @@ import (
 
 const (
 	pluginName = "beacon"
+
+	// series not updated for this many intervals are removed
+	staleIntervals = 3
 )
 
 var (
@@ -24,9 +28,20 @@ var (
 )
 
 type Beacon struct {
-	cfg       *config.ExtensionConfig
-	client    *client.Client
-	monitored map[string]int
+	// extCfg is the extension config of cfg used to read the secrets
+	extCfg  *config.ExtensionConfig
+	cfg     *config.BeaconConfig
+	client  *client.Client
+	streams map[string]*containerStream
+	rules   []*rule
+	samples map[string]*types.StatsJSON
+	sinks   []Sink
+	alerter *alerter
+	lock    *sync.Mutex
+
+	interval    time.Duration
+	eventCounts map[string]int
+	eventsSince time.Time
 }
 
 func log() *logrus.Entry {
@@ -40,6 +55,11 @@ type eventArgs struct {
 }
 
 func NewBeacon(c *config.ExtensionConfig, cl *client.Client) (*Beacon, error) {
+	if c.Beacon == nil {
+		return nil, fmt.Errorf("missing beacon config")
+	}
+	bc := c.Beacon
+
 	// parse config base dir
 	c.ConfigBasePath = filepath.Dir(c.ConfigPath)
 
@@ -50,10 +70,38 @@ func NewBeacon(c *config.ExtensionConfig, cl *client.Client) (*Beacon, error) {
 		}
 	}()
 
+	rules, err := compileRules(bc.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rules) == 0 {
+		log().Warn("no rules configured; no containers will be monitored")
+	}
+
+	alerts, err := compileAlerts(bc.Alerts)
+	if err != nil {
+		return nil, err
+	}
+
+	sinks, err := newSinks(bc)
+	if err != nil {
+		return nil, err
+	}
+
 	ext := &Beacon{
-		cfg:       c,
-		client:    cl,
-		monitored: map[string]int{},
+		extCfg:  c,
+		cfg:     bc,
+		client:  cl,
+		streams: map[string]*containerStream{},
+		rules:   rules,
+		samples: map[string]*types.StatsJSON{},
+		sinks:   sinks,
+		alerter: newAlerter(alerts),
+		lock:    &sync.Mutex{},
+
+		eventCounts: map[string]int{},
+		eventsSince: time.Now(),
 	}
 
 	containerID, err := utils.GetContainerID()
@@ -62,17 +110,19 @@ func NewBeacon(c *config.ExtensionConfig, cl *client.Client) (*Beacon, error) {
 	}
 
 	// ticker to push to gateway if configured
-	d, err := time.ParseDuration(c.StatsInterval)
+	d, err := time.ParseDuration(bc.StatsInterval)
 	if err != nil {
 		return nil, fmt.Errorf("unable to parse stat interval: %s", err)
 	}
+	ext.interval = d
 	t := time.NewTicker(d)
 	go func() {
 		for range t.C {
 			log().Debug("stats ticker")
 			ext.collectStats()
+			ext.collectDaemonStats()
 
-			gw := c.StatsPrometheusPushGatewayURL
+			gw := bc.StatsPrometheusPushGatewayURL
 			if gw != "" {
 				job := "beacon"
 				groups := map[string]string{
@@ -88,10 +138,33 @@ func NewBeacon(c *config.ExtensionConfig, cl *client.Client) (*Beacon, error) {
 					counterTotalImages,
 					counterTotalVolumes,
 					counterTotalNetworks,
+					counterCollectorStreams,
+					counterCollectorLag,
+					counterCollectorDuration,
+					counterDaemonContainers,
+					counterDaemonStorage,
+					counterDaemonSwarmNodeState,
+					counterDaemonSwarmNodes,
+					counterDaemonSwarmManagers,
+					counterDaemonDiskUsage,
+					counterDaemonEventsRate,
+					counterDaemonAPIRequests,
+					counterDaemonAPIErrors,
+					counterDaemonAPILatency,
+					counterDaemonAPILatencyMax,
 					counterCpuTotalUsage,
+					counterCpuPercent,
+					counterCpuThrottledPeriods,
+					counterCpuThrottledTime,
 					counterMemoryUsage,
 					counterMemoryMaxUsage,
 					counterMemoryPercent,
+					counterMemoryWorkingSet,
+					counterBlkioReadBytes,
+					counterBlkioWriteBytes,
+					counterBlkioReadIOPS,
+					counterBlkioWriteIOPS,
+					counterPids,
 					counterNetworkRxBytes,
 					counterNetworkRxPackets,
 					counterNetworkRxErrors,
@@ -115,6 +188,8 @@ func (b *Beacon) Name() string {
 }
 
 func (b *Beacon) HandleEvent(event *events.Message) error {
+	b.countEvent(event)
+
 	switch event.Status {
 	case "interlock-start":
 		// scan all containers and start metrics
@@ -128,16 +203,21 @@ func (b *Beacon) HandleEvent(event *events.Message) error {
 		}
 
 		for _, c := range containers {
-			b.monitored[c.ID] = 1
+			if err := b.startStream(c.ID); err != nil {
+				log().Errorf("unable to start stats stream: id=%s err=%s", c.ID, err)
+			}
 		}
 
-		log().Debugf("containers: %v", b.monitored)
+		log().Debugf("monitored containers: %d", len(b.activeStreams()))
 	case "start":
 		log().Debugf("checking container for stats: id=%s", event.ID)
-		b.monitored[event.ID] = 1
+		if err := b.startStream(event.ID); err != nil {
+			return err
+		}
 	case "kill", "die", "stop", "destroy":
 		log().Debugf("resetting stats: id=%s", event.ID)
-		delete(b.monitored, event.ID)
+		b.stopStream(event.ID)
+		b.removeSample(event.ID)
 
 		if err := b.resetStats(event.ID); err != nil {
 			return err
@@ -0,0 +1,203 @@
+package beacon
+
+import (
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	units "github.com/docker/go-units"
+	"github.com/ehazlett/interlock/client"
+	"github.com/ehazlett/interlock/events"
+	"github.com/ehazlett/interlock/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+)
+
+var (
+	swarmNodeStates = []swarm.LocalNodeState{
+		swarm.LocalNodeStateInactive,
+		swarm.LocalNodeStatePending,
+		swarm.LocalNodeStateActive,
+		swarm.LocalNodeStateError,
+		swarm.LocalNodeStateLocked,
+	}
+)
+
+// countEvent counts the event for the events rate
+func (b *Beacon) countEvent(event *events.Message) {
+	t := event.Type
+	if t == "" {
+		// events generated by interlock (i.e. interlock-start)
+		t = "interlock"
+	}
+
+	b.lock.Lock()
+	b.eventCounts[t]++
+	b.lock.Unlock()
+}
+
+// takeEventCounts returns the events counted since the last call and the
+// time elapsed
+func (b *Beacon) takeEventCounts(now time.Time) (map[string]int, time.Duration) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	counts := b.eventCounts
+	b.eventCounts = map[string]int{}
+
+	elapsed := now.Sub(b.eventsSince)
+	b.eventsSince = now
+
+	return counts, elapsed
+}
+
+// collectDaemonStats reports the daemon info, disk usage, events rate and
+// the latency of the Docker API calls made by interlock
+func (b *Beacon) collectDaemonStats() {
+	info, err := b.client.Info(context.Background())
+	if err != nil {
+		log().Errorf("unable to get daemon info: %s", err)
+		metrics.APIError(pluginName)
+	} else {
+		setDaemonInfo(info)
+	}
+
+	du, err := b.client.DiskUsage(context.Background())
+	if err != nil {
+		log().Errorf("unable to get daemon disk usage: %s", err)
+		metrics.APIError(pluginName)
+	} else {
+		setDiskUsage(du)
+	}
+
+	setEventsRate(b.takeEventCounts(time.Now()))
+	setAPILatency(client.APILatency.Snapshot())
+}
+
+func setDaemonInfo(info types.Info) {
+	for state, v := range map[string]int{
+		"running": info.ContainersRunning,
+		"paused":  info.ContainersPaused,
+		"stopped": info.ContainersStopped,
+	} {
+		counterDaemonContainers.With(prometheus.Labels{
+			"type":  "daemon",
+			"state": state,
+		}).Set(float64(v))
+	}
+
+	for name, v := range parseDriverStatus(info.DriverStatus) {
+		counterDaemonStorage.With(prometheus.Labels{
+			"type":   "daemon",
+			"driver": info.Driver,
+			"name":   name,
+		}).Set(v)
+	}
+
+	for _, state := range swarmNodeStates {
+		v := 0.0
+		if info.Swarm.LocalNodeState == state {
+			v = 1
+		}
+
+		counterDaemonSwarmNodeState.With(prometheus.Labels{
+			"type":  "daemon",
+			"state": string(state),
+		}).Set(v)
+	}
+
+	// the cluster size is only known to managers
+	if info.Swarm.ControlAvailable {
+		counterDaemonSwarmNodes.With(prometheus.Labels{
+			"type": "daemon",
+		}).Set(float64(info.Swarm.Nodes))
+
+		counterDaemonSwarmManagers.With(prometheus.Labels{
+			"type": "daemon",
+		}).Set(float64(info.Swarm.Managers))
+	}
+}
+
+// parseDriverStatus returns the storage driver space entries in bytes
+// (i.e. "Data Space Used" of devicemapper as data_space_used)
+func parseDriverStatus(status [][2]string) map[string]float64 {
+	sizes := map[string]float64{}
+	for _, s := range status {
+		if !strings.Contains(s[0], "Space") {
+			continue
+		}
+
+		v, err := units.FromHumanSize(s[1])
+		if err != nil {
+			log().Debugf("unable to parse driver status: name=%q value=%q", s[0], s[1])
+			continue
+		}
+
+		name := strings.ToLower(strings.Join(strings.Fields(s[0]), "_"))
+		sizes[name] = float64(v)
+	}
+
+	return sizes
+}
+
+func setDiskUsage(du types.DiskUsage) {
+	var containers, volumes int64
+	for _, c := range du.Containers {
+		containers += c.SizeRw
+	}
+
+	for _, v := range du.Volumes {
+		// the size is -1 when it is not available
+		if v.UsageData != nil && v.UsageData.Size > 0 {
+			volumes += v.UsageData.Size
+		}
+	}
+
+	for object, v := range map[string]int64{
+		"images":     du.LayersSize,
+		"containers": containers,
+		"volumes":    volumes,
+	} {
+		counterDaemonDiskUsage.With(prometheus.Labels{
+			"type":   "daemon",
+			"object": object,
+		}).Set(float64(v))
+	}
+}
+
+func setEventsRate(counts map[string]int, elapsed time.Duration) {
+	counterDaemonEventsRate.Reset()
+
+	if elapsed <= 0 {
+		return
+	}
+
+	for t, n := range counts {
+		counterDaemonEventsRate.With(prometheus.Labels{
+			"type":       "daemon",
+			"event_type": t,
+		}).Set(float64(n) / elapsed.Seconds())
+	}
+}
+
+func setAPILatency(stats []client.LatencyStat) {
+	// only report the endpoints called in the last interval
+	counterDaemonAPIRequests.Reset()
+	counterDaemonAPIErrors.Reset()
+	counterDaemonAPILatency.Reset()
+	counterDaemonAPILatencyMax.Reset()
+
+	for _, s := range stats {
+		labels := prometheus.Labels{
+			"type":     "daemon",
+			"method":   s.Method,
+			"endpoint": s.Endpoint,
+		}
+
+		counterDaemonAPIRequests.With(labels).Set(float64(s.Count))
+		counterDaemonAPIErrors.With(labels).Set(float64(s.Errors))
+		counterDaemonAPILatency.With(labels).Set(s.Average().Seconds())
+		counterDaemonAPILatencyMax.With(labels).Set(s.Max.Seconds())
+	}
+}
@@ -0,0 +1,81 @@
+package beacon
+
+import (
+	"testing"
+	"time"
+
+	etypes "github.com/docker/docker/api/types/events"
+	"github.com/ehazlett/interlock/events"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestParseDriverStatus(t *testing.T) {
+	sizes := parseDriverStatus([][2]string{
+		{"Pool Name", "docker-pool"},
+		{"Pool Blocksize", "65.54 kB"},
+		{"Data Space Used", "1.5 GB"},
+		{"Metadata Space Available", "2 MB"},
+		{"Data Space Total", "unknown"},
+	})
+
+	expected := map[string]float64{
+		"data_space_used":          1500000000,
+		"metadata_space_available": 2000000,
+	}
+
+	if len(sizes) != len(expected) {
+		t.Fatalf("expected %d sizes; received %v", len(expected), sizes)
+	}
+
+	for k, v := range expected {
+		if sizes[k] != v {
+			t.Fatalf("expected %s=%g; received %g", k, v, sizes[k])
+		}
+	}
+}
+
+func TestCollectDaemonStats(t *testing.T) {
+	f := &fakeEngine{calls: map[string]int{}}
+	b, cleanup := testBeacon(t, f)
+	defer cleanup()
+
+	b.eventsSince = time.Now().Add(-time.Second * 10)
+	for i := 0; i < 20; i++ {
+		b.HandleEvent(&events.Message{Message: etypes.Message{Type: "network"}})
+	}
+
+	b.collectDaemonStats()
+
+	tests := []struct {
+		g      *prometheus.GaugeVec
+		labels prometheus.Labels
+		value  float64
+	}{
+		{counterDaemonContainers, prometheus.Labels{"type": "daemon", "state": "running"}, 3},
+		{counterDaemonContainers, prometheus.Labels{"type": "daemon", "state": "paused"}, 1},
+		{counterDaemonContainers, prometheus.Labels{"type": "daemon", "state": "stopped"}, 2},
+		{counterDaemonStorage, prometheus.Labels{"type": "daemon", "driver": "devicemapper", "name": "data_space_total"}, 10000000000},
+		{counterDaemonSwarmNodeState, prometheus.Labels{"type": "daemon", "state": "active"}, 1},
+		{counterDaemonSwarmNodeState, prometheus.Labels{"type": "daemon", "state": "inactive"}, 0},
+		{counterDaemonSwarmNodes, prometheus.Labels{"type": "daemon"}, 5},
+		{counterDaemonSwarmManagers, prometheus.Labels{"type": "daemon"}, 3},
+		{counterDaemonDiskUsage, prometheus.Labels{"type": "daemon", "object": "images"}, 1000},
+		{counterDaemonDiskUsage, prometheus.Labels{"type": "daemon", "object": "containers"}, 30},
+		{counterDaemonDiskUsage, prometheus.Labels{"type": "daemon", "object": "volumes"}, 100},
+	}
+
+	for _, test := range tests {
+		if v := gaugeValue(t, test.g, test.labels); v != test.value {
+			t.Fatalf("expected %v=%g; received %g", test.labels, test.value, v)
+		}
+	}
+
+	rate := gaugeValue(t, counterDaemonEventsRate, prometheus.Labels{"type": "daemon", "event_type": "network"})
+	if rate < 1.9 || rate > 2 {
+		t.Fatalf("expected events rate of ~2/s; received %g", rate)
+	}
+
+	if len(b.eventCounts) != 0 {
+		t.Fatal("expected event counts to be reset")
+	}
+}
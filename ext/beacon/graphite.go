@@ -0,0 +1,72 @@
+package beacon
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	graphiteTimeout = time.Second * 10
+)
+
+// graphiteSink sends the stats using the graphite plaintext protocol.
+// The connection is kept open between intervals and re-opened on error.
+type graphiteSink struct {
+	addr   string
+	prefix string
+	conn   net.Conn
+}
+
+func newGraphiteSink(addr, prefix string) (*graphiteSink, error) {
+	if addr == "" {
+		return nil, fmt.Errorf("graphite address is required")
+	}
+
+	return &graphiteSink{
+		addr:   addr,
+		prefix: prefix,
+	}, nil
+}
+
+func (s *graphiteSink) Name() string {
+	return "graphite"
+}
+
+func (s *graphiteSink) Send(stats []Stat) error {
+	if s.conn == nil {
+		conn, err := net.DialTimeout("tcp", s.addr, graphiteTimeout)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	s.conn.SetWriteDeadline(time.Now().Add(graphiteTimeout))
+	w := bufio.NewWriter(s.conn)
+	for _, m := range batchMetrics(stats) {
+		fmt.Fprintf(w, "%s %s %s\n", metricPath(s.prefix, m), strconv.FormatFloat(m.Value, 'f', -1, 64), timestamp)
+	}
+
+	if err := w.Flush(); err != nil {
+		s.Close()
+		return err
+	}
+
+	return nil
+}
+
+func (s *graphiteSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn = nil
+
+	return err
+}
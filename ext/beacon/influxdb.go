@@ -3,6 +3,7 @@ package beacon
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/ehazlett/interlock/config"
 	influx "github.com/influxdata/influxdb/client/v2"
@@ -26,7 +27,7 @@ func queryDB(clnt influx.Client, db, cmd string) (res []influx.Result, err error
 	return res, nil
 }
 
-func NewInfluxDBClient(cfg *config.ExtensionConfig) (influx.Client, error) {
+func NewInfluxDBClient(cfg *config.BeaconConfig) (influx.Client, error) {
 	c, err := influx.NewHTTPClient(
 		influx.HTTPConfig{
 			Addr:     cfg.StatsInfluxDBAddress,
@@ -38,20 +39,109 @@ func NewInfluxDBClient(cfg *config.ExtensionConfig) (influx.Client, error) {
 		return nil, err
 	}
 
-	// check db; create if needed
-	dbName := cfg.StatsInfluxDBDatabase
+	return c, nil
+}
+
+// ensureInfluxDBDatabase creates the stats database if needed
+func ensureInfluxDBDatabase(c influx.Client, dbName string) error {
 	if _, err := queryDB(c, dbName, "SELECT * from cpu_usage limit 1"); err != nil {
 		log().Debugf("error checking influx database: %q", err.Error())
 		if strings.Index(err.Error(), "database not found") != -1 {
 			// create database if needed
 			log().Infof("creating stats influx database: %s", dbName)
 			if _, err := queryDB(c, dbName, fmt.Sprintf("CREATE DATABASE %s", dbName)); err != nil {
-				return nil, err
+				return err
 			}
 		} else {
-			return nil, err
+			return err
 		}
 	}
 
-	return c, nil
+	return nil
+}
+
+// influxDBSink writes the stats of each interval as a single batch
+// using a shared client
+type influxDBSink struct {
+	cfg    *config.BeaconConfig
+	client influx.Client
+	ready  bool
+	// credentials of the client
+	user     string
+	password string
+}
+
+func newInfluxDBSink(cfg *config.BeaconConfig) (*influxDBSink, error) {
+	s := &influxDBSink{
+		cfg: cfg,
+	}
+
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// connect creates the client with the current credentials
+func (s *influxDBSink) connect() error {
+	c, err := NewInfluxDBClient(s.cfg)
+	if err != nil {
+		return err
+	}
+
+	if s.client != nil {
+		s.client.Close()
+	}
+
+	s.client = c
+	s.user = s.cfg.StatsInfluxDBUser
+	s.password = s.cfg.StatsInfluxDBPassword
+
+	return nil
+}
+
+func (s *influxDBSink) Name() string {
+	return "influxdb"
+}
+
+func (s *influxDBSink) Send(stats []Stat) error {
+	// the client is created again when the credentials are rotated
+	if s.cfg.StatsInfluxDBUser != s.user || s.cfg.StatsInfluxDBPassword != s.password {
+		log().Info("influxdb credentials changed; reconnecting")
+		if err := s.connect(); err != nil {
+			return err
+		}
+	}
+	// the database is only checked until it exists
+	if !s.ready {
+		if err := ensureInfluxDBDatabase(s.client, s.cfg.StatsInfluxDBDatabase); err != nil {
+			return err
+		}
+		s.ready = true
+	}
+
+	bp, err := influx.NewBatchPoints(influx.BatchPointsConfig{
+		Database:  s.cfg.StatsInfluxDBDatabase,
+		Precision: s.cfg.StatsInfluxDBPrecision,
+	})
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Now()
+	for _, stat := range stats {
+		points, err := influxDBPoints(stat, timestamp)
+		if err != nil {
+			return err
+		}
+
+		bp.AddPoints(points)
+	}
+
+	return s.client.Write(bp)
+}
+
+func (s *influxDBSink) Close() error {
+	return s.client.Close()
 }
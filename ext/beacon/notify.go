@@ -0,0 +1,147 @@
+package beacon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	notifyTimeout = time.Second * 10
+)
+
+var (
+	notifyClient = &http.Client{
+		Timeout: notifyTimeout,
+	}
+)
+
+type genericPayload struct {
+	Alert     string            `json:"alert"`
+	Status    string            `json:"status"`
+	Metric    string            `json:"metric"`
+	Operator  string            `json:"operator"`
+	Threshold float64           `json:"threshold"`
+	Value     float64           `json:"value"`
+	Tags      map[string]string `json:"tags"`
+	StartsAt  time.Time         `json:"startsAt"`
+	EndsAt    *time.Time        `json:"endsAt,omitempty"`
+}
+
+type slackPayload struct {
+	Text string `json:"text"`
+}
+
+type alertmanagerAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      *time.Time        `json:"endsAt,omitempty"`
+}
+
+// summary returns a human readable description of the notification
+func (n *notification) summary() string {
+	target := n.Tags["name"]
+	if image, ok := n.Tags["image"]; ok && image != "" {
+		target += " (" + image + ")"
+	}
+	if network, ok := n.Tags["network"]; ok && network != "" {
+		target += " on " + network
+	}
+
+	return fmt.Sprintf("[%s] %s: %s %g %s %g for %s", strings.ToUpper(n.Status), n.Alert, n.Metric, n.Value, n.Operator, n.Threshold, target)
+}
+
+// payload returns the request body for the notification format
+func (n *notification) payload() interface{} {
+	var endsAt *time.Time
+	if !n.EndsAt.IsZero() {
+		endsAt = &n.EndsAt
+	}
+
+	switch n.Format {
+	case alertFormatSlack:
+		return &slackPayload{
+			Text: n.summary(),
+		}
+	case alertFormatAlertmanager:
+		labels := map[string]string{
+			"alertname": n.Alert,
+			"metric":    n.Metric,
+			"source":    pluginName,
+		}
+		for k, v := range n.Tags {
+			labels[k] = v
+		}
+
+		return []*alertmanagerAlert{
+			{
+				Labels: labels,
+				Annotations: map[string]string{
+					"summary": n.summary(),
+					"value":   fmt.Sprintf("%g", n.Value),
+				},
+				StartsAt: n.StartsAt,
+				EndsAt:   endsAt,
+			},
+		}
+	}
+
+	return &genericPayload{
+		Alert:     n.Alert,
+		Status:    n.Status,
+		Metric:    n.Metric,
+		Operator:  n.Operator,
+		Threshold: n.Threshold,
+		Value:     n.Value,
+		Tags:      n.Tags,
+		StartsAt:  n.StartsAt,
+		EndsAt:    endsAt,
+	}
+}
+
+// notify posts the notification to the alert webhook
+func notify(n *notification) error {
+	data, err := json.Marshal(n.payload())
+	if err != nil {
+		return err
+	}
+
+	resp, err := notifyClient.Post(n.Webhook, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response from webhook: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// sendNotifications sends the notifications in the background so a slow
+// webhook does not delay the collection
+func sendNotifications(notifications []*notification) {
+	for _, n := range notifications {
+		// the alerts resent on each collection are only logged when debugging
+		if n.Resend {
+			log().Debugf("alert %s (resend): name=%s container=%s value=%g", n.Status, n.Alert, n.Tags["container"], n.Value)
+		} else {
+			log().Infof("alert %s: name=%s container=%s value=%g", n.Status, n.Alert, n.Tags["container"], n.Value)
+		}
+
+		go func(n *notification) {
+			if err := notify(n); err != nil {
+				log().Errorf("unable to send alert notification: alert=%s err=%s", n.Alert, err)
+			}
+		}(n)
+	}
+}
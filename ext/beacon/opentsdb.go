@@ -0,0 +1,115 @@
+package beacon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	openTSDBBatchSize = 50
+	openTSDBTimeout   = time.Second * 10
+)
+
+var (
+	openTSDBTagRegex = regexp.MustCompile(`[^A-Za-z0-9\-_./]`)
+)
+
+type openTSDBPoint struct {
+	Metric    string            `json:"metric"`
+	Timestamp int64             `json:"timestamp"`
+	Value     float64           `json:"value"`
+	Tags      map[string]string `json:"tags"`
+}
+
+// openTSDBSink sends the stats to the OpenTSDB HTTP API
+type openTSDBSink struct {
+	url    string
+	prefix string
+	client *http.Client
+}
+
+func newOpenTSDBSink(addr, prefix string) (*openTSDBSink, error) {
+	if addr == "" {
+		return nil, fmt.Errorf("opentsdb address is required")
+	}
+
+	return &openTSDBSink{
+		url:    strings.TrimSuffix(addr, "/") + "/api/put",
+		prefix: prefix,
+		client: &http.Client{
+			Timeout: openTSDBTimeout,
+		},
+	}, nil
+}
+
+func (s *openTSDBSink) Name() string {
+	return "opentsdb"
+}
+
+func (s *openTSDBSink) Send(stats []Stat) error {
+	timestamp := time.Now().Unix()
+
+	points := []openTSDBPoint{}
+	for _, m := range batchMetrics(stats) {
+		tags := map[string]string{}
+		for k, v := range m.Tags {
+			if v = openTSDBTagRegex.ReplaceAllString(v, "_"); v != "" {
+				tags[k] = v
+			}
+		}
+
+		points = append(points, openTSDBPoint{
+			Metric:    metricName(s.prefix, m),
+			Timestamp: timestamp,
+			Value:     m.Value,
+			Tags:      tags,
+		})
+	}
+
+	for len(points) > 0 {
+		n := openTSDBBatchSize
+		if len(points) < n {
+			n = len(points)
+		}
+
+		if err := s.put(points[:n]); err != nil {
+			return err
+		}
+		points = points[n:]
+	}
+
+	return nil
+}
+
+func (s *openTSDBSink) put(points []openTSDBPoint) error {
+	data, err := json.Marshal(points)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// drain the body to reuse the connection
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response from opentsdb: %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (s *openTSDBSink) Close() error {
+	return nil
+}
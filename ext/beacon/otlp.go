@@ -0,0 +1,137 @@
+package beacon
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	otlpTimeout = time.Second * 10
+)
+
+// otlpSink sends the stats as OTLP gauges over HTTP using the protobuf
+// encoding
+type otlpSink struct {
+	endpoint string
+	prefix   string
+	client   *http.Client
+}
+
+func newOTLPSink(endpoint, prefix string) (*otlpSink, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("otlp endpoint is required")
+	}
+
+	return &otlpSink{
+		endpoint: endpoint,
+		prefix:   prefix,
+		client: &http.Client{
+			Timeout: otlpTimeout,
+		},
+	}, nil
+}
+
+func (s *otlpSink) Name() string {
+	return "otlp"
+}
+
+// request groups the data points by metric name
+func (s *otlpSink) request(stats []Stat, timestamp time.Time) *collectorpb.ExportMetricsServiceRequest {
+	ts := uint64(timestamp.UnixNano())
+
+	metrics := []*metricspb.Metric{}
+	points := map[string]*metricspb.Gauge{}
+	for _, m := range batchMetrics(stats) {
+		name := metricName(s.prefix, m)
+		gauge, ok := points[name]
+		if !ok {
+			gauge = &metricspb.Gauge{}
+			points[name] = gauge
+			metrics = append(metrics, &metricspb.Metric{
+				Name: name,
+				Data: &metricspb.Metric_Gauge{Gauge: gauge},
+			})
+		}
+
+		keys := []string{}
+		for k := range m.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		attrs := []*commonpb.KeyValue{}
+		for _, k := range keys {
+			attrs = append(attrs, stringAttribute(k, m.Tags[k]))
+		}
+
+		gauge.DataPoints = append(gauge.DataPoints, &metricspb.NumberDataPoint{
+			Attributes:   attrs,
+			TimeUnixNano: ts,
+			Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: m.Value},
+		})
+	}
+
+	return &collectorpb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{
+			{
+				Resource: &resourcepb.Resource{
+					Attributes: []*commonpb.KeyValue{
+						stringAttribute("service.name", pluginName),
+					},
+				},
+				ScopeMetrics: []*metricspb.ScopeMetrics{
+					{
+						Scope:   &commonpb.InstrumentationScope{Name: pluginName},
+						Metrics: metrics,
+					},
+				},
+			},
+		},
+	}
+}
+
+func stringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key: key,
+		Value: &commonpb.AnyValue{
+			Value: &commonpb.AnyValue_StringValue{StringValue: value},
+		},
+	}
+}
+
+func (s *otlpSink) Send(stats []Stat) error {
+	data, err := proto.Marshal(s.request(stats, time.Now()))
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Post(s.endpoint, "application/x-protobuf", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// drain the body to reuse the connection
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response from otlp endpoint: %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (s *otlpSink) Close() error {
+	return nil
+}
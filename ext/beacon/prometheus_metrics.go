@@ -49,6 +49,174 @@ var (
 			"type",
 		},
 	)
+	counterCollectorStreams = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "beacon",
+			Subsystem: "collector",
+			Name:      "streams",
+			Help:      "Number of containers with an open stats stream",
+		},
+		[]string{
+			"type",
+		},
+	)
+	counterCollectorLag = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "beacon",
+			Subsystem: "collector",
+			Name:      "lag_seconds",
+			Help:      "Age of the oldest sample reported in the last interval",
+		},
+		[]string{
+			"type",
+		},
+	)
+	counterCollectorDuration = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "beacon",
+			Subsystem: "collector",
+			Name:      "duration_seconds",
+			Help:      "Duration of the last collection in seconds",
+		},
+		[]string{
+			"type",
+		},
+	)
+	counterDaemonContainers = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "beacon",
+			Subsystem: "daemon",
+			Name:      "containers",
+			Help:      "Number of containers by state",
+		},
+		[]string{
+			"state",
+			"type",
+		},
+	)
+	counterDaemonStorage = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "beacon",
+			Subsystem: "daemon",
+			Name:      "storage_bytes",
+			Help:      "Storage driver space reported by the daemon",
+		},
+		[]string{
+			"driver",
+			"name",
+			"type",
+		},
+	)
+	counterDaemonSwarmNodeState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "beacon",
+			Subsystem: "daemon",
+			Name:      "swarm_node_state",
+			Help:      "Swarm state of the node (1 for the current state)",
+		},
+		[]string{
+			"state",
+			"type",
+		},
+	)
+	counterDaemonSwarmNodes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "beacon",
+			Subsystem: "daemon",
+			Name:      "swarm_nodes",
+			Help:      "Number of nodes in the swarm",
+		},
+		[]string{
+			"type",
+		},
+	)
+	counterDaemonSwarmManagers = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "beacon",
+			Subsystem: "daemon",
+			Name:      "swarm_managers",
+			Help:      "Number of managers in the swarm",
+		},
+		[]string{
+			"type",
+		},
+	)
+	counterDaemonDiskUsage = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "beacon",
+			Subsystem: "daemon",
+			Name:      "disk_usage_bytes",
+			Help:      "Disk usage of the daemon by object type",
+		},
+		[]string{
+			"object",
+			"type",
+		},
+	)
+	counterDaemonEventsRate = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "beacon",
+			Subsystem: "daemon",
+			Name:      "events_per_second",
+			Help:      "Rate of events received by interlock",
+		},
+		[]string{
+			"event_type",
+			"type",
+		},
+	)
+	counterDaemonAPIRequests = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "beacon",
+			Subsystem: "daemon",
+			Name:      "api_requests",
+			Help:      "Docker API requests made by interlock in the last interval",
+		},
+		[]string{
+			"endpoint",
+			"method",
+			"type",
+		},
+	)
+	counterDaemonAPIErrors = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "beacon",
+			Subsystem: "daemon",
+			Name:      "api_errors",
+			Help:      "Failed Docker API requests made by interlock in the last interval",
+		},
+		[]string{
+			"endpoint",
+			"method",
+			"type",
+		},
+	)
+	counterDaemonAPILatency = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "beacon",
+			Subsystem: "daemon",
+			Name:      "api_latency_seconds",
+			Help:      "Average Docker API latency in the last interval",
+		},
+		[]string{
+			"endpoint",
+			"method",
+			"type",
+		},
+	)
+	counterDaemonAPILatencyMax = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "beacon",
+			Subsystem: "daemon",
+			Name:      "api_latency_max_seconds",
+			Help:      "Maximum Docker API latency in the last interval",
+		},
+		[]string{
+			"endpoint",
+			"method",
+			"type",
+		},
+	)
 	counterCpuTotalUsage = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: "beacon",
@@ -63,6 +231,48 @@ var (
 			"type",
 		},
 	)
+	counterCpuPercent = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "beacon",
+			Subsystem: "docker",
+			Name:      "cpu_usage_percent",
+			Help:      "CPU usage in percent of a single CPU",
+		},
+		[]string{
+			"container",
+			"image",
+			"name",
+			"type",
+		},
+	)
+	counterCpuThrottledPeriods = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "beacon",
+			Subsystem: "docker",
+			Name:      "cpu_throttled_periods",
+			Help:      "Number of periods the container was throttled",
+		},
+		[]string{
+			"container",
+			"image",
+			"name",
+			"type",
+		},
+	)
+	counterCpuThrottledTime = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "beacon",
+			Subsystem: "docker",
+			Name:      "cpu_throttled_time_nanoseconds",
+			Help:      "Total time the container was throttled in nanoseconds",
+		},
+		[]string{
+			"container",
+			"image",
+			"name",
+			"type",
+		},
+	)
 	counterMemoryUsage = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: "beacon",
@@ -105,6 +315,90 @@ var (
 			"type",
 		},
 	)
+	counterMemoryWorkingSet = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "beacon",
+			Subsystem: "docker",
+			Name:      "memory_working_set_bytes",
+			Help:      "Memory used without reclaimable cache in bytes",
+		},
+		[]string{
+			"container",
+			"image",
+			"name",
+			"type",
+		},
+	)
+	counterBlkioReadBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "beacon",
+			Subsystem: "docker",
+			Name:      "blkio_read_bytes",
+			Help:      "Block IO read in bytes",
+		},
+		[]string{
+			"container",
+			"image",
+			"name",
+			"type",
+		},
+	)
+	counterBlkioWriteBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "beacon",
+			Subsystem: "docker",
+			Name:      "blkio_write_bytes",
+			Help:      "Block IO written in bytes",
+		},
+		[]string{
+			"container",
+			"image",
+			"name",
+			"type",
+		},
+	)
+	counterBlkioReadIOPS = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "beacon",
+			Subsystem: "docker",
+			Name:      "blkio_read_iops",
+			Help:      "Block IO read operations per second",
+		},
+		[]string{
+			"container",
+			"image",
+			"name",
+			"type",
+		},
+	)
+	counterBlkioWriteIOPS = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "beacon",
+			Subsystem: "docker",
+			Name:      "blkio_write_iops",
+			Help:      "Block IO write operations per second",
+		},
+		[]string{
+			"container",
+			"image",
+			"name",
+			"type",
+		},
+	)
+	counterPids = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "beacon",
+			Subsystem: "docker",
+			Name:      "pids",
+			Help:      "Number of processes",
+		},
+		[]string{
+			"container",
+			"image",
+			"name",
+			"type",
+		},
+	)
 	counterNetworkRxBytes = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: "beacon",
@@ -231,10 +525,33 @@ var (
 		counterTotalImages,
 		counterTotalVolumes,
 		counterTotalNetworks,
+		counterCollectorStreams,
+		counterCollectorLag,
+		counterCollectorDuration,
+		counterDaemonContainers,
+		counterDaemonStorage,
+		counterDaemonSwarmNodeState,
+		counterDaemonSwarmNodes,
+		counterDaemonSwarmManagers,
+		counterDaemonDiskUsage,
+		counterDaemonEventsRate,
+		counterDaemonAPIRequests,
+		counterDaemonAPIErrors,
+		counterDaemonAPILatency,
+		counterDaemonAPILatencyMax,
 		counterCpuTotalUsage,
+		counterCpuPercent,
+		counterCpuThrottledPeriods,
+		counterCpuThrottledTime,
 		counterMemoryUsage,
 		counterMemoryMaxUsage,
 		counterMemoryPercent,
+		counterMemoryWorkingSet,
+		counterBlkioReadBytes,
+		counterBlkioWriteBytes,
+		counterBlkioReadIOPS,
+		counterBlkioWriteIOPS,
+		counterPids,
 		counterNetworkRxBytes,
 		counterNetworkRxPackets,
 		counterNetworkRxErrors,
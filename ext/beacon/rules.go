@@ -1,62 +1,144 @@
 package beacon
 
 import (
+	"fmt"
 	"regexp"
+	"strings"
 
-	ctypes "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types"
+	"github.com/ehazlett/interlock/config"
 )
 
-func (b *Beacon) ruleMatch(cfg *ctypes.Config) bool {
-	// iterate through rules and check type / match
-	isMatch := false
-
-	for _, rule := range b.cfg.Rules {
-		switch rule.Type {
-		case "label":
-			// TODO: match label
-			m := b.isLabelMatch(rule.Regex, cfg)
-			if m {
-				isMatch = m
-				break
-			}
-		case "name":
-			// TODO: match name
-			m := b.isNameMatch(rule.Regex, cfg)
-			if m {
-				isMatch = m
-				break
+const (
+	composeProjectLabel = "com.docker.compose.project"
+	composeServiceLabel = "com.docker.compose.service"
+)
+
+// rule is a compiled config.Rule
+type rule struct {
+	name     string
+	ruleType string
+	key      *regexp.Regexp
+	regex    *regexp.Regexp
+	exclude  bool
+}
+
+func compileRules(rules map[string]*config.Rule) ([]*rule, error) {
+	compiled := []*rule{}
+
+	for name, r := range rules {
+		switch r.Type {
+		case "label", "name", "image", "compose_project", "compose_service":
+		default:
+			return nil, fmt.Errorf("unknown rule type for rule %s: %s", name, r.Type)
+		}
+
+		regex, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex for rule %s: %s", name, err)
+		}
+
+		c := &rule{
+			name:     name,
+			ruleType: r.Type,
+			regex:    regex,
+			exclude:  r.Exclude,
+		}
+
+		if r.Key != "" {
+			if r.Type != "label" {
+				return nil, fmt.Errorf("key is only supported for label rules: rule=%s", name)
 			}
-		case "image":
-			// TODO: match name
-			m := b.isImageMatch(rule.Regex, cfg)
-			if m {
-				isMatch = m
-				break
+
+			key, err := regexp.Compile(r.Key)
+			if err != nil {
+				return nil, fmt.Errorf("invalid key regex for rule %s: %s", name, err)
 			}
-		default:
-			log().Errorf("unknown rule type: %s", rule.Type)
+			c.key = key
 		}
+
+		compiled = append(compiled, c)
 	}
 
-	return isMatch
+	return compiled, nil
 }
 
-func (b *Beacon) isLabelMatch(rule string, cfg *ctypes.Config) bool {
-	log().Warnf("isLabelMatch not implemented")
+// match returns true if the container matches the rule
+func (r *rule) match(c types.ContainerJSON) bool {
+	labels := map[string]string{}
+	image := ""
+	if c.Config != nil {
+		labels = c.Config.Labels
+		image = c.Config.Image
+	}
+
+	switch r.ruleType {
+	case "label":
+		return r.isLabelMatch(labels)
+	case "name":
+		return r.regex.MatchString(strings.TrimPrefix(c.Name, "/"))
+	case "image":
+		return r.regex.MatchString(image)
+	case "compose_project":
+		v, ok := labels[composeProjectLabel]
+		return ok && r.regex.MatchString(v)
+	case "compose_service":
+		v, ok := labels[composeServiceLabel]
+		return ok && r.regex.MatchString(v)
+	}
+
 	return false
 }
 
-func (b *Beacon) isNameMatch(rule string, cfg *ctypes.Config) bool {
-	log().Warnf("isNameMatch not implemented")
+// isLabelMatch matches the label value for keys matching the key regex.  If
+// no key is specified the regex is matched against key=value.
+func (r *rule) isLabelMatch(labels map[string]string) bool {
+	for k, v := range labels {
+		if r.key == nil {
+			if r.regex.MatchString(k + "=" + v) {
+				return true
+			}
+			continue
+		}
+
+		if r.key.MatchString(k) && r.regex.MatchString(v) {
+			return true
+		}
+	}
+
 	return false
 }
 
-func (b *Beacon) isImageMatch(rule string, cfg *ctypes.Config) bool {
-	image := cfg.Image
+// ruleMatch returns true if the container should be monitored.  Containers
+// are monitored if they match any include rule (or there are only exclude
+// rules) and do not match any exclude rule.  No container is monitored
+// without rules.
+func (b *Beacon) ruleMatch(c types.ContainerJSON) bool {
+	return matchRules(b.rules, c)
+}
+
+func matchRules(rules []*rule, c types.ContainerJSON) bool {
+	if len(rules) == 0 {
+		return false
+	}
+
+	hasIncludes := false
+	included := false
 
-	r := regexp.MustCompile(rule)
+	for _, r := range rules {
+		if r.exclude {
+			if r.match(c) {
+				log().Debugf("container excluded by rule: id=%s rule=%s", c.ID, r.name)
+				return false
+			}
+			continue
+		}
 
-	m := r.MatchString(image)
+		hasIncludes = true
+		if !included && r.match(c) {
+			included = true
+		}
+	}
 
-	return m
+	return included || !hasIncludes
 }
@@ -0,0 +1,162 @@
+package beacon
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	ctypes "github.com/docker/docker/api/types/container"
+	"github.com/ehazlett/interlock/config"
+)
+
+func testContainer(name, image string, labels map[string]string) types.ContainerJSON {
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:   "abcdef",
+			Name: "/" + name,
+		},
+		Config: &ctypes.Config{
+			Image:  image,
+			Labels: labels,
+		},
+	}
+}
+
+func TestRuleMatch(t *testing.T) {
+	web := testContainer("web_1", "nginx:latest", map[string]string{
+		"com.example.tier":  "frontend",
+		composeProjectLabel: "shop",
+		composeServiceLabel: "web",
+	})
+
+	tests := []struct {
+		name     string
+		rule     config.Rule
+		expected bool
+	}{
+		{"image", config.Rule{Type: "image", Regex: "^nginx"}, true},
+		{"image no match", config.Rule{Type: "image", Regex: "^redis"}, false},
+		{"name", config.Rule{Type: "name", Regex: "^web_"}, true},
+		{"name no match", config.Rule{Type: "name", Regex: "^db_"}, false},
+		{"label key value", config.Rule{Type: "label", Key: "^com.example.tier$", Regex: "front"}, true},
+		{"label key no match", config.Rule{Type: "label", Key: "^com.example.env$", Regex: "front"}, false},
+		{"label value no match", config.Rule{Type: "label", Key: "^com.example.tier$", Regex: "back"}, false},
+		{"label pair", config.Rule{Type: "label", Regex: "^com.example.tier=frontend$"}, true},
+		{"compose project", config.Rule{Type: "compose_project", Regex: "^shop$"}, true},
+		{"compose project no match", config.Rule{Type: "compose_project", Regex: "^blog$"}, false},
+		{"compose service", config.Rule{Type: "compose_service", Regex: "^web$"}, true},
+		{"compose service no match", config.Rule{Type: "compose_service", Regex: "^db$"}, false},
+	}
+
+	for _, test := range tests {
+		rules, err := compileRules(map[string]*config.Rule{
+			test.name: &test.rule,
+		})
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		if m := rules[0].match(web); m != test.expected {
+			t.Fatalf("%s: expected match %v; received %v", test.name, test.expected, m)
+		}
+	}
+}
+
+func TestRuleMatchNoComposeLabels(t *testing.T) {
+	cnt := testContainer("web_1", "nginx:latest", map[string]string{})
+
+	rules, err := compileRules(map[string]*config.Rule{
+		"project": &config.Rule{Type: "compose_project", Regex: ".*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rules[0].match(cnt) {
+		t.Fatal("expected no match for container without compose labels")
+	}
+}
+
+func TestMatchRules(t *testing.T) {
+	web := testContainer("web_1", "nginx:latest", map[string]string{})
+	db := testContainer("db_1", "postgres:9", map[string]string{})
+	debug := testContainer("debug_1", "nginx:debug", map[string]string{})
+
+	tests := []struct {
+		name     string
+		rules    map[string]*config.Rule
+		expected map[string]bool
+	}{
+		{
+			name:  "no rules",
+			rules: map[string]*config.Rule{},
+			expected: map[string]bool{
+				"web_1":   false,
+				"db_1":    false,
+				"debug_1": false,
+			},
+		},
+		{
+			name: "include",
+			rules: map[string]*config.Rule{
+				"nginx": &config.Rule{Type: "image", Regex: "^nginx"},
+			},
+			expected: map[string]bool{
+				"web_1":   true,
+				"db_1":    false,
+				"debug_1": true,
+			},
+		},
+		{
+			name: "exclude",
+			rules: map[string]*config.Rule{
+				"db": &config.Rule{Type: "name", Regex: "^db_", Exclude: true},
+			},
+			expected: map[string]bool{
+				"web_1":   true,
+				"db_1":    false,
+				"debug_1": true,
+			},
+		},
+		{
+			name: "include and exclude",
+			rules: map[string]*config.Rule{
+				"nginx": &config.Rule{Type: "image", Regex: "^nginx"},
+				"debug": &config.Rule{Type: "image", Regex: ":debug$", Exclude: true},
+			},
+			expected: map[string]bool{
+				"web_1":   true,
+				"db_1":    false,
+				"debug_1": false,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		rules, err := compileRules(test.rules)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		for _, c := range []types.ContainerJSON{web, db, debug} {
+			name := c.Name[1:]
+			if m := matchRules(rules, c); m != test.expected[name] {
+				t.Fatalf("%s: expected match %v for %s; received %v", test.name, test.expected[name], name, m)
+			}
+		}
+	}
+}
+
+func TestCompileRulesInvalid(t *testing.T) {
+	tests := map[string]*config.Rule{
+		"unknown type":  &config.Rule{Type: "foo", Regex: ".*"},
+		"invalid regex": &config.Rule{Type: "image", Regex: "(nginx"},
+		"invalid key":   &config.Rule{Type: "label", Key: "(foo", Regex: ".*"},
+		"key for image": &config.Rule{Type: "image", Key: "foo", Regex: ".*"},
+	}
+
+	for name, r := range tests {
+		if _, err := compileRules(map[string]*config.Rule{name: r}); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package beacon
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	series = newContainerSeries()
+)
+
+// containerSeries tracks the prometheus series set for each container so
+// they can be removed without resetting the series of other containers
+type containerSeries struct {
+	lock     sync.Mutex
+	series   map[string]map[*prometheus.GaugeVec]map[string]prometheus.Labels
+	lastSeen map[string]time.Time
+}
+
+func newContainerSeries() *containerSeries {
+	return &containerSeries{
+		series:   map[string]map[*prometheus.GaugeVec]map[string]prometheus.Labels{},
+		lastSeen: map[string]time.Time{},
+	}
+}
+
+// set sets the gauge and records the series for the container label
+func (c *containerSeries) set(g *prometheus.GaugeVec, labels prometheus.Labels, value float64) {
+	g.With(labels).Set(value)
+
+	id := labels["container"]
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.series[id]; !ok {
+		c.series[id] = map[*prometheus.GaugeVec]map[string]prometheus.Labels{}
+	}
+
+	if _, ok := c.series[id][g]; !ok {
+		c.series[id][g] = map[string]prometheus.Labels{}
+	}
+
+	c.series[id][g][seriesKey(labels)] = labels
+	c.lastSeen[id] = time.Now()
+}
+
+// remove deletes all series of the container
+func (c *containerSeries) remove(id string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.removeLocked(id)
+}
+
+func (c *containerSeries) removeLocked(id string) {
+	for g, labels := range c.series[id] {
+		for _, l := range labels {
+			g.Delete(l)
+		}
+	}
+
+	delete(c.series, id)
+	delete(c.lastSeen, id)
+}
+
+// expire deletes the series of containers which have not been updated
+// since the given time and returns their ids
+func (c *containerSeries) expire(before time.Time) []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	expired := []string{}
+	for id, seen := range c.lastSeen {
+		if seen.Before(before) {
+			c.removeLocked(id)
+			expired = append(expired, id)
+		}
+	}
+
+	return expired
+}
+
+// seriesKey returns a unique key for the label set
+func seriesKey(labels prometheus.Labels) string {
+	// the label names are fixed per vector so only the values are needed
+	return labels["container"] + "\xff" + labels["image"] + "\xff" + labels["name"] + "\xff" + labels["network"] + "\xff" + labels["type"]
+}
@@ -0,0 +1,115 @@
+package beacon
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// seriesContainers returns the container label values of the vector
+func seriesContainers(t *testing.T, g *prometheus.GaugeVec) map[string]int {
+	ch := make(chan prometheus.Metric, 1024)
+	g.Collect(ch)
+	close(ch)
+
+	containers := map[string]int{}
+	for m := range ch {
+		pb := &dto.Metric{}
+		if err := m.Write(pb); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, l := range pb.GetLabel() {
+			if l.GetName() == "container" {
+				containers[l.GetValue()]++
+			}
+		}
+	}
+
+	return containers
+}
+
+func testContainerStat(id, name string) Stat {
+	stat := testStat()
+	stat.ID = id
+	stat.Name = name
+	stat.Networks = map[string]types.NetworkStats{
+		"eth0": {RxBytes: 100},
+		"eth1": {RxBytes: 200},
+	}
+
+	return stat
+}
+
+func TestResetStatsKeepsOtherContainers(t *testing.T) {
+	b := &Beacon{
+		samples: map[string]*types.StatsJSON{},
+		lock:    &sync.Mutex{},
+	}
+
+	sendPrometheus(testContainerStat("aaaaaaaaaaaa", "a"))
+	sendPrometheus(testContainerStat("bbbbbbbbbbbb", "b"))
+
+	if err := b.resetStats("aaaaaaaaaaaaffffffff"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, g := range []*prometheus.GaugeVec{counterCpuTotalUsage, counterMemoryUsage, counterPids, counterNetworkRxBytes} {
+		containers := seriesContainers(t, g)
+		if _, ok := containers["aaaaaaaaaaaa"]; ok {
+			t.Fatal("expected series of removed container to be deleted")
+		}
+
+		if _, ok := containers["bbbbbbbbbbbb"]; !ok {
+			t.Fatal("expected series of other container to be kept")
+		}
+	}
+
+	if n := seriesContainers(t, counterNetworkRxBytes)["bbbbbbbbbbbb"]; n != 2 {
+		t.Fatalf("expected 2 network series; received %d", n)
+	}
+
+	// totals are not per container
+	sendPrometheus(testContainerStat("cccccccccccc", "c"))
+	b.resetStats("cccccccccccc")
+
+	ch := make(chan prometheus.Metric, 16)
+	counterTotalContainers.Collect(ch)
+	close(ch)
+	if len(ch) == 0 {
+		t.Fatal("expected totals to be kept")
+	}
+
+	series.remove("bbbbbbbbbbbb")
+}
+
+func TestContainerSeriesExpire(t *testing.T) {
+	sendPrometheus(testContainerStat("dddddddddddd", "d"))
+	cutoff := time.Now()
+	time.Sleep(time.Millisecond * 10)
+	sendPrometheus(testContainerStat("eeeeeeeeeeee", "e"))
+
+	expired := map[string]bool{}
+	for _, id := range series.expire(cutoff) {
+		expired[id] = true
+	}
+
+	if !expired["dddddddddddd"] || expired["eeeeeeeeeeee"] {
+		t.Fatalf("expected only dddddddddddd to expire; received %v", expired)
+	}
+
+	containers := seriesContainers(t, counterCpuPercent)
+	if _, ok := containers["dddddddddddd"]; ok {
+		t.Fatal("expected stale series to be deleted")
+	}
+
+	if _, ok := containers["eeeeeeeeeeee"]; !ok {
+		t.Fatal("expected recent series to be kept")
+	}
+
+	series.remove("eeeeeeeeeeee")
+}
@@ -0,0 +1,251 @@
+package beacon
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/ehazlett/interlock/config"
+)
+
+// Sink receives the container stats collected by Beacon.  Send is called
+// once per interval with the stats of all monitored containers.
+type Sink interface {
+	Name() string
+	Send(stats []Stat) error
+	Close() error
+}
+
+// metric is a single value used by the generic (statsd, graphite,
+// opentsdb, otlp) sinks
+type metric struct {
+	Name  string
+	Value float64
+	Tags  map[string]string
+}
+
+var (
+	metricPathRegex = regexp.MustCompile(`[^A-Za-z0-9_\-]`)
+)
+
+// newSinks returns the sinks for the configured stats backend types.
+// StatsBackendType accepts a comma separated list (i.e. "prometheus,statsd").
+func newSinks(cfg *config.BeaconConfig) ([]Sink, error) {
+	sinks := []Sink{}
+	seen := map[string]bool{}
+
+	for _, t := range strings.Split(cfg.StatsBackendType, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+
+		var (
+			sink Sink
+			err  error
+		)
+
+		switch t {
+		case "prometheus":
+			sink = &prometheusSink{}
+		case "influxdb":
+			sink, err = newInfluxDBSink(cfg)
+		case "statsd":
+			sink, err = newStatsdSink(cfg.StatsStatsDAddress, cfg.StatsMetricPrefix, cfg.StatsDogStatsD)
+		case "graphite":
+			sink, err = newGraphiteSink(cfg.StatsGraphiteAddress, cfg.StatsMetricPrefix)
+		case "opentsdb":
+			sink, err = newOpenTSDBSink(cfg.StatsOpenTSDBAddress, cfg.StatsMetricPrefix)
+		case "otlp":
+			sink, err = newOTLPSink(cfg.StatsOTLPEndpoint, cfg.StatsMetricPrefix)
+		default:
+			err = fmt.Errorf("unsupported stat backend: %s", t)
+		}
+
+		if err != nil {
+			for _, s := range sinks {
+				s.Close()
+			}
+			return nil, err
+		}
+
+		sinks = append(sinks, sink)
+	}
+
+	if len(sinks) == 0 {
+		return nil, fmt.Errorf("no stat backend configured")
+	}
+
+	return sinks, nil
+}
+
+// send sends the stats to all sinks concurrently
+func (b *Beacon) send(stats []Stat) {
+	if len(stats) == 0 {
+		return
+	}
+
+	wg := &sync.WaitGroup{}
+	for _, s := range b.sinks {
+		wg.Add(1)
+		go func(sink Sink) {
+			defer wg.Done()
+
+			log().Debugf("sending stats: sink=%s num=%d", sink.Name(), len(stats))
+			if err := sink.Send(stats); err != nil {
+				log().Errorf("error sending stats to %s: %s", sink.Name(), err)
+			}
+		}(s)
+	}
+
+	wg.Wait()
+}
+
+// batchMetrics flattens the stats into metrics.  The engine totals are
+// the same for every stat and are only reported once.
+func batchMetrics(stats []Stat) []metric {
+	metrics := []metric{}
+	if len(stats) == 0 {
+		return metrics
+	}
+
+	totals := map[string]string{
+		"type": "totals",
+	}
+	metrics = append(metrics,
+		metric{"total_containers", float64(stats[0].NumContainers), totals},
+		metric{"total_images", float64(stats[0].NumImages), totals},
+		metric{"total_volumes", float64(stats[0].NumVolumes), totals},
+		metric{"total_networks", float64(stats[0].NumNetworks), totals},
+	)
+
+	for _, stat := range stats {
+		metrics = append(metrics, statMetrics(stat)...)
+	}
+
+	return metrics
+}
+
+// containerMetrics are the names of the metrics of statMetrics
+var containerMetrics = []string{
+	"cpu_total_usage",
+	"cpu_percent",
+	"cpu_throttled_periods",
+	"cpu_throttled_time",
+	"mem_usage_percent",
+	"mem_working_set",
+	"blkio_read_bytes",
+	"blkio_write_bytes",
+	"blkio_read_iops",
+	"blkio_write_iops",
+	"pids",
+	"mem_usage",
+	"mem_max_usage",
+	"net_rx_bytes",
+	"net_rx_packets",
+	"net_rx_errors",
+	"net_rx_dropped",
+	"net_tx_bytes",
+	"net_tx_packets",
+	"net_tx_errors",
+	"net_tx_dropped",
+}
+
+// statMetrics flattens the container stat into metrics
+func statMetrics(stat Stat) []metric {
+	tags := map[string]string{
+		"container": stat.ID,
+		"image":     stat.Image,
+		"name":      stat.Name,
+	}
+
+	metrics := []metric{
+		{"cpu_total_usage", float64(stat.CPUTotalUsage), tags},
+		{"cpu_percent", stat.Usage.CPUPercent, tags},
+		{"cpu_throttled_periods", float64(stat.Usage.CPUThrottledPeriods), tags},
+		{"cpu_throttled_time", float64(stat.Usage.CPUThrottledTime), tags},
+		{"mem_usage_percent", stat.MemUsagePercent, tags},
+		{"mem_working_set", float64(stat.Usage.MemWorkingSet), tags},
+		{"blkio_read_bytes", float64(stat.Usage.BlkioReadBytes), tags},
+		{"blkio_write_bytes", float64(stat.Usage.BlkioWriteBytes), tags},
+		{"blkio_read_iops", stat.Usage.BlkioReadIOPS, tags},
+		{"blkio_write_iops", stat.Usage.BlkioWriteIOPS, tags},
+		{"pids", float64(stat.Usage.Pids), tags},
+	}
+
+	if stat.Stats != nil {
+		metrics = append(metrics,
+			metric{"mem_usage", float64(stat.Stats.MemoryStats.Usage), tags},
+			metric{"mem_max_usage", float64(stat.Stats.MemoryStats.MaxUsage), tags},
+		)
+	}
+
+	for netName, net := range stat.Networks {
+		netTags := map[string]string{
+			"network": netName,
+		}
+		for k, v := range tags {
+			netTags[k] = v
+		}
+
+		metrics = append(metrics,
+			metric{"net_rx_bytes", float64(net.RxBytes), netTags},
+			metric{"net_rx_packets", float64(net.RxPackets), netTags},
+			metric{"net_rx_errors", float64(net.RxErrors), netTags},
+			metric{"net_rx_dropped", float64(net.RxDropped), netTags},
+			metric{"net_tx_bytes", float64(net.TxBytes), netTags},
+			metric{"net_tx_packets", float64(net.TxPackets), netTags},
+			metric{"net_tx_errors", float64(net.TxErrors), netTags},
+			metric{"net_tx_dropped", float64(net.TxDropped), netTags},
+		)
+	}
+
+	return metrics
+}
+
+// metricPath returns the dotted path of the metric for sinks without
+// tags (i.e. beacon.web_1.eth0.net_rx_bytes)
+func metricPath(prefix string, m metric) string {
+	parts := []string{}
+	if prefix != "" {
+		parts = append(parts, prefix)
+	}
+
+	for _, tag := range []string{"name", "network"} {
+		if v, ok := m.Tags[tag]; ok && v != "" {
+			parts = append(parts, metricPathRegex.ReplaceAllString(v, "_"))
+		}
+	}
+
+	return strings.Join(append(parts, m.Name), ".")
+}
+
+// metricName returns the name of the metric with the prefix for sinks
+// supporting tags
+func metricName(prefix string, m metric) string {
+	if prefix == "" {
+		return m.Name
+	}
+
+	return prefix + "." + m.Name
+}
+
+type prometheusSink struct{}
+
+func (s *prometheusSink) Name() string {
+	return "prometheus"
+}
+
+func (s *prometheusSink) Send(stats []Stat) error {
+	for _, stat := range stats {
+		sendPrometheus(stat)
+	}
+
+	return nil
+}
+
+func (s *prometheusSink) Close() error {
+	return nil
+}
@@ -0,0 +1,421 @@
+package beacon
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/ehazlett/interlock/config"
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func testSinkStats() []Stat {
+	stat := testStat()
+	stat.Name = "web.1"
+	stat.NumContainers = 3
+	stat.Networks = map[string]types.NetworkStats{
+		"eth0": {RxBytes: 100},
+	}
+
+	return []Stat{stat}
+}
+
+func TestNewSinks(t *testing.T) {
+	sinks, err := newSinks(&config.BeaconConfig{
+		StatsBackendType:     "prometheus, graphite,prometheus",
+		StatsGraphiteAddress: "127.0.0.1:2003",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sinks) != 2 {
+		t.Fatalf("expected 2 sinks; received %d", len(sinks))
+	}
+
+	if sinks[0].Name() != "prometheus" || sinks[1].Name() != "graphite" {
+		t.Fatalf("unexpected sinks: %s %s", sinks[0].Name(), sinks[1].Name())
+	}
+}
+
+func TestNewSinksInvalid(t *testing.T) {
+	tests := map[string]*config.BeaconConfig{
+		"unknown":      {StatsBackendType: "foo"},
+		"empty":        {StatsBackendType: ""},
+		"no statsd":    {StatsBackendType: "statsd"},
+		"no graphite":  {StatsBackendType: "graphite"},
+		"no opentsdb":  {StatsBackendType: "opentsdb"},
+		"no otlp":      {StatsBackendType: "otlp"},
+		"prom unknown": {StatsBackendType: "prometheus,foo"},
+	}
+
+	for name, cfg := range tests {
+		if _, err := newSinks(cfg); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestMetricPath(t *testing.T) {
+	m := metric{
+		Name: "net_rx_bytes",
+		Tags: map[string]string{
+			"name":    "web.1",
+			"network": "eth0",
+		},
+	}
+
+	if p := metricPath("beacon", m); p != "beacon.web_1.eth0.net_rx_bytes" {
+		t.Fatalf("unexpected path: %s", p)
+	}
+
+	if p := metricPath("", metric{Name: "total_containers"}); p != "total_containers" {
+		t.Fatalf("unexpected path: %s", p)
+	}
+}
+
+func TestBatchMetricsTotalsOnce(t *testing.T) {
+	stats := append(testSinkStats(), testSinkStats()...)
+
+	n := 0
+	for _, m := range batchMetrics(stats) {
+		if m.Name == "total_containers" {
+			n++
+		}
+	}
+
+	if n != 1 {
+		t.Fatalf("expected totals to be reported once; received %d", n)
+	}
+}
+
+func TestContainerMetrics(t *testing.T) {
+	for _, m := range statMetrics(testSinkStats()[0]) {
+		known := false
+		for _, name := range containerMetrics {
+			if name == m.Name {
+				known = true
+			}
+		}
+
+		if !known {
+			t.Fatalf("expected %s in the container metrics", m.Name)
+		}
+	}
+}
+
+func readUDP(t *testing.T, conn net.PacketConn) string {
+	buf := make([]byte, statsdMaxPacketSize)
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(buf[:n])
+}
+
+func TestStatsdSink(t *testing.T) {
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	s, err := newStatsdSink(l.LocalAddr().String(), "beacon", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Send(testSinkStats()); err != nil {
+		t.Fatal(err)
+	}
+
+	packet := readUDP(t, l)
+	if len(packet) > statsdMaxPacketSize {
+		t.Fatalf("packet too large: %d", len(packet))
+	}
+
+	lines := strings.Split(packet, "\n")
+	if lines[0] != "beacon.total_containers:3|g" {
+		t.Fatalf("unexpected line: %s", lines[0])
+	}
+
+	if !strings.Contains(packet, "beacon.web_1.cpu_percent:20|g") {
+		t.Fatalf("expected cpu percent in packet: %s", packet)
+	}
+}
+
+func TestDogStatsdSink(t *testing.T) {
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	s, err := newStatsdSink(l.LocalAddr().String(), "beacon", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Send(testSinkStats()); err != nil {
+		t.Fatal(err)
+	}
+
+	packet := readUDP(t, l)
+	expected := "beacon.cpu_percent:20|g|#container:abcdef123456,image:nginx,name:web.1"
+	if !strings.Contains(packet, expected) {
+		t.Fatalf("expected %q in packet: %s", expected, packet)
+	}
+}
+
+func TestGraphiteSink(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	lines := make(chan string, 1024)
+	accepted := make(chan bool, 2)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- true
+
+			go func(c net.Conn) {
+				defer c.Close()
+				s := bufio.NewScanner(c)
+				for s.Scan() {
+					lines <- s.Text()
+				}
+			}(conn)
+		}
+	}()
+
+	s, err := newGraphiteSink(l.Addr().String(), "beacon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// the connection is reused between batches
+	for i := 0; i < 2; i++ {
+		if err := s.Send(testSinkStats()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found := false
+	timeout := time.After(time.Second * 5)
+	for !found {
+		select {
+		case line := <-lines:
+			parts := strings.Fields(line)
+			if len(parts) != 3 {
+				t.Fatalf("invalid graphite line: %s", line)
+			}
+
+			if parts[0] == "beacon.web_1.eth0.net_rx_bytes" && parts[1] == "100" {
+				found = true
+			}
+		case <-timeout:
+			t.Fatal("timeout waiting for graphite metrics")
+		}
+	}
+
+	if len(accepted) != 1 {
+		t.Fatalf("expected a single connection; received %d", len(accepted))
+	}
+}
+
+func TestOpenTSDBSink(t *testing.T) {
+	requests := 0
+	points := []openTSDBPoint{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/put" {
+			http.NotFound(w, r)
+			return
+		}
+
+		var batch []openTSDBPoint
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if len(batch) > openTSDBBatchSize {
+			http.Error(w, "batch too large", http.StatusBadRequest)
+			return
+		}
+
+		requests++
+		points = append(points, batch...)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	s, err := newOpenTSDBSink(srv.URL+"/", "beacon")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := []Stat{}
+	for i := 0; i < 5; i++ {
+		stats = append(stats, testSinkStats()...)
+	}
+
+	if err := s.Send(stats); err != nil {
+		t.Fatal(err)
+	}
+
+	if requests < 2 {
+		t.Fatalf("expected the points to be batched; received %d requests", requests)
+	}
+
+	if len(points) != len(batchMetrics(stats)) {
+		t.Fatalf("expected %d points; received %d", len(batchMetrics(stats)), len(points))
+	}
+
+	for _, p := range points {
+		if p.Metric == "beacon.pids" {
+			if p.Value != 7 || p.Tags["name"] != "web.1" {
+				t.Fatalf("unexpected point: %+v", p)
+			}
+			return
+		}
+	}
+
+	t.Fatal("missing beacon.pids point")
+}
+
+func TestOpenTSDBSinkError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "error", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	s, err := newOpenTSDBSink(srv.URL, "beacon")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Send(testSinkStats()); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestOTLPSink(t *testing.T) {
+	var req collectorpb.ExportMetricsServiceRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-protobuf" {
+			http.Error(w, "invalid content type", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := proto.Unmarshal(data, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer srv.Close()
+
+	s, err := newOTLPSink(srv.URL+"/v1/metrics", "beacon")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Send(testSinkStats()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(req.ResourceMetrics) != 1 || len(req.ResourceMetrics[0].ScopeMetrics) != 1 {
+		t.Fatalf("unexpected request: %v", &req)
+	}
+
+	for _, m := range req.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		if m.Name != "beacon.cpu_percent" {
+			continue
+		}
+
+		points := m.GetGauge().GetDataPoints()
+		if len(points) != 1 {
+			t.Fatalf("expected 1 data point; received %d", len(points))
+		}
+
+		dp := points[0]
+		if dp.GetAsDouble() != 20 {
+			t.Fatalf("expected 20; received %v", dp.GetAsDouble())
+		}
+
+		if len(dp.Attributes) != 3 || dp.Attributes[0].Key != "container" || dp.Attributes[0].Value.GetStringValue() == "" {
+			t.Fatalf("unexpected attributes: %+v", dp.Attributes)
+		}
+		return
+	}
+
+	t.Fatal("missing beacon.cpu_percent metric")
+}
+
+func TestInfluxDBSinkRotatedPassword(t *testing.T) {
+	passwords := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, p, ok := r.BasicAuth(); ok && r.URL.Path == "/write" {
+			passwords <- p
+		}
+
+		switch r.URL.Path {
+		case "/query":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"results":[{}]}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := &config.BeaconConfig{
+		StatsInfluxDBAddress:  srv.URL,
+		StatsInfluxDBUser:     "interlock",
+		StatsInfluxDBPassword: "s3cret",
+		StatsInfluxDBDatabase: "stats",
+	}
+
+	sink, err := newInfluxDBSink(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	for _, expected := range []string{"s3cret", "rotated"} {
+		cfg.StatsInfluxDBPassword = expected
+		if err := sink.Send(testSinkStats()); err != nil {
+			t.Fatal(err)
+		}
+
+		if p := <-passwords; p != expected {
+			t.Fatalf("expected password %q; received %q", expected, p)
+		}
+	}
+}
@@ -1,16 +1,13 @@
 package beacon
 
 import (
-	"bufio"
-	"encoding/json"
 	"sync"
 	"time"
 
 	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/filters"
+	"github.com/ehazlett/interlock/metrics"
 	influx "github.com/influxdata/influxdb/client/v2"
 	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/net/context"
 )
 
 type Stat struct {
@@ -24,105 +21,62 @@ type Stat struct {
 	NumNetworks     int
 	CPUTotalUsage   uint64
 	MemUsagePercent float64
+	Usage           Usage
 	Networks        map[string]types.NetworkStats
 	Stats           *types.StatsJSON
 }
 
-func (b *Beacon) sendContainerStats(id string, stats *types.StatsJSON, ec chan error, args ...interface{}) {
+// containerStat builds the stat reported to the sinks for the sample
+func (b *Beacon) containerStat(cs *containerStream, stats *types.StatsJSON, totals *clusterTotals) Stat {
+	id := cs.id
 	log().Debugf("updating container stats: id=%s", id)
 
-	image := ""
 	if len(id) >= 12 {
 		id = id[:12]
 	}
 
-	opts := types.ContainerListOptions{
-		All:  true,
-		Size: false,
-	}
-	allContainers, err := b.client.ContainerList(context.Background(), opts)
-	if err != nil {
-		log().Errorf("unable to list containers: %s", err)
-		return
-	}
-
-	cInfo, err := b.client.ContainerInspect(context.Background(), id)
-	if err != nil {
-		log().Errorf("unable to inspect container: %s", err)
-		return
-	}
-
-	cName := cInfo.Name
-	image = cInfo.Config.Image
-
-	// strip /
-	if cName[0] == '/' {
-		cName = cName[1:]
-	}
-
-	imgOpts := types.ImageListOptions{
-		All: true,
-	}
-	allImages, err := b.client.ImageList(context.Background(), imgOpts)
-	if err != nil {
-		log().Errorf("unable to list images: %s", err)
-		return
-	}
-
-	allVolumes, err := b.client.VolumeList(context.Background(), filters.Args{})
-	if err != nil {
-		log().Errorf("unable to list volumes: %s", err)
-		return
-	}
+	usage := computeUsage(stats, b.swapSample(id, stats))
 
-	networks, err := b.client.NetworkList(context.Background(), types.NetworkListOptions{})
-	if err != nil {
-		log().Errorf("unable to list networks: %s", err)
-		return
-	}
-
-	totalUsage := stats.CPUStats.CPUUsage.TotalUsage
-	memPercent := float64(stats.MemoryStats.Usage) / float64(stats.MemoryStats.Limit) * 100.0
-	numContainers := len(allContainers)
-	numImages := len(allImages)
-	numVolumes := len(allVolumes.Volumes)
-	numNetworks := len(networks)
-
-	s := Stat{
+	return Stat{
 		ID:              id,
-		Image:           image,
-		Name:            cName,
-		ContainerJSON:   cInfo,
-		NumContainers:   numContainers,
-		NumImages:       numImages,
-		NumVolumes:      numVolumes,
-		NumNetworks:     numNetworks,
-		CPUTotalUsage:   totalUsage,
-		MemUsagePercent: memPercent,
+		Image:           cs.image,
+		Name:            cs.name,
+		ContainerJSON:   cs.info,
+		NumContainers:   totals.Containers,
+		NumImages:       totals.Images,
+		NumVolumes:      totals.Volumes,
+		NumNetworks:     totals.Networks,
+		CPUTotalUsage:   stats.CPUStats.CPUUsage.TotalUsage,
+		MemUsagePercent: usage.MemPercent,
+		Usage:           usage,
 		Networks:        stats.Networks,
 		Stats:           stats,
 	}
+}
 
-	log().Debugf("stats backend: type=%s", b.cfg.StatsBackendType)
+// swapSample stores the stats sample for the container and returns the
+// previous sample or nil if there is none
+func (b *Beacon) swapSample(id string, stats *types.StatsJSON) *types.StatsJSON {
+	b.lock.Lock()
+	defer b.lock.Unlock()
 
-	switch b.cfg.StatsBackendType {
-	case "prometheus":
-		log().Debug("updating rometheus")
-		b.sendPrometheus(s)
-	case "influxdb":
-		log().Debugf("sending stats to influxdb: url=%s", b.cfg.StatsInfluxDBAddress)
-		// TODO
-		if err := b.sendInfluxDB(s); err != nil {
-			log().Errorf("error sending stats to influxdb: %s", err)
-			return
-		}
-	default:
-		log().Errorf("unsupported stat backend: %s", b.cfg.StatsBackendType)
-		return
+	prev := b.samples[id]
+	b.samples[id] = stats
+
+	return prev
+}
+
+func (b *Beacon) removeSample(id string) {
+	if len(id) >= 12 {
+		id = id[:12]
 	}
+
+	b.lock.Lock()
+	delete(b.samples, id)
+	b.lock.Unlock()
 }
 
-func (b *Beacon) sendPrometheus(stat Stat) {
+func sendPrometheus(stat Stat) {
 	counterTotalContainers.With(prometheus.Labels{
 		"type": "totals",
 	}).Set(float64(stat.NumContainers))
@@ -139,116 +93,167 @@ func (b *Beacon) sendPrometheus(stat Stat) {
 		"type": "totals",
 	}).Set(float64(stat.NumVolumes))
 
-	counterCpuTotalUsage.With(prometheus.Labels{
+	series.set(counterCpuTotalUsage, prometheus.Labels{
+		"container": stat.ID,
+		"image":     stat.Image,
+		"name":      stat.Name,
+		"type":      "cpu",
+	}, float64(stat.CPUTotalUsage))
+
+	series.set(counterCpuPercent, prometheus.Labels{
 		"container": stat.ID,
 		"image":     stat.Image,
 		"name":      stat.Name,
 		"type":      "cpu",
-	}).Set(float64(stat.CPUTotalUsage))
+	}, stat.Usage.CPUPercent)
 
-	counterMemoryUsage.With(prometheus.Labels{
+	series.set(counterCpuThrottledPeriods, prometheus.Labels{
+		"container": stat.ID,
+		"image":     stat.Image,
+		"name":      stat.Name,
+		"type":      "cpu",
+	}, float64(stat.Usage.CPUThrottledPeriods))
+
+	series.set(counterCpuThrottledTime, prometheus.Labels{
+		"container": stat.ID,
+		"image":     stat.Image,
+		"name":      stat.Name,
+		"type":      "cpu",
+	}, float64(stat.Usage.CPUThrottledTime))
+
+	series.set(counterMemoryUsage, prometheus.Labels{
 		"container": stat.ID,
 		"image":     stat.Image,
 		"name":      stat.Name,
 		"type":      "memory",
-	}).Set(float64(stat.Stats.MemoryStats.Usage))
+	}, float64(stat.Stats.MemoryStats.Usage))
 
-	counterMemoryMaxUsage.With(prometheus.Labels{
+	series.set(counterMemoryMaxUsage, prometheus.Labels{
 		"container": stat.ID,
 		"image":     stat.Image,
 		"name":      stat.Name,
 		"type":      "memory",
-	}).Set(float64(stat.Stats.MemoryStats.MaxUsage))
+	}, float64(stat.Stats.MemoryStats.MaxUsage))
 
-	counterMemoryPercent.With(prometheus.Labels{
+	series.set(counterMemoryPercent, prometheus.Labels{
 		"container": stat.ID,
 		"image":     stat.Image,
 		"name":      stat.Name,
 		"type":      "memory",
-	}).Set(float64(stat.MemUsagePercent))
+	}, float64(stat.MemUsagePercent))
+
+	series.set(counterMemoryWorkingSet, prometheus.Labels{
+		"container": stat.ID,
+		"image":     stat.Image,
+		"name":      stat.Name,
+		"type":      "memory",
+	}, float64(stat.Usage.MemWorkingSet))
+
+	series.set(counterBlkioReadBytes, prometheus.Labels{
+		"container": stat.ID,
+		"image":     stat.Image,
+		"name":      stat.Name,
+		"type":      "blkio",
+	}, float64(stat.Usage.BlkioReadBytes))
+
+	series.set(counterBlkioWriteBytes, prometheus.Labels{
+		"container": stat.ID,
+		"image":     stat.Image,
+		"name":      stat.Name,
+		"type":      "blkio",
+	}, float64(stat.Usage.BlkioWriteBytes))
+
+	series.set(counterBlkioReadIOPS, prometheus.Labels{
+		"container": stat.ID,
+		"image":     stat.Image,
+		"name":      stat.Name,
+		"type":      "blkio",
+	}, stat.Usage.BlkioReadIOPS)
+
+	series.set(counterBlkioWriteIOPS, prometheus.Labels{
+		"container": stat.ID,
+		"image":     stat.Image,
+		"name":      stat.Name,
+		"type":      "blkio",
+	}, stat.Usage.BlkioWriteIOPS)
+
+	series.set(counterPids, prometheus.Labels{
+		"container": stat.ID,
+		"image":     stat.Image,
+		"name":      stat.Name,
+		"type":      "pids",
+	}, float64(stat.Usage.Pids))
 
 	for netName, net := range stat.Networks {
-		counterNetworkRxBytes.With(prometheus.Labels{
+		series.set(counterNetworkRxBytes, prometheus.Labels{
 			"container": stat.ID,
 			"image":     stat.Image,
 			"name":      stat.Name,
 			"network":   netName,
 			"type":      "network",
-		}).Set(float64(net.RxBytes))
+		}, float64(net.RxBytes))
 
-		counterNetworkRxPackets.With(prometheus.Labels{
+		series.set(counterNetworkRxPackets, prometheus.Labels{
 			"container": stat.ID,
 			"image":     stat.Image,
 			"name":      stat.Name,
 			"network":   netName,
 			"type":      "network",
-		}).Set(float64(net.RxPackets))
+		}, float64(net.RxPackets))
 
-		counterNetworkRxErrors.With(prometheus.Labels{
+		series.set(counterNetworkRxErrors, prometheus.Labels{
 			"container": stat.ID,
 			"image":     stat.Image,
 			"name":      stat.Name,
 			"network":   netName,
 			"type":      "network",
-		}).Set(float64(net.RxErrors))
+		}, float64(net.RxErrors))
 
-		counterNetworkRxDropped.With(prometheus.Labels{
+		series.set(counterNetworkRxDropped, prometheus.Labels{
 			"container": stat.ID,
 			"image":     stat.Image,
 			"name":      stat.Name,
 			"network":   netName,
 			"type":      "network",
-		}).Set(float64(net.RxDropped))
+		}, float64(net.RxDropped))
 
-		counterNetworkTxBytes.With(prometheus.Labels{
+		series.set(counterNetworkTxBytes, prometheus.Labels{
 			"container": stat.ID,
 			"image":     stat.Image,
 			"name":      stat.Name,
 			"network":   netName,
 			"type":      "network",
-		}).Set(float64(net.TxBytes))
+		}, float64(net.TxBytes))
 
-		counterNetworkTxPackets.With(prometheus.Labels{
+		series.set(counterNetworkTxPackets, prometheus.Labels{
 			"container": stat.ID,
 			"image":     stat.Image,
 			"name":      stat.Name,
 			"network":   netName,
 			"type":      "network",
-		}).Set(float64(net.TxPackets))
+		}, float64(net.TxPackets))
 
-		counterNetworkTxErrors.With(prometheus.Labels{
+		series.set(counterNetworkTxErrors, prometheus.Labels{
 			"container": stat.ID,
 			"image":     stat.Image,
 			"name":      stat.Name,
 			"network":   netName,
 			"type":      "network",
-		}).Set(float64(net.TxErrors))
+		}, float64(net.TxErrors))
 
-		counterNetworkTxDropped.With(prometheus.Labels{
+		series.set(counterNetworkTxDropped, prometheus.Labels{
 			"container": stat.ID,
 			"image":     stat.Image,
 			"name":      stat.Name,
 			"network":   netName,
 			"type":      "network",
-		}).Set(float64(net.TxDropped))
+		}, float64(net.TxDropped))
 	}
 }
 
-func (b *Beacon) sendInfluxDB(stat Stat) error {
-	c, err := NewInfluxDBClient(b.cfg)
-	if err != nil {
-		return err
-	}
-
-	bp, err := influx.NewBatchPoints(influx.BatchPointsConfig{
-		Database:  b.cfg.StatsInfluxDBDatabase,
-		Precision: b.cfg.StatsInfluxDBPrecision,
-	})
-	if err != nil {
-		return err
-	}
-
-	timestamp := time.Now()
+// influxDBPoints returns the InfluxDB points for the container stat
+func influxDBPoints(stat Stat, timestamp time.Time) ([]*influx.Point, error) {
+	points := []*influx.Point{}
 
 	// cpu total
 	pt, err := influx.NewPoint("containers", map[string]string{
@@ -257,13 +262,9 @@ func (b *Beacon) sendInfluxDB(stat Stat) error {
 		"total_containers": stat.NumContainers,
 	}, timestamp)
 	if err != nil {
-		return err
-	}
-	bp.AddPoint(pt)
-
-	if err := c.Write(bp); err != nil {
-		return err
+		return nil, err
 	}
+	points = append(points, pt)
 
 	// images total
 	pt, err = influx.NewPoint("images", map[string]string{
@@ -272,9 +273,9 @@ func (b *Beacon) sendInfluxDB(stat Stat) error {
 		"total_images": stat.NumImages,
 	}, timestamp)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	bp.AddPoint(pt)
+	points = append(points, pt)
 
 	// networks total
 	pt, err = influx.NewPoint("networks", map[string]string{
@@ -283,9 +284,9 @@ func (b *Beacon) sendInfluxDB(stat Stat) error {
 		"total_networks": stat.NumNetworks,
 	}, timestamp)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	bp.AddPoint(pt)
+	points = append(points, pt)
 
 	// volumes total
 	pt, err = influx.NewPoint("volumes", map[string]string{
@@ -294,9 +295,9 @@ func (b *Beacon) sendInfluxDB(stat Stat) error {
 		"total_volumes": stat.NumVolumes,
 	}, timestamp)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	bp.AddPoint(pt)
+	points = append(points, pt)
 
 	// cpu total
 	pt, err = influx.NewPoint("cpu_usage", map[string]string{
@@ -309,9 +310,9 @@ func (b *Beacon) sendInfluxDB(stat Stat) error {
 		"value": stat.CPUTotalUsage,
 	}, timestamp)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	bp.AddPoint(pt)
+	points = append(points, pt)
 
 	// mem usage
 	pt, err = influx.NewPoint("mem_usage", map[string]string{
@@ -324,9 +325,9 @@ func (b *Beacon) sendInfluxDB(stat Stat) error {
 		"value": stat.Stats.MemoryStats.Usage,
 	}, timestamp)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	bp.AddPoint(pt)
+	points = append(points, pt)
 
 	// mem max usage
 	pt, err = influx.NewPoint("mem_max_usage", map[string]string{
@@ -339,9 +340,9 @@ func (b *Beacon) sendInfluxDB(stat Stat) error {
 		"value": stat.Stats.MemoryStats.MaxUsage,
 	}, timestamp)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	bp.AddPoint(pt)
+	points = append(points, pt)
 
 	// mem usage percent
 	pt, err = influx.NewPoint("mem_usage_percent", map[string]string{
@@ -354,9 +355,145 @@ func (b *Beacon) sendInfluxDB(stat Stat) error {
 		"value": stat.MemUsagePercent,
 	}, timestamp)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	points = append(points, pt)
+
+	// cpu percent
+	pt, err = influx.NewPoint("cpu_percent", map[string]string{
+		"cpu":       "cpu-percent",
+		"resource":  "container",
+		"container": stat.ID,
+		"image":     stat.Image,
+		"name":      stat.Name,
+	}, map[string]interface{}{
+		"value": stat.Usage.CPUPercent,
+	}, timestamp)
+	if err != nil {
+		return nil, err
 	}
-	bp.AddPoint(pt)
+	points = append(points, pt)
+
+	// cpu throttled periods
+	// (counters are converted as the influxdb client writes uint64 as strings)
+	pt, err = influx.NewPoint("cpu_throttled_periods", map[string]string{
+		"cpu":       "cpu-throttled-periods",
+		"resource":  "container",
+		"container": stat.ID,
+		"image":     stat.Image,
+		"name":      stat.Name,
+	}, map[string]interface{}{
+		"value": int64(stat.Usage.CPUThrottledPeriods),
+	}, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	points = append(points, pt)
+
+	// cpu throttled time
+	pt, err = influx.NewPoint("cpu_throttled_time", map[string]string{
+		"cpu":       "cpu-throttled-time",
+		"resource":  "container",
+		"container": stat.ID,
+		"image":     stat.Image,
+		"name":      stat.Name,
+	}, map[string]interface{}{
+		"value": int64(stat.Usage.CPUThrottledTime),
+	}, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	points = append(points, pt)
+
+	// mem working set
+	pt, err = influx.NewPoint("mem_working_set", map[string]string{
+		"memory":    "memory-working-set",
+		"resource":  "container",
+		"container": stat.ID,
+		"image":     stat.Image,
+		"name":      stat.Name,
+	}, map[string]interface{}{
+		"value": int64(stat.Usage.MemWorkingSet),
+	}, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	points = append(points, pt)
+
+	// blkio read bytes
+	pt, err = influx.NewPoint("blkio_read_bytes", map[string]string{
+		"blkio":     "blkio-read-bytes",
+		"resource":  "container",
+		"container": stat.ID,
+		"image":     stat.Image,
+		"name":      stat.Name,
+	}, map[string]interface{}{
+		"value": int64(stat.Usage.BlkioReadBytes),
+	}, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	points = append(points, pt)
+
+	// blkio write bytes
+	pt, err = influx.NewPoint("blkio_write_bytes", map[string]string{
+		"blkio":     "blkio-write-bytes",
+		"resource":  "container",
+		"container": stat.ID,
+		"image":     stat.Image,
+		"name":      stat.Name,
+	}, map[string]interface{}{
+		"value": int64(stat.Usage.BlkioWriteBytes),
+	}, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	points = append(points, pt)
+
+	// blkio read iops
+	pt, err = influx.NewPoint("blkio_read_iops", map[string]string{
+		"blkio":     "blkio-read-iops",
+		"resource":  "container",
+		"container": stat.ID,
+		"image":     stat.Image,
+		"name":      stat.Name,
+	}, map[string]interface{}{
+		"value": stat.Usage.BlkioReadIOPS,
+	}, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	points = append(points, pt)
+
+	// blkio write iops
+	pt, err = influx.NewPoint("blkio_write_iops", map[string]string{
+		"blkio":     "blkio-write-iops",
+		"resource":  "container",
+		"container": stat.ID,
+		"image":     stat.Image,
+		"name":      stat.Name,
+	}, map[string]interface{}{
+		"value": stat.Usage.BlkioWriteIOPS,
+	}, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	points = append(points, pt)
+
+	// pids
+	pt, err = influx.NewPoint("pids", map[string]string{
+		"pids":      "pids-current",
+		"resource":  "container",
+		"container": stat.ID,
+		"image":     stat.Image,
+		"name":      stat.Name,
+	}, map[string]interface{}{
+		"value": int64(stat.Usage.Pids),
+	}, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	points = append(points, pt)
 
 	// networks
 	for netName, net := range stat.Networks {
@@ -370,9 +507,9 @@ func (b *Beacon) sendInfluxDB(stat Stat) error {
 			"value": net.RxBytes,
 		}, timestamp)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		bp.AddPoint(pt)
+		points = append(points, pt)
 
 		pt, err = influx.NewPoint("net_rx_packets", map[string]string{
 			"resource":  "network",
@@ -384,9 +521,9 @@ func (b *Beacon) sendInfluxDB(stat Stat) error {
 			"value": net.RxPackets,
 		}, timestamp)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		bp.AddPoint(pt)
+		points = append(points, pt)
 
 		pt, err = influx.NewPoint("net_rx_errors", map[string]string{
 			"resource":  "network",
@@ -398,9 +535,9 @@ func (b *Beacon) sendInfluxDB(stat Stat) error {
 			"value": net.RxErrors,
 		}, timestamp)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		bp.AddPoint(pt)
+		points = append(points, pt)
 
 		pt, err = influx.NewPoint("net_rx_dropped", map[string]string{
 			"resource":  "network",
@@ -412,9 +549,9 @@ func (b *Beacon) sendInfluxDB(stat Stat) error {
 			"value": net.RxDropped,
 		}, timestamp)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		bp.AddPoint(pt)
+		points = append(points, pt)
 
 		pt, err = influx.NewPoint("net_tx_bytes", map[string]string{
 			"resource":  "network",
@@ -426,9 +563,9 @@ func (b *Beacon) sendInfluxDB(stat Stat) error {
 			"value": net.TxBytes,
 		}, timestamp)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		bp.AddPoint(pt)
+		points = append(points, pt)
 
 		pt, err = influx.NewPoint("net_tx_packets", map[string]string{
 			"resource":  "network",
@@ -440,9 +577,9 @@ func (b *Beacon) sendInfluxDB(stat Stat) error {
 			"value": net.TxPackets,
 		}, timestamp)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		bp.AddPoint(pt)
+		points = append(points, pt)
 
 		pt, err = influx.NewPoint("net_tx_errors", map[string]string{
 			"resource":  "network",
@@ -454,9 +591,9 @@ func (b *Beacon) sendInfluxDB(stat Stat) error {
 			"value": net.TxErrors,
 		}, timestamp)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		bp.AddPoint(pt)
+		points = append(points, pt)
 
 		pt, err = influx.NewPoint("net_tx_dropped", map[string]string{
 			"resource":  "network",
@@ -468,66 +605,138 @@ func (b *Beacon) sendInfluxDB(stat Stat) error {
 			"value": net.TxDropped,
 		}, timestamp)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		bp.AddPoint(pt)
-	}
-
-	if err := c.Write(bp); err != nil {
-		return err
+		points = append(points, pt)
 	}
 
-	return nil
+	return points, nil
 }
 
+// collectStats reports the latest sample of every monitored container.
+// The samples are processed by a bounded number of workers and sent to
+// the sinks as a single batch; the cluster totals are only fetched once
+// per interval.
 func (b *Beacon) collectStats() {
+	start := time.Now()
+
+	b.refreshSecrets()
+
+	totals, err := b.clusterTotals()
+	if err != nil {
+		log().Errorf("unable to get cluster totals: %s", err)
+		metrics.APIError(pluginName)
+		return
+	}
+
+	streams := b.activeStreams()
+
+	workers := b.cfg.StatsWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var (
+		lock   sync.Mutex
+		maxLag time.Duration
+		batch  = []Stat{}
+	)
+
+	work := make(chan *containerStream)
 	wg := &sync.WaitGroup{}
-	for id, _ := range b.monitored {
+	for i := 0; i < workers; i++ {
 		wg.Add(1)
-		go func(cID string) {
+		go func() {
 			defer wg.Done()
 
-			c, err := b.client.ContainerInspect(context.Background(), cID)
-			if err != nil {
-				errChan <- err
-				return
-			}
+			for cs := range work {
+				stats := cs.take()
+				if stats == nil {
+					log().Debugf("no new stats sample: id=%s", cs.id)
+					continue
+				}
 
-			image := c.Config.Image
+				stat := b.containerStat(cs, stats, totals)
 
-			// match rules
-			if !b.ruleMatch(c.Config) {
-				log().Debugf("unable to find rule matching container %s (%s); not monitoring", c.ID, image)
-				return
+				lock.Lock()
+				if !stats.Read.IsZero() {
+					if lag := time.Since(stats.Read); lag > maxLag {
+						maxLag = lag
+					}
+				}
+				batch = append(batch, stat)
+				lock.Unlock()
 			}
+		}()
+	}
 
-			log().Debugf("checking container stats: id=%s", cID)
-			r, err := b.client.ContainerStats(context.Background(), cID, false)
-			if err != nil {
-				log().Errorf("unable to get container stats: %s", err)
-				return
-			}
+	for _, cs := range streams {
+		work <- cs
+	}
+	close(work)
 
-			var stats *types.StatsJSON
-			s := bufio.NewScanner(r.Body)
-			for s.Scan() {
-				if err := json.Unmarshal([]byte(s.Text()), &stats); err != nil {
-					log().Errorf("unable to unmarshal stats: %s", err)
-					return
-				}
+	wg.Wait()
+
+	b.send(batch)
+
+	if b.alerter != nil {
+		sendNotifications(b.alerter.evaluate(batch, time.Now()))
+	}
 
-				b.sendContainerStats(cID, stats, errChan)
+	// expire the series of containers that disappeared without an event
+	if b.interval > 0 {
+		for _, id := range series.expire(time.Now().Add(-b.interval * staleIntervals)) {
+			log().Debugf("expired stale stats: id=%s", id)
+			b.removeSample(id)
+
+			if b.alerter != nil {
+				sendNotifications(b.alerter.remove(id, time.Now()))
 			}
+		}
+	}
 
-		}(id)
+	counterCollectorStreams.With(prometheus.Labels{
+		"type": "collector",
+	}).Set(float64(len(streams)))
+
+	counterCollectorLag.With(prometheus.Labels{
+		"type": "collector",
+	}).Set(maxLag.Seconds())
+
+	counterCollectorDuration.With(prometheus.Labels{
+		"type": "collector",
+	}).Set(time.Since(start).Seconds())
+}
+
+// refreshSecrets reads the secrets of the options again so rotated secrets
+// are used by the sinks and the alerts; the options which cannot be
+// resolved keep their last value
+func (b *Beacon) refreshSecrets() {
+	if b.extCfg == nil {
+		return
 	}
 
-	wg.Wait()
+	secrets, err := b.extCfg.SecretValues()
+	if err != nil {
+		log().Warnf("unable to read secrets; using the last values: %s", err)
+	}
+	b.extCfg.SetSecretValues(secrets)
+
+	if b.alerter != nil {
+		b.alerter.setWebhooks(b.cfg.Alerts)
+	}
 }
 
+// resetStats removes the metrics of the container
 func (b *Beacon) resetStats(id string) error {
-	for _, c := range allCounters {
-		c.Reset()
+	if len(id) >= 12 {
+		id = id[:12]
+	}
+
+	series.remove(id)
+
+	if b.alerter != nil {
+		sendNotifications(b.alerter.remove(id, time.Now()))
 	}
 
 	return nil
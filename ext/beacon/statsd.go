@@ -0,0 +1,96 @@
+package beacon
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// keep packets below the common MTU to avoid fragmentation
+	statsdMaxPacketSize = 1432
+)
+
+// statsdSink sends the stats as gauges over UDP.  With DogStatsD the tags
+// are sent as DogStatsD tags; otherwise they are part of the metric path.
+type statsdSink struct {
+	conn      net.Conn
+	prefix    string
+	dogstatsd bool
+}
+
+func newStatsdSink(addr, prefix string, dogstatsd bool) (*statsdSink, error) {
+	if addr == "" {
+		return nil, fmt.Errorf("statsd address is required")
+	}
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &statsdSink{
+		conn:      conn,
+		prefix:    prefix,
+		dogstatsd: dogstatsd,
+	}, nil
+}
+
+func (s *statsdSink) Name() string {
+	return "statsd"
+}
+
+func (s *statsdSink) line(m metric) string {
+	value := strconv.FormatFloat(m.Value, 'f', -1, 64)
+
+	if !s.dogstatsd {
+		return metricPath(s.prefix, m) + ":" + value + "|g"
+	}
+
+	tags := []string{}
+	for k, v := range m.Tags {
+		tags = append(tags, k+":"+v)
+	}
+	sort.Strings(tags)
+
+	l := metricName(s.prefix, m) + ":" + value + "|g"
+	if len(tags) > 0 {
+		l += "|#" + strings.Join(tags, ",")
+	}
+
+	return l
+}
+
+// Send batches the metrics into as few packets as possible
+func (s *statsdSink) Send(stats []Stat) error {
+	var buf bytes.Buffer
+	for _, m := range batchMetrics(stats) {
+		l := s.line(m)
+		if buf.Len() > 0 && buf.Len()+len(l)+1 > statsdMaxPacketSize {
+			if _, err := s.conn.Write(buf.Bytes()); err != nil {
+				return err
+			}
+			buf.Reset()
+		}
+
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(l)
+	}
+
+	if buf.Len() > 0 {
+		if _, err := s.conn.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *statsdSink) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,209 @@
+package beacon
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/ehazlett/interlock/metrics"
+	"golang.org/x/net/context"
+)
+
+const (
+	streamRetryInterval = time.Second * 5
+)
+
+// containerStream reads the stats stream of a single container and keeps
+// the latest sample until it is collected
+type containerStream struct {
+	id     string
+	name   string
+	image  string
+	info   types.ContainerJSON
+	cancel context.CancelFunc
+
+	lock   sync.Mutex
+	latest *types.StatsJSON
+}
+
+func (s *containerStream) set(stats *types.StatsJSON) {
+	s.lock.Lock()
+	s.latest = stats
+	s.lock.Unlock()
+}
+
+// take returns the latest sample and clears it so a sample is only
+// reported once; nil is returned if there is no new sample
+func (s *containerStream) take() *types.StatsJSON {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stats := s.latest
+	s.latest = nil
+
+	return stats
+}
+
+// clusterTotals are the engine wide counts reported with every sample
+type clusterTotals struct {
+	Containers int
+	Images     int
+	Volumes    int
+	Networks   int
+}
+
+// startStream starts reading the stats of the container if it matches
+// the beacon rules and is not already monitored
+func (b *Beacon) startStream(id string) error {
+	b.lock.Lock()
+	_, ok := b.streams[id]
+	b.lock.Unlock()
+	if ok {
+		return nil
+	}
+
+	info, err := b.client.ContainerInspect(context.Background(), id)
+	if err != nil {
+		metrics.APIError(pluginName)
+		return err
+	}
+
+	if !b.ruleMatch(info) {
+		log().Debugf("unable to find rule matching container %s (%s); not monitoring", id, info.Config.Image)
+		return nil
+	}
+
+	name := info.Name
+	if len(name) > 0 && name[0] == '/' {
+		name = name[1:]
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &containerStream{
+		id:     id,
+		name:   name,
+		image:  info.Config.Image,
+		info:   info,
+		cancel: cancel,
+	}
+
+	b.lock.Lock()
+	if _, ok := b.streams[id]; ok {
+		b.lock.Unlock()
+		cancel()
+		return nil
+	}
+	b.streams[id] = s
+	b.lock.Unlock()
+
+	log().Debugf("starting stats stream: id=%s", id)
+	go b.readStream(ctx, s)
+
+	return nil
+}
+
+// stopStream stops reading the stats of the container
+func (b *Beacon) stopStream(id string) {
+	b.lock.Lock()
+	s, ok := b.streams[id]
+	delete(b.streams, id)
+	b.lock.Unlock()
+
+	if ok {
+		log().Debugf("stopping stats stream: id=%s", id)
+		s.cancel()
+	}
+}
+
+// activeStreams returns the streams of all monitored containers
+func (b *Beacon) activeStreams() []*containerStream {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	streams := make([]*containerStream, 0, len(b.streams))
+	for _, s := range b.streams {
+		streams = append(streams, s)
+	}
+
+	return streams
+}
+
+// readStream decodes the stats stream until the context is cancelled.
+// The stream is re-opened if it is interrupted while the container is
+// still running.
+func (b *Beacon) readStream(ctx context.Context, s *containerStream) {
+	for {
+		r, err := b.client.ContainerStats(ctx, s.id, true)
+		if err == nil {
+			dec := json.NewDecoder(r.Body)
+			for {
+				var stats *types.StatsJSON
+				if err := dec.Decode(&stats); err != nil {
+					break
+				}
+
+				s.set(stats)
+			}
+			r.Body.Close()
+		} else if ctx.Err() == nil {
+			metrics.APIError(pluginName)
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		info, err := b.client.ContainerInspect(ctx, s.id)
+		if err != nil || info.State == nil || !info.State.Running {
+			log().Debugf("container no longer running; stopping stats stream: id=%s", s.id)
+			b.stopStream(s.id)
+			return
+		}
+
+		log().Warnf("stats stream interrupted; retrying: id=%s", s.id)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(streamRetryInterval):
+		}
+	}
+}
+
+// clusterTotals returns the number of containers, images, volumes and
+// networks of the engine
+func (b *Beacon) clusterTotals() (*clusterTotals, error) {
+	allContainers, err := b.client.ContainerList(context.Background(), types.ContainerListOptions{
+		All:  true,
+		Size: false,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	allImages, err := b.client.ImageList(context.Background(), types.ImageListOptions{
+		All: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	allVolumes, err := b.client.VolumeList(context.Background(), filters.Args{})
+	if err != nil {
+		return nil, err
+	}
+
+	networks, err := b.client.NetworkList(context.Background(), types.NetworkListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &clusterTotals{
+		Containers: len(allContainers),
+		Images:     len(allImages),
+		Volumes:    len(allVolumes.Volumes),
+		Networks:   len(networks),
+	}, nil
+}
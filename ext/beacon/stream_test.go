@@ -0,0 +1,236 @@
+package beacon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	engineClient "github.com/docker/docker/client"
+	"github.com/ehazlett/interlock/client"
+	"github.com/ehazlett/interlock/config"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// fakeEngine is a minimal docker API serving container stats streams
+type fakeEngine struct {
+	lock  sync.Mutex
+	calls map[string]int
+}
+
+func (f *fakeEngine) called(name string) int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.calls[name]
+}
+
+func (f *fakeEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	name := path
+	if i := strings.Index(path[1:], "/"); i >= 0 {
+		name = path[i+1:]
+	}
+
+	f.lock.Lock()
+	f.calls[name]++
+	f.lock.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case name == "/containers/json":
+		json.NewEncoder(w).Encode([]types.Container{{ID: "a"}, {ID: "b"}, {ID: "c"}})
+	case name == "/images/json":
+		json.NewEncoder(w).Encode([]types.ImageSummary{{ID: "img"}})
+	case name == "/volumes":
+		fmt.Fprint(w, `{"Volumes": [], "Warnings": []}`)
+	case name == "/networks":
+		json.NewEncoder(w).Encode([]types.NetworkResource{{Name: "bridge"}, {Name: "host"}})
+	case name == "/info":
+		fmt.Fprint(w, `{"ContainersRunning": 3, "ContainersPaused": 1, "ContainersStopped": 2, "Driver": "devicemapper", "DriverStatus": [["Pool Name", "docker-pool"], ["Data Space Used", "1.5 GB"], ["Data Space Total", "10 GB"]], "Swarm": {"LocalNodeState": "active", "ControlAvailable": true, "Nodes": 5, "Managers": 3}}`)
+	case name == "/system/df":
+		fmt.Fprint(w, `{"LayersSize": 1000, "Containers": [{"SizeRw": 10}, {"SizeRw": 20}], "Volumes": [{"UsageData": {"Size": 100, "RefCount": 1}}, {"UsageData": {"Size": -1, "RefCount": 0}}]}`)
+	case strings.HasSuffix(name, "/stats"):
+		if r.URL.Query().Get("stream") != "1" {
+			http.Error(w, "expected stream", http.StatusBadRequest)
+			return
+		}
+
+		enc := json.NewEncoder(w)
+		for i := uint64(1); i <= 2; i++ {
+			stats := testStats(time.Now(), i*1000, i*10000, i, i)
+			enc.Encode(stats)
+		}
+		w.(http.Flusher).Flush()
+
+		// keep the stream open until the client goes away
+		<-r.Context().Done()
+	case strings.HasSuffix(name, "/json"):
+		id := strings.Split(name, "/")[2]
+		fmt.Fprintf(w, `{"Id": %q, "Name": "/%s", "State": {"Running": true}, "Config": {"Image": "nginx", "Labels": {}}}`, id, id)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func testBeacon(t *testing.T, f *fakeEngine) (*Beacon, func()) {
+	srv := httptest.NewServer(f)
+
+	cl, err := engineClient.NewClient("tcp://"+strings.TrimPrefix(srv.URL, "http://"), "1.24", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// monitor every container
+	rules, err := compileRules(map[string]*config.Rule{
+		"all": {Type: "image", Regex: ".*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &Beacon{
+		cfg: &config.BeaconConfig{
+			StatsBackendType: "prometheus",
+			StatsWorkers:     2,
+		},
+		rules:   rules,
+		client:  client.NewClient(cl, nil),
+		streams: map[string]*containerStream{},
+		samples: map[string]*types.StatsJSON{},
+		sinks:   []Sink{&prometheusSink{}},
+		lock:    &sync.Mutex{},
+
+		eventCounts: map[string]int{},
+		eventsSince: time.Now(),
+	}
+
+	return b, func() {
+		for _, s := range b.activeStreams() {
+			b.stopStream(s.id)
+		}
+		srv.Close()
+	}
+}
+
+func gaugeValue(t *testing.T, g *prometheus.GaugeVec, labels prometheus.Labels) float64 {
+	m := &dto.Metric{}
+	if err := g.With(labels).Write(m); err != nil {
+		t.Fatal(err)
+	}
+
+	return m.GetGauge().GetValue()
+}
+
+func TestContainerStreamTake(t *testing.T) {
+	s := &containerStream{}
+	if s.take() != nil {
+		t.Fatal("expected no sample")
+	}
+
+	s.set(&types.StatsJSON{})
+	if s.take() == nil {
+		t.Fatal("expected sample")
+	}
+
+	if s.take() != nil {
+		t.Fatal("expected sample to only be taken once")
+	}
+}
+
+func TestCollectStats(t *testing.T) {
+	f := &fakeEngine{calls: map[string]int{}}
+	b, cleanup := testBeacon(t, f)
+	defer cleanup()
+
+	for _, id := range []string{"a", "b", "c"} {
+		if err := b.startStream(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// starting a monitored container again is a no-op
+	if err := b.startStream("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(b.activeStreams()); n != 3 {
+		t.Fatalf("expected 3 streams; received %d", n)
+	}
+
+	// wait for the streams to receive both samples
+	deadline := time.Now().Add(time.Second * 5)
+	for {
+		ready := true
+		for _, s := range b.activeStreams() {
+			s.lock.Lock()
+			if s.latest == nil || s.latest.CPUStats.CPUUsage.TotalUsage != 2000 {
+				ready = false
+			}
+			s.lock.Unlock()
+		}
+
+		if ready {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for stats samples")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	b.collectStats()
+
+	if n := f.called("/containers/json"); n != 1 {
+		t.Fatalf("expected containers to be listed once per interval; listed %d times", n)
+	}
+
+	if n := f.called("/containers/a/stats"); n != 1 {
+		t.Fatalf("expected a single stats stream; received %d", n)
+	}
+
+	if v := gaugeValue(t, counterTotalContainers, prometheus.Labels{"type": "totals"}); v != 3 {
+		t.Fatalf("expected 3 containers; received %v", v)
+	}
+
+	if v := gaugeValue(t, counterTotalNetworks, prometheus.Labels{"type": "totals"}); v != 2 {
+		t.Fatalf("expected 2 networks; received %v", v)
+	}
+
+	if v := gaugeValue(t, counterCollectorStreams, prometheus.Labels{"type": "collector"}); v != 3 {
+		t.Fatalf("expected 3 streams; received %v", v)
+	}
+
+	for _, id := range []string{"a", "b", "c"} {
+		v := gaugeValue(t, counterCpuTotalUsage, prometheus.Labels{
+			"container": id,
+			"image":     "nginx",
+			"name":      id,
+			"type":      "cpu",
+		})
+		if v != 2000 {
+			t.Fatalf("%s: expected cpu usage 2000; received %v", id, v)
+		}
+	}
+
+	// samples are only reported once
+	for _, s := range b.activeStreams() {
+		if s.take() != nil {
+			t.Fatalf("%s: expected sample to be consumed", s.id)
+		}
+	}
+
+	b.stopStream("a")
+
+	if n := len(b.activeStreams()); n != 2 {
+		t.Fatalf("expected 2 streams after stop; received %d", n)
+	}
+}
@@ -0,0 +1,119 @@
+package beacon
+
+import (
+	"strings"
+
+	"github.com/docker/docker/api/types"
+)
+
+// Usage is the resource usage derived from a stats sample and the
+// previous sample of the same container
+type Usage struct {
+	CPUPercent          float64
+	CPUThrottledPeriods uint64
+	CPUThrottledTime    uint64
+	MemWorkingSet       uint64
+	MemPercent          float64
+	BlkioReadBytes      uint64
+	BlkioWriteBytes     uint64
+	BlkioReadIOPS       float64
+	BlkioWriteIOPS      float64
+	Pids                uint64
+}
+
+// computeUsage calculates the usage for the stats sample.  prev is the
+// previous sample for the container or nil if there is none; the CPU
+// percentage then falls back to the precpu stats reported by the engine.
+func computeUsage(stats, prev *types.StatsJSON) Usage {
+	u := Usage{
+		CPUThrottledPeriods: stats.CPUStats.ThrottlingData.ThrottledPeriods,
+		CPUThrottledTime:    stats.CPUStats.ThrottlingData.ThrottledTime,
+		MemWorkingSet:       memWorkingSet(stats.MemoryStats),
+		Pids:                stats.PidsStats.Current,
+	}
+
+	if stats.MemoryStats.Limit > 0 {
+		u.MemPercent = float64(u.MemWorkingSet) / float64(stats.MemoryStats.Limit) * 100.0
+	}
+
+	preCPU := stats.PreCPUStats
+	if prev != nil {
+		preCPU = prev.CPUStats
+	}
+	u.CPUPercent = cpuPercent(stats.CPUStats, preCPU)
+
+	u.BlkioReadBytes, u.BlkioWriteBytes = blkioTotals(stats.BlkioStats.IoServiceBytesRecursive)
+
+	if prev != nil {
+		elapsed := stats.Read.Sub(prev.Read).Seconds()
+		if elapsed > 0 {
+			reads, writes := blkioTotals(stats.BlkioStats.IoServicedRecursive)
+			prevReads, prevWrites := blkioTotals(prev.BlkioStats.IoServicedRecursive)
+			u.BlkioReadIOPS = counterRate(reads, prevReads, elapsed)
+			u.BlkioWriteIOPS = counterRate(writes, prevWrites, elapsed)
+		}
+	}
+
+	return u
+}
+
+// cpuPercent returns the CPU usage between the two samples as a
+// percentage of a single CPU (i.e. 200 for two fully used CPUs)
+func cpuPercent(cpu, preCPU types.CPUStats) float64 {
+	if cpu.CPUUsage.TotalUsage < preCPU.CPUUsage.TotalUsage || cpu.SystemUsage <= preCPU.SystemUsage {
+		return 0
+	}
+
+	cpuDelta := float64(cpu.CPUUsage.TotalUsage - preCPU.CPUUsage.TotalUsage)
+	systemDelta := float64(cpu.SystemUsage - preCPU.SystemUsage)
+
+	onlineCPUs := float64(cpu.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(cpu.CPUUsage.PercpuUsage))
+	}
+	if onlineCPUs == 0 {
+		onlineCPUs = 1
+	}
+
+	return cpuDelta / systemDelta * onlineCPUs * 100.0
+}
+
+// memWorkingSet returns the memory usage without the page cache which
+// the kernel can reclaim
+func memWorkingSet(mem types.MemoryStats) uint64 {
+	// cgroup v1 reports total_inactive_file; cgroup v2 inactive_file
+	cache, ok := mem.Stats["total_inactive_file"]
+	if !ok {
+		cache = mem.Stats["inactive_file"]
+	}
+
+	if cache > mem.Usage {
+		return 0
+	}
+
+	return mem.Usage - cache
+}
+
+// blkioTotals sums the read and write entries for all devices
+func blkioTotals(entries []types.BlkioStatEntry) (uint64, uint64) {
+	var read, write uint64
+	for _, e := range entries {
+		switch strings.ToLower(e.Op) {
+		case "read":
+			read += e.Value
+		case "write":
+			write += e.Value
+		}
+	}
+
+	return read, write
+}
+
+func counterRate(cur, prev uint64, elapsed float64) float64 {
+	// counters reset when the container restarts
+	if cur < prev {
+		return 0
+	}
+
+	return float64(cur-prev) / elapsed
+}
@@ -0,0 +1,232 @@
+package beacon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func testStats(read time.Time, cpuTotal, systemTotal, reads, writes uint64) *types.StatsJSON {
+	s := &types.StatsJSON{}
+	s.Read = read
+	s.CPUStats = types.CPUStats{
+		CPUUsage: types.CPUUsage{
+			TotalUsage:  cpuTotal,
+			PercpuUsage: []uint64{cpuTotal / 2, cpuTotal / 2},
+		},
+		SystemUsage: systemTotal,
+		ThrottlingData: types.ThrottlingData{
+			Periods:          100,
+			ThrottledPeriods: 10,
+			ThrottledTime:    5000,
+		},
+	}
+	s.MemoryStats = types.MemoryStats{
+		Usage: 600,
+		Limit: 1000,
+		Stats: map[string]uint64{
+			"total_inactive_file": 100,
+		},
+	}
+	s.BlkioStats = types.BlkioStats{
+		IoServiceBytesRecursive: []types.BlkioStatEntry{
+			{Major: 8, Minor: 0, Op: "Read", Value: 4096},
+			{Major: 8, Minor: 0, Op: "Write", Value: 1024},
+			{Major: 8, Minor: 0, Op: "Total", Value: 5120},
+			{Major: 8, Minor: 16, Op: "Read", Value: 4096},
+		},
+		IoServicedRecursive: []types.BlkioStatEntry{
+			{Major: 8, Minor: 0, Op: "Read", Value: reads},
+			{Major: 8, Minor: 0, Op: "Write", Value: writes},
+		},
+	}
+	s.PidsStats = types.PidsStats{
+		Current: 7,
+	}
+
+	return s
+}
+
+func TestComputeUsage(t *testing.T) {
+	now := time.Now()
+	prev := testStats(now, 1000, 10000, 10, 20)
+	cur := testStats(now.Add(time.Second*10), 2000, 20000, 110, 70)
+
+	u := computeUsage(cur, prev)
+
+	// 1000 / 10000 * 2 cpus
+	if u.CPUPercent != 20 {
+		t.Fatalf("expected cpu percent 20; received %v", u.CPUPercent)
+	}
+
+	if u.CPUThrottledPeriods != 10 || u.CPUThrottledTime != 5000 {
+		t.Fatalf("unexpected throttling: periods=%d time=%d", u.CPUThrottledPeriods, u.CPUThrottledTime)
+	}
+
+	if u.MemWorkingSet != 500 {
+		t.Fatalf("expected working set 500; received %d", u.MemWorkingSet)
+	}
+
+	if u.MemPercent != 50 {
+		t.Fatalf("expected memory percent 50; received %v", u.MemPercent)
+	}
+
+	if u.BlkioReadBytes != 8192 || u.BlkioWriteBytes != 1024 {
+		t.Fatalf("unexpected blkio bytes: read=%d write=%d", u.BlkioReadBytes, u.BlkioWriteBytes)
+	}
+
+	if u.BlkioReadIOPS != 10 || u.BlkioWriteIOPS != 5 {
+		t.Fatalf("unexpected iops: read=%v write=%v", u.BlkioReadIOPS, u.BlkioWriteIOPS)
+	}
+
+	if u.Pids != 7 {
+		t.Fatalf("expected 7 pids; received %d", u.Pids)
+	}
+}
+
+func TestComputeUsagePreCPUStats(t *testing.T) {
+	cur := testStats(time.Now(), 3000, 20000, 10, 10)
+	cur.PreCPUStats = types.CPUStats{
+		CPUUsage: types.CPUUsage{
+			TotalUsage: 1000,
+		},
+		SystemUsage: 10000,
+	}
+	cur.CPUStats.OnlineCPUs = 4
+
+	u := computeUsage(cur, nil)
+
+	// 2000 / 10000 * 4 cpus
+	if u.CPUPercent != 80 {
+		t.Fatalf("expected cpu percent 80; received %v", u.CPUPercent)
+	}
+
+	if u.BlkioReadIOPS != 0 || u.BlkioWriteIOPS != 0 {
+		t.Fatal("expected no iops without previous sample")
+	}
+}
+
+func TestComputeUsageCounterReset(t *testing.T) {
+	now := time.Now()
+	prev := testStats(now, 5000, 10000, 500, 500)
+	cur := testStats(now.Add(time.Second), 1000, 20000, 10, 10)
+
+	u := computeUsage(cur, prev)
+
+	if u.CPUPercent != 0 {
+		t.Fatalf("expected cpu percent 0 after restart; received %v", u.CPUPercent)
+	}
+
+	if u.BlkioReadIOPS != 0 || u.BlkioWriteIOPS != 0 {
+		t.Fatal("expected no iops after restart")
+	}
+}
+
+func TestMemWorkingSetCgroupV2(t *testing.T) {
+	ws := memWorkingSet(types.MemoryStats{
+		Usage: 1000,
+		Stats: map[string]uint64{
+			"inactive_file": 250,
+		},
+	})
+
+	if ws != 750 {
+		t.Fatalf("expected working set 750; received %d", ws)
+	}
+}
+
+func testStat() Stat {
+	now := time.Now()
+	stats := testStats(now.Add(time.Second*10), 2000, 20000, 110, 70)
+
+	return Stat{
+		ID:              "abcdef123456",
+		Name:            "web",
+		Image:           "nginx",
+		MemUsagePercent: 50,
+		Usage:           computeUsage(stats, testStats(now, 1000, 10000, 10, 20)),
+		Stats:           stats,
+	}
+}
+
+func TestSendPrometheusUsage(t *testing.T) {
+	stat := testStat()
+	sendPrometheus(stat)
+
+	tests := []struct {
+		gauge    *prometheus.GaugeVec
+		typ      string
+		expected float64
+	}{
+		{counterCpuPercent, "cpu", 20},
+		{counterCpuThrottledPeriods, "cpu", 10},
+		{counterMemoryWorkingSet, "memory", 500},
+		{counterBlkioReadBytes, "blkio", 8192},
+		{counterBlkioWriteIOPS, "blkio", 5},
+		{counterPids, "pids", 7},
+	}
+
+	for _, test := range tests {
+		m := &dto.Metric{}
+		g := test.gauge.With(prometheus.Labels{
+			"container": stat.ID,
+			"image":     stat.Image,
+			"name":      stat.Name,
+			"type":      test.typ,
+		})
+		if err := g.Write(m); err != nil {
+			t.Fatal(err)
+		}
+
+		if v := m.GetGauge().GetValue(); v != test.expected {
+			t.Fatalf("expected %v; received %v", test.expected, v)
+		}
+	}
+}
+
+func TestInfluxDBPointsUsage(t *testing.T) {
+	points, err := influxDBPoints(testStat(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"cpu_percent":       20.0,
+		"mem_working_set":   int64(500),
+		"blkio_read_bytes":  int64(8192),
+		"blkio_write_iops":  5.0,
+		"pids":              int64(7),
+		"mem_usage_percent": 50.0,
+	}
+
+	found := map[string]bool{}
+	for _, pt := range points {
+		v, ok := expected[pt.Name()]
+		if !ok {
+			continue
+		}
+
+		fields, err := pt.Fields()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if fields["value"] != v {
+			t.Fatalf("%s: expected %v; received %v (%T)", pt.Name(), v, fields["value"], fields["value"])
+		}
+
+		if pt.Tags()["container"] != "abcdef123456" {
+			t.Fatalf("%s: expected container tag", pt.Name())
+		}
+		found[pt.Name()] = true
+	}
+
+	for name := range expected {
+		if !found[name] {
+			t.Fatalf("missing point %s", name)
+		}
+	}
+}
@@ -3,27 +3,43 @@ package ext
 import "github.com/ehazlett/interlock/events"
 
 const (
-	InterlockAppLabel                 = "interlock.app"                    // internal
-	InterlockExtNameLabel             = "interlock.ext.name"               // common
-	InterlockHostnameLabel            = "interlock.hostname"               // haproxy, nginx
-	InterlockNetworkLabel             = "interlock.network"                // common
-	InterlockDomainLabel              = "interlock.domain"                 // haproxy, nginx
-	InterlockSSLLabel                 = "interlock.ssl"                    // nginx
-	InterlockSSLOnlyLabel             = "interlock.ssl_only"               // haproxy, nginx
-	InterlockSSLBackendLabel          = "interlock.ssl_backend"            // haproxy, nginx
-	InterlockSSLBackendTLSVerifyLabel = "interlock.ssl_backend_tls_verify" // haproxy, nginx
-	InterlockSSLCertLabel             = "interlock.ssl_cert"               // nginx
-	InterlockSSLCertKeyLabel          = "interlock.ssl_cert_key"           // nginx
-	InterlockPortLabel                = "interlock.port"                   // haproxy, nginx
-	InterlockWebsocketEndpointLabel   = "interlock.websocket_endpoint"     // nginx
-	InterlockAliasDomainLabel         = "interlock.alias_domain"           // haproxy, nginx
-	InterlockHealthCheckLabel         = "interlock.health_check"           // haproxy
-	InterlockHealthCheckIntervalLabel = "interlock.health_check_interval"  // haproxy
-	InterlockBalanceAlgorithmLabel    = "interlock.balance_algorithm"      // haproxy
-	InterlockBackendOptionLabel       = "interlock.backend_option"         // haproxy, nginx
-	InterlockIPHashLabel              = "interlock.ip_hash"                // nginx
-	InterlockContextRootLabel         = "interlock.context_root"           // haproxy, nginx
-	InterlockContextRootRewriteLabel  = "interlock.context_root_rewrite"   // haproxy, nginx
+	InterlockAppLabel                   = "interlock.app"                     // internal
+	InterlockExtNameLabel               = "interlock.ext.name"                // common
+	InterlockHostnameLabel              = "interlock.hostname"                // haproxy, nginx
+	InterlockNetworkLabel               = "interlock.network"                 // common
+	InterlockDomainLabel                = "interlock.domain"                  // haproxy, nginx
+	InterlockSSLLabel                   = "interlock.ssl"                     // nginx
+	InterlockSSLOnlyLabel               = "interlock.ssl_only"                // haproxy, nginx
+	InterlockSSLBackendLabel            = "interlock.ssl_backend"             // haproxy, nginx
+	InterlockSSLBackendTLSVerifyLabel   = "interlock.ssl_backend_tls_verify"  // haproxy, nginx
+	InterlockSSLCertLabel               = "interlock.ssl_cert"                // nginx
+	InterlockSSLCertKeyLabel            = "interlock.ssl_cert_key"            // nginx
+	InterlockPortLabel                  = "interlock.port"                    // haproxy, nginx
+	InterlockWebsocketEndpointLabel     = "interlock.websocket_endpoint"      // nginx
+	InterlockAliasDomainLabel           = "interlock.alias_domain"            // haproxy, nginx
+	InterlockHealthCheckLabel           = "interlock.health_check"            // haproxy
+	InterlockHealthCheckIntervalLabel   = "interlock.health_check_interval"   // haproxy
+	InterlockBalanceAlgorithmLabel      = "interlock.balance_algorithm"       // haproxy
+	InterlockBackendOptionLabel         = "interlock.backend_option"          // haproxy, nginx
+	InterlockIPHashLabel                = "interlock.ip_hash"                 // nginx
+	InterlockContextRootLabel           = "interlock.context_root"            // haproxy, nginx
+	InterlockContextRootRewriteLabel    = "interlock.context_root_rewrite"    // haproxy, nginx
+	InterlockResponseHeaderLabel        = "interlock.response_header"         // haproxy, nginx
+	InterlockRequestHeaderLabel         = "interlock.request_header"          // haproxy, nginx
+	InterlockCORSOriginLabel            = "interlock.cors_origin"             // haproxy, nginx
+	InterlockCORSMethodsLabel           = "interlock.cors_methods"            // haproxy, nginx
+	InterlockCORSHeadersLabel           = "interlock.cors_headers"            // haproxy, nginx
+	InterlockHSTSMaxAgeLabel            = "interlock.hsts_max_age"            // haproxy, nginx
+	InterlockHSTSIncludeSubdomainsLabel = "interlock.hsts_include_subdomains" // haproxy, nginx
+	InterlockHSTSPreloadLabel           = "interlock.hsts_preload"            // haproxy, nginx
+	InterlockRedirectLabel              = "interlock.redirect"                // haproxy, nginx
+	InterlockRewriteLabel               = "interlock.rewrite"                 // haproxy, nginx
+	InterlockStickyCookieLabel          = "interlock.sticky_cookie"           // haproxy, nginx
+	InterlockHTTP2Label                 = "interlock.http2"                   // haproxy, nginx
+	InterlockBackendProtocolLabel       = "interlock.backend_protocol"        // haproxy, nginx
+	InterlockGzipLabel                  = "interlock.gzip"                    // haproxy, nginx
+	InterlockCacheLabel                 = "interlock.cache"                   // haproxy, nginx
+	InterlockCacheBypassHeaderLabel     = "interlock.cache_bypass_header"     // haproxy, nginx
 )
 
 type Extension interface {
@@ -0,0 +1,98 @@
+package ext
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultLabelPrefix is the prefix of the interlock labels
+const DefaultLabelPrefix = "interlock."
+
+var labelPrefix = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9._-]*[a-zA-Z0-9])?[./]$`)
+
+// LabelNamespace is the set of labels used by an extension: the interlock
+// labels under an alternative prefix (i.e. public.interlock.hostname or
+// interlock.example.com/hostname) and an optional selector.  It allows
+// several interlock stacks to select disjoint containers.
+type LabelNamespace struct {
+	Prefix   string
+	Selector Selector
+}
+
+// NewLabelNamespace returns the namespace of the prefix and selector; the
+// default prefix is used if empty
+func NewLabelNamespace(prefix, selector string) (*LabelNamespace, error) {
+	if prefix == "" {
+		prefix = DefaultLabelPrefix
+	}
+
+	if err := ValidateLabelPrefix(prefix); err != nil {
+		return nil, err
+	}
+
+	s, err := ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LabelNamespace{
+		Prefix:   prefix,
+		Selector: s,
+	}, nil
+}
+
+// ValidateLabelPrefix checks the prefix ends with a . or a / (i.e.
+// public.interlock. or interlock.example.com/)
+func ValidateLabelPrefix(prefix string) error {
+	if !labelPrefix.MatchString(prefix) {
+		return fmt.Errorf("invalid label prefix %q; expected a prefix ending with . or / (i.e. public.interlock.)", prefix)
+	}
+
+	return nil
+}
+
+// Label returns the name of the interlock label in the namespace (i.e.
+// public.interlock.hostname for InterlockHostnameLabel)
+func (n *LabelNamespace) Label(label string) string {
+	return n.Prefix + strings.TrimPrefix(label, DefaultLabelPrefix)
+}
+
+// Filters returns the docker label filters of the containers of the
+// namespace
+func (n *LabelNamespace) Filters() []string {
+	return append([]string{n.Label(InterlockHostnameLabel)}, n.Selector.Filters()...)
+}
+
+// Selects returns whether the container labels are in the namespace and
+// match the selector
+func (n *LabelNamespace) Selects(labels map[string]string) bool {
+	if _, ok := labels[n.Label(InterlockHostnameLabel)]; !ok {
+		return false
+	}
+
+	return n.Selector.Matches(labels)
+}
+
+// Normalize returns the labels with the labels of the namespace renamed to
+// the interlock labels so they are read by the label parsers.  The
+// interlock labels outside of the namespace are removed.
+func (n *LabelNamespace) Normalize(labels map[string]string) map[string]string {
+	if n.Prefix == DefaultLabelPrefix {
+		return labels
+	}
+
+	normalized := map[string]string{}
+	for k, v := range labels {
+		switch {
+		case strings.HasPrefix(k, n.Prefix):
+			normalized[DefaultLabelPrefix+strings.TrimPrefix(k, n.Prefix)] = v
+		case strings.HasPrefix(k, DefaultLabelPrefix):
+			continue
+		default:
+			normalized[k] = v
+		}
+	}
+
+	return normalized
+}
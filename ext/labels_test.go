@@ -0,0 +1,144 @@
+package ext
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSelector(t *testing.T) {
+	s, err := ParseSelector("env=prod, tier!=internal,team in (web, api),legacy notin (true),canary,!debug,zone==a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Selector{
+		{Key: "env", Operator: Equals, Values: []string{"prod"}},
+		{Key: "tier", Operator: NotEquals, Values: []string{"internal"}},
+		{Key: "team", Operator: In, Values: []string{"web", "api"}},
+		{Key: "legacy", Operator: NotIn, Values: []string{"true"}},
+		{Key: "canary", Operator: Exists},
+		{Key: "debug", Operator: DoesNotExist},
+		{Key: "zone", Operator: Equals, Values: []string{"a"}},
+	}
+
+	if !reflect.DeepEqual(s, expected) {
+		t.Fatalf("expected %+v; received %+v", expected, s)
+	}
+
+	if f := s.Filters(); !reflect.DeepEqual(f, []string{"env=prod", "canary", "zone=a"}) {
+		t.Fatalf("unexpected filters: %v", f)
+	}
+}
+
+func TestParseSelectorInvalid(t *testing.T) {
+	for _, s := range []string{
+		"=prod",
+		"!",
+		"env=prod=1",
+		"team in (web api)",
+		"my label",
+	} {
+		if _, err := ParseSelector(s); err == nil {
+			t.Fatalf("expected error for %q", s)
+		}
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	s, err := ParseSelector("env=prod,tier!=internal,team in (web,api),!debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		labels  map[string]string
+		matches bool
+	}{
+		{map[string]string{"env": "prod", "team": "web"}, true},
+		{map[string]string{"env": "prod", "team": "api", "tier": "public"}, true},
+		{map[string]string{"env": "dev", "team": "web"}, false},
+		{map[string]string{"env": "prod", "team": "web", "tier": "internal"}, false},
+		{map[string]string{"env": "prod", "team": "db"}, false},
+		{map[string]string{"env": "prod", "team": "web", "debug": ""}, false},
+	}
+
+	for _, test := range tests {
+		if m := s.Matches(test.labels); m != test.matches {
+			t.Fatalf("expected %v for %v; received %v", test.matches, test.labels, m)
+		}
+	}
+
+	// an empty selector matches all the containers
+	empty, err := ParseSelector("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !empty.Matches(map[string]string{}) {
+		t.Fatal("expected empty selector to match")
+	}
+}
+
+func TestLabelNamespace(t *testing.T) {
+	n, err := NewLabelNamespace("public.interlock.", "env=prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := n.Label(InterlockHostnameLabel); l != "public.interlock.hostname" {
+		t.Fatalf("unexpected label: %s", l)
+	}
+
+	if f := n.Filters(); !reflect.DeepEqual(f, []string{"public.interlock.hostname", "env=prod"}) {
+		t.Fatalf("unexpected filters: %v", f)
+	}
+
+	labels := map[string]string{
+		"public.interlock.hostname": "web",
+		"public.interlock.domain":   "example.com",
+		"interlock.hostname":        "internal",
+		"interlock.ssl":             "true",
+		"env":                       "prod",
+	}
+
+	if !n.Selects(labels) {
+		t.Fatal("expected labels to be selected")
+	}
+
+	expected := map[string]string{
+		"interlock.hostname": "web",
+		"interlock.domain":   "example.com",
+		"env":                "prod",
+	}
+
+	if normalized := n.Normalize(labels); !reflect.DeepEqual(normalized, expected) {
+		t.Fatalf("expected %v; received %v", expected, normalized)
+	}
+
+	// containers of the default namespace are not selected
+	if n.Selects(map[string]string{"interlock.hostname": "web", "env": "prod"}) {
+		t.Fatal("expected default labels not to be selected")
+	}
+}
+
+func TestLabelNamespaceKubernetesPrefix(t *testing.T) {
+	n, err := NewLabelNamespace("interlock.example.com/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	normalized := n.Normalize(map[string]string{
+		"interlock.example.com/hostname":       "web",
+		"interlock.example.com/alias_domain.0": "www.example.com",
+	})
+
+	if normalized[InterlockHostnameLabel] != "web" || normalized[InterlockAliasDomainLabel+".0"] != "www.example.com" {
+		t.Fatalf("unexpected labels: %v", normalized)
+	}
+
+	for _, p := range []string{"public", "public.interlock", ".interlock.", "a b/"} {
+		if _, err := NewLabelNamespace(p, ""); err == nil {
+			t.Fatalf("expected error for prefix %q", p)
+		}
+	}
+}
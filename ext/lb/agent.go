@@ -0,0 +1,329 @@
+package lb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/libkv/store"
+)
+
+const (
+	// configPathPlaceholder is replaced by the path of the new config in
+	// the validate command
+	configPathPlaceholder = "{config}"
+	defaultAgentInterval  = time.Second * 5
+)
+
+// AgentConfig is the configuration of an agent
+type AgentConfig struct {
+	// Extension is the extension of the proxy (i.e. nginx)
+	Extension string
+	// LabelPrefix is the label prefix of the extension publishing the
+	// config (empty for interlock.)
+	LabelPrefix string
+	// BaseDir is the directory the config and the ssl files are written
+	// to; relative paths are relative to it and the paths outside of it
+	// are rejected
+	BaseDir string
+	// ConfigPath overrides the path of the published config
+	ConfigPath string
+	// ValidateCommand checks the new config; {config} is replaced by its
+	// path (i.e. nginx -t -c {config})
+	ValidateCommand string
+	// ReloadCommand reloads the proxy (i.e. nginx -s reload)
+	ReloadCommand string
+	// PollInterval is used for the stores without watch support
+	PollInterval time.Duration
+}
+
+// Agent writes the config published for an extension along with the ssl
+// files and reloads the local proxy
+type Agent struct {
+	kv  store.Store
+	cfg *AgentConfig
+	// baseDir is the base directory with the symlinks resolved
+	baseDir string
+	// revision is accessed atomically
+	revision uint64
+}
+
+// NewAgent returns an agent of the published config in the store
+func NewAgent(kv store.Store, cfg *AgentConfig) (*Agent, error) {
+	if cfg.Extension == "" {
+		return nil, fmt.Errorf("extension is required")
+	}
+
+	if cfg.BaseDir == "" {
+		return nil, fmt.Errorf("base directory is required")
+	}
+
+	baseDir, err := filepath.EvalSymlinks(cfg.BaseDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base directory: %s", err)
+	}
+
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = defaultAgentInterval
+	}
+
+	return &Agent{
+		kv:      kv,
+		cfg:     cfg,
+		baseDir: baseDir,
+	}, nil
+}
+
+// Revision returns the revision of the applied config
+func (a *Agent) Revision() uint64 {
+	return atomic.LoadUint64(&a.revision)
+}
+
+// Run applies the published config on each change until stopped.  The key
+// is watched if the store supports it otherwise it is polled.  The watch is
+// established again when it is closed (i.e. the connection to the store is
+// lost).
+func (a *Agent) Run(stopCh <-chan struct{}) error {
+	key := a.key()
+
+	for {
+		pairs, err := a.kv.Watch(key, stopCh)
+		switch err {
+		case nil:
+			log().Infof("watching published config: key=%s", key)
+			for kv := range pairs {
+				if kv == nil {
+					continue
+				}
+				a.apply(kv.Value)
+			}
+			log().Warnf("watch of published config closed: key=%s", key)
+		case store.ErrCallNotSupported:
+			return a.poll(stopCh)
+		default:
+			log().Errorf("unable to watch published config: key=%s err=%s", key, err)
+		}
+
+		if !a.wait(stopCh) {
+			return nil
+		}
+
+		// changes may have been missed while the watch was closed
+		if err := a.Sync(); err != nil {
+			log().Error(err)
+		}
+	}
+}
+
+// poll applies the published config at each interval until stopped
+func (a *Agent) poll(stopCh <-chan struct{}) error {
+	log().Infof("polling published config: key=%s interval=%s", a.key(), a.cfg.PollInterval)
+
+	for {
+		if err := a.Sync(); err != nil {
+			log().Error(err)
+		}
+
+		if !a.wait(stopCh) {
+			return nil
+		}
+	}
+}
+
+// wait waits for the poll interval; it returns false if stopped
+func (a *Agent) wait(stopCh <-chan struct{}) bool {
+	t := time.NewTimer(a.cfg.PollInterval)
+	defer t.Stop()
+
+	select {
+	case <-stopCh:
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+func (a *Agent) key() string {
+	return PublishKey(a.cfg.Extension, a.cfg.LabelPrefix)
+}
+
+// Sync applies the published config if it changed
+func (a *Agent) Sync() error {
+	kv, err := a.kv.Get(a.key())
+	if err == store.ErrKeyNotFound {
+		log().Debugf("no published config: extension=%s", a.cfg.Extension)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return a.apply(kv.Value)
+}
+
+// apply writes the ssl files and the config, validates the config and
+// reloads the proxy; an invalid config is not applied
+func (a *Agent) apply(data []byte) error {
+	var pc PublishedConfig
+	if err := json.Unmarshal(data, &pc); err != nil {
+		err = fmt.Errorf("invalid published config: %s", err)
+		log().Error(err)
+		return err
+	}
+
+	if pc.Revision <= a.Revision() {
+		return nil
+	}
+
+	if err := a.applyConfig(&pc); err != nil {
+		err = fmt.Errorf("unable to apply config revision %d: %s", pc.Revision, err)
+		log().Error(err)
+		return err
+	}
+
+	atomic.StoreUint64(&a.revision, pc.Revision)
+	log().Infof("applied config: extension=%s revision=%d", pc.Extension, pc.Revision)
+
+	return nil
+}
+
+// stagedFile is a new file written next to the file it replaces
+type stagedFile struct {
+	path    string
+	tmpPath string
+}
+
+// applyConfig writes the ssl files and the config next to their path; the
+// current files are only replaced once the new config is valid
+func (a *Agent) applyConfig(pc *PublishedConfig) error {
+	configPath := pc.ConfigPath
+	if a.cfg.ConfigPath != "" {
+		configPath = a.cfg.ConfigPath
+	}
+
+	configPath, err := a.localPath(configPath)
+	if err != nil {
+		return err
+	}
+
+	paths := []string{}
+	for p := range pc.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	// the config is replaced last
+	files := []stagedFile{}
+	defer func() {
+		for _, f := range files {
+			os.Remove(f.tmpPath)
+		}
+	}()
+
+	for _, p := range paths {
+		path, err := a.localPath(p)
+		if err != nil {
+			return err
+		}
+
+		if err := writeFile(path+".new", pc.Files[p], 0600); err != nil {
+			return err
+		}
+		files = append(files, stagedFile{path, path + ".new"})
+	}
+
+	tmpPath := configPath + ".new"
+	if err := writeFile(tmpPath, pc.Config, 0644); err != nil {
+		return err
+	}
+	files = append(files, stagedFile{configPath, tmpPath})
+
+	if a.cfg.ValidateCommand != "" {
+		cmd := strings.Replace(a.cfg.ValidateCommand, configPathPlaceholder, tmpPath, -1)
+		if err := runCommand(cmd); err != nil {
+			return fmt.Errorf("invalid config: %s", err)
+		}
+	}
+
+	for len(files) > 0 {
+		if err := os.Rename(files[0].tmpPath, files[0].path); err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+
+	if a.cfg.ReloadCommand != "" {
+		if err := runCommand(a.cfg.ReloadCommand); err != nil {
+			return fmt.Errorf("reload failed: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// localPath returns the path of a published file in the base directory;
+// relative paths are relative to the base directory and the paths outside
+// of it, including through a symlinked directory, are rejected
+func (a *Agent) localPath(p string) (string, error) {
+	base := filepath.Clean(a.cfg.BaseDir)
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(base, p)
+	}
+	p = filepath.Clean(p)
+
+	if p == base || !within(base, p) {
+		return "", fmt.Errorf("%s is outside of the base directory %s", p, base)
+	}
+
+	// the directories are created when missing so the first existing
+	// directory is checked
+	for dir := filepath.Dir(p); ; dir = filepath.Dir(dir) {
+		real, err := filepath.EvalSymlinks(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if !within(a.baseDir, real) {
+			return "", fmt.Errorf("%s is outside of the base directory %s", p, base)
+		}
+
+		return p, nil
+	}
+}
+
+// within returns true if the path is the directory or in the directory
+func within(dir, p string) bool {
+	rel, err := filepath.Rel(dir, p)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func writeFile(path string, data []byte, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, mode)
+}
+
+func runCommand(cmd string) error {
+	out, err := exec.Command("sh", "-c", cmd).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %s: %s", cmd, err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
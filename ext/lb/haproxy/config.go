@@ -2,6 +2,7 @@ package haproxy
 
 import (
 	"github.com/ehazlett/interlock/config"
+	"github.com/ehazlett/interlock/ext/lb/utils"
 )
 
 type ContextRoot struct {
@@ -16,11 +17,27 @@ type Host struct {
 	Domain              string
 	Check               string
 	BackendOptions      []string
+	RequestHeaders      []utils.Header
+	ResponseHeaders     []utils.Header
+	HSTS                string
+	Redirects           []utils.Redirect
+	Rewrites            []*Rewrite
 	Upstreams           []*Upstream
 	SSLOnly             bool
 	SSLBackend          bool
 	SSLBackendTLSVerify string
 	BalanceAlgorithm    string
+	StickyCookie        string
+	HTTP2               bool
+	BackendProtocol     string
+	Gzip                bool
+	Cache               *utils.CachePolicy
+	CacheBypassHeader   string
+}
+
+type Rewrite struct {
+	Regex       string
+	Replacement string
 }
 
 type Upstream struct {
@@ -31,6 +48,14 @@ type Upstream struct {
 
 type Config struct {
 	Hosts    []*Host
-	Config   *config.ExtensionConfig
+	Config   *TemplateConfig
 	Networks map[string]string
+	HTTP2    bool
+}
+
+// TemplateConfig has the extension options available to the template as
+// .Config (i.e. .Config.MaxConn)
+type TemplateConfig struct {
+	*config.ExtensionConfig
+	*config.HAProxyConfig
 }
@@ -2,14 +2,22 @@ package haproxy
 
 import (
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/docker/docker/api/types"
 	"github.com/ehazlett/interlock/ext/lb/utils"
+	"github.com/ehazlett/interlock/metrics"
+	"github.com/ehazlett/interlock/trace"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/net/context"
 )
 
-func (p *HAProxyLoadBalancer) GenerateProxyConfig(containers []types.Container) (interface{}, error) {
+// haproxy uses \1 instead of $1 for back references
+var backReferenceRegex = regexp.MustCompile(`\$(\d)`)
+
+func (p *HAProxyLoadBalancer) GenerateProxyConfig(ctx context.Context, containers []types.Container) (interface{}, error) {
 	var hosts []*Host
 
 	proxyUpstreams := map[string][]*Upstream{}
@@ -21,10 +29,34 @@ func (p *HAProxyLoadBalancer) GenerateProxyConfig(containers []types.Container)
 	hostSSLOnly := map[string]bool{}
 	hostSSLBackend := map[string]bool{}
 	hostSSLBackendTLSVerify := map[string]string{}
+	hostRequestHeaders := map[string][]utils.Header{}
+	hostResponseHeaders := map[string][]utils.Header{}
+	hostHSTS := map[string]string{}
+	hostRedirects := map[string][]utils.Redirect{}
+	hostRewrites := map[string][]*Rewrite{}
+	hostStickyCookies := map[string]string{}
+	hostHTTP2 := map[string]bool{}
+	hostBackendProtocols := map[string]string{}
+	hostGzip := map[string]bool{}
+	hostCache := map[string]*utils.CachePolicy{}
+	hostCacheBypassHeaders := map[string]string{}
 
 	networks := map[string]string{}
+	upstreams := 0
+
+	// the containers are sorted and the settings of the hosts merged so
+	// the config does not depend on the order of the containers
+	hostContainers, conflicts := utils.MergeHostSettings(containers, p.cfg.HAProxy.ConflictPolicy)
+	for _, conflict := range conflicts {
+		if conflict.Policy == utils.ConflictPolicyStrict {
+			log().Error(conflict)
+		} else {
+			log().Warn(conflict)
+		}
+	}
+	metrics.SettingConflicts(p.Name(), p.cfg.HAProxy.ConflictPolicy, len(conflicts))
 
-	for _, c := range containers {
+	for _, c := range hostContainers {
 		cntId := c.ID[:12]
 		// load interlock data
 		hostname := utils.Hostname(c)
@@ -55,21 +87,99 @@ func (p *HAProxyLoadBalancer) GenerateProxyConfig(containers []types.Container)
 			continue
 		}
 
-		if healthCheck != "" {
-			if val, ok := hostChecks[domain]; ok {
-				// check existing host check for different values
-				if val != healthCheck {
-					log().Warnf("conflicting check specified for %s", domain)
-				}
-			} else {
-				hostChecks[domain] = healthCheck
-				log().Debugf("using custom check for %s: %s", domain, healthCheck)
-			}
+		requestHeaders, err := utils.RequestHeaders(c)
+		if err != nil {
+			log().Errorf("error parsing request headers: %s", err)
+			continue
+		}
+
+		responseHeaders, err := utils.ResponseHeaders(c)
+		if err != nil {
+			log().Errorf("error parsing response headers: %s", err)
+			continue
+		}
+
+		corsHeaders, err := utils.CORSHeaders(c)
+		if err != nil {
+			log().Errorf("error parsing cors policy: %s", err)
+			continue
+		}
+
+		hsts, err := utils.HSTS(c, p.cfg.HAProxy.HSTSMaxAge)
+		if err != nil {
+			log().Errorf("error parsing hsts policy: %s", err)
+			continue
+		}
+
+		redirects, err := utils.Redirects(c)
+		if err != nil {
+			log().Errorf("error parsing redirects: %s", err)
+			continue
+		}
+
+		rewrites, err := utils.Rewrites(c)
+		if err != nil {
+			log().Errorf("error parsing rewrites: %s", err)
+			continue
+		}
+
+		stickyCookie, err := utils.StickyCookie(c)
+		if err != nil {
+			log().Errorf("error parsing sticky cookie: %s", err)
+			continue
+		}
+
+		http2, err := utils.HTTP2(c)
+		if err != nil {
+			log().Errorf("error parsing http2: %s", err)
+			continue
+		}
 
+		backendProtocol, err := utils.BackendProtocol(c)
+		if err != nil {
+			log().Errorf("error parsing backend protocol: %s", err)
+			continue
+		}
+
+		gzip, err := utils.Gzip(c)
+		if err != nil {
+			log().Errorf("error parsing gzip: %s", err)
+			continue
+		}
+
+		cache, err := utils.Cache(c, p.cfg.HAProxy.CacheZoneSize)
+		if err != nil {
+			log().Errorf("error parsing cache policy: %s", err)
+			continue
+		}
+
+		cacheBypassHeader, err := utils.CacheBypassHeader(c)
+		if err != nil {
+			log().Errorf("error parsing cache bypass header: %s", err)
+			continue
+		}
+
+		// grpc clients require http2
+		if backendProtocol == utils.BackendProtocolGRPC {
+			http2 = true
+		}
+
+		if healthCheck != "" {
+			hostChecks[domain] = healthCheck
+			log().Debugf("using custom check for %s: %s", domain, healthCheck)
 			log().Debugf("check interval for %s: %d", domain, healthCheckInterval)
 		}
 
 		hostBalanceAlgorithms[domain] = utils.BalanceAlgorithm(c)
+		hostStickyCookies[domain] = stickyCookie
+		hostHTTP2[domain] = http2
+		hostBackendProtocols[domain] = backendProtocol
+		hostGzip[domain] = gzip
+
+		if cache != nil {
+			hostCache[domain] = cache
+			hostCacheBypassHeaders[domain] = cacheBypassHeader
+		}
 
 		backendOptions := utils.BackendOptions(c)
 
@@ -78,6 +188,32 @@ func (p *HAProxyLoadBalancer) GenerateProxyConfig(containers []types.Container)
 			log().Debugf("using backend options for %s: %s", domain, strings.Join(backendOptions, ","))
 		}
 
+		if len(requestHeaders) > 0 {
+			hostRequestHeaders[domain] = requestHeaders
+		}
+
+		// cors headers are rendered as regular response headers
+		responseHeaders = append(corsHeaders, responseHeaders...)
+		if len(responseHeaders) > 0 {
+			hostResponseHeaders[domain] = responseHeaders
+		}
+
+		hostHSTS[domain] = hsts
+
+		if len(redirects) > 0 {
+			hostRedirects[domain] = redirects
+		}
+
+		if len(rewrites) > 0 {
+			hostRewrites[domain] = []*Rewrite{}
+			for _, rw := range rewrites {
+				hostRewrites[domain] = append(hostRewrites[domain], &Rewrite{
+					Regex:       rw.Regex,
+					Replacement: backReferenceRegex.ReplaceAllString(rw.Replacement, `\$1`),
+				})
+			}
+		}
+
 		hostSSLOnly[domain] = utils.SSLOnly(c)
 
 		// ssl backend
@@ -90,7 +226,14 @@ func (p *HAProxyLoadBalancer) GenerateProxyConfig(containers []types.Container)
 		if n, ok := utils.OverlayEnabled(c); ok {
 			log().Debugf("configuring docker network: name=%s", n)
 
-			network, err := p.client.NetworkInspect(context.Background(), n, false)
+			_, span := trace.StartKind(ctx, "docker.network_inspect", trace.KindClient)
+			span.SetAttributes(
+				attribute.String("container", cntId),
+				attribute.String("network", n),
+			)
+			network, err := p.client.NetworkInspect(ctx, n, false)
+			trace.SetError(span, err)
+			span.End()
 			if err != nil {
 				log().Error(err)
 				continue
@@ -109,7 +252,7 @@ func (p *HAProxyLoadBalancer) GenerateProxyConfig(containers []types.Container)
 				continue
 			}
 
-			a, err := utils.BackendAddress(c, p.cfg.BackendOverrideAddress)
+			a, err := utils.BackendAddress(c, p.cfg.HAProxy.BackendOverrideAddress)
 			if err != nil {
 				log().Error(err)
 				continue
@@ -138,9 +281,21 @@ func (p *HAProxyLoadBalancer) GenerateProxyConfig(containers []types.Container)
 				Name: contextRootName,
 				Path: contextRoot,
 			}
+			hostRequestHeaders[alias] = hostRequestHeaders[domain]
+			hostResponseHeaders[alias] = hostResponseHeaders[domain]
+			hostHSTS[alias] = hostHSTS[domain]
+			hostRedirects[alias] = hostRedirects[domain]
+			hostRewrites[alias] = hostRewrites[domain]
+			hostStickyCookies[alias] = hostStickyCookies[domain]
+			hostHTTP2[alias] = hostHTTP2[domain]
+			hostBackendProtocols[alias] = hostBackendProtocols[domain]
+			hostGzip[alias] = hostGzip[domain]
+			hostCache[alias] = hostCache[domain]
+			hostCacheBypassHeaders[alias] = hostCacheBypassHeaders[domain]
 		}
 
 		proxyUpstreams[domain] = append(proxyUpstreams[domain], up)
+		upstreams++
 	}
 
 	for k, v := range proxyUpstreams {
@@ -153,7 +308,18 @@ func (p *HAProxyLoadBalancer) GenerateProxyConfig(containers []types.Container)
 			Upstreams:           v,
 			Check:               hostChecks[k],
 			BalanceAlgorithm:    hostBalanceAlgorithms[k],
+			StickyCookie:        hostStickyCookies[k],
+			HTTP2:               hostHTTP2[k],
+			BackendProtocol:     hostBackendProtocols[k],
+			Gzip:                hostGzip[k],
+			Cache:               hostCache[k],
+			CacheBypassHeader:   hostCacheBypassHeaders[k],
 			BackendOptions:      hostBackendOptions[k],
+			RequestHeaders:      hostRequestHeaders[k],
+			ResponseHeaders:     hostResponseHeaders[k],
+			HSTS:                hostHSTS[k],
+			Redirects:           hostRedirects[k],
+			Rewrites:            hostRewrites[k],
 			SSLOnly:             hostSSLOnly[k],
 			SSLBackend:          hostSSLBackend[k],
 			SSLBackendTLSVerify: hostSSLBackendTLSVerify[k],
@@ -162,11 +328,35 @@ func (p *HAProxyLoadBalancer) GenerateProxyConfig(containers []types.Container)
 		hosts = append(hosts, host)
 	}
 
+	sort.Sort(hostsByDomain(hosts))
+
+	// alpn is negotiated on the frontend so it is enabled
+	// if any host requires http2
+	enableHTTP2 := false
+	for _, h := range hosts {
+		if h.HTTP2 {
+			enableHTTP2 = true
+			break
+		}
+	}
+
 	cfg := &Config{
-		Hosts:    hosts,
-		Config:   p.cfg,
+		Hosts: hosts,
+		Config: &TemplateConfig{
+			ExtensionConfig: p.cfg,
+			HAProxyConfig:   p.cfg.HAProxy,
+		},
 		Networks: networks,
+		HTTP2:    enableHTTP2,
 	}
 
+	metrics.ProxyConfig(p.Name(), len(hosts), upstreams, len(containers)-upstreams)
+
 	return cfg, nil
 }
+
+type hostsByDomain []*Host
+
+func (h hostsByDomain) Len() int           { return len(h) }
+func (h hostsByDomain) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h hostsByDomain) Less(i, j int) bool { return h[i].Domain < h[j].Domain }
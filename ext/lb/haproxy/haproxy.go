@@ -1,14 +1,18 @@
 package haproxy
 
 import (
+	"fmt"
 	"io/ioutil"
 	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/client"
+	"github.com/ehazlett/interlock/client"
 	"github.com/ehazlett/interlock/config"
 	"github.com/ehazlett/interlock/events"
+	"github.com/ehazlett/interlock/metrics"
+	"github.com/ehazlett/interlock/trace"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/net/context"
 )
 
@@ -28,6 +32,10 @@ func log() *logrus.Entry {
 }
 
 func NewHAProxyLoadBalancer(c *config.ExtensionConfig, cl *client.Client) (*HAProxyLoadBalancer, error) {
+	if c.HAProxy == nil {
+		return nil, fmt.Errorf("missing haproxy config")
+	}
+
 	lb := &HAProxyLoadBalancer{
 		cfg:    c,
 		client: cl,
@@ -49,8 +57,8 @@ func (p *HAProxyLoadBalancer) ConfigPath() string {
 }
 
 func (p *HAProxyLoadBalancer) Template() string {
-	if p.cfg.TemplatePath != "" {
-		d, err := ioutil.ReadFile(p.cfg.TemplatePath)
+	if p.cfg.HAProxy.TemplatePath != "" {
+		d, err := ioutil.ReadFile(p.cfg.HAProxy.TemplatePath)
 
 		if err == nil {
 			return string(d)
@@ -63,7 +71,11 @@ func (p *HAProxyLoadBalancer) Template() string {
 
 }
 
-func (p *HAProxyLoadBalancer) Reload(proxyContainers []types.Container) error {
+// Reload restarts all the proxy containers; an error is returned if any of
+// them fails
+func (p *HAProxyLoadBalancer) Reload(ctx context.Context, proxyContainers []types.Container) error {
+	failed := 0
+
 	// drop SYN to allow for restarts
 	if err := p.dropSYN(); err != nil {
 		log().Warnf("error signaling clients to resend; you will notice dropped packets: %s", err)
@@ -73,8 +85,15 @@ func (p *HAProxyLoadBalancer) Reload(proxyContainers []types.Container) error {
 		// restart
 		log().Debugf("restarting proxy container: id=%s", cnt.ID)
 		d := time.Millisecond * 1000
-		if err := p.client.ContainerRestart(context.Background(), cnt.ID, &d); err != nil {
+		_, span := trace.StartKind(ctx, "haproxy.restart", trace.KindClient)
+		span.SetAttributes(attribute.String("container", cnt.ID))
+		err := p.client.ContainerRestart(ctx, cnt.ID, &d)
+		trace.SetError(span, err)
+		span.End()
+		metrics.ProxyReload(pluginName, cnt.ID, err)
+		if err != nil {
 			log().Errorf("error restarting container: id=%s err=%s", cnt.ID[:12], err)
+			failed++
 			continue
 		}
 
@@ -85,5 +104,9 @@ func (p *HAProxyLoadBalancer) Reload(proxyContainers []types.Container) error {
 		log().Warnf("error signaling clients to resume; you will notice dropped packets: %s", err)
 	}
 
+	if failed > 0 {
+		return fmt.Errorf("unable to restart %d of %d proxy containers", failed, len(proxyContainers))
+	}
+
 	return nil
 }
@@ -8,11 +8,11 @@ import (
 
 func (p *HAProxyLoadBalancer) configIPTables(drop bool) error {
 	ports := []int{
-		p.cfg.Port,
+		p.cfg.HAProxy.Port,
 	}
 
-	if p.cfg.SSLPort != 0 {
-		ports = append(ports, p.cfg.SSLPort)
+	if p.cfg.HAProxy.SSLPort != 0 {
+		ports = append(ports, p.cfg.HAProxy.SSLPort)
 	}
 
 	d := "-I"
@@ -26,13 +26,17 @@ defaults
 
 frontend http-default
     bind *:{{ .Config.Port }}
-    {{ if .Config.SSLCert }}bind *:{{ .Config.SSLPort }} ssl crt {{ .Config.SSLCert }} {{ .Config.SSLOpts }}{{ end }}
+    {{ if .Config.SSLCert }}bind *:{{ .Config.SSLPort }} ssl crt {{ .Config.SSLCert }}{{ if .HTTP2 }} alpn h2,http/1.1{{ end }} {{ .Config.SSLOpts }}{{ end }}
     monitor-uri /haproxy?monitor
     {{ if .Config.AdminUser }}stats realm Stats
     stats auth {{ .Config.AdminUser }}:{{ .Config.AdminPass}}{{ end }}
     stats enable
     stats uri /haproxy?stats
     stats refresh 5s
+    {{ range $host := .Hosts }}{{ range $redirect := $host.Redirects }}{{ if $redirect.IsHost }}{{ if $redirect.HasScheme }}http-request redirect prefix {{ $redirect.To }} code {{ $redirect.Code }} if { hdr_dom(host) -i {{ $redirect.From }} }
+    {{ else }}http-request redirect prefix https://{{ $redirect.To }} code {{ $redirect.Code }} if { hdr_dom(host) -i {{ $redirect.From }} } { ssl_fc }
+    http-request redirect prefix http://{{ $redirect.To }} code {{ $redirect.Code }} if { hdr_dom(host) -i {{ $redirect.From }} } !{ ssl_fc }
+    {{ end }}{{ end }}{{ end }}{{ end }}
     {{ range $host := .Hosts }}{{ if ne $host.ContextRoot.Path "" }}acl url{{ $host.ContextRoot.Name }} url_beg -i {{ $host.ContextRoot.Path }}
     use_backend {{ $host.Name }} if url{{$host.ContextRoot.Name}}{{ end }}
     acl is_{{ $host.Name }} hdr_dom(host) {{ $host.Domain }}
@@ -49,13 +53,29 @@ frontend http-default
     http-request set-header X-Forwarded-Port %[dst_port]
     http-request add-header X-Forwarded-Proto https if { ssl_fc }
     balance {{ $host.BalanceAlgorithm }}
+    {{ if $host.StickyCookie }}cookie {{ $host.StickyCookie }} insert indirect nocache{{ end }}
+    {{ if $host.Gzip }}compression algo gzip
+    compression type text/html {{ $.Config.GzipTypes }}{{ end }}
+    {{ if $host.Cache }}http-request cache-use {{ $host.Name }}{{ if $host.CacheBypassHeader }} if !{ req.hdr({{ $host.CacheBypassHeader }}) -m found }{{ end }}
+    http-response cache-store {{ $host.Name }}{{ end }}
+    {{ range $redirect := $host.Redirects }}{{ if not $redirect.IsHost }}http-request redirect location %[url,regsub(\"{{ $redirect.FromRegex }}\",\"{{ $redirect.To }}\")] code {{ $redirect.Code }} if { path_beg {{ $redirect.From }} }
+    {{ end }}{{ end }}{{ range $rewrite := $host.Rewrites }}http-request set-path %[path,regsub(\"{{ $rewrite.Regex }}\",\"{{ $rewrite.Replacement }}\")]
+    {{ end }}
     {{ range $option := $host.BackendOptions }}option {{ $option }}
     {{ end }}
     {{ if $host.Check }}option {{ $host.Check }}{{ end }}
+    {{ range $header := $host.RequestHeaders }}http-request set-header {{ $header.Name }} "{{ $header.Value }}"
+    {{ end }}{{ range $header := $host.ResponseHeaders }}http-response set-header {{ $header.Name }} "{{ $header.Value }}"
+    {{ end }}
     {{ if $host.SSLOnly }}redirect scheme https code 301 if !{ ssl_fc }{{ end }}
-	{{ if $host.SSLOnly }}http-response set-header Strict-Transport-Security "max-age=16000000; includeSubDomains; preload;"{{ end }}
-    {{ range $i,$up := $host.Upstreams }}server {{ $up.Container }} {{ $up.Addr }} check inter {{ $up.CheckInterval }}{{ if $host.SSLBackend }} ssl verify {{ $host.SSLBackendTLSVerify }} sni req.hdr(Host){{ end }}
+	{{ if $host.SSLOnly }}http-response set-header Strict-Transport-Security "{{ $host.HSTS }}"{{ end }}
+    {{ range $i,$up := $host.Upstreams }}server {{ $up.Container }} {{ $up.Addr }} check inter {{ $up.CheckInterval }}{{ if $host.StickyCookie }} cookie {{ $up.Container }}{{ end }}{{ if $host.SSLBackend }} ssl verify {{ $host.SSLBackendTLSVerify }} sni req.hdr(Host){{ if ne $host.BackendProtocol "http" }} alpn h2{{ end }}{{ else }}{{ if ne $host.BackendProtocol "http" }} proto h2{{ end }}{{ end }}
     {{ end }}
 {{ end }}
+{{ range $host := .Hosts }}{{ if $host.Cache }}
+cache {{ $host.Name }}
+    total-max-size {{ $host.Cache.ZoneSizeMB }}
+    max-age {{ $host.Cache.TTLSeconds }}
+{{ end }}{{ end }}
 `
 )
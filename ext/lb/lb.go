@@ -16,14 +16,20 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
 	ntypes "github.com/docker/docker/api/types/network"
-	"github.com/docker/docker/client"
+	"github.com/docker/libkv/store"
+	"github.com/ehazlett/interlock/client"
 	"github.com/ehazlett/interlock/config"
 	"github.com/ehazlett/interlock/events"
 	"github.com/ehazlett/interlock/ext"
 	"github.com/ehazlett/interlock/ext/lb/haproxy"
 	"github.com/ehazlett/interlock/ext/lb/nginx"
+	lbutils "github.com/ehazlett/interlock/ext/lb/utils"
+	"github.com/ehazlett/interlock/metrics"
+	"github.com/ehazlett/interlock/trace"
 	"github.com/ehazlett/interlock/utils"
 	"github.com/ehazlett/ttlcache"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/net/context"
 )
 
@@ -33,6 +39,9 @@ const (
 )
 
 var (
+	// pause after copying the config to ensure the file write is synced
+	proxyConfigSyncWait = time.Millisecond * 1000
+
 	errChan                 chan (error)
 	restartChan             = make(chan bool)
 	lbUpdateChan            chan (bool)
@@ -47,9 +56,9 @@ type proxyContainerNetworkConfig struct {
 type LoadBalancerBackend interface {
 	Name() string
 	ConfigPath() string
-	GenerateProxyConfig(c []types.Container) (interface{}, error)
+	GenerateProxyConfig(ctx context.Context, c []types.Container) (interface{}, error)
 	Template() string
-	Reload(proxyContainers []types.Container) error
+	Reload(ctx context.Context, proxyContainers []types.Container) error
 }
 
 type LoadBalancer struct {
@@ -59,6 +68,29 @@ type LoadBalancer struct {
 	cache   *ttlcache.TTLCache
 	lock    *sync.Mutex
 	backend LoadBalancerBackend
+
+	// labels of the containers handled by the load balancer
+	labels *ext.LabelNamespace
+
+	// read the labels of the container images
+	imageLabels bool
+	// labels of the container images by image id
+	images map[string]map[string]string
+
+	// diagnostics of the container labels of the last update
+	reports []*lbutils.Report
+
+	// interlock domain by backend name for the proxy stats
+	statsDomains map[string]string
+
+	// store of the published proxy configs (nil if not publishing)
+	kv store.Store
+	// also publish the ssl certificates and keys
+	publishSSLFiles bool
+
+	// spans of the events which triggered the pending reload
+	pendingEvents []trace.SpanContext
+	pendingSince  time.Time
 }
 
 func log() *logrus.Entry {
@@ -72,19 +104,29 @@ type eventArgs struct {
 }
 
 func NewLoadBalancer(c *config.ExtensionConfig, client *client.Client) (*LoadBalancer, error) {
-	if c.TemplatePath != "" {
-		if _, err := os.Stat(c.TemplatePath); os.IsNotExist(err) {
-			log().Errorf("Missing %s configuration template: file=%s", c.Name, c.TemplatePath)
+	proxyCfg := c.Proxy()
+	if proxyCfg == nil {
+		return nil, fmt.Errorf("missing load balancer config: %s", c.Name)
+	}
+
+	if proxyCfg.TemplatePath != "" {
+		if _, err := os.Stat(proxyCfg.TemplatePath); os.IsNotExist(err) {
+			log().Errorf("Missing %s configuration template: file=%s", c.Name, proxyCfg.TemplatePath)
 			log().Errorf("Use the TemplatePath option in your Interlock config.toml to set a custom location for the %s configuration template", c.Name)
 			log().Errorf("Examples of an configuration template: url=https://github.com/ehazlett/interlock/tree/master/docs/examples/%s", c.Name)
 			log().Fatal(err)
 		} else {
-			log().Debugf("using configuration template: file=%s", c.TemplatePath)
+			log().Debugf("using configuration template: file=%s", proxyCfg.TemplatePath)
 		}
 	} else {
 		log().Debugf("using internal configuration template")
 	}
 
+	labels, err := ext.NewLabelNamespace(proxyCfg.LabelPrefix, proxyCfg.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
 	// parse config base dir
 	c.ConfigBasePath = filepath.Dir(c.ConfigPath)
 
@@ -123,6 +165,13 @@ func NewLoadBalancer(c *config.ExtensionConfig, client *client.Client) (*LoadBal
 		cache:  cache,
 		lock:   &sync.Mutex{},
 		nodeID: containerID,
+		labels: labels,
+
+		imageLabels:     proxyCfg.ImageLabels,
+		publishSSLFiles: proxyCfg.PublishSSLFiles,
+
+		statsDomains:  map[string]string{},
+		pendingEvents: []trace.SpanContext{},
 	}
 
 	// select backend
@@ -143,6 +192,22 @@ func NewLoadBalancer(c *config.ExtensionConfig, client *client.Client) (*LoadBal
 		return nil, fmt.Errorf("unknown load balancer backend: %s", c.Name)
 	}
 
+	// scrape the proxy containers for traffic stats if configured
+	if proxyCfg.ProxyStatsInterval != "" {
+		d, err := time.ParseDuration(proxyCfg.ProxyStatsInterval)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse proxy stats interval: %s", err)
+		}
+
+		t := time.NewTicker(d)
+		go func() {
+			for range t.C {
+				log().Debug("proxy stats ticker")
+				extension.scrapeProxyStats()
+			}
+		}()
+	}
+
 	// proxy network cleanup chan
 	// this waits for a reload event and removes the proxy containers
 	// from unused proxy networks
@@ -198,126 +263,274 @@ func NewLoadBalancer(c *config.ExtensionConfig, client *client.Client) (*LoadBal
 				continue
 			}
 
-			start := time.Now()
+			metrics.ReloadAttempts.With(prometheus.Labels{
+				"extension": extension.backend.Name(),
+			}).Inc()
 
-			log().Debug("updating load balancers")
+			ctx, span := extension.startUpdateSpan()
+			err := extension.update(ctx)
+			trace.SetError(span, err)
+			span.End()
 
-			optFilters := filters.NewArgs()
-			optFilters.Add("status", "running")
-			optFilters.Add("label", "interlock.hostname")
-			opts := types.ContainerListOptions{
-				All:     false,
-				Size:    false,
-				Filters: optFilters,
-			}
-			log().Debug("getting container list")
-			containers, err := client.ContainerList(context.Background(), opts)
 			if err != nil {
-				errChan <- err
+				extension.reloadFailed(err)
 				continue
 			}
+		}
+	}()
 
-			// generate proxy config
-			log().Debug("generating proxy config")
-			cfg, err := extension.backend.GenerateProxyConfig(containers)
-			if err != nil {
-				errChan <- err
-				continue
-			}
+	return extension, nil
+}
 
-			// save proxy config
-			configPath := extension.backend.ConfigPath()
-			log().Debugf("proxy config path: %s", configPath)
+// startUpdateSpan starts the span of an update cycle.  The span is a child
+// of the first event which triggered the reload and links to the others.
+func (l *LoadBalancer) startUpdateSpan() (context.Context, trace.Span) {
+	l.lock.Lock()
+	pending := l.pendingEvents
+	since := l.pendingSince
+	l.pendingEvents = []trace.SpanContext{}
+	l.pendingSince = time.Time{}
+	l.lock.Unlock()
+
+	ctx := context.Background()
+	if len(pending) > 0 {
+		ctx = trace.ContextWithSpanContext(ctx, pending[0])
+	}
 
-			proxyNetworks := map[string]string{}
+	links := []trace.SpanContext{}
+	if len(pending) > 1 {
+		links = pending[1:]
+	}
 
-			proxyContainers, err := extension.ProxyContainers(extension.backend.Name())
-			if err != nil {
-				errChan <- err
-				continue
-			}
+	ctx, span := trace.Start(ctx, "lb.update", trace.WithLinks(links...))
+	span.SetAttributes(
+		attribute.String("extension", l.backend.Name()),
+		attribute.Int("events", len(pending)),
+	)
 
-			// save config
-			log().Debug("saving proxy config")
-			if err := extension.SaveConfig(configPath, cfg, proxyContainers); err != nil {
-				errChan <- err
-				continue
-			}
+	// time from the first event until the debounced update started
+	if !since.IsZero() {
+		span.SetAttributes(attribute.Float64("debounce_ms", time.Since(since).Seconds()*1000))
+	}
 
-			// connect to networks
-			switch extension.backend.Name() {
-			case "nginx":
-				proxyConfig := cfg.(*nginx.Config)
-				proxyNetworks = proxyConfig.Networks
-			case "haproxy":
-				proxyConfig := cfg.(*haproxy.Config)
-				proxyNetworks = proxyConfig.Networks
-			default:
-				errChan <- fmt.Errorf("unable to connect to networks; unknown backend: %s", extension.backend.Name())
-				continue
-			}
+	return ctx, span
+}
 
-			proxyContainerNetworkConfigs := []proxyContainerNetworkConfig{}
-
-			for _, cnt := range proxyContainers {
-				proxyContainerNetworkConfigs = append(proxyContainerNetworkConfigs, proxyContainerNetworkConfig{
-					ContainerID:   cnt.ID,
-					ProxyNetworks: proxyNetworks,
-				})
-				for net, _ := range proxyNetworks {
-					if _, ok := cnt.NetworkSettings.Networks[net]; !ok {
-						log().Debugf("connecting proxy container %s to network %s", cnt.ID, net)
-
-						// connect
-						if err := client.NetworkConnect(context.Background(), net, cnt.ID, &ntypes.EndpointSettings{}); err != nil {
-							log().Warnf("unable to connect container %s to network %s: %s", cnt.ID, net, err)
-							continue
-						}
-					}
+// update generates the proxy config, copies it to the proxy containers and
+// reloads them
+func (l *LoadBalancer) update(ctx context.Context) error {
+	start := time.Now()
+	name := l.backend.Name()
+
+	log().Debug("updating load balancers")
+
+	optFilters := filters.NewArgs()
+	optFilters.Add("status", "running")
+	// the labels of the images are only known after listing the containers
+	if !l.imageLabels {
+		for _, f := range l.labels.Filters() {
+			optFilters.Add("label", f)
+		}
+	}
+	opts := types.ContainerListOptions{
+		All:     false,
+		Size:    false,
+		Filters: optFilters,
+	}
+	log().Debug("getting container list")
+	_, listSpan := trace.StartKind(ctx, "docker.container_list", trace.KindClient)
+	containers, err := l.client.ContainerList(ctx, opts)
+	listSpan.SetAttributes(attribute.Int("containers", len(containers)))
+	trace.SetError(listSpan, err)
+	listSpan.End()
+	if err != nil {
+		metrics.APIError(name)
+		return err
+	}
+
+	if l.imageLabels {
+		l.addImageLabels(ctx, containers)
+	}
+
+	containers = selectContainers(l.labels, containers)
+
+	l.lint(containers)
+
+	// read the secrets again so rotated secrets are used by the proxy; the
+	// options which cannot be resolved keep their last value
+	l.refreshSecrets()
+
+	// generate proxy config
+	log().Debug("generating proxy config")
+	generateStart := time.Now()
+	generateCtx, generateSpan := trace.Start(ctx, "lb.generate_config")
+	cfg, err := l.backend.GenerateProxyConfig(generateCtx, containers)
+	trace.SetError(generateSpan, err)
+	generateSpan.End()
+	if err != nil {
+		return err
+	}
+
+	metrics.GenerateDuration.With(prometheus.Labels{
+		"extension": name,
+	}).Set(time.Since(generateStart).Seconds())
+
+	l.lock.Lock()
+	l.statsDomains = proxyStatsDomains(cfg)
+	l.lock.Unlock()
+
+	// save proxy config
+	configPath := l.backend.ConfigPath()
+	log().Debugf("proxy config path: %s", configPath)
+
+	proxyNetworks := map[string]string{}
+
+	_, proxySpan := trace.StartKind(ctx, "docker.container_list", trace.KindClient)
+	proxyContainers, err := l.ProxyContainers(name)
+	proxySpan.SetAttributes(attribute.Int("containers", len(proxyContainers)))
+	trace.SetError(proxySpan, err)
+	proxySpan.End()
+	if err != nil {
+		metrics.APIError(name)
+		return err
+	}
+
+	// save config
+	log().Debug("saving proxy config")
+	saveCtx, saveSpan := trace.Start(ctx, "lb.save_config")
+	err = l.SaveConfig(saveCtx, configPath, cfg, proxyContainers)
+	trace.SetError(saveSpan, err)
+	saveSpan.End()
+	if err != nil {
+		return err
+	}
+
+	// publish the config for the agents of the proxies outside of docker
+	l.lock.Lock()
+	kv := l.kv
+	l.lock.Unlock()
+
+	if kv != nil {
+		_, publishSpan := trace.StartKind(ctx, "lb.publish_config", trace.KindClient)
+		revision, err := l.publishConfig(kv, cfg)
+		publishSpan.SetAttributes(attribute.Int64("revision", int64(revision)))
+		trace.SetError(publishSpan, err)
+		publishSpan.End()
+		if err != nil {
+			log().Errorf("error publishing proxy config: %s", err)
+		}
+	}
+
+	// connect to networks
+	switch name {
+	case "nginx":
+		proxyConfig := cfg.(*nginx.Config)
+		proxyNetworks = proxyConfig.Networks
+	case "haproxy":
+		proxyConfig := cfg.(*haproxy.Config)
+		proxyNetworks = proxyConfig.Networks
+	default:
+		return fmt.Errorf("unable to connect to networks; unknown backend: %s", name)
+	}
+
+	proxyContainerNetworkConfigs := []proxyContainerNetworkConfig{}
+
+	connectCtx, connectSpan := trace.Start(ctx, "lb.connect_networks")
+	for _, cnt := range proxyContainers {
+		proxyContainerNetworkConfigs = append(proxyContainerNetworkConfigs, proxyContainerNetworkConfig{
+			ContainerID:   cnt.ID,
+			ProxyNetworks: proxyNetworks,
+		})
+		for net, _ := range proxyNetworks {
+			if _, ok := cnt.NetworkSettings.Networks[net]; !ok {
+				log().Debugf("connecting proxy container %s to network %s", cnt.ID, net)
+
+				// connect
+				_, netSpan := trace.StartKind(connectCtx, "docker.network_connect", trace.KindClient)
+				netSpan.SetAttributes(
+					attribute.String("container", cnt.ID),
+					attribute.String("network", net),
+				)
+				err := l.client.NetworkConnect(connectCtx, net, cnt.ID, &ntypes.EndpointSettings{})
+				trace.SetError(netSpan, err)
+				netSpan.End()
+				if err != nil {
+					log().Warnf("unable to connect container %s to network %s: %s", cnt.ID, net, err)
+					metrics.APIError(name)
+					continue
 				}
 			}
+		}
+	}
+	connectSpan.End()
 
-			// get interlock nodes
-			nodeFilters := filters.NewArgs()
-			nodeFilters.Add("status", "running")
-			nodeFilters.Add("label", ext.InterlockAppLabel)
-			nodeOpts := types.ContainerListOptions{
-				All:     false,
-				Size:    false,
-				Filters: nodeFilters,
-			}
+	// get interlock nodes
+	nodeFilters := filters.NewArgs()
+	nodeFilters.Add("status", "running")
+	nodeFilters.Add("label", l.labels.Label(ext.InterlockAppLabel))
+	nodeOpts := types.ContainerListOptions{
+		All:     false,
+		Size:    false,
+		Filters: nodeFilters,
+	}
 
-			log().Debug("getting interlock container list")
-			interlockNodes, err := client.ContainerList(context.Background(), nodeOpts)
-			if err != nil {
-				errChan <- err
-				continue
-			}
+	log().Debug("getting interlock container list")
+	_, nodeSpan := trace.StartKind(ctx, "docker.container_list", trace.KindClient)
+	interlockNodes, err := l.client.ContainerList(ctx, nodeOpts)
+	nodeSpan.SetAttributes(attribute.Int("containers", len(interlockNodes)))
+	trace.SetError(nodeSpan, err)
+	nodeSpan.End()
+	if err != nil {
+		metrics.APIError(name)
+		return err
+	}
 
-			proxyContainersToRestart := extension.proxyContainersToRestart(interlockNodes, proxyContainers)
+	proxyContainersToRestart := l.proxyContainersToRestart(interlockNodes, proxyContainers)
 
-			// trigger reload
-			log().Debug("signaling reload")
+	// trigger reload
+	log().Debug("signaling reload")
 
-			// pause to ensure file write sync
-			time.Sleep(time.Millisecond * 1000)
-			if err := extension.backend.Reload(proxyContainersToRestart); err != nil {
-				log().Error(err)
-				errChan <- err
-				continue
-			}
+	// pause to ensure file write sync
+	_, waitSpan := trace.Start(ctx, "lb.sync_wait")
+	time.Sleep(proxyConfigSyncWait)
+	waitSpan.End()
 
-			d := time.Since(start)
-			duration := float64(d.Seconds() * float64(1000))
+	reloadCtx, reloadSpan := trace.Start(ctx, "lb.reload")
+	reloadSpan.SetAttributes(attribute.Int("containers", len(proxyContainersToRestart)))
+	err = l.backend.Reload(reloadCtx, proxyContainersToRestart)
+	trace.SetError(reloadSpan, err)
+	reloadSpan.End()
+	if err != nil {
+		log().Error(err)
+		return err
+	}
 
-			//log().Debug("triggering proxy network cleanup")
-			//proxyNetworkCleanupChan <- proxyContainerNetworkConfigs
+	d := time.Since(start)
+	duration := float64(d.Seconds() * float64(1000))
 
-			log().Infof("reload duration: %0.2fms", duration)
-		}
-	}()
+	metrics.Reloads.With(prometheus.Labels{
+		"extension": name,
+		"result":    metrics.ResultSuccess,
+	}).Inc()
 
-	return extension, nil
+	metrics.ReloadDuration(name, d)
+
+	//log().Debug("triggering proxy network cleanup")
+	//proxyNetworkCleanupChan <- proxyContainerNetworkConfigs
+
+	log().Infof("reload duration: %0.2fms", duration)
+
+	return nil
+}
+
+// reloadFailed counts the failed reload and reports the error
+func (l *LoadBalancer) reloadFailed(err error) {
+	metrics.Reloads.With(prometheus.Labels{
+		"extension": l.backend.Name(),
+		"result":    metrics.ResultFailure,
+	}).Inc()
+
+	errChan <- err
 }
 
 func (l *LoadBalancer) Name() string {
@@ -327,7 +540,7 @@ func (l *LoadBalancer) Name() string {
 func (l *LoadBalancer) ProxyContainers(name string) ([]types.Container, error) {
 	optFilters := filters.NewArgs()
 	optFilters.Add("status", "running")
-	optFilters.Add("label", "interlock.ext.name="+name)
+	optFilters.Add("label", l.labels.Label(ext.InterlockExtNameLabel)+"="+name)
 	opts := types.ContainerListOptions{
 		All:     false,
 		Filters: optFilters,
@@ -342,7 +555,9 @@ func (l *LoadBalancer) ProxyContainers(name string) ([]types.Container, error) {
 	return containers, nil
 }
 
-func (l *LoadBalancer) SaveConfig(configPath string, cfg interface{}, proxyContainers []types.Container) error {
+// renderConfig executes the template of the backend with the generated
+// proxy config
+func (l *LoadBalancer) renderConfig(cfg interface{}) ([]byte, error) {
 	t := template.New("lb")
 	confTmpl := l.backend.Template()
 
@@ -350,7 +565,7 @@ func (l *LoadBalancer) SaveConfig(configPath string, cfg interface{}, proxyConta
 
 	tmpl, err := t.Parse(confTmpl)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// cast to config type
@@ -358,22 +573,29 @@ func (l *LoadBalancer) SaveConfig(configPath string, cfg interface{}, proxyConta
 	case "nginx":
 		config := cfg.(*nginx.Config)
 		if err := tmpl.Execute(&c, config); err != nil {
-			return err
+			return nil, err
 		}
 	case "haproxy":
 		config := cfg.(*haproxy.Config)
 		if err := tmpl.Execute(&c, config); err != nil {
-			return err
+			return nil, err
 		}
 	default:
-		return fmt.Errorf("unknown backend type: %s", l.backend.Name())
+		return nil, fmt.Errorf("unknown backend type: %s", l.backend.Name())
+	}
+
+	return c.Bytes(), nil
+}
+
+func (l *LoadBalancer) SaveConfig(ctx context.Context, configPath string, cfg interface{}, proxyContainers []types.Container) error {
+	data, err := l.renderConfig(cfg)
+	if err != nil {
+		return err
 	}
 
 	fName := path.Base(l.backend.ConfigPath())
 	proxyConfigPath := path.Dir(l.backend.ConfigPath())
 
-	data := c.Bytes()
-
 	// copy to proxy nodes
 	for _, cnt := range proxyContainers {
 		log().Debugf("updating proxy config: id=%s", cnt.ID)
@@ -401,7 +623,12 @@ func (l *LoadBalancer) SaveConfig(configPath string, cfg interface{}, proxyConta
 		opts := types.CopyToContainerOptions{
 			AllowOverwriteDirWithFile: true,
 		}
-		if err := l.client.CopyToContainer(context.Background(), cnt.ID, proxyConfigPath, buf, opts); err != nil {
+		_, span := trace.StartKind(ctx, "docker.copy_to_container", trace.KindClient)
+		span.SetAttributes(attribute.String("container", cnt.ID))
+		err := l.client.CopyToContainer(ctx, cnt.ID, proxyConfigPath, buf, opts)
+		trace.SetError(span, err)
+		span.End()
+		if err != nil {
 			log().Errorf("error copying proxy config: %s", err)
 			continue
 		}
@@ -463,6 +690,16 @@ func (l *LoadBalancer) HandleEvent(event *events.Message) error {
 
 	if reload {
 		log().Debug("triggering reload")
+
+		l.lock.Lock()
+		if l.pendingSince.IsZero() {
+			l.pendingSince = time.Now()
+		}
+		if event.Trace.IsValid() {
+			l.pendingEvents = append(l.pendingEvents, event.Trace)
+		}
+		l.lock.Unlock()
+
 		l.cache.Set("reload", true)
 	}
 
@@ -508,6 +745,151 @@ func (l *LoadBalancer) proxyContainersToRestart(nodes []types.Container, proxyCo
 	return containersToRestart
 }
 
+// selectContainers returns the containers selected by the labels with the
+// labels renamed to the interlock labels
+func selectContainers(labels *ext.LabelNamespace, containers []types.Container) []types.Container {
+	selected := []types.Container{}
+	for _, c := range containers {
+		if !labels.Selects(c.Labels) {
+			continue
+		}
+
+		c.Labels = labels.Normalize(c.Labels)
+		selected = append(selected, c)
+	}
+
+	return selected
+}
+
+// lint checks the labels of the containers; the new diagnostics are logged
+func (l *LoadBalancer) lint(containers []types.Container) {
+	name := l.backend.Name()
+
+	l.lock.Lock()
+	previous := map[string]bool{}
+	for _, r := range l.reports {
+		for _, d := range r.Diagnostics {
+			previous[r.Container+d.String()] = true
+		}
+	}
+	l.lock.Unlock()
+
+	reports := lbutils.LintContainers(containers, name)
+	errors, warnings := 0, 0
+	for _, r := range reports {
+		for i, d := range r.Diagnostics {
+			// report the labels of the namespace
+			if strings.HasPrefix(d.Label, ext.DefaultLabelPrefix) {
+				d.Label = l.labels.Label(d.Label)
+				r.Diagnostics[i] = d
+			}
+
+			if d.Severity == lbutils.SeverityError {
+				errors++
+			} else {
+				warnings++
+			}
+
+			if !previous[r.Container+d.String()] {
+				log().Warnf("container label problem: container=%s %s", r.Name, d)
+			}
+		}
+	}
+
+	metrics.LabelProblems(name, errors, warnings)
+
+	l.lock.Lock()
+	l.reports = reports
+	l.lock.Unlock()
+}
+
+// Lint returns the diagnostics of the container labels of the last update
+func (l *LoadBalancer) Lint() []*lbutils.Report {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.reports
+}
+
+// refreshSecrets reads the secrets of the options again; the options are
+// set under the lock as the proxy stats use them
+func (l *LoadBalancer) refreshSecrets() {
+	secrets, err := l.cfg.SecretValues()
+	if err != nil {
+		log().Warnf("unable to read secrets; using the last values: %s", err)
+	}
+
+	l.lock.Lock()
+	l.cfg.SetSecretValues(secrets)
+	l.lock.Unlock()
+}
+
+// addImageLabels adds the labels of the container images to the containers;
+// the labels of the container take precedence.  The labels of the images
+// are cached by image id.  The containers of images which cannot be
+// inspected keep their own labels.
+func (l *LoadBalancer) addImageLabels(ctx context.Context, containers []types.Container) {
+	images := map[string]map[string]string{}
+
+	for i, c := range containers {
+		labels, ok := images[c.ImageID]
+		if !ok {
+			labels, ok = l.images[c.ImageID]
+		}
+
+		if !ok {
+			_, span := trace.StartKind(ctx, "docker.image_inspect", trace.KindClient)
+			span.SetAttributes(attribute.String("image", c.ImageID))
+			imgLabels, err := l.inspectImageLabels(ctx, c.ImageID)
+			trace.SetError(span, err)
+			span.End()
+			if err != nil {
+				// inspected again on the next update
+				metrics.APIError(l.backend.Name())
+				log().Errorf("unable to read image labels; using the container labels: id=%s image=%s err=%s", c.ID, c.ImageID, err)
+				continue
+			}
+			labels = imgLabels
+		}
+		images[c.ImageID] = labels
+
+		containers[i].Labels = mergeLabels(labels, c.Labels)
+	}
+
+	// only the images of the running containers are kept
+	l.images = images
+}
+
+func (l *LoadBalancer) inspectImageLabels(ctx context.Context, id string) (map[string]string, error) {
+	img, _, err := l.client.ImageInspectWithRaw(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if img.Config == nil {
+		return map[string]string{}, nil
+	}
+
+	return img.Config.Labels, nil
+}
+
+// mergeLabels returns the image labels overridden by the container labels
+func mergeLabels(image, container map[string]string) map[string]string {
+	if len(image) == 0 {
+		return container
+	}
+
+	merged := map[string]string{}
+	for k, v := range image {
+		merged[k] = v
+	}
+	for k, v := range container {
+		merged[k] = v
+	}
+
+	return merged
+}
+
 func (l *LoadBalancer) isExposedContainer(id string) bool {
 	log().Debugf("inspecting container: id=%s", id)
 	c, err := l.client.ContainerInspect(context.Background(), id)
@@ -519,16 +901,32 @@ func (l *LoadBalancer) isExposedContainer(id string) bool {
 
 	log().Debugf("checking container labels: id=%s", id)
 	// ignore proxy containers
-	if _, ok := c.Config.Labels[ext.InterlockExtNameLabel]; ok {
+	if _, ok := c.Config.Labels[l.labels.Label(ext.InterlockExtNameLabel)]; ok {
 		log().Debugf("ignoring proxy container: id=%s", id)
 		return false
 	}
 
-	if _, ok := c.Config.Labels[ext.InterlockAppLabel]; ok {
+	if _, ok := c.Config.Labels[l.labels.Label(ext.InterlockAppLabel)]; ok {
 		log().Debugf("ignoring interlock container: id=%s", id)
 		return false
 	}
 
+	labels := c.Config.Labels
+	if l.imageLabels {
+		imgLabels, err := l.inspectImageLabels(context.Background(), c.Image)
+		if err != nil {
+			log().Errorf("error: id=%s err=%s", id, err)
+			return false
+		}
+		labels = mergeLabels(imgLabels, labels)
+	}
+
+	// ignore containers of other interlock stacks
+	if !l.labels.Selects(labels) {
+		log().Debugf("container not selected by the labels; ignoring: id=%s", id)
+		return false
+	}
+
 	log().Debugf("checking container ports: id=%s", id)
 	// ignore containers without exposed ports
 	if len(c.Config.ExposedPorts) == 0 {
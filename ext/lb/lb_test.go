@@ -0,0 +1,368 @@
+package lb
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	etypes "github.com/docker/docker/api/types/events"
+	engineClient "github.com/docker/docker/client"
+	"github.com/ehazlett/interlock/client"
+	"github.com/ehazlett/interlock/config"
+	"github.com/ehazlett/interlock/events"
+	"github.com/ehazlett/interlock/ext"
+	"github.com/ehazlett/interlock/ext/lb/haproxy"
+	"github.com/ehazlett/interlock/metrics"
+	"github.com/ehazlett/interlock/trace"
+	"github.com/ehazlett/ttlcache"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+	"golang.org/x/net/context"
+)
+
+// fakeBackend records the reloaded proxy containers
+type fakeBackend struct {
+	lock     sync.Mutex
+	reloaded []string
+	// err is returned by Reload
+	err error
+}
+
+func (b *fakeBackend) Name() string {
+	return "haproxy"
+}
+
+func (b *fakeBackend) ConfigPath() string {
+	return "/usr/local/etc/haproxy/haproxy.cfg"
+}
+
+func (b *fakeBackend) GenerateProxyConfig(ctx context.Context, c []types.Container) (interface{}, error) {
+	_, span := trace.Start(ctx, "fake.generate")
+	span.End()
+
+	return &haproxy.Config{
+		Hosts:    []*haproxy.Host{},
+		Networks: map[string]string{},
+	}, nil
+}
+
+func (b *fakeBackend) Template() string {
+	return "hosts={{ len .Hosts }}"
+}
+
+func (b *fakeBackend) Reload(ctx context.Context, proxyContainers []types.Container) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, c := range proxyContainers {
+		b.reloaded = append(b.reloaded, c.ID)
+	}
+
+	return b.err
+}
+
+// fakeEngine serves the docker API calls of an update cycle
+func fakeEngine(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			f := r.URL.Query().Get("filters")
+			switch {
+			case strings.Contains(f, "interlock.ext.name=haproxy"):
+				json.NewEncoder(w).Encode([]types.Container{{
+					ID:              "proxy0123456789",
+					Names:           []string{"/proxy"},
+					NetworkSettings: &types.SummaryNetworkSettings{},
+				}})
+			case strings.Contains(f, "interlock.app"):
+				json.NewEncoder(w).Encode([]types.Container{})
+			default:
+				json.NewEncoder(w).Encode([]types.Container{{ID: "app0123456789"}})
+			}
+		case strings.HasSuffix(r.URL.Path, "/archive") && r.Method == "PUT":
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func testLoadBalancer(t *testing.T, srv *httptest.Server, backend LoadBalancerBackend) *LoadBalancer {
+	cl, err := engineClient.NewClient("tcp://"+strings.TrimPrefix(srv.URL, "http://"), "1.24", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := ttlcache.NewTTLCache(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &LoadBalancer{
+		cfg:     &config.ExtensionConfig{Name: "haproxy"},
+		client:  client.NewClient(cl, nil),
+		cache:   cache,
+		lock:    &sync.Mutex{},
+		backend: backend,
+		labels:  &ext.LabelNamespace{Prefix: ext.DefaultLabelPrefix},
+
+		statsDomains:  map[string]string{},
+		pendingEvents: []trace.SpanContext{},
+	}
+}
+
+func TestUpdateSpans(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	srv := fakeEngine(t)
+	defer srv.Close()
+
+	proxyConfigSyncWait = 0
+
+	backend := &fakeBackend{}
+	l := testLoadBalancer(t, srv, backend)
+
+	// events which trigger a reload are linked to the update
+	_, first := trace.Start(context.Background(), "interlock.event")
+	first.End()
+	_, second := trace.Start(context.Background(), "interlock.event")
+	second.End()
+
+	for _, sc := range []trace.SpanContext{first.SpanContext(), second.SpanContext()} {
+		if err := l.HandleEvent(&events.Message{
+			Message: etypes.Message{ID: "0", Status: "interlock-start"},
+			Trace:   sc,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, span := l.startUpdateSpan()
+	if err := l.update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	span.End()
+
+	if len(backend.reloaded) != 1 || backend.reloaded[0] != "proxy0123456789" {
+		t.Fatalf("expected proxy container to be reloaded: %v", backend.reloaded)
+	}
+
+	byName := map[string]tracetest.SpanStubs{}
+	for _, s := range exp.GetSpans() {
+		byName[s.Name] = append(byName[s.Name], s)
+	}
+
+	update := byName["lb.update"]
+	if len(update) != 1 {
+		t.Fatalf("expected 1 update span; received %d", len(update))
+	}
+
+	u := update[0]
+	if u.Parent.SpanID() != first.SpanContext().SpanID() || u.SpanContext.TraceID() != first.SpanContext().TraceID() {
+		t.Fatalf("expected update to be a child of the first event: %+v", u)
+	}
+
+	if len(u.Links) != 1 || !u.Links[0].SpanContext.Equal(second.SpanContext()) {
+		t.Fatalf("expected update to link the second event: %+v", u.Links)
+	}
+
+	for name, n := range map[string]int{
+		"docker.container_list": 3,
+		"lb.generate_config":    1,
+		"lb.save_config":        1,
+		"lb.connect_networks":   1,
+		"lb.sync_wait":          1,
+		"lb.reload":             1,
+	} {
+		if len(byName[name]) != n {
+			t.Fatalf("expected %d %s spans; received %d", n, name, len(byName[name]))
+		}
+
+		for _, s := range byName[name] {
+			if s.Parent.SpanID() != u.SpanContext.SpanID() {
+				t.Fatalf("expected %s to be a child of the update: %+v", name, s)
+			}
+		}
+	}
+
+	if s := byName["fake.generate"]; len(s) != 1 || s[0].Parent.SpanID() != byName["lb.generate_config"][0].SpanContext.SpanID() {
+		t.Fatalf("expected backend span to be a child of generate: %+v", s)
+	}
+
+	if s := byName["docker.copy_to_container"]; len(s) != 1 || s[0].Parent.SpanID() != byName["lb.save_config"][0].SpanContext.SpanID() {
+		t.Fatalf("expected copy span to be a child of save: %+v", s)
+	}
+
+	if len(l.pendingEvents) != 0 || !l.pendingSince.IsZero() {
+		t.Fatal("expected pending events to be reset")
+	}
+}
+
+func TestUpdateSpanError(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	srv := fakeEngine(t)
+	l := testLoadBalancer(t, srv, &fakeBackend{})
+
+	// the engine is unavailable
+	srv.Close()
+
+	ctx, span := l.startUpdateSpan()
+	err := l.update(ctx)
+	trace.SetError(span, err)
+	span.End()
+
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	spans := tracetest.SpanStubs{}
+	for _, s := range exp.GetSpans() {
+		if s.Name == "lb.update" {
+			spans = append(spans, s)
+		}
+	}
+
+	if len(spans) != 1 || spans[0].Status.Code != codes.Error || spans[0].Parent.IsValid() {
+		t.Fatalf("expected failed root update span: %+v", spans)
+	}
+}
+
+func TestUpdateReloadError(t *testing.T) {
+	srv := fakeEngine(t)
+	defer srv.Close()
+
+	proxyConfigSyncWait = 0
+
+	l := testLoadBalancer(t, srv, &fakeBackend{err: errors.New("unable to reload 1 of 1 proxy containers")})
+
+	success := prometheus.Labels{"extension": "haproxy", "result": metrics.ResultSuccess}
+	before := counterValue(t, metrics.Reloads, success)
+
+	if err := l.update(context.Background()); err == nil {
+		t.Fatal("expected the reload error")
+	}
+
+	if v := counterValue(t, metrics.Reloads, success); v != before {
+		t.Fatalf("expected the failed reload not to be counted as a success: %v", v)
+	}
+}
+
+func counterValue(t *testing.T, c *prometheus.CounterVec, labels prometheus.Labels) float64 {
+	m := &dto.Metric{}
+	if err := c.With(labels).Write(m); err != nil {
+		t.Fatal(err)
+	}
+
+	return m.GetCounter().GetValue()
+}
+
+func TestSelectContainers(t *testing.T) {
+	labels, err := ext.NewLabelNamespace("public.interlock.", "env=prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	containers := selectContainers(labels, []types.Container{
+		{ID: "public", Labels: map[string]string{"public.interlock.hostname": "web", "env": "prod"}},
+		{ID: "internal", Labels: map[string]string{"interlock.hostname": "web", "env": "prod"}},
+		{ID: "dev", Labels: map[string]string{"public.interlock.hostname": "web", "env": "dev"}},
+	})
+
+	if len(containers) != 1 || containers[0].ID != "public" {
+		t.Fatalf("expected the public container; received %+v", containers)
+	}
+
+	if containers[0].Labels[ext.InterlockHostnameLabel] != "web" {
+		t.Fatalf("expected normalized labels; received %v", containers[0].Labels)
+	}
+}
+
+func TestAddImageLabels(t *testing.T) {
+	inspects := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/images/sha256:web/json") {
+			http.NotFound(w, r)
+			return
+		}
+
+		inspects++
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(types.ImageInspect{
+			ID: "sha256:web",
+			Config: &container.Config{
+				Labels: map[string]string{
+					ext.InterlockHostnameLabel: "web",
+					ext.InterlockDomainLabel:   "example.com",
+				},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	l := testLoadBalancer(t, srv, &fakeBackend{})
+
+	containers := []types.Container{
+		{ID: "web0", ImageID: "sha256:web"},
+		{ID: "web1", ImageID: "sha256:web", Labels: map[string]string{ext.InterlockHostnameLabel: "api"}},
+	}
+
+	l.addImageLabels(context.Background(), containers)
+
+	if inspects != 1 {
+		t.Fatalf("expected 1 image inspect; received %d", inspects)
+	}
+
+	if h := containers[0].Labels[ext.InterlockHostnameLabel]; h != "web" {
+		t.Fatalf("expected the image hostname; received %q", h)
+	}
+
+	// the container labels take precedence
+	if h := containers[1].Labels[ext.InterlockHostnameLabel]; h != "api" {
+		t.Fatalf("expected the container hostname; received %q", h)
+	}
+
+	if d := containers[1].Labels[ext.InterlockDomainLabel]; d != "example.com" {
+		t.Fatalf("expected the image domain; received %q", d)
+	}
+
+	// the image labels are cached
+	l.addImageLabels(context.Background(), []types.Container{{ID: "web2", ImageID: "sha256:web"}})
+
+	if inspects != 1 {
+		t.Fatalf("expected cached image labels; received %d inspects", inspects)
+	}
+
+	// the containers of images which cannot be inspected keep their labels
+	containers = []types.Container{
+		{ID: "web3", ImageID: "sha256:web"},
+		{ID: "api0", ImageID: "sha256:api", Labels: map[string]string{ext.InterlockHostnameLabel: "api"}},
+	}
+	l.addImageLabels(context.Background(), containers)
+
+	if h := containers[0].Labels[ext.InterlockHostnameLabel]; h != "web" {
+		t.Fatalf("expected the image hostname; received %q", h)
+	}
+
+	if len(containers[1].Labels) != 1 || containers[1].Labels[ext.InterlockHostnameLabel] != "api" {
+		t.Fatalf("expected the container labels; received %v", containers[1].Labels)
+	}
+}
@@ -2,6 +2,7 @@ package nginx
 
 import (
 	"github.com/ehazlett/interlock/config"
+	"github.com/ehazlett/interlock/ext/lb/utils"
 )
 
 type Server struct {
@@ -25,6 +26,11 @@ type Host struct {
 	Port               int
 	ContextRoots       map[string]*ContextRoot
 	BackendOptions     []string
+	RequestHeaders     []utils.Header
+	ResponseHeaders    []utils.Header
+	HSTS               string
+	Redirects          []utils.Redirect
+	Rewrites           []utils.Rewrite
 	SSLPort            int
 	SSL                bool
 	SSLCert            string
@@ -34,9 +40,22 @@ type Host struct {
 	Upstream           *Upstream
 	WebsocketEndpoints []string
 	IPHash             bool
+	StickyCookie       string
+	HTTP2              bool
+	BackendProtocol    string
+	Gzip               bool
+	Cache              *utils.CachePolicy
+	CacheBypass        string
 }
 type Config struct {
 	Hosts    []*Host
-	Config   *config.ExtensionConfig
+	Config   *TemplateConfig
 	Networks map[string]string
 }
+
+// TemplateConfig has the extension options available to the template as
+// .Config (i.e. .Config.MaxConn)
+type TemplateConfig struct {
+	*config.ExtensionConfig
+	*config.NginxConfig
+}
@@ -3,14 +3,18 @@ package nginx
 import (
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/docker/docker/api/types"
 	"github.com/ehazlett/interlock/ext/lb/utils"
+	"github.com/ehazlett/interlock/metrics"
+	"github.com/ehazlett/interlock/trace"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/net/context"
 )
 
-func (p *NginxLoadBalancer) GenerateProxyConfig(containers []types.Container) (interface{}, error) {
+func (p *NginxLoadBalancer) GenerateProxyConfig(ctx context.Context, containers []types.Container) (interface{}, error) {
 	var hosts []*Host
 	upstreamHosts := map[string]struct{}{}
 	upstreamServers := map[string][]string{}
@@ -24,9 +28,33 @@ func (p *NginxLoadBalancer) GenerateProxyConfig(containers []types.Container) (i
 	hostSSLBackend := map[string]bool{}
 	hostWebsocketEndpoints := map[string][]string{}
 	hostIPHash := map[string]bool{}
+	hostRequestHeaders := map[string][]utils.Header{}
+	hostResponseHeaders := map[string][]utils.Header{}
+	hostHSTS := map[string]string{}
+	hostRedirects := map[string][]utils.Redirect{}
+	hostRewrites := map[string][]utils.Rewrite{}
+	hostStickyCookies := map[string]string{}
+	hostHTTP2 := map[string]bool{}
+	hostBackendProtocols := map[string]string{}
+	hostGzip := map[string]bool{}
+	hostCache := map[string]*utils.CachePolicy{}
+	hostCacheBypass := map[string]string{}
 	networks := map[string]string{}
+	upstreams := 0
+
+	// the containers are sorted and the settings of the hosts merged so
+	// the config does not depend on the order of the containers
+	hostContainers, conflicts := utils.MergeHostSettings(containers, p.cfg.Nginx.ConflictPolicy)
+	for _, conflict := range conflicts {
+		if conflict.Policy == utils.ConflictPolicyStrict {
+			log().Error(conflict)
+		} else {
+			log().Warn(conflict)
+		}
+	}
+	metrics.SettingConflicts(p.Name(), p.cfg.Nginx.ConflictPolicy, len(conflicts))
 
-	for _, c := range containers {
+	for _, c := range hostContainers {
 		cntId := c.ID[:12]
 		// load interlock data
 		contextRoot := utils.ContextRoot(c)
@@ -46,6 +74,88 @@ func (p *NginxLoadBalancer) GenerateProxyConfig(containers []types.Container) (i
 		contextRootName := fmt.Sprintf("%s_%s", domain, strings.Replace(contextRoot, "/", "_", -1))
 		contextRootRewrite := utils.ContextRootRewrite(c)
 
+		requestHeaders, err := utils.RequestHeaders(c)
+		if err != nil {
+			log().Errorf("error parsing request headers: %s", err)
+			continue
+		}
+
+		responseHeaders, err := utils.ResponseHeaders(c)
+		if err != nil {
+			log().Errorf("error parsing response headers: %s", err)
+			continue
+		}
+
+		corsHeaders, err := utils.CORSHeaders(c)
+		if err != nil {
+			log().Errorf("error parsing cors policy: %s", err)
+			continue
+		}
+
+		hsts, err := utils.HSTS(c, p.cfg.Nginx.HSTSMaxAge)
+		if err != nil {
+			log().Errorf("error parsing hsts policy: %s", err)
+			continue
+		}
+
+		redirects, err := utils.Redirects(c)
+		if err != nil {
+			log().Errorf("error parsing redirects: %s", err)
+			continue
+		}
+
+		rewrites, err := utils.Rewrites(c)
+		if err != nil {
+			log().Errorf("error parsing rewrites: %s", err)
+			continue
+		}
+
+		stickyCookie, err := utils.StickyCookie(c)
+		if err != nil {
+			log().Errorf("error parsing sticky cookie: %s", err)
+			continue
+		}
+
+		http2, err := utils.HTTP2(c)
+		if err != nil {
+			log().Errorf("error parsing http2: %s", err)
+			continue
+		}
+
+		backendProtocol, err := utils.BackendProtocol(c)
+		if err != nil {
+			log().Errorf("error parsing backend protocol: %s", err)
+			continue
+		}
+
+		gzip, err := utils.Gzip(c)
+		if err != nil {
+			log().Errorf("error parsing gzip: %s", err)
+			continue
+		}
+
+		cache, err := utils.Cache(c, p.cfg.Nginx.CacheZoneSize)
+		if err != nil {
+			log().Errorf("error parsing cache policy: %s", err)
+			continue
+		}
+
+		cacheBypassHeader, err := utils.CacheBypassHeader(c)
+		if err != nil {
+			log().Errorf("error parsing cache bypass header: %s", err)
+			continue
+		}
+
+		switch backendProtocol {
+		case utils.BackendProtocolGRPC:
+			// grpc clients require http2
+			http2 = true
+		case utils.BackendProtocolH2C:
+			// nginx only speaks http2 to upstreams using grpc_pass
+			log().Warnf("%s: nginx does not support h2c upstreams; using http/1.1", cntId)
+			backendProtocol = utils.DefaultBackendProtocol
+		}
+
 		// check if the first server name is there; if not, add
 		// this happens if there are multiple backend containers
 		if _, ok := serverNames[domain]; !ok {
@@ -55,6 +165,17 @@ func (p *NginxLoadBalancer) GenerateProxyConfig(containers []types.Container) (i
 		hostSSL[domain] = utils.SSLEnabled(c)
 		hostSSLOnly[domain] = utils.SSLOnly(c)
 		hostIPHash[domain] = utils.IPHash(c)
+		hostStickyCookies[domain] = stickyCookie
+		hostHTTP2[domain] = http2
+		hostBackendProtocols[domain] = backendProtocol
+		hostGzip[domain] = gzip
+
+		if cache != nil {
+			hostCache[domain] = cache
+			if cacheBypassHeader != "" {
+				hostCacheBypass[domain] = headerVariable(cacheBypassHeader)
+			}
+		}
 		// check ssl backend
 		hostSSLBackend[domain] = utils.SSLBackend(c)
 
@@ -64,8 +185,28 @@ func (p *NginxLoadBalancer) GenerateProxyConfig(containers []types.Container) (i
 			log().Debugf("using backend options for %s: %s", domain, strings.Join(backendOptions, ","))
 		}
 
+		if len(requestHeaders) > 0 {
+			hostRequestHeaders[domain] = requestHeaders
+		}
+
+		// cors headers are rendered as regular response headers
+		responseHeaders = append(corsHeaders, responseHeaders...)
+		if len(responseHeaders) > 0 {
+			hostResponseHeaders[domain] = responseHeaders
+		}
+
+		hostHSTS[domain] = hsts
+
+		if len(redirects) > 0 {
+			hostRedirects[domain] = redirects
+		}
+
+		if len(rewrites) > 0 {
+			hostRewrites[domain] = rewrites
+		}
+
 		// set cert paths
-		baseCertPath := p.cfg.SSLCertPath
+		baseCertPath := p.cfg.Nginx.SSLCertPath
 
 		certName := utils.SSLCertName(c)
 
@@ -88,7 +229,14 @@ func (p *NginxLoadBalancer) GenerateProxyConfig(containers []types.Container) (i
 		if n, ok := utils.OverlayEnabled(c); ok {
 			log().Debugf("configuring docker network: name=%s", n)
 
-			network, err := p.client.NetworkInspect(context.Background(), n, false)
+			_, span := trace.StartKind(ctx, "docker.network_inspect", trace.KindClient)
+			span.SetAttributes(
+				attribute.String("container", cntId),
+				attribute.String("network", n),
+			)
+			network, err := p.client.NetworkInspect(ctx, n, false)
+			trace.SetError(span, err)
+			span.End()
 			if err != nil {
 				log().Error(err)
 				continue
@@ -107,7 +255,7 @@ func (p *NginxLoadBalancer) GenerateProxyConfig(containers []types.Container) (i
 				continue
 			}
 
-			a, err := utils.BackendAddress(c, p.cfg.BackendOverrideAddress)
+			a, err := utils.BackendAddress(c, p.cfg.Nginx.BackendOverrideAddress)
 			if err != nil {
 				log().Error(err)
 				continue
@@ -167,6 +315,7 @@ func (p *NginxLoadBalancer) GenerateProxyConfig(containers []types.Container) (i
 		}
 
 		upstreamHosts[domain] = struct{}{}
+		upstreams++
 		log().Infof("%s: upstream=%s", domain, addr)
 	}
 
@@ -174,9 +323,9 @@ func (p *NginxLoadBalancer) GenerateProxyConfig(containers []types.Container) (i
 		log().Debugf("%s contextroots=%+v", k, hostContextRoots[k])
 		h := &Host{
 			ServerNames:        serverNames[k],
-			Port:               p.cfg.Port,
+			Port:               p.cfg.Nginx.Port,
 			ContextRoots:       hostContextRoots[k],
-			SSLPort:            p.cfg.SSLPort,
+			SSLPort:            p.cfg.Nginx.SSLPort,
 			SSL:                hostSSL[k],
 			SSLCert:            hostSSLCert[k],
 			SSLCertKey:         hostSSLCertKey[k],
@@ -184,7 +333,18 @@ func (p *NginxLoadBalancer) GenerateProxyConfig(containers []types.Container) (i
 			SSLBackend:         hostSSLBackend[k],
 			WebsocketEndpoints: hostWebsocketEndpoints[k],
 			BackendOptions:     hostBackendOptions[k],
+			RequestHeaders:     hostRequestHeaders[k],
+			ResponseHeaders:    hostResponseHeaders[k],
+			HSTS:               hostHSTS[k],
+			Redirects:          hostRedirects[k],
+			Rewrites:           hostRewrites[k],
 			IPHash:             hostIPHash[k],
+			StickyCookie:       hostStickyCookies[k],
+			HTTP2:              hostHTTP2[k],
+			BackendProtocol:    hostBackendProtocols[k],
+			Gzip:               hostGzip[k],
+			Cache:              hostCache[k],
+			CacheBypass:        hostCacheBypass[k],
 		}
 
 		servers := []*Server{}
@@ -206,11 +366,29 @@ func (p *NginxLoadBalancer) GenerateProxyConfig(containers []types.Container) (i
 		hosts = append(hosts, h)
 	}
 
+	sort.Sort(hostsByName(hosts))
+
 	config := &Config{
-		Hosts:    hosts,
-		Config:   p.cfg,
+		Hosts: hosts,
+		Config: &TemplateConfig{
+			ExtensionConfig: p.cfg,
+			NginxConfig:     p.cfg.Nginx,
+		},
 		Networks: networks,
 	}
 
+	metrics.ProxyConfig(p.Name(), len(hosts), upstreams, len(containers)-upstreams)
+
 	return config, nil
 }
+
+// headerVariable returns the name of the nginx variable for the request header
+func headerVariable(header string) string {
+	return "$http_" + strings.Replace(strings.ToLower(header), "-", "_", -1)
+}
+
+type hostsByName []*Host
+
+func (h hostsByName) Len() int           { return len(h) }
+func (h hostsByName) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h hostsByName) Less(i, j int) bool { return h[i].Upstream.Name < h[j].Upstream.Name }
@@ -1,15 +1,19 @@
 package nginx
 
 import (
+	"fmt"
 	"io/ioutil"
 	"path/filepath"
 	"strings"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/client"
+	"github.com/ehazlett/interlock/client"
 	"github.com/ehazlett/interlock/config"
 	"github.com/ehazlett/interlock/events"
+	"github.com/ehazlett/interlock/metrics"
+	"github.com/ehazlett/interlock/trace"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/net/context"
 )
 
@@ -29,6 +33,10 @@ func log() *logrus.Entry {
 }
 
 func NewNginxLoadBalancer(c *config.ExtensionConfig, cl *client.Client) (*NginxLoadBalancer, error) {
+	if c.Nginx == nil {
+		return nil, fmt.Errorf("missing nginx config")
+	}
+
 	// parse config base dir
 	c.ConfigBasePath = filepath.Dir(c.ConfigPath)
 
@@ -49,8 +57,8 @@ func (p *NginxLoadBalancer) HandleEvent(event *events.Message) error {
 }
 
 func (p *NginxLoadBalancer) Template() string {
-	if p.cfg.TemplatePath != "" {
-		d, err := ioutil.ReadFile(p.cfg.TemplatePath)
+	if p.cfg.Nginx.TemplatePath != "" {
+		d, err := ioutil.ReadFile(p.cfg.Nginx.TemplatePath)
 
 		if err == nil {
 			return string(d)
@@ -58,7 +66,7 @@ func (p *NginxLoadBalancer) Template() string {
 			return err.Error()
 		}
 	} else {
-		if p.cfg.NginxPlusEnabled {
+		if p.cfg.Nginx.NginxPlusEnabled {
 			return nginxPlusConfTemplate
 		}
 
@@ -70,77 +78,93 @@ func (p *NginxLoadBalancer) ConfigPath() string {
 	return p.cfg.ConfigPath
 }
 
-func (p *NginxLoadBalancer) Reload(proxyContainers []types.Container) error {
+// Reload reloads all the proxy containers; an error is returned if any of
+// them fails
+func (p *NginxLoadBalancer) Reload(ctx context.Context, proxyContainers []types.Container) error {
+	failed := 0
+
 	// restart all interlock managed nginx containers
 	for _, cnt := range proxyContainers {
 		log().Debugf("reloading proxy container: id=%s", cnt.ID)
-		resp, err := p.client.ContainerExecCreate(context.Background(), cnt.ID, types.ExecConfig{
-			User: "root",
-			Cmd: []string{
-				"nginx",
-				"-t",
-			},
-			Detach:       false,
-			AttachStdout: true,
-			AttachStderr: true,
-		})
+		_, span := trace.StartKind(ctx, "nginx.reload", trace.KindClient)
+		span.SetAttributes(attribute.String("container", cnt.ID))
+		err := p.reloadContainer(ctx, cnt)
+		trace.SetError(span, err)
+		span.End()
+		metrics.ProxyReload(pluginName, cnt.ID, err)
 		if err != nil {
-			log().Errorf("error validating config (exec create): id=%s err=%s", cnt.ID[:12], err)
+			log().Error(err)
+			failed++
 			continue
 		}
 
-		aResp, err := p.client.ContainerExecAttach(context.Background(), resp.ID, types.ExecConfig{
-			AttachStdout: true,
-			AttachStderr: true,
-		})
-		if err != nil {
-			log().Errorf("error validating config (exec attach): id=%s err=%s", cnt.ID[:12], err)
-			continue
-		}
-		defer aResp.Conn.Close()
+		log().Infof("restarted proxy container: id=%s name=%s", cnt.ID[:12], cnt.Names[0])
+	}
 
-		// wait for exec to finish
-		res, err := p.waitForExec(resp.ID)
-		if err != nil {
-			log().Errorf("error validating config (exec attach): id=%s err=%s", cnt.ID[:12], err)
-			continue
-		}
+	if failed > 0 {
+		return fmt.Errorf("unable to reload %d of %d proxy containers", failed, len(proxyContainers))
+	}
 
-		if res.ExitCode != 0 {
-			out, err := aResp.Reader.ReadString('\n')
-			if err != nil {
-				log().Error("error validating config: unable to read output from exec")
-				continue
-			}
-			// restore
-			log().Warn("restoring proxy config")
-			if err := p.restoreConfig(cnt.ID); err != nil {
-				log().Errorf("error validating config: error restoring config: %s", err)
-				continue
-			}
-			log().Errorf("error validating config, invalid proxy configuration: %s", strings.TrimSpace(out))
-			continue
-		}
+	return nil
+}
 
-		// reload process
-		if err := p.client.ContainerKill(context.Background(), cnt.ID, "HUP"); err != nil {
-			log().Warn("restoring proxy config")
-			if err := p.restoreConfig(cnt.ID); err != nil {
-				log().Errorf("error restoring config: %s", err)
-			}
-			log().Errorf("error reloading proxy container: %s", err)
-			continue
+// reloadContainer validates the config in the proxy container and signals
+// nginx to reload; the previous config is restored if it is invalid
+func (p *NginxLoadBalancer) reloadContainer(ctx context.Context, cnt types.Container) error {
+	resp, err := p.client.ContainerExecCreate(ctx, cnt.ID, types.ExecConfig{
+		User: "root",
+		Cmd: []string{
+			"nginx",
+			"-t",
+		},
+		Detach:       false,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return fmt.Errorf("error validating config (exec create): id=%s err=%s", cnt.ID[:12], err)
+	}
+
+	aResp, err := p.client.ContainerExecAttach(ctx, resp.ID, types.ExecConfig{
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return fmt.Errorf("error validating config (exec attach): id=%s err=%s", cnt.ID[:12], err)
+	}
+	defer aResp.Conn.Close()
+
+	// wait for exec to finish
+	res, err := p.waitForExec(resp.ID)
+	if err != nil {
+		return fmt.Errorf("error validating config (exec attach): id=%s err=%s", cnt.ID[:12], err)
+	}
+
+	if res.ExitCode != 0 {
+		out, err := aResp.Reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("error validating config: unable to read output from exec")
 		}
+		// restore
+		log().Warn("restoring proxy config")
+		if err := p.restoreConfig(cnt.ID); err != nil {
+			return fmt.Errorf("error validating config: error restoring config: %s", err)
+		}
+		return fmt.Errorf("error validating config, invalid proxy configuration: %s", strings.TrimSpace(out))
+	}
 
-		// backup config
-		if err := p.backupConfig(cnt.ID); err != nil {
-			log().WithFields(logrus.Fields{
-				"id": cnt.ID,
-			}).Errorf("error backing up config: skipping update")
-			continue
+	// reload process
+	if err := p.client.ContainerKill(ctx, cnt.ID, "HUP"); err != nil {
+		log().Warn("restoring proxy config")
+		if err := p.restoreConfig(cnt.ID); err != nil {
+			log().Errorf("error restoring config: %s", err)
 		}
+		return fmt.Errorf("error reloading proxy container: %s", err)
+	}
 
-		log().Infof("restarted proxy container: id=%s name=%s", cnt.ID[:12], cnt.Names[0])
+	// backup config
+	if err := p.backupConfig(cnt.ID); err != nil {
+		return fmt.Errorf("error backing up config: id=%s err=%s", cnt.ID[:12], err)
 	}
 
 	return nil
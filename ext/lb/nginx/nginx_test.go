@@ -0,0 +1,42 @@
+package nginx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	engineClient "github.com/docker/docker/client"
+	"github.com/ehazlett/interlock/client"
+	"github.com/ehazlett/interlock/config"
+	"golang.org/x/net/context"
+)
+
+func TestReloadError(t *testing.T) {
+	// the engine cannot exec the config test
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "error", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	cl, err := engineClient.NewClient("tcp://"+strings.TrimPrefix(srv.URL, "http://"), "1.24", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewNginxLoadBalancer(&config.ExtensionConfig{
+		Name:       "nginx",
+		ConfigPath: "/etc/nginx/nginx.conf",
+		Nginx:      &config.NginxConfig{},
+	}, client.NewClient(cl, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Reload(context.Background(), []types.Container{
+		{ID: "proxy0123456789", Names: []string{"/proxy"}},
+	}); err == nil {
+		t.Fatal("expected error when the proxy container fails to reload")
+	}
+}
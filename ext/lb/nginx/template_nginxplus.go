@@ -81,9 +81,11 @@ http {
     }
 
     {{ range $host := .Hosts }}
+    {{ if $host.Cache }}proxy_cache_path {{ $.Config.CachePath }}/{{ $host.Upstream.Name }} levels=1:2 keys_zone={{ $host.Upstream.Name }}_cache:{{ $host.Cache.ZoneSize }} max_size={{ $.Config.CacheMaxSize }} inactive=60m;{{ end }}
     {{ if $host.Upstream.Servers }}
     upstream {{ $host.Upstream.Name }} {
-        {{ if $host.IPHash }}ip_hash; {{else}}zone {{ $host.Upstream.Name }}_backend 64k;{{ end }}
+        {{ if $host.IPHash }}ip_hash; {{else}}zone {{ $host.Upstream.Name }}_backend 64k;{{ if $host.StickyCookie }}
+        sticky cookie {{ $host.StickyCookie }};{{ end }}{{ end }}
 
         {{ range $up := $host.Upstream.Servers }}server {{ $up.Addr }};
         {{ end }}
@@ -91,7 +93,8 @@ http {
     {{ end }}
     {{ range $k, $ctxroot := $host.ContextRoots }}
     upstream ctx{{ $k }} {
-        {{ if $host.IPHash }}ip_hash; {{else}}zone ctx{{ $ctxroot.Name }}_backend 64k;{{ end }}
+        {{ if $host.IPHash }}ip_hash; {{else}}zone ctx{{ $ctxroot.Name }}_backend 64k;{{ if $host.StickyCookie }}
+        sticky cookie {{ $host.StickyCookie }};{{ end }}{{ end }}
 	{{ range $d := $ctxroot.Upstreams }}server {{ $d }};
 	{{ end }}
     } {{ end }}
@@ -99,6 +102,27 @@ http {
     server {
         listen {{ $host.Port }};
         server_name{{ range $name := $host.ServerNames }} {{ $name }}{{ end }};
+        {{ range $header := $host.ResponseHeaders }}add_header {{ $header.Name }} "{{ $header.Value }}" always;
+        {{ end }}{{ if $host.RequestHeaders }}proxy_set_header        X-Real-IP         $remote_addr;
+        proxy_set_header        X-Forwarded-For   $proxy_add_x_forwarded_for;
+        proxy_set_header        X-Forwarded-Proto $proxy_x_forwarded_proto;
+        proxy_set_header        Host              $http_host;
+        {{ range $header := $host.RequestHeaders }}proxy_set_header {{ $header.Name }} "{{ $header.Value }}";
+        {{ end }}{{ end }}
+        {{ range $rewrite := $host.Rewrites }}rewrite "{{ $rewrite.Regex }}" "{{ $rewrite.Replacement }}";
+        {{ end }}{{ range $redirect := $host.Redirects }}{{ if not $redirect.IsHost }}location ~ "{{ $redirect.FromRegex }}(.*)$" {
+            return {{ $redirect.Code }} {{ $redirect.To }}$1$is_args$args;
+        }
+        {{ end }}{{ end }}
+        {{ if $host.Gzip }}gzip on;
+        gzip_vary on;
+        gzip_proxied any;
+        gzip_types {{ $.Config.GzipTypes }};
+        {{ end }}{{ if $host.Cache }}proxy_cache {{ $host.Upstream.Name }}_cache;
+        proxy_cache_valid 200 301 302 {{ $host.Cache.TTLSeconds }}s;
+        {{ if $host.CacheBypass }}proxy_cache_bypass {{ $host.CacheBypass }};
+        proxy_no_cache {{ $host.CacheBypass }};
+        {{ end }}{{ end }}
 
 	# nginxplus
 	status_zone {{ $host.Upstream.Name  }}_backend;
@@ -118,7 +142,7 @@ http {
         {{ if $host.SSLOnly }}return 302 https://$server_name$request_uri;{{ else }}
 	{{ if $host.Upstream.Servers }}
         location / {
-            {{ if $host.SSLBackend }}proxy_pass https://{{ $host.Upstream.Name }};{{ else }}proxy_pass http://{{ $host.Upstream.Name }};{{ end }}
+            {{ if eq $host.BackendProtocol "grpc" }}{{ if $host.SSLBackend }}grpc_pass grpcs://{{ $host.Upstream.Name }};{{ else }}grpc_pass grpc://{{ $host.Upstream.Name }};{{ end }}{{ else }}{{ if $host.SSLBackend }}proxy_pass https://{{ $host.Upstream.Name }};{{ else }}proxy_pass http://{{ $host.Upstream.Name }};{{ end }}{{ end }}
         }
 	{{ end }}
 
@@ -128,6 +152,12 @@ http {
             proxy_http_version 1.1;
             proxy_set_header Upgrade $http_upgrade;
             proxy_set_header Connection $connection_upgrade;
+            proxy_set_header        X-Real-IP         $remote_addr;
+            proxy_set_header        X-Forwarded-For   $proxy_add_x_forwarded_for;
+            proxy_set_header        X-Forwarded-Proto $proxy_x_forwarded_proto;
+            proxy_set_header        Host              $http_host;
+            {{ range $header := $host.RequestHeaders }}proxy_set_header {{ $header.Name }} "{{ $header.Value }}";
+            {{ end }}
         }
 
         location /nginx_status {
@@ -136,17 +166,40 @@ http {
         }
 
         {{ end }}
+        {{ end }}
     }
     {{ if $host.SSL }}
     server {
-        listen {{ $host.SSLPort }};
+        listen {{ $host.SSLPort }}{{ if $host.HTTP2 }} ssl http2{{ end }};
         ssl on;
         ssl_certificate {{ $host.SSLCert }};
         ssl_certificate_key {{ $host.SSLCertKey }};
         server_name{{ range $name := $host.ServerNames }} {{ $name }}{{ end }};
+        {{ if $host.SSLOnly }}add_header Strict-Transport-Security "{{ $host.HSTS }}" always;{{ end }}
+        {{ range $header := $host.ResponseHeaders }}add_header {{ $header.Name }} "{{ $header.Value }}" always;
+        {{ end }}{{ if $host.RequestHeaders }}proxy_set_header        X-Real-IP         $remote_addr;
+        proxy_set_header        X-Forwarded-For   $proxy_add_x_forwarded_for;
+        proxy_set_header        X-Forwarded-Proto $proxy_x_forwarded_proto;
+        proxy_set_header        Host              $http_host;
+        {{ range $header := $host.RequestHeaders }}proxy_set_header {{ $header.Name }} "{{ $header.Value }}";
+        {{ end }}{{ end }}
+        {{ range $rewrite := $host.Rewrites }}rewrite "{{ $rewrite.Regex }}" "{{ $rewrite.Replacement }}";
+        {{ end }}{{ range $redirect := $host.Redirects }}{{ if not $redirect.IsHost }}location ~ "{{ $redirect.FromRegex }}(.*)$" {
+            return {{ $redirect.Code }} {{ $redirect.To }}$1$is_args$args;
+        }
+        {{ end }}{{ end }}
+        {{ if $host.Gzip }}gzip on;
+        gzip_vary on;
+        gzip_proxied any;
+        gzip_types {{ $.Config.GzipTypes }};
+        {{ end }}{{ if $host.Cache }}proxy_cache {{ $host.Upstream.Name }}_cache;
+        proxy_cache_valid 200 301 302 {{ $host.Cache.TTLSeconds }}s;
+        {{ if $host.CacheBypass }}proxy_cache_bypass {{ $host.CacheBypass }};
+        proxy_no_cache {{ $host.CacheBypass }};
+        {{ end }}{{ end }}
 
         location / {
-            {{ if $host.SSLBackend }}proxy_pass https://{{ $host.Upstream.Name }};{{ else }}proxy_pass http://{{ $host.Upstream.Name }};{{ end }}
+            {{ if eq $host.BackendProtocol "grpc" }}{{ if $host.SSLBackend }}grpc_pass grpcs://{{ $host.Upstream.Name }};{{ else }}grpc_pass grpc://{{ $host.Upstream.Name }};{{ end }}{{ else }}{{ if $host.SSLBackend }}proxy_pass https://{{ $host.Upstream.Name }};{{ else }}proxy_pass http://{{ $host.Upstream.Name }};{{ end }}{{ end }}
         }
 
         {{ range $ws := $host.WebsocketEndpoints }}
@@ -155,6 +208,12 @@ http {
             proxy_http_version 1.1;
             proxy_set_header Upgrade $http_upgrade;
             proxy_set_header Connection $connection_upgrade;
+            proxy_set_header        X-Real-IP         $remote_addr;
+            proxy_set_header        X-Forwarded-For   $proxy_add_x_forwarded_for;
+            proxy_set_header        X-Forwarded-Proto $proxy_x_forwarded_proto;
+            proxy_set_header        Host              $http_host;
+            {{ range $header := $host.RequestHeaders }}proxy_set_header {{ $header.Name }} "{{ $header.Value }}";
+            {{ end }}
         }
 
         location /nginx_status {
@@ -165,7 +224,23 @@ http {
     }
     {{ end }}
 
-    {{ end }}
+    {{ range $redirect := $host.Redirects }}{{ if $redirect.IsHost }}
+    server {
+        listen {{ $host.Port }};
+        server_name {{ $redirect.From }};
+        return {{ $redirect.Code }} {{ if $redirect.HasScheme }}{{ $redirect.To }}{{ else }}$scheme://{{ $redirect.To }}{{ end }}$request_uri;
+    }
+    {{ if $host.SSL }}
+    server {
+        listen {{ $host.SSLPort }};
+        ssl on;
+        ssl_certificate {{ $host.SSLCert }};
+        ssl_certificate_key {{ $host.SSLCertKey }};
+        server_name {{ $redirect.From }};
+        return {{ $redirect.Code }} {{ if $redirect.HasScheme }}{{ $redirect.To }}{{ else }}$scheme://{{ $redirect.To }}{{ end }}$request_uri;
+    }
+    {{ end }}{{ end }}{{ end }}
+
     {{ end }} {{/* end host range */}}
 
     include {{ .Config.ConfigBasePath }}/conf.d/*.conf;
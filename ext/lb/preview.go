@@ -0,0 +1,166 @@
+package lb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/ehazlett/interlock/config"
+	"github.com/ehazlett/interlock/ext"
+	"github.com/ehazlett/interlock/ext/lb/haproxy"
+	"github.com/ehazlett/interlock/ext/lb/nginx"
+	"golang.org/x/net/context"
+)
+
+// Route is a host of a generated proxy config with its upstreams
+type Route struct {
+	Host         string
+	Aliases      []string
+	ContextRoots []string
+	Upstreams    []string
+}
+
+// PreviewRoutes generates the proxy config of the extension for containers
+// which are not deployed (i.e. the services of a compose file) and returns
+// its routes.  The upstreams of the containers using an overlay network
+// (interlock.network) are reported by container name as the addresses of
+// the tasks are only known once deployed.
+func PreviewRoutes(ctx context.Context, c *config.ExtensionConfig, containers []types.Container) ([]*Route, error) {
+	proxyCfg := c.Proxy()
+	if proxyCfg == nil {
+		return nil, fmt.Errorf("missing load balancer config: %s", c.Name)
+	}
+
+	labels, err := ext.NewLabelNamespace(proxyCfg.LabelPrefix, proxyCfg.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	var backend LoadBalancerBackend
+	switch c.Name {
+	case "haproxy":
+		backend, err = haproxy.NewHAProxyLoadBalancer(c, nil)
+	case "nginx":
+		backend, err = nginx.NewNginxLoadBalancer(c, nil)
+	default:
+		return nil, fmt.Errorf("unknown load balancer backend: %s", c.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	selected := selectContainers(labels, containers)
+	for i, cnt := range selected {
+		selected[i] = previewContainer(cnt)
+	}
+
+	cfg, err := backend.GenerateProxyConfig(ctx, selected)
+	if err != nil {
+		return nil, err
+	}
+
+	return proxyRoutes(cfg), nil
+}
+
+// previewContainer replaces the overlay network of the container by ports
+// on the container name since the network cannot be inspected
+func previewContainer(c types.Container) types.Container {
+	if _, ok := c.Labels[ext.InterlockNetworkLabel]; !ok {
+		return c
+	}
+
+	name := c.ID
+	if len(c.Names) > 0 {
+		name = strings.TrimPrefix(c.Names[0], "/")
+	}
+
+	labels := map[string]string{}
+	for k, v := range c.Labels {
+		if k != ext.InterlockNetworkLabel {
+			labels[k] = v
+		}
+	}
+	c.Labels = labels
+
+	ports := []types.Port{}
+	for _, p := range c.Ports {
+		ports = append(ports, types.Port{
+			IP:          name,
+			PrivatePort: p.PrivatePort,
+			PublicPort:  p.PrivatePort,
+			Type:        p.Type,
+		})
+	}
+	c.Ports = ports
+
+	return c
+}
+
+// proxyRoutes returns the routes of the proxy config sorted by host
+func proxyRoutes(cfg interface{}) []*Route {
+	routes := []*Route{}
+
+	switch c := cfg.(type) {
+	case *haproxy.Config:
+		for _, h := range c.Hosts {
+			r := &Route{
+				Host:      h.Domain,
+				Upstreams: []string{},
+			}
+
+			if h.ContextRoot != nil && h.ContextRoot.Path != "" {
+				r.ContextRoots = []string{h.ContextRoot.Path}
+			}
+
+			for _, u := range h.Upstreams {
+				r.Upstreams = append(r.Upstreams, u.Addr)
+			}
+
+			routes = append(routes, r)
+		}
+	case *nginx.Config:
+		for _, h := range c.Hosts {
+			r := &Route{
+				Upstreams: []string{},
+			}
+
+			if h.Upstream != nil {
+				r.Host = h.Upstream.Name
+				for _, s := range h.Upstream.Servers {
+					r.Upstreams = append(r.Upstreams, s.Addr)
+				}
+			}
+
+			for _, n := range h.ServerNames {
+				if n != r.Host {
+					r.Aliases = append(r.Aliases, n)
+				}
+			}
+
+			for _, ctxroot := range h.ContextRoots {
+				r.ContextRoots = append(r.ContextRoots, ctxroot.Path)
+				r.Upstreams = append(r.Upstreams, ctxroot.Upstreams...)
+			}
+			sort.Strings(r.ContextRoots)
+
+			routes = append(routes, r)
+		}
+	}
+
+	sort.Sort(routesByHost(routes))
+
+	return routes
+}
+
+type routesByHost []*Route
+
+func (r routesByHost) Len() int      { return len(r) }
+func (r routesByHost) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r routesByHost) Less(i, j int) bool {
+	if r[i].Host != r[j].Host {
+		return r[i].Host < r[j].Host
+	}
+
+	return strings.Join(r[i].ContextRoots, ",") < strings.Join(r[j].ContextRoots, ",")
+}
@@ -0,0 +1,181 @@
+package lb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/ehazlett/interlock/config"
+	"github.com/ehazlett/interlock/ext"
+	"github.com/ehazlett/interlock/ext/lb/haproxy"
+	"github.com/ehazlett/interlock/ext/lb/nginx"
+	"golang.org/x/net/context"
+)
+
+func previewContainers() []types.Container {
+	return []types.Container{
+		{
+			ID:    "web00000000000001",
+			Names: []string{"/app_web.1"},
+			Labels: map[string]string{
+				ext.InterlockHostnameLabel:           "web",
+				ext.InterlockDomainLabel:             "example.com",
+				ext.InterlockAliasDomainLabel + ".0": "www.example.com",
+			},
+			Ports: []types.Port{{IP: "0.0.0.0", PrivatePort: 80, PublicPort: 8080, Type: "tcp"}},
+		},
+		{
+			ID:    "api00000000000001",
+			Names: []string{"/app_api.1"},
+			Labels: map[string]string{
+				ext.InterlockHostnameLabel: "api",
+				ext.InterlockDomainLabel:   "example.com",
+				ext.InterlockNetworkLabel:  "backend",
+			},
+			Ports: []types.Port{{PrivatePort: 9000, Type: "tcp"}},
+		},
+		{
+			ID:    "db000000000000001",
+			Names: []string{"/app_db.1"},
+		},
+	}
+}
+
+func TestPreviewRoutesNginx(t *testing.T) {
+	c := &config.ExtensionConfig{Name: "nginx"}
+	if err := config.SetConfigDefaults(c); err != nil {
+		t.Fatal(err)
+	}
+
+	routes, err := PreviewRoutes(context.Background(), c, previewContainers())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*Route{
+		{Host: "api.example.com", Upstreams: []string{"app_api.1:9000"}},
+		{Host: "web.example.com", Aliases: []string{"www.example.com"}, Upstreams: []string{"0.0.0.0:8080"}},
+	}
+
+	if !reflect.DeepEqual(routes, expected) {
+		t.Fatalf("expected %+v; received %+v", expected, routes)
+	}
+}
+
+func TestPreviewRoutesHAProxy(t *testing.T) {
+	c := &config.ExtensionConfig{Name: "haproxy"}
+	if err := config.SetConfigDefaults(c); err != nil {
+		t.Fatal(err)
+	}
+	c.HAProxy.LabelPrefix = "public.interlock."
+
+	containers := previewContainers()
+	containers[0].Labels = map[string]string{
+		"public.interlock.hostname": "web",
+		"public.interlock.domain":   "example.com",
+	}
+
+	routes, err := PreviewRoutes(context.Background(), c, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 1 || routes[0].Host != "web.example.com" || !reflect.DeepEqual(routes[0].Upstreams, []string{"0.0.0.0:8080"}) {
+		t.Fatalf("unexpected routes: %+v", routes)
+	}
+}
+
+func TestGenerateProxyConfigAliasRedirectsHAProxy(t *testing.T) {
+	c := &config.ExtensionConfig{Name: "haproxy"}
+	if err := config.SetConfigDefaults(c); err != nil {
+		t.Fatal(err)
+	}
+
+	backend, err := haproxy.NewHAProxyLoadBalancer(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	containers := previewContainers()[:1]
+	containers[0].Labels[ext.InterlockRedirectLabel+".0"] = "/docs,/v2/docs"
+
+	cfg, err := backend.GenerateProxyConfig(context.Background(), containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts := map[string]*haproxy.Host{}
+	for _, h := range cfg.(*haproxy.Config).Hosts {
+		hosts[h.Domain] = h
+	}
+
+	for _, domain := range []string{"web.example.com", "www.example.com"} {
+		h, ok := hosts[domain]
+		if !ok {
+			t.Fatalf("expected host %s; received %+v", domain, hosts)
+		}
+
+		if len(h.Redirects) != 1 || h.Redirects[0].To != "/v2/docs" {
+			t.Fatalf("expected the redirect for %s; received %+v", domain, h.Redirects)
+		}
+	}
+}
+
+func TestGenerateProxyConfigOrder(t *testing.T) {
+	c := &config.ExtensionConfig{Name: "nginx"}
+	if err := config.SetConfigDefaults(c); err != nil {
+		t.Fatal(err)
+	}
+
+	backend, err := nginx.NewNginxLoadBalancer(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	containers := previewContainers()[:1]
+	for _, n := range []string{"2", "3"} {
+		replica := containers[0]
+		replica.ID = "web0000000000000" + n
+		replica.Names = []string{"/app_web." + n}
+		replica.Labels = map[string]string{
+			ext.InterlockHostnameLabel: "web",
+			ext.InterlockDomainLabel:   "example.com",
+			ext.InterlockSSLOnlyLabel:  "true",
+		}
+		containers = append(containers, replica)
+	}
+	containers = append(containers, types.Container{
+		ID:     "docs0000000000001",
+		Names:  []string{"/app_docs.1"},
+		Labels: map[string]string{ext.InterlockHostnameLabel: "docs", ext.InterlockDomainLabel: "example.com"},
+		Ports:  []types.Port{{IP: "0.0.0.0", PrivatePort: 80, PublicPort: 8081, Type: "tcp"}},
+	})
+
+	reversed := []types.Container{}
+	for i := len(containers) - 1; i >= 0; i-- {
+		reversed = append(reversed, containers[i])
+	}
+
+	configs := []*nginx.Config{}
+	for _, cnts := range [][]types.Container{containers, reversed} {
+		cfg, err := backend.GenerateProxyConfig(context.Background(), cnts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		configs = append(configs, cfg.(*nginx.Config))
+	}
+
+	if !reflect.DeepEqual(configs[0].Hosts, configs[1].Hosts) {
+		t.Fatalf("expected the same hosts for the containers in any order")
+	}
+
+	hosts := configs[0].Hosts
+	if len(hosts) != 2 || hosts[0].Upstream.Name != "docs.example.com" || hosts[1].Upstream.Name != "web.example.com" {
+		t.Fatalf("expected the hosts sorted by name; received %+v", hosts)
+	}
+
+	// the first replica (app_web.1) does not set ssl_only
+	if hosts[1].SSLOnly {
+		t.Fatalf("expected ssl_only of the first replica")
+	}
+}
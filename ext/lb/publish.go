@@ -0,0 +1,181 @@
+package lb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/docker/libkv/store"
+	"github.com/ehazlett/interlock/ext"
+	"github.com/ehazlett/interlock/ext/lb/haproxy"
+	"github.com/ehazlett/interlock/ext/lb/nginx"
+)
+
+const (
+	publishKeyPrefix = "interlock/v1"
+	publishRetries   = 5
+)
+
+// PublishedConfig is the proxy config of an extension published to the key
+// value store for the agents
+type PublishedConfig struct {
+	// Revision is incremented on each change of the config or files
+	Revision   uint64
+	Extension  string
+	ConfigPath string
+	Config     []byte
+	// Files are the ssl certificates and keys of the config by path; they
+	// are only published with PublishSSLFiles
+	Files    map[string][]byte
+	Checksum string
+}
+
+// PublishKey returns the key of the config published by the extension
+// with the label prefix; the extensions of the same type are told apart by
+// their label prefix (i.e. interlock/v1/haproxy/public.interlock/config)
+func PublishKey(extension, labelPrefix string) string {
+	if labelPrefix == "" {
+		labelPrefix = ext.DefaultLabelPrefix
+	}
+
+	return fmt.Sprintf("%s/%s/%s/config", publishKeyPrefix, extension, strings.TrimRight(labelPrefix, "./"))
+}
+
+// checksum returns the checksum of the config and the files
+func (c *PublishedConfig) checksum() string {
+	h := sha256.New()
+	h.Write([]byte(c.ConfigPath))
+	h.Write(c.Config)
+
+	paths := []string{}
+	for p := range c.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		h.Write([]byte(p))
+		h.Write(c.Files[p])
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Publish enables writing the proxy configs to the key value store on
+// each update
+func (l *LoadBalancer) Publish(kv store.Store) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.kv = kv
+}
+
+// publishConfig writes the rendered proxy config along with the ssl files
+// to the key value store.  The revision is only incremented when the config
+// or files change; concurrent writers are handled by the atomic put of the
+// store.  It returns the published revision.
+func (l *LoadBalancer) publishConfig(kv store.Store, cfg interface{}) (uint64, error) {
+	data, err := l.renderConfig(cfg)
+	if err != nil {
+		return 0, err
+	}
+
+	pc := &PublishedConfig{
+		Extension:  l.backend.Name(),
+		ConfigPath: l.backend.ConfigPath(),
+		Config:     data,
+		Files:      map[string][]byte{},
+	}
+
+	// the keys are stored in plaintext so they are only published if
+	// enabled; otherwise they are provisioned on the proxy hosts
+	if l.publishSSLFiles {
+		for _, p := range sslFiles(cfg) {
+			d, err := ioutil.ReadFile(p)
+			if err != nil {
+				log().Warnf("unable to publish ssl file: %s", err)
+				continue
+			}
+			pc.Files[p] = d
+		}
+	}
+	pc.Checksum = pc.checksum()
+
+	key := PublishKey(pc.Extension, l.labels.Prefix)
+	for i := 0; i < publishRetries; i++ {
+		prev, err := kv.Get(key)
+		if err != nil && err != store.ErrKeyNotFound {
+			return 0, err
+		}
+
+		pc.Revision = 1
+		if prev != nil {
+			var current PublishedConfig
+			if err := json.Unmarshal(prev.Value, &current); err != nil {
+				return 0, fmt.Errorf("invalid published config: %s", err)
+			}
+
+			if current.Checksum == pc.Checksum {
+				return current.Revision, nil
+			}
+
+			pc.Revision = current.Revision + 1
+		}
+
+		v, err := json.Marshal(pc)
+		if err != nil {
+			return 0, err
+		}
+
+		_, _, err = kv.AtomicPut(key, v, prev, nil)
+		switch err {
+		case nil:
+			log().Infof("published proxy config: key=%s revision=%d", key, pc.Revision)
+			return pc.Revision, nil
+		case store.ErrKeyModified, store.ErrKeyExists:
+			log().Debugf("published config modified; retrying: key=%s", key)
+			continue
+		}
+
+		return 0, err
+	}
+
+	return 0, fmt.Errorf("unable to publish config: key %s modified %d times", key, publishRetries)
+}
+
+// sslFiles returns the ssl certificates and keys used by the proxy config
+func sslFiles(cfg interface{}) []string {
+	found := map[string]bool{}
+
+	switch c := cfg.(type) {
+	case *nginx.Config:
+		for _, h := range c.Hosts {
+			for _, p := range []string{h.SSLCert, h.SSLCertKey} {
+				if p != "" {
+					found[p] = true
+				}
+			}
+		}
+	case *haproxy.Config:
+		if c.Config != nil && c.Config.HAProxyConfig != nil && c.Config.SSLCert != "" {
+			p := c.Config.SSLCert
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(c.Config.SSLCertPath, p)
+			}
+			found[p] = true
+		}
+	}
+
+	files := []string{}
+	for p := range found {
+		files = append(files, p)
+	}
+	sort.Strings(files)
+
+	return files
+}
@@ -0,0 +1,370 @@
+package lb
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/libkv"
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/ehazlett/interlock/config"
+	"github.com/ehazlett/interlock/ext/lb/haproxy"
+	"github.com/ehazlett/interlock/ext/lb/nginx"
+)
+
+func testStore(t *testing.T, dir string) store.Store {
+	boltdb.Register()
+
+	kv, err := libkv.NewStore(store.BOLTDB, []string{filepath.Join(dir, "interlock.db")}, &store.Config{Bucket: "interlock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return kv
+}
+
+func TestPublishConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "interlock-publish")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	kv := testStore(t, dir)
+	defer kv.Close()
+
+	srv := fakeEngine(t)
+	defer srv.Close()
+
+	l := testLoadBalancer(t, srv, &fakeBackend{})
+	cfg := &haproxy.Config{Hosts: []*haproxy.Host{}}
+
+	rev, err := l.publishConfig(kv, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rev != 1 {
+		t.Fatalf("expected revision 1; received %d", rev)
+	}
+
+	// an unchanged config is not published again
+	if rev, err = l.publishConfig(kv, cfg); err != nil || rev != 1 {
+		t.Fatalf("expected revision 1; received %d %v", rev, err)
+	}
+
+	cfg.Hosts = append(cfg.Hosts, &haproxy.Host{Name: "app"})
+	if rev, err = l.publishConfig(kv, cfg); err != nil || rev != 2 {
+		t.Fatalf("expected revision 2; received %d %v", rev, err)
+	}
+
+	pair, err := kv.Get("interlock/v1/haproxy/interlock/config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pc PublishedConfig
+	if err := json.Unmarshal(pair.Value, &pc); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(pc.Config) != "hosts=1" || pc.ConfigPath != "/usr/local/etc/haproxy/haproxy.cfg" || pc.Revision != 2 {
+		t.Fatalf("unexpected published config: %+v", pc)
+	}
+}
+
+func TestPublishSSLFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "interlock-publish")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	kv := testStore(t, dir)
+	defer kv.Close()
+
+	srv := fakeEngine(t)
+	defer srv.Close()
+
+	certPath := filepath.Join(dir, "proxy.pem")
+	if err := ioutil.WriteFile(certPath, []byte("cert"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	l := testLoadBalancer(t, srv, &fakeBackend{})
+	cfg := &haproxy.Config{
+		Hosts: []*haproxy.Host{},
+		Config: &haproxy.TemplateConfig{
+			HAProxyConfig: &config.HAProxyConfig{SSLCert: certPath},
+		},
+	}
+
+	for _, publishSSLFiles := range []bool{false, true} {
+		l.publishSSLFiles = publishSSLFiles
+		if _, err := l.publishConfig(kv, cfg); err != nil {
+			t.Fatal(err)
+		}
+
+		pair, err := kv.Get(PublishKey("haproxy", ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var pc PublishedConfig
+		if err := json.Unmarshal(pair.Value, &pc); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := pc.Files[certPath]; ok != publishSSLFiles {
+			t.Fatalf("expected the ssl files to be published only if enabled; received %v", pc.Files)
+		}
+	}
+}
+
+func TestSSLFiles(t *testing.T) {
+	cfg := &nginx.Config{
+		Hosts: []*nginx.Host{
+			{SSLCert: "/certs/b.pem", SSLCertKey: "/certs/b.key"},
+			{SSLCert: "/certs/a.pem", SSLCertKey: "/certs/a.key"},
+			{SSLCert: "/certs/a.pem", SSLCertKey: "/certs/a.key"},
+			{},
+		},
+	}
+
+	expected := []string{"/certs/a.key", "/certs/a.pem", "/certs/b.key", "/certs/b.pem"}
+	if files := sslFiles(cfg); !reflect.DeepEqual(files, expected) {
+		t.Fatalf("expected %v; received %v", expected, files)
+	}
+}
+
+func testPublish(t *testing.T, kv store.Store, pc *PublishedConfig) {
+	pc.Checksum = pc.checksum()
+
+	v, err := json.Marshal(pc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := kv.Put(PublishKey(pc.Extension, ""), v, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAgentSync(t *testing.T) {
+	dir, err := ioutil.TempDir("", "interlock-agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	kv := testStore(t, dir)
+	defer kv.Close()
+
+	configPath := filepath.Join(dir, "nginx", "nginx.conf")
+	certPath := filepath.Join(dir, "certs", "app.pem")
+	reloaded := filepath.Join(dir, "reloaded")
+
+	a, err := NewAgent(kv, &AgentConfig{
+		Extension:       "nginx",
+		BaseDir:         dir,
+		ConfigPath:      configPath,
+		ValidateCommand: "grep -q server {config}",
+		ReloadCommand:   "touch " + reloaded,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing is published yet
+	if err := a.Sync(); err != nil || a.Revision() != 0 {
+		t.Fatalf("expected no revision; received %d %v", a.Revision(), err)
+	}
+
+	testPublish(t, kv, &PublishedConfig{
+		Revision:  1,
+		Extension: "nginx",
+		Config:    []byte("server {}"),
+		Files:     map[string][]byte{certPath: []byte("cert")},
+	})
+
+	if err := a.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Revision() != 1 {
+		t.Fatalf("expected revision 1; received %d", a.Revision())
+	}
+
+	for p, expected := range map[string]string{configPath: "server {}", certPath: "cert"} {
+		d, err := ioutil.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(d) != expected {
+			t.Fatalf("expected %q in %s; received %q", expected, p, d)
+		}
+	}
+
+	if _, err := os.Stat(reloaded); err != nil {
+		t.Fatalf("expected proxy to be reloaded: %s", err)
+	}
+
+	// an invalid config does not replace the current config and files
+	testPublish(t, kv, &PublishedConfig{
+		Revision:  2,
+		Extension: "nginx",
+		Config:    []byte("invalid"),
+		Files:     map[string][]byte{certPath: []byte("new cert")},
+	})
+
+	if err := a.Sync(); err == nil || !strings.Contains(err.Error(), "invalid config") {
+		t.Fatalf("expected invalid config error; received %v", err)
+	}
+
+	if a.Revision() != 1 {
+		t.Fatalf("expected revision 1; received %d", a.Revision())
+	}
+
+	if d, _ := ioutil.ReadFile(configPath); string(d) != "server {}" {
+		t.Fatalf("expected current config to be kept; received %q", d)
+	}
+
+	if d, _ := ioutil.ReadFile(certPath); string(d) != "cert" {
+		t.Fatalf("expected current cert to be kept; received %q", d)
+	}
+
+	for _, p := range []string{configPath, certPath} {
+		if _, err := os.Stat(p + ".new"); !os.IsNotExist(err) {
+			t.Fatalf("expected %s.new to be removed", p)
+		}
+	}
+}
+
+func TestAgentPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "interlock-agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	base := filepath.Join(dir, "nginx")
+	if err := os.Mkdir(base, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink(dir, filepath.Join(base, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewAgent(nil, &AgentConfig{Extension: "nginx"}); err == nil {
+		t.Fatal("expected the base directory to be required")
+	}
+
+	a, err := NewAgent(nil, &AgentConfig{Extension: "nginx", BaseDir: base})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for p, expected := range map[string]string{
+		filepath.Join(base, "nginx.conf"):      filepath.Join(base, "nginx.conf"),
+		filepath.Join(base, "ssl", "app.pem"):  filepath.Join(base, "ssl", "app.pem"),
+		"ssl/app.pem":                          filepath.Join(base, "ssl", "app.pem"),
+		"/etc/passwd":                          "",
+		"../nginx.conf":                        "",
+		filepath.Join(base, "..", "app.pem"):   "",
+		filepath.Join(base, "link", "app.pem"): "",
+		base:                                   "",
+	} {
+		path, err := a.localPath(p)
+		if expected == "" {
+			if err == nil {
+				t.Fatalf("expected %s to be rejected; received %s", p, path)
+			}
+			continue
+		}
+
+		if err != nil || path != expected {
+			t.Fatalf("expected %s for %s; received %s %v", expected, p, path, err)
+		}
+	}
+}
+
+// closingStore closes the first watch of the key as if the connection to
+// the store was lost
+type closingStore struct {
+	store.Store
+	watches int
+	pair    *store.KVPair
+}
+
+func (s *closingStore) Watch(key string, stopCh <-chan struct{}) (<-chan *store.KVPair, error) {
+	s.watches++
+
+	ch := make(chan *store.KVPair, 1)
+	if s.watches == 1 {
+		close(ch)
+		return ch, nil
+	}
+
+	ch <- s.pair
+	go func() {
+		<-stopCh
+		close(ch)
+	}()
+
+	return ch, nil
+}
+
+func (s *closingStore) Get(key string) (*store.KVPair, error) {
+	return nil, store.ErrKeyNotFound
+}
+
+func TestAgentRunWatchClosed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "interlock-agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pc := &PublishedConfig{Revision: 1, Extension: "nginx", Config: []byte("server {}")}
+	v, err := json.Marshal(pc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kv := &closingStore{pair: &store.KVPair{Value: v}}
+	a, err := NewAgent(kv, &AgentConfig{
+		Extension:    "nginx",
+		BaseDir:      dir,
+		ConfigPath:   "nginx.conf",
+		PollInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stopCh := make(chan struct{})
+	errCh := make(chan error)
+	go func() {
+		errCh <- a.Run(stopCh)
+	}()
+
+	// the config is applied by the watch established again
+	for i := 0; a.Revision() != 1; i++ {
+		if i == 1000 {
+			t.Fatal("expected the watch to be established again")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(stopCh)
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
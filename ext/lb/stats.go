@@ -0,0 +1,394 @@
+package lb
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/ehazlett/interlock/ext/lb/haproxy"
+	"github.com/ehazlett/interlock/ext/lb/nginx"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	proxyStatsTimeout = time.Second * 5
+)
+
+// proxyStat is the traffic for a single backend (haproxy) or
+// upstream (nginx) as reported by a proxy container
+type proxyStat struct {
+	Name              string
+	Requests          float64
+	Responses         map[string]float64 // keyed by status class (1xx, 2xx, ...)
+	ActiveConnections float64
+	ResponseTime      float64 // seconds; zero if not reported
+	Upstreams         []*proxyUpstreamStat
+}
+
+// proxyUpstreamStat is the traffic for a single server in a backend
+type proxyUpstreamStat struct {
+	Name              string
+	Up                bool
+	Requests          float64
+	ActiveConnections float64
+	ResponseTime      float64 // seconds; zero if not reported
+}
+
+type proxySample struct {
+	domains   map[string]string
+	stats     []*proxyStat
+	rates     map[string]float64
+	timestamp time.Time
+}
+
+var (
+	descProxyRequests = prometheus.NewDesc(
+		"interlock_proxy_requests_total",
+		"Total number of requests handled by the proxy",
+		[]string{"proxy", "domain", "backend"}, nil,
+	)
+	descProxyRequestRate = prometheus.NewDesc(
+		"interlock_proxy_request_rate",
+		"Requests per second since the previous scrape",
+		[]string{"proxy", "domain", "backend"}, nil,
+	)
+	descProxyResponses = prometheus.NewDesc(
+		"interlock_proxy_responses_total",
+		"Total number of responses by status class",
+		[]string{"proxy", "domain", "backend", "code"}, nil,
+	)
+	descProxyActiveConnections = prometheus.NewDesc(
+		"interlock_proxy_active_connections",
+		"Number of active connections",
+		[]string{"proxy", "domain", "backend"}, nil,
+	)
+	descProxyResponseTime = prometheus.NewDesc(
+		"interlock_proxy_response_time_seconds",
+		"Average backend response time",
+		[]string{"proxy", "domain", "backend"}, nil,
+	)
+	// the open source nginx only reports totals for the whole proxy
+	descProxyServerRequests = prometheus.NewDesc(
+		"interlock_proxy_server_requests_total",
+		"Total number of requests handled by the proxy for all the domains",
+		[]string{"proxy"}, nil,
+	)
+	descProxyServerRequestRate = prometheus.NewDesc(
+		"interlock_proxy_server_request_rate",
+		"Requests per second for all the domains since the previous scrape",
+		[]string{"proxy"}, nil,
+	)
+	descProxyServerActiveConnections = prometheus.NewDesc(
+		"interlock_proxy_server_active_connections",
+		"Number of active connections for all the domains",
+		[]string{"proxy"}, nil,
+	)
+	descProxyUpstreamUp = prometheus.NewDesc(
+		"interlock_proxy_upstream_up",
+		"Whether the upstream server is considered up by the proxy",
+		[]string{"proxy", "domain", "backend", "upstream"}, nil,
+	)
+	descProxyUpstreamRequests = prometheus.NewDesc(
+		"interlock_proxy_upstream_requests_total",
+		"Total number of requests sent to the upstream server",
+		[]string{"proxy", "domain", "backend", "upstream"}, nil,
+	)
+	descProxyUpstreamActiveConnections = prometheus.NewDesc(
+		"interlock_proxy_upstream_active_connections",
+		"Number of active connections to the upstream server",
+		[]string{"proxy", "domain", "backend", "upstream"}, nil,
+	)
+	descProxyUpstreamResponseTime = prometheus.NewDesc(
+		"interlock_proxy_upstream_response_time_seconds",
+		"Average response time of the upstream server",
+		[]string{"proxy", "domain", "backend", "upstream"}, nil,
+	)
+
+	proxyStats = newProxyStatsCollector()
+)
+
+func init() {
+	prometheus.MustRegister(proxyStats)
+}
+
+// proxyStatsCollector exports the latest sample scraped from each
+// proxy container
+type proxyStatsCollector struct {
+	lock    sync.Mutex
+	proxies map[string]*proxySample
+}
+
+func newProxyStatsCollector() *proxyStatsCollector {
+	return &proxyStatsCollector{
+		proxies: map[string]*proxySample{},
+	}
+}
+
+// update stores a new sample for the proxy and computes the request
+// rates from the previous sample
+func (p *proxyStatsCollector) update(proxy string, domains map[string]string, stats []*proxyStat, now time.Time) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	sample := &proxySample{
+		domains:   domains,
+		stats:     stats,
+		rates:     map[string]float64{},
+		timestamp: now,
+	}
+
+	if prev, ok := p.proxies[proxy]; ok {
+		elapsed := now.Sub(prev.timestamp).Seconds()
+		previous := map[string]float64{}
+		for _, s := range prev.stats {
+			previous[s.Name] = s.Requests
+		}
+
+		for _, s := range stats {
+			last, ok := previous[s.Name]
+			// counters reset when the proxy reloads
+			if !ok || elapsed <= 0 || s.Requests < last {
+				continue
+			}
+
+			sample.rates[s.Name] = (s.Requests - last) / elapsed
+		}
+	}
+
+	p.proxies[proxy] = sample
+}
+
+// prune removes the samples of proxies that are no longer running
+func (p *proxyStatsCollector) prune(running map[string]bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for id := range p.proxies {
+		if !running[id] {
+			delete(p.proxies, id)
+		}
+	}
+}
+
+func (p *proxyStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descProxyRequests
+	ch <- descProxyRequestRate
+	ch <- descProxyResponses
+	ch <- descProxyActiveConnections
+	ch <- descProxyResponseTime
+	ch <- descProxyServerRequests
+	ch <- descProxyServerRequestRate
+	ch <- descProxyServerActiveConnections
+	ch <- descProxyUpstreamUp
+	ch <- descProxyUpstreamRequests
+	ch <- descProxyUpstreamActiveConnections
+	ch <- descProxyUpstreamResponseTime
+}
+
+func (p *proxyStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for proxy, sample := range p.proxies {
+		for _, s := range sample.stats {
+			// proxy wide totals are not labelled with a domain or backend
+			if s.Name == "" {
+				ch <- prometheus.MustNewConstMetric(descProxyServerRequests, prometheus.CounterValue, s.Requests, proxy)
+				ch <- prometheus.MustNewConstMetric(descProxyServerActiveConnections, prometheus.GaugeValue, s.ActiveConnections, proxy)
+
+				if rate, ok := sample.rates[s.Name]; ok {
+					ch <- prometheus.MustNewConstMetric(descProxyServerRequestRate, prometheus.GaugeValue, rate, proxy)
+				}
+				continue
+			}
+
+			domain, ok := sample.domains[s.Name]
+			if !ok {
+				domain = s.Name
+			}
+
+			ch <- prometheus.MustNewConstMetric(descProxyRequests, prometheus.CounterValue, s.Requests, proxy, domain, s.Name)
+			ch <- prometheus.MustNewConstMetric(descProxyActiveConnections, prometheus.GaugeValue, s.ActiveConnections, proxy, domain, s.Name)
+
+			if rate, ok := sample.rates[s.Name]; ok {
+				ch <- prometheus.MustNewConstMetric(descProxyRequestRate, prometheus.GaugeValue, rate, proxy, domain, s.Name)
+			}
+
+			if s.ResponseTime > 0 {
+				ch <- prometheus.MustNewConstMetric(descProxyResponseTime, prometheus.GaugeValue, s.ResponseTime, proxy, domain, s.Name)
+			}
+
+			for code, v := range s.Responses {
+				ch <- prometheus.MustNewConstMetric(descProxyResponses, prometheus.CounterValue, v, proxy, domain, s.Name, code)
+			}
+
+			for _, u := range s.Upstreams {
+				up := 0.0
+				if u.Up {
+					up = 1.0
+				}
+
+				ch <- prometheus.MustNewConstMetric(descProxyUpstreamUp, prometheus.GaugeValue, up, proxy, domain, s.Name, u.Name)
+				ch <- prometheus.MustNewConstMetric(descProxyUpstreamRequests, prometheus.CounterValue, u.Requests, proxy, domain, s.Name, u.Name)
+				ch <- prometheus.MustNewConstMetric(descProxyUpstreamActiveConnections, prometheus.GaugeValue, u.ActiveConnections, proxy, domain, s.Name, u.Name)
+
+				if u.ResponseTime > 0 {
+					ch <- prometheus.MustNewConstMetric(descProxyUpstreamResponseTime, prometheus.GaugeValue, u.ResponseTime, proxy, domain, s.Name, u.Name)
+				}
+			}
+		}
+	}
+}
+
+// proxyStatsDomains returns the interlock domain for each backend or
+// upstream name in the generated proxy config
+func proxyStatsDomains(cfg interface{}) map[string]string {
+	domains := map[string]string{}
+
+	switch c := cfg.(type) {
+	case *haproxy.Config:
+		for _, h := range c.Hosts {
+			domains[h.Name] = h.Domain
+		}
+	case *nginx.Config:
+		for _, h := range c.Hosts {
+			if len(h.ServerNames) == 0 {
+				continue
+			}
+
+			domain := h.ServerNames[0]
+			if h.Upstream != nil {
+				domains[h.Upstream.Name] = domain
+			}
+
+			for k, ctxroot := range h.ContextRoots {
+				domains["ctx"+k] = domain
+				domains["ctx"+ctxroot.Name] = domain
+			}
+		}
+	}
+
+	return domains
+}
+
+// proxyStatsAddr returns the address used to reach the stats endpoint
+// of the proxy container
+func proxyStatsAddr(cnt types.Container, port int) (string, error) {
+	if cnt.NetworkSettings == nil {
+		return "", fmt.Errorf("no networks for proxy container %s", cnt.ID)
+	}
+
+	networks := []string{}
+	for name := range cnt.NetworkSettings.Networks {
+		networks = append(networks, name)
+	}
+	sort.Strings(networks)
+
+	for _, name := range networks {
+		n := cnt.NetworkSettings.Networks[name]
+		if n != nil && n.IPAddress != "" {
+			return net.JoinHostPort(n.IPAddress, strconv.Itoa(port)), nil
+		}
+	}
+
+	return "", fmt.Errorf("unable to find address for proxy container %s", cnt.ID)
+}
+
+// scrapeProxyStats collects the traffic stats from all proxy containers
+func (l *LoadBalancer) scrapeProxyStats() {
+	proxyContainers, err := l.ProxyContainers(l.backend.Name())
+	if err != nil {
+		log().Errorf("unable to get proxy containers for stats: %s", err)
+		return
+	}
+
+	l.lock.Lock()
+	domains := l.statsDomains
+	// the secrets are set by the updates
+	var user, pass string
+	if l.cfg.HAProxy != nil {
+		user, pass = l.cfg.HAProxy.AdminUser, l.cfg.HAProxy.AdminPass
+	}
+	l.lock.Unlock()
+
+	running := map[string]bool{}
+	client := &http.Client{
+		Timeout: proxyStatsTimeout,
+	}
+
+	for _, cnt := range proxyContainers {
+		id := cnt.ID
+		if len(id) > 12 {
+			id = id[:12]
+		}
+		running[id] = true
+
+		addr, err := proxyStatsAddr(cnt, l.cfg.Proxy().Port)
+		if err != nil {
+			log().Warn(err)
+			continue
+		}
+
+		stats, err := l.fetchProxyStats(client, addr, user, pass)
+		if err != nil {
+			log().Warnf("unable to get proxy stats: id=%s err=%s", id, err)
+			continue
+		}
+
+		proxyStats.update(id, domains, stats, time.Now())
+	}
+
+	proxyStats.prune(running)
+}
+
+// fetchProxyStats gets the stats of a proxy; the haproxy stats use the
+// admin user and password
+func (l *LoadBalancer) fetchProxyStats(client *http.Client, addr, user, pass string) ([]*proxyStat, error) {
+	var (
+		u     string
+		parse func(io.Reader) ([]*proxyStat, error)
+	)
+
+	switch l.backend.Name() {
+	case "haproxy":
+		u = "http://" + addr + "/haproxy?stats;csv"
+		parse = parseHAProxyStats
+	case "nginx":
+		if l.cfg.Nginx.NginxPlusEnabled {
+			u = "http://" + addr + "/status"
+			parse = parseNginxPlusStatus
+		} else {
+			u = "http://" + addr + "/nginx_status"
+			parse = parseNginxStubStatus
+		}
+	default:
+		return nil, fmt.Errorf("stats not supported for backend: %s", l.backend.Name())
+	}
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if user != "" {
+		req.SetBasicAuth(user, pass)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status from %s: %s", u, resp.Status)
+	}
+
+	return parse(resp.Body)
+}
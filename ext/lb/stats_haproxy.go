@@ -0,0 +1,107 @@
+package lb
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	haproxyStatsTypeBackend = "1"
+	haproxyStatsTypeServer  = "2"
+)
+
+// parseHAProxyStats parses the CSV output of the haproxy stats page
+func parseHAProxyStats(r io.Reader) ([]*proxyStat, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read haproxy stats header: %s", err)
+	}
+
+	fields := map[string]int{}
+	for i, f := range header {
+		fields[strings.TrimSpace(strings.TrimPrefix(f, "#"))] = i
+	}
+
+	for _, f := range []string{"pxname", "svname", "type"} {
+		if _, ok := fields[f]; !ok {
+			return nil, fmt.Errorf("invalid haproxy stats: missing field %s", f)
+		}
+	}
+
+	stats := []*proxyStat{}
+	backends := map[string]*proxyStat{}
+	backend := func(name string) *proxyStat {
+		if s, ok := backends[name]; ok {
+			return s
+		}
+
+		s := &proxyStat{
+			Name:      name,
+			Responses: map[string]float64{},
+		}
+		backends[name] = s
+		stats = append(stats, s)
+		return s
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read haproxy stats: %s", err)
+		}
+
+		value := func(f string) string {
+			i, ok := fields[f]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return record[i]
+		}
+
+		number := func(f string) float64 {
+			v, err := strconv.ParseFloat(value(f), 64)
+			if err != nil {
+				return 0
+			}
+			return v
+		}
+
+		// req_tot is only reported for http backends by newer versions
+		requests := number("stot")
+		if value("req_tot") != "" {
+			requests = number("req_tot")
+		}
+
+		switch value("type") {
+		case haproxyStatsTypeBackend:
+			s := backend(value("pxname"))
+			s.Requests = requests
+			s.ActiveConnections = number("scur")
+			s.ResponseTime = number("rtime") / 1000
+			for _, code := range []string{"1xx", "2xx", "3xx", "4xx", "5xx"} {
+				s.Responses[code] = number("hrsp_" + code)
+			}
+		case haproxyStatsTypeServer:
+			s := backend(value("pxname"))
+			status := value("status")
+			s.Upstreams = append(s.Upstreams, &proxyUpstreamStat{
+				Name:              value("svname"),
+				Up:                strings.HasPrefix(status, "UP") || status == "no check",
+				Requests:          requests,
+				ActiveConnections: number("scur"),
+				ResponseTime:      number("rtime") / 1000,
+			})
+		}
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,188 @@
+package lb
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	nginxZoneSuffix = "_backend"
+)
+
+// parseNginxStubStatus parses the output of the nginx stub_status module.
+// stub_status only reports totals for the whole proxy so a single
+// stat is returned without a backend name.
+func parseNginxStubStatus(r io.Reader) ([]*proxyStat, error) {
+	s := &proxyStat{
+		Responses: map[string]float64{},
+	}
+
+	lines := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSpace(scanner.Text()))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	foundActive := false
+	foundRequests := false
+	for i, line := range lines {
+		if strings.HasPrefix(line, "Active connections:") {
+			v, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimPrefix(line, "Active connections:")), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid nginx active connections: %s", line)
+			}
+			s.ActiveConnections = v
+			foundActive = true
+		}
+
+		// the totals follow the "server accepts handled requests" line
+		if strings.HasPrefix(line, "server accepts handled requests") && i+1 < len(lines) {
+			parts := strings.Fields(lines[i+1])
+			if len(parts) != 3 {
+				return nil, fmt.Errorf("invalid nginx request totals: %s", lines[i+1])
+			}
+
+			v, err := strconv.ParseFloat(parts[2], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid nginx request totals: %s", lines[i+1])
+			}
+			s.Requests = v
+			foundRequests = true
+		}
+	}
+
+	if !foundActive || !foundRequests {
+		return nil, fmt.Errorf("invalid nginx stub status")
+	}
+
+	return []*proxyStat{s}, nil
+}
+
+type nginxPlusResponses struct {
+	Responses1xx float64 `json:"1xx"`
+	Responses2xx float64 `json:"2xx"`
+	Responses3xx float64 `json:"3xx"`
+	Responses4xx float64 `json:"4xx"`
+	Responses5xx float64 `json:"5xx"`
+}
+
+func (r nginxPlusResponses) byClass() map[string]float64 {
+	return map[string]float64{
+		"1xx": r.Responses1xx,
+		"2xx": r.Responses2xx,
+		"3xx": r.Responses3xx,
+		"4xx": r.Responses4xx,
+		"5xx": r.Responses5xx,
+	}
+}
+
+type nginxPlusServerZone struct {
+	Processing float64            `json:"processing"`
+	Requests   float64            `json:"requests"`
+	Responses  nginxPlusResponses `json:"responses"`
+}
+
+type nginxPlusPeer struct {
+	Server       string  `json:"server"`
+	State        string  `json:"state"`
+	Active       float64 `json:"active"`
+	Requests     float64 `json:"requests"`
+	ResponseTime float64 `json:"response_time"`
+}
+
+type nginxPlusStatus struct {
+	ServerZones map[string]nginxPlusServerZone `json:"server_zones"`
+	Upstreams   map[string]json.RawMessage     `json:"upstreams"`
+}
+
+// nginxPlusPeers decodes the peers of an upstream; older versions of the
+// status module report the peers as a list instead of an object
+func nginxPlusPeers(data json.RawMessage) ([]nginxPlusPeer, error) {
+	var upstream struct {
+		Peers []nginxPlusPeer `json:"peers"`
+	}
+	if err := json.Unmarshal(data, &upstream); err == nil {
+		return upstream.Peers, nil
+	}
+
+	var peers []nginxPlusPeer
+	if err := json.Unmarshal(data, &peers); err != nil {
+		return nil, err
+	}
+
+	return peers, nil
+}
+
+// parseNginxPlusStatus parses the JSON output of the nginx plus status module
+func parseNginxPlusStatus(r io.Reader) ([]*proxyStat, error) {
+	var status nginxPlusStatus
+	if err := json.NewDecoder(r).Decode(&status); err != nil {
+		return nil, fmt.Errorf("invalid nginx plus status: %s", err)
+	}
+
+	stats := map[string]*proxyStat{}
+	stat := func(name string) *proxyStat {
+		if s, ok := stats[name]; ok {
+			return s
+		}
+
+		s := &proxyStat{
+			Name:      name,
+			Responses: map[string]float64{},
+		}
+		stats[name] = s
+		return s
+	}
+
+	for zone, z := range status.ServerZones {
+		s := stat(strings.TrimSuffix(zone, nginxZoneSuffix))
+		s.Requests = z.Requests
+		s.ActiveConnections = z.Processing
+		s.Responses = z.Responses.byClass()
+	}
+
+	for name, data := range status.Upstreams {
+		peers, err := nginxPlusPeers(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid nginx plus upstream %s: %s", name, err)
+		}
+
+		s := stat(name)
+		responseTime := 0.0
+		for _, p := range peers {
+			s.Upstreams = append(s.Upstreams, &proxyUpstreamStat{
+				Name:              p.Server,
+				Up:                p.State == "up",
+				Requests:          p.Requests,
+				ActiveConnections: p.Active,
+				ResponseTime:      p.ResponseTime / 1000,
+			})
+			responseTime += p.ResponseTime
+		}
+
+		if len(peers) > 0 {
+			s.ResponseTime = responseTime / float64(len(peers)) / 1000
+		}
+	}
+
+	names := []string{}
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := []*proxyStat{}
+	for _, name := range names {
+		result = append(result, stats[name])
+	}
+
+	return result, nil
+}
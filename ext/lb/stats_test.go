@@ -0,0 +1,254 @@
+package lb
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ehazlett/interlock/ext/lb/haproxy"
+	"github.com/ehazlett/interlock/ext/lb/nginx"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	testHAProxyStats = `# pxname,svname,qcur,qmax,scur,smax,slim,stot,bin,bout,dreq,dresp,ereq,econ,eresp,wretr,wredis,status,weight,act,bck,chkfail,chkdown,lastchg,downtime,qlimit,pid,iid,sid,throttle,lbtot,tracked,type,rate,rate_lim,rate_max,check_status,check_code,check_duration,hrsp_1xx,hrsp_2xx,hrsp_3xx,hrsp_4xx,hrsp_5xx,hrsp_other,hanafail,req_rate,req_rate_max,req_tot,cli_abrt,srv_abrt,comp_in,comp_out,comp_byp,comp_rsp,lastsess,last_chk,last_agt,qtime,ctime,rtime,ttime,
+http-default,FRONTEND,,,2,10,1024,120,1000,2000,0,0,0,,,,,OPEN,,,,,,,,,1,2,0,,,,0,1,0,5,,,,0,100,10,5,5,0,,1,5,120,,,0,0,0,0,,,,,,,,
+local_test,web_1,0,0,1,3,,60,500,1000,,0,,0,0,0,0,UP,1,1,0,0,0,100,0,,1,3,1,,60,,2,0,,3,L4OK,,0,0,50,5,3,2,0,0,,,,0,0,,,,,10,L4OK,,0,0,12,40,
+local_test,web_2,0,0,0,3,,40,500,1000,,0,,0,0,0,0,DOWN,1,1,0,3,1,100,50,,1,3,2,,40,,2,0,,3,L4CON,,0,0,50,5,2,3,0,0,,,,0,0,,,,,10,L4CON,,0,0,0,0,
+local_test,BACKEND,0,0,1,5,103,100,1000,2000,0,0,,0,0,0,0,UP,1,1,0,,0,100,0,,1,3,0,,100,,1,0,,5,,,,0,100,10,5,5,0,,,,100,0,0,0,0,0,0,10,,,0,0,20,50,
+`
+
+	testNginxStubStatus = `Active connections: 3
+server accepts handled requests
+ 10 10 25
+Reading: 0 Writing: 1 Waiting: 2
+`
+
+	testNginxPlusStatus = `{
+  "version": 6,
+  "connections": {"accepted": 10, "dropped": 0, "active": 3, "idle": 1},
+  "server_zones": {
+    "local_test_backend": {
+      "processing": 2,
+      "requests": 100,
+      "responses": {"1xx": 0, "2xx": 90, "3xx": 5, "4xx": 3, "5xx": 2, "total": 100},
+      "received": 1000,
+      "sent": 2000
+    }
+  },
+  "upstreams": {
+    "local_test": {
+      "peers": [
+        {"id": 0, "server": "10.0.0.2:8080", "state": "up", "active": 1, "requests": 60, "response_time": 10},
+        {"id": 1, "server": "10.0.0.3:8080", "state": "unhealthy", "active": 0, "requests": 40, "response_time": 30}
+      ],
+      "keepalive": 0
+    },
+    "ctxapi": [
+      {"id": 0, "server": "10.0.0.4:8080", "state": "up", "active": 0, "requests": 5}
+    ]
+  }
+}`
+)
+
+func TestParseHAProxyStats(t *testing.T) {
+	stats, err := parseHAProxyStats(strings.NewReader(testHAProxyStats))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 1 {
+		t.Fatalf("expected 1 backend; received %d", len(stats))
+	}
+
+	s := stats[0]
+	if s.Name != "local_test" {
+		t.Fatalf("expected backend local_test; received %s", s.Name)
+	}
+
+	if s.Requests != 100 {
+		t.Fatalf("expected 100 requests; received %v", s.Requests)
+	}
+
+	if s.ActiveConnections != 1 {
+		t.Fatalf("expected 1 active connection; received %v", s.ActiveConnections)
+	}
+
+	if s.ResponseTime != 0.02 {
+		t.Fatalf("expected response time 0.02; received %v", s.ResponseTime)
+	}
+
+	if s.Responses["5xx"] != 5 {
+		t.Fatalf("expected 5 5xx responses; received %v", s.Responses["5xx"])
+	}
+
+	if len(s.Upstreams) != 2 {
+		t.Fatalf("expected 2 upstreams; received %d", len(s.Upstreams))
+	}
+
+	if up := s.Upstreams[0]; up.Name != "web_1" || !up.Up || up.Requests != 60 {
+		t.Fatalf("unexpected upstream: %+v", up)
+	}
+
+	if up := s.Upstreams[1]; up.Name != "web_2" || up.Up {
+		t.Fatalf("expected web_2 to be down: %+v", up)
+	}
+}
+
+func TestParseHAProxyStatsInvalid(t *testing.T) {
+	if _, err := parseHAProxyStats(strings.NewReader("foo,bar\n1,2\n")); err == nil {
+		t.Fatal("expected error for invalid stats")
+	}
+}
+
+func TestParseNginxStubStatus(t *testing.T) {
+	stats, err := parseNginxStubStatus(strings.NewReader(testNginxStubStatus))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 1 {
+		t.Fatalf("expected 1 stat; received %d", len(stats))
+	}
+
+	if stats[0].ActiveConnections != 3 {
+		t.Fatalf("expected 3 active connections; received %v", stats[0].ActiveConnections)
+	}
+
+	if stats[0].Requests != 25 {
+		t.Fatalf("expected 25 requests; received %v", stats[0].Requests)
+	}
+}
+
+func TestParseNginxStubStatusInvalid(t *testing.T) {
+	if _, err := parseNginxStubStatus(strings.NewReader("<html></html>")); err == nil {
+		t.Fatal("expected error for invalid stub status")
+	}
+}
+
+func TestParseNginxPlusStatus(t *testing.T) {
+	stats, err := parseNginxPlusStatus(strings.NewReader(testNginxPlusStatus))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 stats; received %d", len(stats))
+	}
+
+	ctx := stats[0]
+	if ctx.Name != "ctxapi" || len(ctx.Upstreams) != 1 {
+		t.Fatalf("unexpected context root stat: %+v", ctx)
+	}
+
+	s := stats[1]
+	if s.Name != "local_test" {
+		t.Fatalf("expected local_test; received %s", s.Name)
+	}
+
+	if s.Requests != 100 || s.ActiveConnections != 2 {
+		t.Fatalf("unexpected zone stats: %+v", s)
+	}
+
+	if s.Responses["2xx"] != 90 {
+		t.Fatalf("expected 90 2xx responses; received %v", s.Responses["2xx"])
+	}
+
+	if s.ResponseTime != 0.02 {
+		t.Fatalf("expected response time 0.02; received %v", s.ResponseTime)
+	}
+
+	if len(s.Upstreams) != 2 {
+		t.Fatalf("expected 2 upstreams; received %d", len(s.Upstreams))
+	}
+
+	if !s.Upstreams[0].Up || s.Upstreams[1].Up {
+		t.Fatalf("unexpected upstream state: %+v %+v", s.Upstreams[0], s.Upstreams[1])
+	}
+}
+
+func TestProxyStatsCollectorRates(t *testing.T) {
+	c := newProxyStatsCollector()
+	now := time.Now()
+
+	c.update("proxy", nil, []*proxyStat{{Name: "local_test", Requests: 100}}, now)
+	c.update("proxy", nil, []*proxyStat{{Name: "local_test", Requests: 150}}, now.Add(time.Second*10))
+
+	if rate := c.proxies["proxy"].rates["local_test"]; rate != 5 {
+		t.Fatalf("expected rate 5; received %v", rate)
+	}
+
+	// counters reset on reload
+	c.update("proxy", nil, []*proxyStat{{Name: "local_test", Requests: 10}}, now.Add(time.Second*20))
+
+	if _, ok := c.proxies["proxy"].rates["local_test"]; ok {
+		t.Fatal("expected no rate after counter reset")
+	}
+
+	c.prune(map[string]bool{})
+
+	if len(c.proxies) != 0 {
+		t.Fatal("expected stopped proxies to be removed")
+	}
+}
+
+func TestProxyStatsCollectorServerTotals(t *testing.T) {
+	c := newProxyStatsCollector()
+
+	stats, err := parseNginxStubStatus(strings.NewReader(testNginxStubStatus))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.update("proxy", nil, stats, time.Now())
+
+	ch := make(chan prometheus.Metric, 10)
+	c.Collect(ch)
+	close(ch)
+
+	n := 0
+	for metric := range ch {
+		m := &dto.Metric{}
+		if err := metric.Write(m); err != nil {
+			t.Fatal(err)
+		}
+
+		// only labelled by the proxy; no empty domain or backend
+		if len(m.Label) != 1 || m.Label[0].GetName() != "proxy" {
+			t.Fatalf("expected the proxy label only; received %v", m.Label)
+		}
+		n++
+	}
+
+	if n != 2 {
+		t.Fatalf("expected the requests and active connections; received %d metrics", n)
+	}
+}
+
+func TestProxyStatsDomains(t *testing.T) {
+	haproxyDomains := proxyStatsDomains(&haproxy.Config{
+		Hosts: []*haproxy.Host{
+			{Name: "local_test", Domain: "test.local"},
+		},
+	})
+
+	if haproxyDomains["local_test"] != "test.local" {
+		t.Fatalf("unexpected haproxy domains: %v", haproxyDomains)
+	}
+
+	nginxDomains := proxyStatsDomains(&nginx.Config{
+		Hosts: []*nginx.Host{
+			{
+				ServerNames: []string{"test.local", "www.test.local"},
+				Upstream:    &nginx.Upstream{Name: "local_test"},
+				ContextRoots: map[string]*nginx.ContextRoot{
+					"api": {Name: "api", Path: "/api"},
+				},
+			},
+		},
+	})
+
+	if nginxDomains["local_test"] != "test.local" || nginxDomains["ctxapi"] != "test.local" {
+		t.Fatalf("unexpected nginx domains: %v", nginxDomains)
+	}
+}
@@ -12,7 +12,7 @@ func AliasDomains(config types.Container) []string {
 
 	for l, v := range config.Labels {
 		// this is for labels like interlock.alias_domain.1=foo.local
-		if strings.Index(l, ext.InterlockAliasDomainLabel) > -1 {
+		if strings.HasPrefix(l, ext.InterlockAliasDomainLabel) {
 			aliasDomains = append(aliasDomains, v)
 		}
 	}
@@ -33,3 +33,18 @@ func TestAliasDomainsNoLabels(t *testing.T) {
 		t.Fatalf("expected no alias domains; received %s", ep)
 	}
 }
+
+func TestAliasDomainsOtherPrefix(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockAliasDomainLabel + ".0":             "foo.bar",
+			"public." + ext.InterlockAliasDomainLabel + ".0": "bar.baz",
+		},
+	}
+
+	ep := AliasDomains(cfg)
+
+	if len(ep) != 1 || ep[0] != "foo.bar" {
+		t.Fatalf("expected only the interlock alias domain; received %s", ep)
+	}
+}
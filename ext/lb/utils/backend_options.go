@@ -12,7 +12,7 @@ func BackendOptions(config types.Container) []string {
 
 	for l, v := range config.Labels {
 		// this is for labels like interlock.backend_option.1=foo
-		if strings.Index(l, ext.InterlockBackendOptionLabel) > -1 {
+		if strings.HasPrefix(l, ext.InterlockBackendOptionLabel) {
 			options = append(options, v)
 		}
 	}
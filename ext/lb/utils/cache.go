@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/go-units"
+	"github.com/ehazlett/interlock/ext"
+)
+
+const (
+	DefaultCacheTTL = time.Minute * 5
+)
+
+// zoneSizeRegex is the nginx size syntax (i.e. 512k, 10m, 1g)
+var zoneSizeRegex = regexp.MustCompile(`^[0-9]+[kKmMgG]?$`)
+
+// CachePolicy is the response cache configuration for a host
+type CachePolicy struct {
+	ZoneSize string
+	TTL      time.Duration
+}
+
+// ZoneSizeMB returns the size of the cache zone in megabytes; zones smaller
+// than a megabyte are rounded up
+func (c *CachePolicy) ZoneSizeMB() (int64, error) {
+	size, err := zoneSize(c.ZoneSize)
+	if err != nil {
+		return 0, err
+	}
+
+	b, err := units.RAMInBytes(size)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cache zone size: %s", err)
+	}
+
+	mb := b / units.MiB
+	if mb < 1 {
+		mb = 1
+	}
+
+	return mb, nil
+}
+
+// zoneSize checks the size uses the nginx size syntax as it is written to
+// the nginx config as is; the unit is returned in lower case
+func zoneSize(v string) (string, error) {
+	if !zoneSizeRegex.MatchString(v) {
+		return "", fmt.Errorf("invalid cache zone size: expected a size such as 512k, 10m or 1g; received %q", v)
+	}
+
+	return strings.ToLower(v), nil
+}
+
+// TTLSeconds returns the time to cache responses in seconds
+func (c *CachePolicy) TTLSeconds() int64 {
+	return int64(c.TTL / time.Second)
+}
+
+func Gzip(config types.Container) (bool, error) {
+	return boolLabel(config, ext.InterlockGzipLabel, false)
+}
+
+// Cache parses labels like interlock.cache=zone:10m,ttl:5m; nil is returned
+// if caching is not enabled.  defaultZoneSize is used unless overridden by
+// the container labels.
+func Cache(config types.Container, defaultZoneSize string) (*CachePolicy, error) {
+	v, ok := config.Labels[ext.InterlockCacheLabel]
+	if !ok || v == "" {
+		return nil, nil
+	}
+
+	policy := &CachePolicy{
+		ZoneSize: defaultZoneSize,
+		TTL:      DefaultCacheTTL,
+	}
+
+	// allow interlock.cache=true to use the defaults
+	if enabled, err := boolLabel(config, ext.InterlockCacheLabel, false); err == nil {
+		if !enabled {
+			return nil, nil
+		}
+
+		v = ""
+	}
+
+	for _, opt := range strings.Split(v, ",") {
+		if opt == "" {
+			continue
+		}
+
+		parts := strings.SplitN(opt, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid cache option: expected key:value; received %q", opt)
+		}
+
+		key := strings.TrimSpace(parts[0])
+		val := strings.TrimSpace(parts[1])
+
+		switch key {
+		case "zone":
+			policy.ZoneSize = val
+		case "ttl":
+			d, err := time.ParseDuration(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cache ttl: %s", err)
+			}
+			policy.TTL = d
+		default:
+			return nil, fmt.Errorf("unknown cache option: %s", key)
+		}
+	}
+
+	size, err := zoneSize(policy.ZoneSize)
+	if err != nil {
+		return nil, err
+	}
+	policy.ZoneSize = size
+
+	if policy.TTL < time.Second {
+		return nil, fmt.Errorf("cache ttl must be at least one second: %s", policy.TTL)
+	}
+
+	return policy, nil
+}
+
+func CacheBypassHeader(config types.Container) (string, error) {
+	v, ok := config.Labels[ext.InterlockCacheBypassHeaderLabel]
+	if !ok || v == "" {
+		return "", nil
+	}
+
+	if !headerNameRegex.MatchString(v) {
+		return "", fmt.Errorf("invalid cache bypass header: %q", v)
+	}
+
+	return v, nil
+}
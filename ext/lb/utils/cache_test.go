@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/ehazlett/interlock/ext"
+)
+
+func TestGzip(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockGzipLabel: "true",
+		},
+	}
+
+	gzip, err := Gzip(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !gzip {
+		t.Fatal("expected gzip")
+	}
+}
+
+func TestCache(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockCacheLabel: "zone:20m,ttl:2m",
+		},
+	}
+
+	cache, err := Cache(cfg, "10m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cache == nil {
+		t.Fatal("expected cache policy")
+	}
+
+	if cache.ZoneSize != "20m" {
+		t.Fatalf("expected zone size 20m; received %s", cache.ZoneSize)
+	}
+
+	mb, err := cache.ZoneSizeMB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mb != 20 {
+		t.Fatalf("expected zone size of 20 MB; received %d", mb)
+	}
+
+	if cache.TTLSeconds() != 120 {
+		t.Fatalf("expected ttl of 120s; received %d", cache.TTLSeconds())
+	}
+}
+
+func TestCacheDefaults(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockCacheLabel: "true",
+		},
+	}
+
+	cache, err := Cache(cfg, "10m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cache.ZoneSize != "10m" {
+		t.Fatalf("expected zone size 10m; received %s", cache.ZoneSize)
+	}
+
+	if cache.TTL != DefaultCacheTTL {
+		t.Fatalf("expected ttl %s; received %s", DefaultCacheTTL, cache.TTL)
+	}
+}
+
+func TestCacheNoLabel(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{},
+	}
+
+	cache, err := Cache(cfg, "10m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cache != nil {
+		t.Fatalf("expected no cache policy; received %+v", cache)
+	}
+}
+
+func TestCacheInvalid(t *testing.T) {
+	for _, v := range []string{"zone", "zone:abc", "zone:10mb", "zone:1.5m", "zone:10 MB", "zone:-1m", "ttl:forever", "ttl:1ms", "size:10m"} {
+		cfg := types.Container{
+			Labels: map[string]string{
+				ext.InterlockCacheLabel: v,
+			},
+		}
+
+		if _, err := Cache(cfg, "10m"); err == nil {
+			t.Fatalf("expected error for cache policy %q", v)
+		}
+	}
+}
+
+func TestCacheZoneSize(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockCacheLabel: "zone:1G",
+		},
+	}
+
+	cache, err := Cache(cfg, "10m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cache.ZoneSize != "1g" {
+		t.Fatalf("expected zone size 1g; received %s", cache.ZoneSize)
+	}
+
+	mb, err := cache.ZoneSizeMB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mb != 1024 {
+		t.Fatalf("expected zone size of 1024 MB; received %d", mb)
+	}
+}
+
+func TestCacheInvalidDefaultZoneSize(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockCacheLabel: "true",
+		},
+	}
+
+	if _, err := Cache(cfg, "10 MB"); err == nil {
+		t.Fatal("expected error for default zone size")
+	}
+}
+
+func TestZoneSizeMBInvalid(t *testing.T) {
+	cache := &CachePolicy{ZoneSize: "1.5m"}
+	if _, err := cache.ZoneSizeMB(); err == nil {
+		t.Fatal("expected error for zone size")
+	}
+}
+
+func TestCacheBypassHeader(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockCacheBypassHeaderLabel: "X-No-Cache",
+		},
+	}
+
+	h, err := CacheBypassHeader(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h != "X-No-Cache" {
+		t.Fatalf("expected X-No-Cache; received %s", h)
+	}
+}
@@ -0,0 +1,274 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/ehazlett/interlock/ext"
+)
+
+const (
+	// ConflictPolicyFirst uses the setting of the first container of the
+	// host by name
+	ConflictPolicyFirst = "first"
+	// ConflictPolicyOldest uses the setting of the oldest container
+	ConflictPolicyOldest = "oldest"
+	// ConflictPolicyMajority uses the setting of most containers; ties are
+	// resolved as with ConflictPolicyFirst
+	ConflictPolicyMajority = "majority"
+	// ConflictPolicyStrict skips the hosts with conflicting settings
+	ConflictPolicyStrict = "strict"
+
+	DefaultConflictPolicy = ConflictPolicyFirst
+)
+
+// hostSettingLabels are the labels of the settings applied to the whole
+// host; they must be the same for all the containers of a host
+var hostSettingLabels = []string{
+	ext.InterlockSSLLabel,
+	ext.InterlockSSLOnlyLabel,
+	ext.InterlockSSLBackendLabel,
+	ext.InterlockSSLBackendTLSVerifyLabel,
+	ext.InterlockSSLCertLabel,
+	ext.InterlockSSLCertKeyLabel,
+	ext.InterlockHealthCheckLabel,
+	ext.InterlockBalanceAlgorithmLabel,
+	ext.InterlockBackendOptionLabel,
+	ext.InterlockIPHashLabel,
+	ext.InterlockResponseHeaderLabel,
+	ext.InterlockRequestHeaderLabel,
+	ext.InterlockCORSOriginLabel,
+	ext.InterlockCORSMethodsLabel,
+	ext.InterlockCORSHeadersLabel,
+	ext.InterlockHSTSMaxAgeLabel,
+	ext.InterlockHSTSIncludeSubdomainsLabel,
+	ext.InterlockHSTSPreloadLabel,
+	ext.InterlockRedirectLabel,
+	ext.InterlockRewriteLabel,
+	ext.InterlockStickyCookieLabel,
+	ext.InterlockHTTP2Label,
+	ext.InterlockBackendProtocolLabel,
+	ext.InterlockGzipLabel,
+	ext.InterlockCacheLabel,
+	ext.InterlockCacheBypassHeaderLabel,
+}
+
+// Conflict is a host setting the containers of a host disagree on
+type Conflict struct {
+	Host  string
+	Label string
+	// Values are the values of the containers in order (empty if unset)
+	Values []string
+	// Value is the value used; empty with ConflictPolicyStrict as the
+	// host is skipped
+	Value  string
+	Policy string
+}
+
+func (c Conflict) String() string {
+	if c.Policy == ConflictPolicyStrict {
+		return fmt.Sprintf("conflicting %s for %s: values=%q; the host is skipped", c.Label, c.Host, c.Values)
+	}
+
+	return fmt.Sprintf("conflicting %s for %s: values=%q using=%q policy=%s", c.Label, c.Host, c.Values, c.Value, c.Policy)
+}
+
+// MergeHostSettings sorts the containers by name and makes the host
+// settings of the containers of each host the same according to the policy
+// (first, oldest, majority or strict).  The labels of the containers using
+// another value are replaced by a copy with the settings used; with the
+// strict policy the containers of the conflicting hosts are removed.
+func MergeHostSettings(containers []types.Container, policy string) ([]types.Container, []Conflict) {
+	if policy == "" {
+		policy = DefaultConflictPolicy
+	}
+
+	sorted := append([]types.Container{}, containers...)
+	sort.Stable(containersByName(sorted))
+
+	hosts := []string{}
+	hostContainers := map[string][]int{}
+	for i, c := range sorted {
+		h := hostName(c)
+		if h == "" && ContextRoot(c) == "" {
+			continue
+		}
+
+		if _, ok := hostContainers[h]; !ok {
+			hosts = append(hosts, h)
+		}
+		hostContainers[h] = append(hostContainers[h], i)
+	}
+
+	conflicts := []Conflict{}
+	skipped := map[string]bool{}
+
+	for _, h := range hosts {
+		idx := hostContainers[h]
+		if len(idx) < 2 {
+			continue
+		}
+
+		for _, l := range hostSettingLabels {
+			values := make([]string, len(idx))
+			for i, n := range idx {
+				values[i] = hostSetting(sorted[n], l)
+			}
+
+			if !differ(values) {
+				continue
+			}
+
+			conflict := Conflict{
+				Host:   h,
+				Label:  l,
+				Values: values,
+				Policy: policy,
+			}
+
+			if policy == ConflictPolicyStrict {
+				conflicts = append(conflicts, conflict)
+				skipped[h] = true
+				continue
+			}
+
+			winner := idx[0]
+			switch policy {
+			case ConflictPolicyOldest:
+				for _, n := range idx {
+					if sorted[n].Created < sorted[winner].Created {
+						winner = n
+					}
+				}
+			case ConflictPolicyMajority:
+				winner = idx[majority(values)]
+			}
+
+			conflict.Value = hostSetting(sorted[winner], l)
+			conflicts = append(conflicts, conflict)
+
+			for _, n := range idx {
+				if hostSetting(sorted[n], l) != conflict.Value {
+					sorted[n].Labels = replaceSetting(sorted[n].Labels, sorted[winner].Labels, l)
+				}
+			}
+		}
+	}
+
+	if len(skipped) == 0 {
+		return sorted, conflicts
+	}
+
+	merged := []types.Container{}
+	for _, c := range sorted {
+		if !skipped[hostName(c)] {
+			merged = append(merged, c)
+		}
+	}
+
+	return merged, conflicts
+}
+
+// hostName returns the host of the container (hostname.domain)
+func hostName(c types.Container) string {
+	hostname := Hostname(c)
+	domain := Domain(c)
+	if hostname != domain && hostname != "" {
+		domain = fmt.Sprintf("%s.%s", hostname, domain)
+	}
+
+	return domain
+}
+
+// hostSetting returns the value of the setting; the values of the labels
+// which can be set several times are joined in label order
+func hostSetting(c types.Container, label string) string {
+	values := []string{}
+	for _, l := range settingLabels(c.Labels, label) {
+		values = append(values, c.Labels[l])
+	}
+
+	return strings.Join(values, ",")
+}
+
+// settingLabels returns the labels of the setting sorted by index
+func settingLabels(labels map[string]string, label string) []string {
+	keys := []string{}
+	for l := range labels {
+		if l == label || strings.HasPrefix(l, label+".") {
+			keys = append(keys, l)
+		}
+	}
+	sort.Sort(labelsByIndex(keys))
+
+	return keys
+}
+
+// replaceSetting returns a copy of the labels with the setting of the
+// source labels
+func replaceSetting(labels, source map[string]string, label string) map[string]string {
+	replaced := map[string]string{}
+	for k, v := range labels {
+		replaced[k] = v
+	}
+
+	for _, l := range settingLabels(labels, label) {
+		delete(replaced, l)
+	}
+
+	for _, l := range settingLabels(source, label) {
+		replaced[l] = source[l]
+	}
+
+	return replaced
+}
+
+func differ(values []string) bool {
+	for _, v := range values[1:] {
+		if v != values[0] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// majority returns the index of the first of the most common values
+func majority(values []string) int {
+	counts := map[string]int{}
+	for _, v := range values {
+		counts[v]++
+	}
+
+	best := 0
+	for i, v := range values {
+		if counts[v] > counts[values[best]] {
+			best = i
+		}
+	}
+
+	return best
+}
+
+type containersByName []types.Container
+
+func (c containersByName) Len() int      { return len(c) }
+func (c containersByName) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c containersByName) Less(i, j int) bool {
+	a, b := containerName(c[i]), containerName(c[j])
+	if a != b {
+		return a < b
+	}
+
+	return c[i].ID < c[j].ID
+}
+
+func containerName(c types.Container) string {
+	if len(c.Names) == 0 {
+		return ""
+	}
+
+	return c.Names[0]
+}
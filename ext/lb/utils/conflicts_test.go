@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/ehazlett/interlock/ext"
+)
+
+func replicas() []types.Container {
+	replica := func(name string, created int64, sslOnly string, options ...string) types.Container {
+		c := types.Container{
+			ID:      name + "0000000000",
+			Names:   []string{"/" + name},
+			Created: created,
+			Labels: map[string]string{
+				ext.InterlockHostnameLabel: "web",
+				ext.InterlockDomainLabel:   "example.com",
+				ext.InterlockSSLOnlyLabel:  sslOnly,
+			},
+		}
+		for i, o := range options {
+			c.Labels[ext.InterlockBackendOptionLabel+"."+strconv.Itoa(i)] = o
+		}
+
+		return c
+	}
+
+	return []types.Container{
+		replica("web.3", 100, "true", "option a"),
+		replica("web.1", 300, "false", "option b"),
+		replica("web.2", 200, "true", "option b"),
+		{
+			ID:     "api0000000000",
+			Names:  []string{"/api.1"},
+			Labels: map[string]string{ext.InterlockHostnameLabel: "api", ext.InterlockDomainLabel: "example.com"},
+		},
+	}
+}
+
+func settings(containers []types.Container) map[string][]string {
+	m := map[string][]string{}
+	for _, c := range containers {
+		m[containerName(c)] = []string{
+			hostSetting(c, ext.InterlockSSLOnlyLabel),
+			hostSetting(c, ext.InterlockBackendOptionLabel),
+		}
+	}
+
+	return m
+}
+
+func TestMergeHostSettings(t *testing.T) {
+	for policy, expected := range map[string][]string{
+		ConflictPolicyFirst:    {"false", "option b"},
+		ConflictPolicyOldest:   {"true", "option a"},
+		ConflictPolicyMajority: {"true", "option b"},
+	} {
+		containers := replicas()
+		merged, conflicts := MergeHostSettings(containers, policy)
+
+		names := []string{}
+		for _, c := range merged {
+			names = append(names, containerName(c))
+		}
+		if !reflect.DeepEqual(names, []string{"/api.1", "/web.1", "/web.2", "/web.3"}) {
+			t.Fatalf("%s: expected the containers sorted by name; received %v", policy, names)
+		}
+
+		for name, s := range settings(merged) {
+			if name != "/api.1" && !reflect.DeepEqual(s, expected) {
+				t.Fatalf("%s: expected %v for %s; received %v", policy, expected, name, s)
+			}
+		}
+
+		if len(conflicts) != 2 || conflicts[0].Host != "web.example.com" || conflicts[0].Label != ext.InterlockSSLOnlyLabel {
+			t.Fatalf("%s: unexpected conflicts: %+v", policy, conflicts)
+		}
+
+		// the labels of the containers are not changed
+		if containers[0].Labels[ext.InterlockSSLOnlyLabel] != "true" {
+			t.Fatalf("%s: the container labels were changed", policy)
+		}
+	}
+}
+
+func TestMergeHostSettingsStrict(t *testing.T) {
+	merged, conflicts := MergeHostSettings(replicas(), ConflictPolicyStrict)
+
+	if len(merged) != 1 || containerName(merged[0]) != "/api.1" {
+		t.Fatalf("expected the web containers to be skipped; received %+v", merged)
+	}
+
+	expected := `conflicting interlock.ssl_only for web.example.com: values=["false" "true" "true"]; the host is skipped`
+	if len(conflicts) != 2 || conflicts[0].String() != expected {
+		t.Fatalf("expected %q; received %v", expected, conflicts)
+	}
+}
+
+func TestMergeHostSettingsNoConflict(t *testing.T) {
+	containers := replicas()[1:3]
+	containers[1].Labels[ext.InterlockSSLOnlyLabel] = "false"
+
+	merged, conflicts := MergeHostSettings(containers, ConflictPolicyStrict)
+	if len(merged) != 2 || len(conflicts) != 0 {
+		t.Fatalf("expected no conflicts; received %v", conflicts)
+	}
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/ehazlett/interlock/ext"
+)
+
+const (
+	DefaultCORSMethods = "GET, POST, PUT, DELETE, OPTIONS"
+	DefaultCORSHeaders = "Authorization, Content-Type"
+)
+
+var headerNameRegex = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// Header is a single http header to be set by the proxy
+type Header struct {
+	Name  string
+	Value string
+}
+
+func ResponseHeaders(config types.Container) ([]Header, error) {
+	return parseHeaders(config, ext.InterlockResponseHeaderLabel)
+}
+
+func RequestHeaders(config types.Container) ([]Header, error) {
+	return parseHeaders(config, ext.InterlockRequestHeaderLabel)
+}
+
+// CORSHeaders returns the response headers for the cors policy of the
+// container; no headers are returned if no origin is specified
+func CORSHeaders(config types.Container) ([]Header, error) {
+	origin, ok := config.Labels[ext.InterlockCORSOriginLabel]
+	if !ok || origin == "" {
+		return nil, nil
+	}
+
+	methods := DefaultCORSMethods
+	if v, ok := config.Labels[ext.InterlockCORSMethodsLabel]; ok && v != "" {
+		methods = v
+	}
+
+	allowHeaders := DefaultCORSHeaders
+	if v, ok := config.Labels[ext.InterlockCORSHeadersLabel]; ok && v != "" {
+		allowHeaders = v
+	}
+
+	headers := []Header{
+		{Name: "Access-Control-Allow-Origin", Value: origin},
+		{Name: "Access-Control-Allow-Methods", Value: methods},
+		{Name: "Access-Control-Allow-Headers", Value: allowHeaders},
+	}
+
+	// responses differ per origin so caches must key on it
+	if origin != "*" {
+		headers = append(headers, Header{Name: "Vary", Value: "Origin"})
+	}
+
+	for _, h := range headers {
+		if err := validateHeaderValue(h.Value); err != nil {
+			return nil, fmt.Errorf("invalid cors policy: %s", err)
+		}
+	}
+
+	return headers, nil
+}
+
+// parseHeaders parses labels like interlock.response_header.1=X-Foo: bar
+func parseHeaders(config types.Container, label string) ([]Header, error) {
+	headers := []Header{}
+	for _, k := range sortedLabels(config, label) {
+		v := config.Labels[k]
+		parts := strings.SplitN(v, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid header for %s: expected name:value; received %q", k, v)
+		}
+
+		name := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+
+		if !headerNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("invalid header name for %s: %q", k, name)
+		}
+
+		if err := validateHeaderValue(value); err != nil {
+			return nil, fmt.Errorf("invalid header value for %s: %s", k, err)
+		}
+
+		headers = append(headers, Header{
+			Name:  name,
+			Value: value,
+		})
+	}
+
+	return headers, nil
+}
+
+// validateHeaderValue ensures the value can be safely quoted in the
+// generated proxy config; backslashes would escape the closing quote and
+// nginx and haproxy expand variables ($var) and fetches (%[...]) in values
+func validateHeaderValue(v string) error {
+	if strings.ContainsAny(v, "\"\\$%\r\n") {
+		return fmt.Errorf("value cannot contain quotes, backslashes, $, %% or newlines: %q", v)
+	}
+
+	return nil
+}
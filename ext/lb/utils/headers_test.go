@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/ehazlett/interlock/ext"
+)
+
+func TestResponseHeaders(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockResponseHeaderLabel + ".0": "X-Frame-Options: DENY",
+			ext.InterlockResponseHeaderLabel + ".1": "X-Foo:bar:baz",
+		},
+	}
+
+	headers, err := ResponseHeaders(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(headers) != 2 {
+		t.Fatalf("expected %d headers; received %d", len(cfg.Labels), len(headers))
+	}
+
+	if headers[0].Name != "X-Frame-Options" || headers[0].Value != "DENY" {
+		t.Fatalf("unexpected header: %+v", headers[0])
+	}
+
+	if headers[1].Name != "X-Foo" || headers[1].Value != "bar:baz" {
+		t.Fatalf("unexpected header: %+v", headers[1])
+	}
+}
+
+func TestResponseHeadersNoLabels(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{},
+	}
+
+	headers, err := ResponseHeaders(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(headers) != 0 {
+		t.Fatalf("expected no headers; received %v", headers)
+	}
+}
+
+func TestRequestHeaders(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockRequestHeaderLabel + ".0": "X-Env: prod",
+		},
+	}
+
+	headers, err := RequestHeaders(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(headers) != 1 {
+		t.Fatalf("expected 1 header; received %d", len(headers))
+	}
+
+	if headers[0].Name != "X-Env" || headers[0].Value != "prod" {
+		t.Fatalf("unexpected header: %+v", headers[0])
+	}
+}
+
+func TestRequestHeadersOrder(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockRequestHeaderLabel + ".10": "X-Ten: 10",
+			ext.InterlockRequestHeaderLabel + ".2":  "X-Two: 2",
+			ext.InterlockRequestHeaderLabel + ".1":  "X-One: 1",
+		},
+	}
+
+	headers, err := RequestHeaders(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(headers) != 3 {
+		t.Fatalf("expected 3 headers; received %d", len(headers))
+	}
+
+	for i, name := range []string{"X-One", "X-Two", "X-Ten"} {
+		if headers[i].Name != name {
+			t.Fatalf("expected header %d to be %s; received %s", i, name, headers[i].Name)
+		}
+	}
+}
+
+func TestRequestHeadersInvalid(t *testing.T) {
+	for _, v := range []string{"X-Env", "X Env: prod", "X-Env: \"prod\"", "X-Env: prod\\", "X-Env: $host", "X-Env: %[src]"} {
+		cfg := types.Container{
+			Labels: map[string]string{
+				ext.InterlockRequestHeaderLabel + ".0": v,
+			},
+		}
+
+		if _, err := RequestHeaders(cfg); err == nil {
+			t.Fatalf("expected error for header %q", v)
+		}
+	}
+}
+
+func TestCORSHeaders(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockCORSOriginLabel:  "https://example.com",
+			ext.InterlockCORSMethodsLabel: "GET",
+		},
+	}
+
+	headers, err := CORSHeaders(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"Access-Control-Allow-Origin":  "https://example.com",
+		"Access-Control-Allow-Methods": "GET",
+		"Access-Control-Allow-Headers": DefaultCORSHeaders,
+		"Vary":                         "Origin",
+	}
+
+	if len(headers) != len(expected) {
+		t.Fatalf("expected %d headers; received %d", len(expected), len(headers))
+	}
+
+	for _, h := range headers {
+		if expected[h.Name] != h.Value {
+			t.Fatalf("expected %s for %s; received %s", expected[h.Name], h.Name, h.Value)
+		}
+	}
+}
+
+func TestCORSHeadersWildcard(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockCORSOriginLabel: "*",
+		},
+	}
+
+	headers, err := CORSHeaders(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, h := range headers {
+		if h.Name == "Vary" {
+			t.Fatal("expected no vary header for wildcard origin")
+		}
+	}
+}
+
+func TestCORSHeadersNoLabel(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{},
+	}
+
+	headers, err := CORSHeaders(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(headers) != 0 {
+		t.Fatalf("expected no headers; received %v", headers)
+	}
+}
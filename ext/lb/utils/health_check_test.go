@@ -51,7 +51,7 @@ func TestHealthCheckInterval(t *testing.T) {
 	}
 
 	if i != testInterval {
-		t.Fatalf("expected %s; received %s", testInterval, i)
+		t.Fatalf("expected %d; received %d", testInterval, i)
 	}
 }
 
@@ -66,6 +66,6 @@ func TestHealthCheckIntervalNoLabel(t *testing.T) {
 	}
 
 	if i != DefaultHealthCheckInterval {
-		t.Fatalf("expected %s; received %s", DefaultHealthCheckInterval, i)
+		t.Fatalf("expected %d; received %d", DefaultHealthCheckInterval, i)
 	}
 }
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/docker/docker/api/types"
+	"github.com/ehazlett/interlock/ext"
+)
+
+const (
+	DefaultHSTSMaxAge = 16000000
+)
+
+// HSTS returns the Strict-Transport-Security header value for the container.
+// defaultMaxAge is used unless overridden by the container labels; nil uses
+// DefaultHSTSMaxAge.  A max age of 0 revokes the policy.
+func HSTS(config types.Container, defaultMaxAge *int) (string, error) {
+	maxAge := DefaultHSTSMaxAge
+	if defaultMaxAge != nil {
+		maxAge = *defaultMaxAge
+	}
+
+	if v, ok := config.Labels[ext.InterlockHSTSMaxAgeLabel]; ok && v != "" {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return "", err
+		}
+		if i < 0 {
+			return "", fmt.Errorf("hsts max age cannot be negative: %d", i)
+		}
+		maxAge = i
+	}
+
+	includeSubdomains, err := boolLabel(config, ext.InterlockHSTSIncludeSubdomainsLabel, true)
+	if err != nil {
+		return "", err
+	}
+
+	preload, err := boolLabel(config, ext.InterlockHSTSPreloadLabel, true)
+	if err != nil {
+		return "", err
+	}
+
+	hsts := fmt.Sprintf("max-age=%d", maxAge)
+
+	if includeSubdomains {
+		hsts += "; includeSubDomains"
+	}
+
+	if preload {
+		hsts += "; preload"
+	}
+
+	return hsts, nil
+}
+
+func boolLabel(config types.Container, label string, defaultValue bool) (bool, error) {
+	v, ok := config.Labels[label]
+	if !ok || v == "" {
+		return defaultValue, nil
+	}
+
+	return strconv.ParseBool(v)
+}
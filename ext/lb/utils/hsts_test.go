@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/ehazlett/interlock/ext"
+)
+
+func TestHSTSDefault(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{},
+	}
+
+	hsts, err := HSTS(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "max-age=16000000; includeSubDomains; preload"
+	if hsts != expected {
+		t.Fatalf("expected %s; received %s", expected, hsts)
+	}
+}
+
+func TestHSTSLabels(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockHSTSMaxAgeLabel:            "3600",
+			ext.InterlockHSTSIncludeSubdomainsLabel: "false",
+			ext.InterlockHSTSPreloadLabel:           "false",
+		},
+	}
+
+	maxAge := 31536000
+	hsts, err := HSTS(cfg, &maxAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "max-age=3600"
+	if hsts != expected {
+		t.Fatalf("expected %s; received %s", expected, hsts)
+	}
+}
+
+func TestHSTSRevoke(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockHSTSIncludeSubdomainsLabel: "false",
+			ext.InterlockHSTSPreloadLabel:           "false",
+		},
+	}
+
+	maxAge := 0
+	hsts, err := HSTS(cfg, &maxAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "max-age=0"
+	if hsts != expected {
+		t.Fatalf("expected %s; received %s", expected, hsts)
+	}
+}
+
+func TestHSTSInvalidMaxAge(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockHSTSMaxAgeLabel: "forever",
+		},
+	}
+
+	if _, err := HSTS(cfg, nil); err == nil {
+		t.Fatal("expected error for invalid max age")
+	}
+}
@@ -0,0 +1,410 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/ehazlett/interlock/ext"
+)
+
+// Severity is the severity of a diagnostic
+type Severity string
+
+const (
+	// SeverityError is a problem which skips the container or a label
+	SeverityError Severity = "error"
+	// SeverityWarning is a problem which may not be intended
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a problem found in the interlock labels of a container
+type Diagnostic struct {
+	Label    string   `json:"label,omitempty"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	if d.Label == "" {
+		return fmt.Sprintf("%s: %s", d.Severity, d.Message)
+	}
+
+	return fmt.Sprintf("%s: %s: %s", d.Severity, d.Label, d.Message)
+}
+
+// Report has the diagnostics of a container
+type Report struct {
+	Container   string       `json:"container"`
+	Name        string       `json:"name"`
+	Extension   string       `json:"extension,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// Errors returns the number of error diagnostics
+func (r *Report) Errors() int {
+	n := 0
+	for _, d := range r.Diagnostics {
+		if d.Severity == SeverityError {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (r *Report) errorf(label, format string, args ...interface{}) {
+	r.add(SeverityError, label, format, args...)
+}
+
+func (r *Report) warnf(label, format string, args ...interface{}) {
+	r.add(SeverityWarning, label, format, args...)
+}
+
+func (r *Report) add(severity Severity, label, format string, args ...interface{}) {
+	r.Diagnostics = append(r.Diagnostics, Diagnostic{
+		Label:    label,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// labelChecks are the labels checked by parsing them; a parse error skips
+// the container
+var labelChecks = []struct {
+	label string
+	parse func(types.Container) error
+}{
+	{ext.InterlockHealthCheckIntervalLabel, func(c types.Container) error {
+		_, err := HealthCheckInterval(c)
+		return err
+	}},
+	{ext.InterlockRequestHeaderLabel, func(c types.Container) error {
+		_, err := RequestHeaders(c)
+		return err
+	}},
+	{ext.InterlockResponseHeaderLabel, func(c types.Container) error {
+		_, err := ResponseHeaders(c)
+		return err
+	}},
+	{ext.InterlockCORSOriginLabel, func(c types.Container) error {
+		_, err := CORSHeaders(c)
+		return err
+	}},
+	{ext.InterlockHSTSMaxAgeLabel, func(c types.Container) error {
+		_, err := HSTS(c, nil)
+		return err
+	}},
+	{ext.InterlockRedirectLabel, func(c types.Container) error {
+		_, err := Redirects(c)
+		return err
+	}},
+	{ext.InterlockRewriteLabel, func(c types.Container) error {
+		_, err := Rewrites(c)
+		return err
+	}},
+	{ext.InterlockStickyCookieLabel, func(c types.Container) error {
+		_, err := StickyCookie(c)
+		return err
+	}},
+	{ext.InterlockHTTP2Label, func(c types.Container) error {
+		_, err := HTTP2(c)
+		return err
+	}},
+	{ext.InterlockBackendProtocolLabel, func(c types.Container) error {
+		_, err := BackendProtocol(c)
+		return err
+	}},
+	{ext.InterlockGzipLabel, func(c types.Container) error {
+		_, err := Gzip(c)
+		return err
+	}},
+	{ext.InterlockCacheLabel, func(c types.Container) error {
+		_, err := Cache(c, "")
+		return err
+	}},
+	{ext.InterlockCacheBypassHeaderLabel, func(c types.Container) error {
+		_, err := CacheBypassHeader(c)
+		return err
+	}},
+}
+
+// extensionLabels are the labels used by a single extension; the other
+// extension ignores them
+var extensionLabels = map[string]string{
+	ext.InterlockSSLLabel:                 "nginx",
+	ext.InterlockSSLCertLabel:             "nginx",
+	ext.InterlockSSLCertKeyLabel:          "nginx",
+	ext.InterlockWebsocketEndpointLabel:   "nginx",
+	ext.InterlockHealthCheckLabel:         "haproxy",
+	ext.InterlockHealthCheckIntervalLabel: "haproxy",
+	ext.InterlockBalanceAlgorithmLabel:    "haproxy",
+	ext.InterlockIPHashLabel:              "nginx",
+}
+
+// multiLabels are the labels which can be set several times with a suffix
+// (i.e. interlock.alias_domain.0)
+var multiLabels = []string{
+	ext.InterlockAliasDomainLabel,
+	ext.InterlockBackendOptionLabel,
+	ext.InterlockWebsocketEndpointLabel,
+	ext.InterlockRedirectLabel,
+	ext.InterlockRewriteLabel,
+	ext.InterlockRequestHeaderLabel,
+	ext.InterlockResponseHeaderLabel,
+}
+
+var knownLabels = []string{
+	ext.InterlockAppLabel,
+	ext.InterlockExtNameLabel,
+	ext.InterlockHostnameLabel,
+	ext.InterlockNetworkLabel,
+	ext.InterlockDomainLabel,
+	ext.InterlockSSLLabel,
+	ext.InterlockSSLOnlyLabel,
+	ext.InterlockSSLBackendLabel,
+	ext.InterlockSSLBackendTLSVerifyLabel,
+	ext.InterlockSSLCertLabel,
+	ext.InterlockSSLCertKeyLabel,
+	ext.InterlockPortLabel,
+	ext.InterlockWebsocketEndpointLabel,
+	ext.InterlockAliasDomainLabel,
+	ext.InterlockHealthCheckLabel,
+	ext.InterlockHealthCheckIntervalLabel,
+	ext.InterlockBalanceAlgorithmLabel,
+	ext.InterlockBackendOptionLabel,
+	ext.InterlockIPHashLabel,
+	ext.InterlockContextRootLabel,
+	ext.InterlockContextRootRewriteLabel,
+	ext.InterlockResponseHeaderLabel,
+	ext.InterlockRequestHeaderLabel,
+	ext.InterlockCORSOriginLabel,
+	ext.InterlockCORSMethodsLabel,
+	ext.InterlockCORSHeadersLabel,
+	ext.InterlockHSTSMaxAgeLabel,
+	ext.InterlockHSTSIncludeSubdomainsLabel,
+	ext.InterlockHSTSPreloadLabel,
+	ext.InterlockRedirectLabel,
+	ext.InterlockRewriteLabel,
+	ext.InterlockStickyCookieLabel,
+	ext.InterlockHTTP2Label,
+	ext.InterlockBackendProtocolLabel,
+	ext.InterlockGzipLabel,
+	ext.InterlockCacheLabel,
+	ext.InterlockCacheBypassHeaderLabel,
+}
+
+// Lint checks the interlock labels of the container for the extension
+// (i.e. nginx)
+func Lint(c types.Container, extension string) *Report {
+	r := &Report{
+		Container:   c.ID,
+		Extension:   extension,
+		Diagnostics: []Diagnostic{},
+	}
+	if len(c.Names) > 0 {
+		r.Name = strings.TrimPrefix(c.Names[0], "/")
+	}
+
+	for _, l := range sortedKeys(c.Labels) {
+		if !strings.HasPrefix(l, ext.DefaultLabelPrefix) || isKnownLabel(l) {
+			continue
+		}
+
+		if s := suggestLabel(l); s != "" {
+			r.warnf(l, "unknown label; did you mean %s?", s)
+		} else {
+			r.warnf(l, "unknown label")
+		}
+	}
+
+	for _, l := range sortedKeys(c.Labels) {
+		if e, ok := extensionLabels[baseLabel(l)]; ok && e != extension {
+			r.warnf(l, "not supported by %s; the label is ignored", extension)
+		}
+	}
+
+	for _, check := range labelChecks {
+		if !usesLabel(extension, check.label) {
+			continue
+		}
+
+		if err := check.parse(c); err != nil {
+			r.errorf(check.label, "%s; the container is skipped", err)
+		}
+	}
+
+	if _, ok := c.Labels[ext.InterlockHealthCheckIntervalLabel]; ok && HealthCheck(c) == "" && usesLabel(extension, ext.InterlockHealthCheckIntervalLabel) {
+		r.warnf(ext.InterlockHealthCheckIntervalLabel, "ignored without %s", ext.InterlockHealthCheckLabel)
+	}
+
+	network, overlay := OverlayEnabled(c)
+	if overlay && c.NetworkSettings != nil {
+		if _, ok := c.NetworkSettings.Networks[network]; !ok {
+			r.errorf(ext.InterlockNetworkLabel, "container is not connected to network %s; the container is skipped", network)
+		}
+	}
+
+	if !overlay && len(c.Ports) == 0 {
+		r.errorf("", "no ports exposed; the container is skipped")
+	}
+
+	port, ok, err := portLabel(c)
+	switch {
+	case err != nil:
+		r.errorf(ext.InterlockPortLabel, "%s; the container is skipped", err)
+	case ok && !overlay && !hasPrivatePort(c, port):
+		r.errorf(ext.InterlockPortLabel, "port %d is not exposed by the container; the first port is used", port)
+	}
+
+	return r
+}
+
+// usesLabel returns whether the extension reads the label
+func usesLabel(extension, label string) bool {
+	e, ok := extensionLabels[label]
+	return !ok || e == extension
+}
+
+// LintContainers checks the labels of each container and the labels which
+// conflict between the containers of a domain
+func LintContainers(containers []types.Container, extension string) []*Report {
+	reports := []*Report{}
+
+	type domainLabel struct {
+		domain string
+		label  string
+	}
+	values := map[domainLabel]string{}
+
+	for _, c := range containers {
+		r := Lint(c, extension)
+		reports = append(reports, r)
+
+		domain := containerDomain(c)
+		for _, l := range []string{ext.InterlockHealthCheckLabel, ext.InterlockBalanceAlgorithmLabel, ext.InterlockStickyCookieLabel} {
+			v, ok := c.Labels[l]
+			if !ok || !usesLabel(extension, l) {
+				continue
+			}
+
+			k := domainLabel{domain, l}
+			prev, ok := values[k]
+			if !ok {
+				values[k] = v
+				continue
+			}
+
+			if prev != v {
+				r.warnf(l, "conflicting value %q for %s; %q is set by another container", v, domain, prev)
+			}
+		}
+	}
+
+	return reports
+}
+
+// containerDomain returns the domain of the container as used by the proxy
+// configs
+func containerDomain(c types.Container) string {
+	domain := hostName(c)
+	if root := ContextRoot(c); root != "" {
+		domain += root
+	}
+
+	return domain
+}
+
+func hasPrivatePort(c types.Container, port int) bool {
+	for _, p := range c.Ports {
+		if int(p.PrivatePort) == port {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isKnownLabel(l string) bool {
+	for _, k := range knownLabels {
+		if l == k {
+			return true
+		}
+	}
+
+	for _, k := range multiLabels {
+		if strings.HasPrefix(l, k+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// baseLabel returns the label without the suffix of the labels set
+// several times (i.e. interlock.alias_domain.0)
+func baseLabel(l string) string {
+	if i := strings.LastIndex(l, "."); i > len(ext.DefaultLabelPrefix) {
+		if _, err := strconv.Atoi(l[i+1:]); err == nil {
+			return l[:i]
+		}
+	}
+
+	return l
+}
+
+// suggestLabel returns the known label closest to the label if any
+func suggestLabel(l string) string {
+	name := baseLabel(l)
+
+	best := ""
+	bestDistance := 3
+	for _, k := range knownLabels {
+		if d := levenshtein(name, k); d < bestDistance {
+			best = k
+			bestDistance = d
+		}
+	}
+
+	return best
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j] + 1
+			if d := cur[j-1] + 1; d < cur[j] {
+				cur[j] = d
+			}
+			if d := prev[j-1] + cost; d < cur[j] {
+				cur[j] = d
+			}
+		}
+		prev = cur
+	}
+
+	return prev[len(b)]
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
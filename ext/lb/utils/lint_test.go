@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/ehazlett/interlock/ext"
+)
+
+func diagnostics(r *Report) map[string]Diagnostic {
+	m := map[string]Diagnostic{}
+	for _, d := range r.Diagnostics {
+		m[d.Label] = d
+	}
+
+	return m
+}
+
+func TestLint(t *testing.T) {
+	c := types.Container{
+		ID:    "0123456789abcdef",
+		Names: []string{"/web"},
+		Labels: map[string]string{
+			ext.InterlockHostnameLabel:            "web",
+			ext.InterlockHealthCheckLabel:         "/health",
+			ext.InterlockHealthCheckIntervalLabel: "5s",
+			ext.InterlockPortLabel:                "8080",
+			ext.InterlockAliasDomainLabel + ".0":  "www.example.com",
+			"interlock.hostnme":                   "web",
+			"interlock.foo":                       "bar",
+			"com.example.team":                    "web",
+		},
+		Ports: []types.Port{{PrivatePort: 80, PublicPort: 32768}},
+	}
+
+	r := Lint(c, "haproxy")
+	if r.Name != "web" || r.Extension != "haproxy" || r.Errors() != 2 {
+		t.Fatalf("expected 2 errors; received %+v", r.Diagnostics)
+	}
+
+	d := diagnostics(r)
+	expected := map[string]string{
+		ext.InterlockHealthCheckIntervalLabel: `error: interlock.health_check_interval: strconv.Atoi: parsing "5s": invalid syntax; the container is skipped`,
+		ext.InterlockPortLabel:                "error: interlock.port: port 8080 is not exposed by the container; the first port is used",
+		"interlock.hostnme":                   "warning: interlock.hostnme: unknown label; did you mean interlock.hostname?",
+		"interlock.foo":                       "warning: interlock.foo: unknown label",
+	}
+
+	for label, msg := range expected {
+		if d[label].String() != msg {
+			t.Fatalf("expected %q; received %q", msg, d[label].String())
+		}
+	}
+}
+
+func TestLintNetwork(t *testing.T) {
+	c := types.Container{
+		ID: "0123456789abcdef",
+		Labels: map[string]string{
+			ext.InterlockHostnameLabel: "web",
+			ext.InterlockNetworkLabel:  "backend",
+			ext.InterlockPortLabel:     "http",
+		},
+		NetworkSettings: &types.SummaryNetworkSettings{
+			Networks: map[string]*network.EndpointSettings{"bridge": {}},
+		},
+	}
+
+	d := diagnostics(Lint(c, "haproxy"))
+	if d[ext.InterlockNetworkLabel].Severity != SeverityError || d[ext.InterlockPortLabel].Severity != SeverityError {
+		t.Fatalf("expected network and port errors; received %v", d)
+	}
+
+	// no ports are required on an overlay network
+	if _, ok := d[""]; ok {
+		t.Fatalf("unexpected ports error: %v", d[""])
+	}
+
+	c.NetworkSettings.Networks["backend"] = &network.EndpointSettings{}
+	c.Labels[ext.InterlockPortLabel] = "8080"
+	c.Labels[ext.InterlockHealthCheckIntervalLabel] = "5000"
+	d = diagnostics(Lint(c, "haproxy"))
+	if len(d) != 1 || d[ext.InterlockHealthCheckIntervalLabel].Severity != SeverityWarning {
+		t.Fatalf("expected a health check interval warning; received %v", d)
+	}
+}
+
+func TestLintInvalidPort(t *testing.T) {
+	for _, v := range []string{"0", "70000"} {
+		c := types.Container{
+			ID: "0123456789abcdef",
+			Labels: map[string]string{
+				ext.InterlockHostnameLabel: "web",
+				ext.InterlockPortLabel:     v,
+			},
+			Ports: []types.Port{{PrivatePort: 80, PublicPort: 32768}},
+		}
+
+		d := diagnostics(Lint(c, "nginx"))
+		expected := `error: interlock.port: invalid port "` + v + `"; the container is skipped`
+		if d[ext.InterlockPortLabel].String() != expected {
+			t.Fatalf("expected %q; received %q", expected, d[ext.InterlockPortLabel].String())
+		}
+
+		// the generators skip the container on the same error
+		if _, err := BackendAddress(c, ""); err == nil {
+			t.Fatalf("expected backend address error for port %q", v)
+		}
+	}
+}
+
+func TestLintUnsupportedLabels(t *testing.T) {
+	c := types.Container{
+		ID: "0123456789abcdef",
+		Labels: map[string]string{
+			ext.InterlockHostnameLabel:            "web",
+			ext.InterlockHealthCheckIntervalLabel: "5s",
+			ext.InterlockIPHashLabel:              "true",
+		},
+		Ports: []types.Port{{PrivatePort: 80, PublicPort: 32768}},
+	}
+
+	r := Lint(c, "nginx")
+	if r.Errors() != 0 {
+		t.Fatalf("expected no errors; received %+v", r.Diagnostics)
+	}
+
+	d := diagnostics(r)
+	expected := "warning: interlock.health_check_interval: not supported by nginx; the label is ignored"
+	if len(d) != 1 || d[ext.InterlockHealthCheckIntervalLabel].String() != expected {
+		t.Fatalf("expected %q; received %v", expected, d)
+	}
+
+	// haproxy skips the container on the health check interval error
+	r = Lint(c, "haproxy")
+	d = diagnostics(r)
+	expected = "warning: interlock.ip_hash: not supported by haproxy; the label is ignored"
+	if r.Errors() != 1 || d[ext.InterlockIPHashLabel].String() != expected {
+		t.Fatalf("expected ip hash warning and health check interval error; received %+v", r.Diagnostics)
+	}
+}
+
+func TestLintContainers(t *testing.T) {
+	container := func(id, check string) types.Container {
+		return types.Container{
+			ID: id,
+			Labels: map[string]string{
+				ext.InterlockHostnameLabel:    "web",
+				ext.InterlockDomainLabel:      "example.com",
+				ext.InterlockHealthCheckLabel: check,
+			},
+			Ports: []types.Port{{PrivatePort: 80, PublicPort: 32768}},
+		}
+	}
+
+	reports := LintContainers([]types.Container{
+		container("web0", "/health"),
+		container("web1", "/health"),
+		container("web2", "/ping"),
+	}, "haproxy")
+
+	if len(reports) != 3 || len(reports[0].Diagnostics) != 0 || len(reports[1].Diagnostics) != 0 {
+		t.Fatalf("unexpected reports: %+v", reports)
+	}
+
+	d := reports[2].Diagnostics
+	expected := `warning: interlock.health_check: conflicting value "/ping" for web.example.com; "/health" is set by another container`
+	if len(d) != 1 || d[0].String() != expected {
+		t.Fatalf("expected %q; received %v", expected, d)
+	}
+}
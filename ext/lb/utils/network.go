@@ -45,8 +45,12 @@ func BackendOverlayAddress(network types.NetworkResource, cnt types.Container) (
 	}
 
 	// check for custom port
-	if v, ok := cnt.Labels[ext.InterlockPortLabel]; ok {
-		portDef.HostPort = v
+	port, ok, err := portLabel(cnt)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		portDef.HostPort = strconv.Itoa(port)
 	}
 
 	if portDef.HostPort == "" {
@@ -75,11 +79,11 @@ func BackendAddress(cnt types.Container, backendOverrideAddress string) (string,
 	}
 
 	// check for custom port
-	if v, ok := cnt.Labels[ext.InterlockPortLabel]; ok {
-		interlockPort, err := strconv.Atoi(v)
-		if err != nil {
-			return "", err
-		}
+	interlockPort, ok, err := portLabel(cnt)
+	if err != nil {
+		return "", err
+	}
+	if ok {
 		for _, port := range ports {
 			if port.PrivatePort == uint16(interlockPort) {
 				portDef.HostPort = fmt.Sprintf("%d", port.PublicPort)
@@ -91,3 +95,19 @@ func BackendAddress(cnt types.Container, backendOverrideAddress string) (string,
 	addr = fmt.Sprintf("%s:%s", portDef.HostIP, portDef.HostPort)
 	return addr, nil
 }
+
+// portLabel returns the port of the interlock.port label; ok is false if
+// the label is not set
+func portLabel(cnt types.Container) (int, bool, error) {
+	v, ok := cnt.Labels[ext.InterlockPortLabel]
+	if !ok {
+		return 0, false, nil
+	}
+
+	port, err := strconv.Atoi(v)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, true, fmt.Errorf("invalid port %q", v)
+	}
+
+	return port, true, nil
+}
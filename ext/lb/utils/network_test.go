@@ -116,3 +116,25 @@ func TestBackendAddressOverride(t *testing.T) {
 		t.Fatalf("expected %s; received %s", expected, addr)
 	}
 }
+
+func TestBackendAddressInvalidPort(t *testing.T) {
+	for _, v := range []string{"http", "0", "70000"} {
+		cnt := types.Container{
+			ID: "a5bb3cae92fb660eba775831d7fec0227d980ce04c138b0b8e5d69885a82d75f",
+			Labels: map[string]string{
+				ext.InterlockPortLabel: v,
+			},
+			Ports: []types.Port{
+				{
+					IP:          "0.0.0.0",
+					PrivatePort: 80,
+					PublicPort:  32768,
+				},
+			},
+		}
+
+		if _, err := BackendAddress(cnt, ""); err == nil {
+			t.Fatalf("expected error for port %q", v)
+		}
+	}
+}
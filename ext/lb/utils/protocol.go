@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+	"github.com/ehazlett/interlock/ext"
+)
+
+const (
+	DefaultBackendProtocol = "http"
+	BackendProtocolGRPC    = "grpc"
+	BackendProtocolH2C     = "h2c"
+)
+
+func HTTP2(config types.Container) (bool, error) {
+	return boolLabel(config, ext.InterlockHTTP2Label, false)
+}
+
+func BackendProtocol(config types.Container) (string, error) {
+	v, ok := config.Labels[ext.InterlockBackendProtocolLabel]
+	if !ok || v == "" {
+		return DefaultBackendProtocol, nil
+	}
+
+	switch v {
+	case DefaultBackendProtocol, BackendProtocolGRPC, BackendProtocolH2C:
+		return v, nil
+	default:
+		return "", fmt.Errorf("unsupported backend protocol: %s", v)
+	}
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/ehazlett/interlock/ext"
+)
+
+func TestHTTP2(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockHTTP2Label: "true",
+		},
+	}
+
+	h2, err := HTTP2(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !h2 {
+		t.Fatal("expected http2")
+	}
+}
+
+func TestHTTP2NoLabel(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{},
+	}
+
+	h2, err := HTTP2(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h2 {
+		t.Fatal("expected no http2")
+	}
+}
+
+func TestBackendProtocol(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockBackendProtocolLabel: BackendProtocolGRPC,
+		},
+	}
+
+	proto, err := BackendProtocol(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if proto != BackendProtocolGRPC {
+		t.Fatalf("expected %s; received %s", BackendProtocolGRPC, proto)
+	}
+}
+
+func TestBackendProtocolDefault(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{},
+	}
+
+	proto, err := BackendProtocol(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if proto != DefaultBackendProtocol {
+		t.Fatalf("expected %s; received %s", DefaultBackendProtocol, proto)
+	}
+}
+
+func TestBackendProtocolInvalid(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockBackendProtocolLabel: "spdy",
+		},
+	}
+
+	if _, err := BackendProtocol(cfg); err == nil {
+		t.Fatal("expected error for unsupported protocol")
+	}
+}
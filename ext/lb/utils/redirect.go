@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/ehazlett/interlock/ext"
+)
+
+const (
+	DefaultRedirectCode = 301
+)
+
+var validRedirectCodes = map[int]bool{
+	301: true,
+	302: true,
+	303: true,
+	307: true,
+	308: true,
+}
+
+// Redirect redirects requests for a host or a path prefix
+type Redirect struct {
+	From string
+	To   string
+	Code int
+}
+
+// IsHost returns true if the redirect matches on the host instead of the path
+func (r Redirect) IsHost() bool {
+	return !strings.HasPrefix(r.From, "/")
+}
+
+// HasScheme returns true if the redirect target is an absolute url
+func (r Redirect) HasScheme() bool {
+	return strings.Contains(r.To, "://")
+}
+
+// FromRegex returns an anchored regex matching the path prefix
+func (r Redirect) FromRegex() string {
+	return "^" + regexp.QuoteMeta(r.From)
+}
+
+// Rewrite rewrites the request path before sending to the upstream
+type Rewrite struct {
+	Regex       string
+	Replacement string
+}
+
+// Redirects parses labels like interlock.redirect.1=from,to,code
+func Redirects(config types.Container) ([]Redirect, error) {
+	redirects := []Redirect{}
+
+	for _, k := range sortedLabels(config, ext.InterlockRedirectLabel) {
+		v := config.Labels[k]
+		parts := strings.Split(v, ",")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("invalid redirect for %s: expected from,to[,code]; received %q", k, v)
+		}
+
+		from := strings.TrimSpace(parts[0])
+		to := strings.TrimSpace(parts[1])
+		code := DefaultRedirectCode
+
+		if len(parts) == 3 {
+			c, err := strconv.Atoi(strings.TrimSpace(parts[2]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid redirect code for %s: %s", k, err)
+			}
+			code = c
+		}
+
+		if !validRedirectCodes[code] {
+			return nil, fmt.Errorf("invalid redirect code for %s: %d", k, code)
+		}
+
+		if from == "" || to == "" {
+			return nil, fmt.Errorf("invalid redirect for %s: from and to must be specified", k)
+		}
+
+		for _, s := range []string{from, to} {
+			if strings.ContainsAny(s, " \t\r\n\"';{}\\") {
+				return nil, fmt.Errorf("invalid redirect for %s: %q contains invalid characters", k, s)
+			}
+		}
+
+		r := Redirect{
+			From: from,
+			To:   to,
+			Code: code,
+		}
+
+		if r.IsHost() && strings.Contains(from, "/") {
+			return nil, fmt.Errorf("invalid redirect for %s: %q must be a hostname or a path", k, from)
+		}
+
+		redirects = append(redirects, r)
+	}
+
+	return redirects, nil
+}
+
+// Rewrites parses labels like interlock.rewrite.1=regex,replacement
+func Rewrites(config types.Container) ([]Rewrite, error) {
+	rewrites := []Rewrite{}
+
+	for _, k := range sortedLabels(config, ext.InterlockRewriteLabel) {
+		v := config.Labels[k]
+		parts := strings.SplitN(v, ",", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid rewrite for %s: expected regex,replacement; received %q", k, v)
+		}
+
+		regex := strings.TrimSpace(parts[0])
+		replacement := strings.TrimSpace(parts[1])
+
+		if regex == "" || replacement == "" {
+			return nil, fmt.Errorf("invalid rewrite for %s: regex and replacement must be specified", k)
+		}
+
+		if _, err := regexp.Compile(regex); err != nil {
+			return nil, fmt.Errorf("invalid rewrite regex for %s: %s", k, err)
+		}
+
+		for _, s := range []string{regex, replacement} {
+			if strings.ContainsAny(s, " \t\r\n\"") {
+				return nil, fmt.Errorf("invalid rewrite for %s: %q contains invalid characters", k, s)
+			}
+		}
+
+		// a backslash would escape the closing quote of the replacement
+		if strings.Contains(replacement, "\\") {
+			return nil, fmt.Errorf("invalid rewrite for %s: %q contains invalid characters", k, replacement)
+		}
+
+		rewrites = append(rewrites, Rewrite{
+			Regex:       regex,
+			Replacement: replacement,
+		})
+	}
+
+	return rewrites, nil
+}
+
+// sortedLabels returns the keys of all labels matching the prefix sorted by
+// index to keep the generated config stable
+func sortedLabels(config types.Container, label string) []string {
+	keys := []string{}
+	for l := range config.Labels {
+		if strings.HasPrefix(l, label) {
+			keys = append(keys, l)
+		}
+	}
+
+	sort.Sort(labelsByIndex(keys))
+
+	return keys
+}
+
+// labelsByIndex sorts the labels by their numeric suffix (.2 before .10);
+// the labels without one are sorted lexically after them
+type labelsByIndex []string
+
+func (l labelsByIndex) Len() int      { return len(l) }
+func (l labelsByIndex) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l labelsByIndex) Less(i, j int) bool {
+	a, aErr := labelIndex(l[i])
+	b, bErr := labelIndex(l[j])
+	switch {
+	case aErr == nil && bErr == nil && a != b:
+		return a < b
+	case aErr == nil && bErr != nil:
+		return true
+	case aErr != nil && bErr == nil:
+		return false
+	}
+
+	return l[i] < l[j]
+}
+
+func labelIndex(label string) (int, error) {
+	return strconv.Atoi(label[strings.LastIndex(label, ".")+1:])
+}
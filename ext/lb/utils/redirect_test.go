@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/ehazlett/interlock/ext"
+)
+
+func TestRedirects(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockRedirectLabel + ".0": "old.example.com,new.example.com",
+			ext.InterlockRedirectLabel + ".1": "/docs,/v2/docs,302",
+		},
+	}
+
+	redirects, err := Redirects(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(redirects) != 2 {
+		t.Fatalf("expected %d redirects; received %d", len(cfg.Labels), len(redirects))
+	}
+
+	host := redirects[0]
+	if !host.IsHost() {
+		t.Fatalf("expected host redirect: %+v", host)
+	}
+
+	if host.Code != DefaultRedirectCode {
+		t.Fatalf("expected code %d; received %d", DefaultRedirectCode, host.Code)
+	}
+
+	path := redirects[1]
+	if path.IsHost() {
+		t.Fatalf("expected path redirect: %+v", path)
+	}
+
+	if path.To != "/v2/docs" || path.Code != 302 {
+		t.Fatalf("unexpected redirect: %+v", path)
+	}
+}
+
+func TestRedirectsInvalid(t *testing.T) {
+	for _, v := range []string{
+		"old.example.com",
+		"old.example.com,new.example.com,200",
+		"old.example.com,new.example.com,abc",
+		"old.example.com/foo,new.example.com",
+		",new.example.com",
+		"/docs,/v2 docs",
+		"/docs,/v2/docs\\",
+	} {
+		cfg := types.Container{
+			Labels: map[string]string{
+				ext.InterlockRedirectLabel: v,
+			},
+		}
+
+		if _, err := Redirects(cfg); err == nil {
+			t.Fatalf("expected error for redirect %q", v)
+		}
+	}
+}
+
+func TestRedirectFromRegex(t *testing.T) {
+	r := Redirect{
+		From: "/docs.old",
+	}
+
+	expected := `^/docs\.old`
+	if r.FromRegex() != expected {
+		t.Fatalf("expected %s; received %s", expected, r.FromRegex())
+	}
+}
+
+func TestRewrites(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockRewriteLabel + ".0": "^/api/(.*)$,/$1",
+		},
+	}
+
+	rewrites, err := Rewrites(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rewrites) != 1 {
+		t.Fatalf("expected 1 rewrite; received %d", len(rewrites))
+	}
+
+	if rewrites[0].Regex != "^/api/(.*)$" || rewrites[0].Replacement != "/$1" {
+		t.Fatalf("unexpected rewrite: %+v", rewrites[0])
+	}
+}
+
+func TestRewritesInvalidRegex(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockRewriteLabel + ".0": "^/api/(.*$,/$1",
+		},
+	}
+
+	if _, err := Rewrites(cfg); err == nil {
+		t.Fatal("expected error for invalid regex")
+	}
+}
+
+func TestRewritesInvalidReplacement(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockRewriteLabel + ".0": "^/api/(.*)$,/$1\\",
+		},
+	}
+
+	if _, err := Rewrites(cfg); err == nil {
+		t.Fatal("expected error for invalid replacement")
+	}
+}
+
+func TestRewritesNoLabels(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{},
+	}
+
+	rewrites, err := Rewrites(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rewrites) != 0 {
+		t.Fatalf("expected no rewrites; received %v", rewrites)
+	}
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/docker/docker/api/types"
+	"github.com/ehazlett/interlock/ext"
+)
+
+// cookie names are also used as nginx variables ($cookie_NAME)
+var cookieNameRegex = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+func StickyCookie(config types.Container) (string, error) {
+	v, ok := config.Labels[ext.InterlockStickyCookieLabel]
+	if !ok || v == "" {
+		return "", nil
+	}
+
+	if !cookieNameRegex.MatchString(v) {
+		return "", fmt.Errorf("invalid sticky cookie name: %q", v)
+	}
+
+	return v, nil
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/ehazlett/interlock/ext"
+)
+
+func TestStickyCookie(t *testing.T) {
+	testCookie := "SERVERID"
+
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockStickyCookieLabel: testCookie,
+		},
+	}
+
+	cookie, err := StickyCookie(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cookie != testCookie {
+		t.Fatalf("expected %s; received %s", testCookie, cookie)
+	}
+}
+
+func TestStickyCookieNoLabel(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{},
+	}
+
+	cookie, err := StickyCookie(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cookie != "" {
+		t.Fatalf("expected no sticky cookie; received %s", cookie)
+	}
+}
+
+func TestStickyCookieInvalid(t *testing.T) {
+	cfg := types.Container{
+		Labels: map[string]string{
+			ext.InterlockStickyCookieLabel: "server-id",
+		},
+	}
+
+	if _, err := StickyCookie(cfg); err == nil {
+		t.Fatal("expected error for invalid cookie name")
+	}
+}
@@ -12,7 +12,7 @@ func WebsocketEndpoints(config types.Container) []string {
 
 	for l, v := range config.Labels {
 		// this is for labels like interlock.websocket_endpoint.1=foo
-		if strings.Index(l, ext.InterlockWebsocketEndpointLabel) > -1 {
+		if strings.HasPrefix(l, ext.InterlockWebsocketEndpointLabel) {
 			websocketEndpoints = append(websocketEndpoints, v)
 		}
 	}
@@ -0,0 +1,197 @@
+package ext
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Operator is the operator of a selector requirement
+type Operator string
+
+const (
+	Exists       Operator = "exists"
+	DoesNotExist Operator = "!"
+	Equals       Operator = "="
+	NotEquals    Operator = "!="
+	In           Operator = "in"
+	NotIn        Operator = "notin"
+)
+
+var setRequirement = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+
+// Requirement is a condition on a label of a selector
+type Requirement struct {
+	Key      string
+	Operator Operator
+	Values   []string
+}
+
+// Selector selects containers by their labels using the Kubernetes label
+// selector syntax (i.e. env=prod,tier!=internal,team in (web,api),!legacy).
+// All the requirements must match.
+type Selector []Requirement
+
+// ParseSelector parses a comma separated list of requirements
+func ParseSelector(s string) (Selector, error) {
+	selector := Selector{}
+
+	for _, part := range splitSelector(s) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		r, err := parseRequirement(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %s", s, err)
+		}
+
+		selector = append(selector, r)
+	}
+
+	return selector, nil
+}
+
+// splitSelector splits the requirements on the commas outside of the
+// value sets
+func splitSelector(s string) []string {
+	parts := []string{}
+	depth := 0
+	start := 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+func parseRequirement(s string) (Requirement, error) {
+	if m := setRequirement.FindStringSubmatch(s); m != nil {
+		values := []string{}
+		for _, v := range strings.Split(m[3], ",") {
+			v = strings.TrimSpace(v)
+			if err := validSelectorValue(v); err != nil {
+				return Requirement{}, err
+			}
+			values = append(values, v)
+		}
+
+		return newRequirement(m[1], Operator(m[2]), values)
+	}
+
+	if strings.HasPrefix(s, "!") {
+		return newRequirement(strings.TrimSpace(s[1:]), DoesNotExist, nil)
+	}
+
+	for _, op := range []string{"!=", "==", "="} {
+		i := strings.Index(s, op)
+		if i < 0 {
+			continue
+		}
+
+		v := strings.TrimSpace(s[i+len(op):])
+		if err := validSelectorValue(v); err != nil {
+			return Requirement{}, err
+		}
+
+		o := Equals
+		if op == "!=" {
+			o = NotEquals
+		}
+
+		return newRequirement(strings.TrimSpace(s[:i]), o, []string{v})
+	}
+
+	return newRequirement(s, Exists, nil)
+}
+
+func newRequirement(key string, op Operator, values []string) (Requirement, error) {
+	if key == "" {
+		return Requirement{}, fmt.Errorf("missing label key")
+	}
+
+	if strings.ContainsAny(key, " \t=!(),") {
+		return Requirement{}, fmt.Errorf("invalid label key %q", key)
+	}
+
+	return Requirement{
+		Key:      key,
+		Operator: op,
+		Values:   values,
+	}, nil
+}
+
+func validSelectorValue(v string) error {
+	if strings.ContainsAny(v, " \t=!(),") {
+		return fmt.Errorf("invalid label value %q", v)
+	}
+
+	return nil
+}
+
+// Matches returns whether the labels match the requirement
+func (r Requirement) Matches(labels map[string]string) bool {
+	v, ok := labels[r.Key]
+
+	switch r.Operator {
+	case Exists:
+		return ok
+	case DoesNotExist:
+		return !ok
+	case Equals, In:
+		return ok && contains(r.Values, v)
+	case NotEquals, NotIn:
+		return !ok || !contains(r.Values, v)
+	}
+
+	return false
+}
+
+// Matches returns whether the labels match all the requirements
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Filters returns the requirements which can be used as docker label
+// filters (i.e. env=prod); the other requirements are only checked by
+// Matches
+func (s Selector) Filters() []string {
+	filters := []string{}
+	for _, r := range s {
+		switch {
+		case r.Operator == Exists:
+			filters = append(filters, r.Key)
+		case r.Operator == Equals, r.Operator == In && len(r.Values) == 1:
+			filters = append(filters, r.Key+"="+r.Values[0])
+		}
+	}
+
+	return filters
+}
+
+func contains(values []string, v string) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,270 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// ServerLabel is the extension label value for metrics of the
+	// interlock server itself
+	ServerLabel = "server"
+
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+var (
+	EventsProcessed = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "interlock",
+			Subsystem: "totals",
+			Name:      "events_processed",
+			Help:      "Total number of events processed",
+		},
+	)
+
+	EventStreamReconnects = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "interlock",
+			Subsystem: "events",
+			Name:      "stream_reconnects_total",
+			Help:      "Total number of event stream reconnects",
+		},
+	)
+
+	Uptime = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "interlock",
+			Subsystem: "totals",
+			Name:      "uptime",
+			Help:      "Uptime in seconds",
+		},
+	)
+
+	LastReloadDuration = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "interlock",
+			Subsystem: "system",
+			Name:      "last_reload_duration_seconds",
+			Help:      "Duration of last reload in seconds",
+		},
+		[]string{
+			"extension",
+		},
+	)
+
+	ReloadAttempts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "interlock",
+			Subsystem: "lb",
+			Name:      "reload_attempts_total",
+			Help:      "Total number of proxy reload attempts",
+		},
+		[]string{
+			"extension",
+		},
+	)
+
+	Reloads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "interlock",
+			Subsystem: "lb",
+			Name:      "reloads_total",
+			Help:      "Total number of proxy reloads by result",
+		},
+		[]string{
+			"extension",
+			"result",
+		},
+	)
+
+	ProxyReloads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "interlock",
+			Subsystem: "lb",
+			Name:      "proxy_reloads_total",
+			Help:      "Total number of reloads of each proxy container by result",
+		},
+		[]string{
+			"extension",
+			"container",
+			"result",
+		},
+	)
+
+	GenerateDuration = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "interlock",
+			Subsystem: "lb",
+			Name:      "generate_duration_seconds",
+			Help:      "Duration of the last proxy config generation in seconds",
+		},
+		[]string{
+			"extension",
+		},
+	)
+
+	Hosts = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "interlock",
+			Subsystem: "lb",
+			Name:      "hosts",
+			Help:      "Number of hosts in the last proxy config",
+		},
+		[]string{
+			"extension",
+		},
+	)
+
+	Upstreams = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "interlock",
+			Subsystem: "lb",
+			Name:      "upstreams",
+			Help:      "Number of containers added as upstreams in the last proxy config",
+		},
+		[]string{
+			"extension",
+		},
+	)
+
+	SkippedContainers = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "interlock",
+			Subsystem: "lb",
+			Name:      "skipped_containers",
+			Help:      "Number of containers skipped in the last proxy config",
+		},
+		[]string{
+			"extension",
+		},
+	)
+
+	LabelDiagnostics = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "interlock",
+			Subsystem: "lb",
+			Name:      "label_diagnostics",
+			Help:      "Number of problems found in the container labels of the last proxy config by severity",
+		},
+		[]string{
+			"extension",
+			"severity",
+		},
+	)
+
+	HostConflicts = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "interlock",
+			Subsystem: "lb",
+			Name:      "host_conflicts",
+			Help:      "Number of host settings the containers of a host disagree on in the last proxy config",
+		},
+		[]string{
+			"extension",
+			"policy",
+		},
+	)
+
+	DockerAPIErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "interlock",
+			Subsystem: "docker",
+			Name:      "api_errors_total",
+			Help:      "Total number of failed Docker API calls",
+		},
+		[]string{
+			"extension",
+		},
+	)
+
+	allCollectors = []prometheus.Collector{
+		EventsProcessed,
+		EventStreamReconnects,
+		Uptime,
+		LastReloadDuration,
+		ReloadAttempts,
+		Reloads,
+		ProxyReloads,
+		GenerateDuration,
+		Hosts,
+		Upstreams,
+		SkippedContainers,
+		LabelDiagnostics,
+		HostConflicts,
+		DockerAPIErrors,
+	}
+)
+
+func init() {
+	// register the interlock metrics
+	for _, c := range allCollectors {
+		prometheus.MustRegister(c)
+	}
+}
+
+// ProxyReload records the result of reloading a proxy container
+func ProxyReload(extension, container string, err error) {
+	result := ResultSuccess
+	if err != nil {
+		result = ResultFailure
+	}
+
+	if len(container) > 12 {
+		container = container[:12]
+	}
+
+	ProxyReloads.With(prometheus.Labels{
+		"extension": extension,
+		"container": container,
+		"result":    result,
+	}).Inc()
+}
+
+// ReloadDuration records the duration of the last reload of the extension
+func ReloadDuration(extension string, d time.Duration) {
+	LastReloadDuration.With(prometheus.Labels{
+		"extension": extension,
+	}).Set(d.Seconds())
+}
+
+// ProxyConfig records the size of the generated proxy config
+func ProxyConfig(extension string, hosts, upstreams, skipped int) {
+	labels := prometheus.Labels{
+		"extension": extension,
+	}
+
+	Hosts.With(labels).Set(float64(hosts))
+	Upstreams.With(labels).Set(float64(upstreams))
+	SkippedContainers.With(labels).Set(float64(skipped))
+}
+
+// LabelProblems records the problems found in the container labels
+func LabelProblems(extension string, errors, warnings int) {
+	LabelDiagnostics.With(prometheus.Labels{
+		"extension": extension,
+		"severity":  "error",
+	}).Set(float64(errors))
+	LabelDiagnostics.With(prometheus.Labels{
+		"extension": extension,
+		"severity":  "warning",
+	}).Set(float64(warnings))
+}
+
+// SettingConflicts records the conflicting host settings resolved by the
+// policy
+func SettingConflicts(extension, policy string, conflicts int) {
+	HostConflicts.With(prometheus.Labels{
+		"extension": extension,
+		"policy":    policy,
+	}).Set(float64(conflicts))
+}
+
+// APIError counts a failed Docker API call of the extension
+func APIError(extension string) {
+	DockerAPIErrors.With(prometheus.Labels{
+		"extension": extension,
+	}).Inc()
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func counterValue(t *testing.T, c *prometheus.CounterVec, labels prometheus.Labels) float64 {
+	m := &dto.Metric{}
+	if err := c.With(labels).Write(m); err != nil {
+		t.Fatal(err)
+	}
+
+	return m.GetCounter().GetValue()
+}
+
+func gaugeValue(t *testing.T, g *prometheus.GaugeVec, labels prometheus.Labels) float64 {
+	m := &dto.Metric{}
+	if err := g.With(labels).Write(m); err != nil {
+		t.Fatal(err)
+	}
+
+	return m.GetGauge().GetValue()
+}
+
+func TestProxyReload(t *testing.T) {
+	id := "0123456789abcdef"
+
+	ProxyReload("nginx", id, nil)
+	ProxyReload("nginx", id, nil)
+	ProxyReload("nginx", id, errors.New("error"))
+
+	success := prometheus.Labels{"extension": "nginx", "container": "0123456789ab", "result": ResultSuccess}
+	if v := counterValue(t, ProxyReloads, success); v != 2 {
+		t.Fatalf("expected 2 successful reloads; received %g", v)
+	}
+
+	failure := prometheus.Labels{"extension": "nginx", "container": "0123456789ab", "result": ResultFailure}
+	if v := counterValue(t, ProxyReloads, failure); v != 1 {
+		t.Fatalf("expected 1 failed reload; received %g", v)
+	}
+}
+
+func TestReloadDuration(t *testing.T) {
+	ReloadDuration("nginx", time.Millisecond*1500)
+
+	if v := gaugeValue(t, LastReloadDuration, prometheus.Labels{"extension": "nginx"}); v != 1.5 {
+		t.Fatalf("expected 1.5 seconds; received %g", v)
+	}
+}
+
+func TestProxyConfig(t *testing.T) {
+	ProxyConfig("haproxy", 2, 5, 1)
+
+	labels := prometheus.Labels{"extension": "haproxy"}
+	for g, expected := range map[*prometheus.GaugeVec]float64{
+		Hosts:             2,
+		Upstreams:         5,
+		SkippedContainers: 1,
+	} {
+		if v := gaugeValue(t, g, labels); v != expected {
+			t.Fatalf("expected %g; received %g", expected, v)
+		}
+	}
+}
+
+func TestLabelProblems(t *testing.T) {
+	LabelProblems("nginx", 1, 3)
+
+	for severity, expected := range map[string]float64{"error": 1, "warning": 3} {
+		labels := prometheus.Labels{"extension": "nginx", "severity": severity}
+		if v := gaugeValue(t, LabelDiagnostics, labels); v != expected {
+			t.Fatalf("expected %g %s diagnostics; received %g", expected, severity, v)
+		}
+	}
+}
+
+func TestSettingConflicts(t *testing.T) {
+	SettingConflicts("haproxy", "majority", 2)
+
+	labels := prometheus.Labels{"extension": "haproxy", "policy": "majority"}
+	if v := gaugeValue(t, HostConflicts, labels); v != 2 {
+		t.Fatalf("expected 2 conflicts; received %g", v)
+	}
+}
@@ -0,0 +1,335 @@
+package compose
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"gopkg.in/yaml.v2"
+)
+
+var variable = regexp.MustCompile(`\$(\$|\{([a-zA-Z_][a-zA-Z0-9_]*)(?:(:?-)([^}]*))?\}|[a-zA-Z_][a-zA-Z0-9_]*)`)
+
+// Project is the set of services of a compose file
+type Project struct {
+	Name     string
+	Services []*Service
+}
+
+// Service is a service of a compose file
+type Service struct {
+	Name  string
+	Image string
+	// Labels are the container labels over the service (deploy) labels
+	Labels   map[string]string
+	Ports    []types.Port
+	Networks []string
+	Replicas int
+}
+
+type composeFile struct {
+	Version  string                    `yaml:"version"`
+	Services map[string]composeService `yaml:"services"`
+}
+
+type composeService struct {
+	Image    string        `yaml:"image"`
+	Labels   labels        `yaml:"labels"`
+	Ports    []interface{} `yaml:"ports"`
+	Expose   []interface{} `yaml:"expose"`
+	Networks networks      `yaml:"networks"`
+	Deploy   struct {
+		Replicas *int   `yaml:"replicas"`
+		Labels   labels `yaml:"labels"`
+	} `yaml:"deploy"`
+}
+
+// labels are either a map or a list of key=value
+type labels map[string]string
+
+func (l *labels) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	m := map[string]string{}
+	if err := unmarshal(&m); err == nil {
+		*l = m
+		return nil
+	}
+
+	list := []string{}
+	if err := unmarshal(&list); err != nil {
+		return fmt.Errorf("labels must be a map or a list of key=value")
+	}
+
+	for _, s := range list {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) == 1 {
+			parts = append(parts, "")
+		}
+		m[parts[0]] = parts[1]
+	}
+	*l = m
+
+	return nil
+}
+
+// networks are either a list of names or a map of network options
+type networks []string
+
+func (n *networks) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	list := []string{}
+	if err := unmarshal(&list); err == nil {
+		*n = list
+		return nil
+	}
+
+	m := map[string]interface{}{}
+	if err := unmarshal(&m); err != nil {
+		return fmt.Errorf("networks must be a list or a map")
+	}
+
+	for name := range m {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	*n = list
+
+	return nil
+}
+
+// Parse parses a compose file (version 2 or 3).  Variables (i.e. ${DOMAIN}
+// or ${DOMAIN:-example.com}) are replaced by the environment.
+func Parse(data []byte, project string) (*Project, error) {
+	var f composeFile
+	if err := yaml.Unmarshal([]byte(interpolate(string(data))), &f); err != nil {
+		return nil, fmt.Errorf("invalid compose file: %s", err)
+	}
+
+	if f.Version != "" && !strings.HasPrefix(f.Version, "2") && !strings.HasPrefix(f.Version, "3") {
+		return nil, fmt.Errorf("unsupported compose file version %q", f.Version)
+	}
+
+	if len(f.Services) == 0 {
+		return nil, fmt.Errorf("no services in the compose file")
+	}
+
+	p := &Project{
+		Name:     project,
+		Services: []*Service{},
+	}
+
+	for name, s := range f.Services {
+		svc, err := newService(name, s)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %s", name, err)
+		}
+
+		p.Services = append(p.Services, svc)
+	}
+
+	sort.Sort(servicesByName(p.Services))
+
+	return p, nil
+}
+
+func newService(name string, s composeService) (*Service, error) {
+	svc := &Service{
+		Name:     name,
+		Image:    s.Image,
+		Labels:   map[string]string{},
+		Ports:    []types.Port{},
+		Networks: s.Networks,
+		Replicas: 1,
+	}
+
+	if s.Deploy.Replicas != nil {
+		svc.Replicas = *s.Deploy.Replicas
+	}
+
+	for k, v := range s.Deploy.Labels {
+		svc.Labels[k] = v
+	}
+	for k, v := range s.Labels {
+		svc.Labels[k] = v
+	}
+
+	for _, v := range s.Ports {
+		ports, err := parsePort(v)
+		if err != nil {
+			return nil, err
+		}
+		svc.Ports = append(svc.Ports, ports...)
+	}
+
+	for _, v := range s.Expose {
+		port, proto, err := parsePortProto(fmt.Sprint(v))
+		if err != nil {
+			return nil, err
+		}
+		svc.Ports = append(svc.Ports, types.Port{
+			PrivatePort: port,
+			Type:        proto,
+		})
+	}
+
+	return svc, nil
+}
+
+// parsePort parses the short (i.e. 127.0.0.1:8080:80/tcp) and long syntax
+// of a port
+func parsePort(v interface{}) ([]types.Port, error) {
+	if m, ok := v.(map[interface{}]interface{}); ok {
+		target, err := strconv.ParseUint(fmt.Sprint(m["target"]), 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port target %v", m["target"])
+		}
+
+		p := types.Port{
+			PrivatePort: uint16(target),
+			Type:        "tcp",
+		}
+
+		if proto, ok := m["protocol"]; ok {
+			p.Type = fmt.Sprint(proto)
+		}
+
+		if published, ok := m["published"]; ok {
+			n, err := strconv.ParseUint(fmt.Sprint(published), 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("invalid published port %v", published)
+			}
+			p.IP = "0.0.0.0"
+			p.PublicPort = uint16(n)
+		}
+
+		return []types.Port{p}, nil
+	}
+
+	s := fmt.Sprint(v)
+	parts := strings.Split(s, ":")
+
+	ip := "0.0.0.0"
+	if len(parts) == 3 {
+		ip = parts[0]
+		parts = parts[1:]
+	}
+
+	target, proto, err := parsePortProto(parts[len(parts)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	p := types.Port{
+		PrivatePort: target,
+		Type:        proto,
+	}
+
+	switch len(parts) {
+	case 1:
+	case 2:
+		published, err := strconv.ParseUint(parts[0], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q (port ranges are not supported)", s)
+		}
+		p.IP = ip
+		p.PublicPort = uint16(published)
+	default:
+		return nil, fmt.Errorf("invalid port %q", s)
+	}
+
+	return []types.Port{p}, nil
+}
+
+func parsePortProto(s string) (uint16, string, error) {
+	proto := "tcp"
+	if i := strings.Index(s, "/"); i > -1 {
+		s, proto = s[:i], s[i+1:]
+	}
+
+	port, err := strconv.ParseUint(s, 10, 16)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid port %q (port ranges are not supported)", s)
+	}
+
+	return uint16(port), proto, nil
+}
+
+// interpolate replaces the variables by their value in the environment;
+// $$ is an escaped $
+func interpolate(s string) string {
+	return variable.ReplaceAllStringFunc(s, func(v string) string {
+		m := variable.FindStringSubmatch(v)
+		switch {
+		case m[1] == "$":
+			return "$"
+		case m[2] == "":
+			return os.Getenv(m[1])
+		}
+
+		value, ok := os.LookupEnv(m[2])
+		switch m[3] {
+		case ":-":
+			if value == "" {
+				return m[4]
+			}
+		case "-":
+			if !ok {
+				return m[4]
+			}
+		}
+
+		return value
+	})
+}
+
+// Containers returns the containers the services would run.  The
+// containers are named as the tasks of the services (i.e. app_web.1).
+func (p *Project) Containers() []types.Container {
+	containers := []types.Container{}
+
+	for _, s := range p.Services {
+		for i := 1; i <= s.Replicas; i++ {
+			name := fmt.Sprintf("%s.%d", s.Name, i)
+			if p.Name != "" {
+				name = fmt.Sprintf("%s_%s", p.Name, name)
+			}
+
+			h := sha256.Sum256([]byte(name))
+
+			labels := map[string]string{}
+			for k, v := range s.Labels {
+				labels[k] = v
+			}
+
+			networks := map[string]*network.EndpointSettings{}
+			for _, n := range s.Networks {
+				networks[n] = &network.EndpointSettings{}
+			}
+
+			containers = append(containers, types.Container{
+				ID:     hex.EncodeToString(h[:]),
+				Names:  []string{"/" + name},
+				Image:  s.Image,
+				Labels: labels,
+				Ports:  append([]types.Port{}, s.Ports...),
+				State:  "running",
+				NetworkSettings: &types.SummaryNetworkSettings{
+					Networks: networks,
+				},
+			})
+		}
+	}
+
+	return containers
+}
+
+type servicesByName []*Service
+
+func (s servicesByName) Len() int           { return len(s) }
+func (s servicesByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s servicesByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
@@ -0,0 +1,133 @@
+package compose
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+const testCompose = `
+version: "3.4"
+services:
+  web:
+    image: nginx:${NGINX_VERSION:-stable}
+    labels:
+      interlock.hostname: web
+      interlock.domain: ${DOMAIN}
+      interlock.rewrite.0: "^/api/(.*)$$,/$$1"
+    ports:
+      - "8080:80"
+      - "127.0.0.1:8443:443/tcp"
+    deploy:
+      replicas: 2
+      labels:
+        - interlock.hostname=ignored
+        - env=prod
+  api:
+    image: api
+    expose:
+      - 9000
+    ports:
+      - target: 8000
+        published: 8000
+    networks:
+      backend:
+        aliases:
+          - api
+  db:
+    image: postgres
+`
+
+func TestParse(t *testing.T) {
+	os.Setenv("DOMAIN", "example.com")
+	defer os.Unsetenv("DOMAIN")
+
+	p, err := Parse([]byte(testCompose), "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.Services) != 3 || p.Services[0].Name != "api" || p.Services[2].Name != "web" {
+		t.Fatalf("expected sorted services; received %+v", p.Services)
+	}
+
+	api := p.Services[0]
+	expectedPorts := []types.Port{
+		{IP: "0.0.0.0", PrivatePort: 8000, PublicPort: 8000, Type: "tcp"},
+		{PrivatePort: 9000, Type: "tcp"},
+	}
+	if !reflect.DeepEqual(api.Ports, expectedPorts) {
+		t.Fatalf("expected %+v; received %+v", expectedPorts, api.Ports)
+	}
+
+	if !reflect.DeepEqual(api.Networks, []string{"backend"}) {
+		t.Fatalf("unexpected networks: %v", api.Networks)
+	}
+
+	web := p.Services[2]
+	if web.Image != "nginx:stable" || web.Replicas != 2 {
+		t.Fatalf("unexpected service: %+v", web)
+	}
+
+	expectedLabels := map[string]string{
+		"interlock.hostname":  "web",
+		"interlock.domain":    "example.com",
+		"interlock.rewrite.0": "^/api/(.*)$,/$1",
+		"env":                 "prod",
+	}
+	if !reflect.DeepEqual(web.Labels, expectedLabels) {
+		t.Fatalf("expected %v; received %v", expectedLabels, web.Labels)
+	}
+
+	expectedPorts = []types.Port{
+		{IP: "0.0.0.0", PrivatePort: 80, PublicPort: 8080, Type: "tcp"},
+		{IP: "127.0.0.1", PrivatePort: 443, PublicPort: 8443, Type: "tcp"},
+	}
+	if !reflect.DeepEqual(web.Ports, expectedPorts) {
+		t.Fatalf("expected %+v; received %+v", expectedPorts, web.Ports)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, data := range []string{
+		`version: "1"`,
+		`version: "3"`,
+		"services:\n  web:\n    ports:\n      - \"8000-8010:80\"",
+		"services: [",
+	} {
+		if _, err := Parse([]byte(data), "app"); err == nil {
+			t.Fatalf("expected error for %q", data)
+		}
+	}
+}
+
+func TestContainers(t *testing.T) {
+	p, err := Parse([]byte(testCompose), "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	containers := p.Containers()
+	if len(containers) != 4 {
+		t.Fatalf("expected 4 containers; received %d", len(containers))
+	}
+
+	names := []string{}
+	for _, c := range containers {
+		names = append(names, c.Names[0])
+		if len(c.ID) != 64 {
+			t.Fatalf("unexpected id: %s", c.ID)
+		}
+	}
+
+	expected := []string{"/app_api.1", "/app_db.1", "/app_web.1", "/app_web.2"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %v; received %v", expected, names)
+	}
+
+	if _, ok := containers[0].NetworkSettings.Networks["backend"]; !ok {
+		t.Fatalf("expected the backend network: %+v", containers[0].NetworkSettings)
+	}
+}
@@ -0,0 +1,90 @@
+// Package discovery returns the key value store of a discovery address
+package discovery
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/docker/libkv"
+	kvstore "github.com/docker/libkv/store"
+	"github.com/ehazlett/interlock/pkg/vault"
+)
+
+const (
+	// defaultBoltBucket is the bucket of the boltdb store
+	defaultBoltBucket = "interlock"
+)
+
+// NewStore returns the key value store of the address:
+//
+//	consul://127.0.0.1:8500
+//	etcd://127.0.0.1:2379
+//	zk://127.0.0.1:2181
+//	boltdb:///var/lib/interlock/config.db
+//	vault://127.0.0.1:8200/secret (vault+http:// without tls)
+//
+// The libkv backends must be registered (i.e. consul.Register()); the vault
+// auth is only used by the vault store.
+func NewStore(addr string, options *kvstore.Config, auth *vault.Auth) (kvstore.Store, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if options == nil {
+		options = &kvstore.Config{}
+	}
+
+	endpoints := []string{u.Host}
+	var backend kvstore.Backend
+
+	switch strings.ToLower(u.Scheme) {
+	case "consul":
+		backend = kvstore.CONSUL
+	case "etcd":
+		backend = kvstore.ETCD
+	case "zk", "zookeeper":
+		backend = kvstore.ZK
+		endpoints = strings.Split(u.Host, ",")
+	case "boltdb":
+		backend = kvstore.BOLTDB
+		endpoints = []string{u.Host + u.Path}
+		if options.Bucket == "" {
+			options.Bucket = defaultBoltBucket
+		}
+	case "vault":
+		return newVaultStore("https", u, options, auth)
+	case "vault+http":
+		return newVaultStore("http", u, options, auth)
+	default:
+		return nil, fmt.Errorf("unsupported discovery backend %q; expected consul, etcd, zk, boltdb or vault", u.Scheme)
+	}
+
+	return libkv.NewStore(
+		backend,
+		endpoints,
+		options,
+	)
+}
+
+// newVaultStore returns the store of the KV version 2 mount in the path of
+// the address; the rest of the path is the prefix of the keys
+func newVaultStore(scheme string, u *url.URL, options *kvstore.Config, auth *vault.Auth) (kvstore.Store, error) {
+	parts := strings.SplitN(strings.Trim(u.Path, "/"), "/", 2)
+	if parts[0] == "" {
+		return nil, fmt.Errorf("missing vault mount (i.e. vault://127.0.0.1:8200/secret)")
+	}
+
+	prefix := ""
+	if len(parts) == 2 {
+		prefix = parts[1]
+	}
+
+	client, err := vault.NewClient(scheme+"://"+u.Host, auth, options.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	return vault.NewStore(client, parts[0], prefix), nil
+}
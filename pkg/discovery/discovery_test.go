@@ -0,0 +1,93 @@
+package discovery
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	kvstore "github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/ehazlett/interlock/pkg/vault"
+	"github.com/ehazlett/interlock/pkg/vault/vaulttest"
+)
+
+func testStore(t *testing.T, kv kvstore.Store) {
+	if err := kv.Put("interlock/v1/config", []byte(`ListenAddr = ":8080"`), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := kv.Exists("interlock/v1/config")
+	if err != nil || !ok {
+		t.Fatalf("expected key to exist; received %v %v", ok, err)
+	}
+
+	pair, err := kv.Get("interlock/v1/config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(pair.Value) != `ListenAddr = ":8080"` {
+		t.Fatalf("unexpected value: %s", pair.Value)
+	}
+}
+
+func TestNewStoreBoltDB(t *testing.T) {
+	boltdb.Register()
+
+	dir, err := ioutil.TempDir("", "interlock-discovery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	kv, err := NewStore("boltdb://"+filepath.Join(dir, "config.db"), &kvstore.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer kv.Close()
+
+	testStore(t, kv)
+}
+
+func TestNewStoreVault(t *testing.T) {
+	srv := vaulttest.NewServer()
+	defer srv.Close()
+
+	addr := strings.Replace(srv.URL, "http://", "vault+http://", 1) + "/" + vaulttest.Mount + "/cluster"
+	auth := &vault.Auth{
+		RoleID:   vaulttest.RoleID,
+		SecretID: vaulttest.SecretID,
+	}
+
+	kv, err := NewStore(addr, &kvstore.Config{}, auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testStore(t, kv)
+
+	// keys are under the prefix of the address
+	c, err := vault.NewClient(srv.URL, &vault.Auth{Token: vaulttest.Token}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := c.Read(vaulttest.Mount, "cluster/interlock/v1/config"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewStoreErrors(t *testing.T) {
+	addrs := []string{
+		"redis://127.0.0.1:6379",
+		"vault://127.0.0.1:8200",
+	}
+
+	for _, addr := range addrs {
+		if _, err := NewStore(addr, &kvstore.Config{}, &vault.Auth{Token: "t"}); err == nil {
+			t.Fatalf("expected error for %s", addr)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package vault
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/libkv/store"
+)
+
+const (
+	// valueField is the field of the secret with the value of the key
+	valueField = "value"
+)
+
+// Store is a libkv store of the keys in a KV version 2 mount.  Each key is a
+// secret with the value in the value field; the version of the secret is
+// the index of the key.
+type Store struct {
+	client *Client
+	mount  string
+	prefix string
+}
+
+// NewStore returns the store of the keys under the prefix of the mount
+func NewStore(client *Client, mount, prefix string) *Store {
+	return &Store{
+		client: client,
+		mount:  strings.Trim(mount, "/"),
+		prefix: strings.Trim(prefix, "/"),
+	}
+}
+
+func (s *Store) path(key string) string {
+	key = strings.Trim(key, "/")
+	if s.prefix == "" {
+		return key
+	}
+
+	return s.prefix + "/" + key
+}
+
+func (s *Store) Put(key string, value []byte, options *store.WriteOptions) error {
+	_, err := s.client.Write(s.mount, s.path(key), map[string]interface{}{
+		valueField: string(value),
+	}, -1)
+	return err
+}
+
+func (s *Store) Get(key string) (*store.KVPair, error) {
+	data, version, err := s.client.Read(s.mount, s.path(key))
+	if err == ErrNotFound {
+		return nil, store.ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok := data[valueField].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s: missing %s field", key, valueField)
+	}
+
+	return &store.KVPair{
+		Key:       key,
+		Value:     []byte(v),
+		LastIndex: uint64(version),
+	}, nil
+}
+
+func (s *Store) Delete(key string) error {
+	err := s.client.Delete(s.mount, s.path(key))
+	if err == ErrNotFound {
+		return store.ErrKeyNotFound
+	}
+
+	return err
+}
+
+func (s *Store) Exists(key string) (bool, error) {
+	_, err := s.Get(key)
+	switch err {
+	case nil:
+		return true, nil
+	case store.ErrKeyNotFound:
+		return false, nil
+	}
+
+	return false, err
+}
+
+func (s *Store) List(directory string) ([]*store.KVPair, error) {
+	keys, err := s.client.List(s.mount, s.path(directory))
+	if err == ErrNotFound {
+		return nil, store.ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := []*store.KVPair{}
+	for _, k := range keys {
+		if strings.HasSuffix(k, "/") {
+			continue
+		}
+
+		kv, err := s.Get(strings.Trim(directory, "/") + "/" + k)
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, kv)
+	}
+
+	return pairs, nil
+}
+
+// AtomicPut writes the key using the version of the previous pair as the
+// check-and-set version
+func (s *Store) AtomicPut(key string, value []byte, previous *store.KVPair, options *store.WriteOptions) (bool, *store.KVPair, error) {
+	cas := 0
+	if previous != nil {
+		cas = int(previous.LastIndex)
+	}
+
+	version, err := s.client.Write(s.mount, s.path(key), map[string]interface{}{
+		valueField: string(value),
+	}, cas)
+	if err == ErrCheckAndSet {
+		if previous == nil {
+			return false, nil, store.ErrKeyExists
+		}
+		return false, nil, store.ErrKeyModified
+	}
+	if err != nil {
+		return false, nil, err
+	}
+
+	return true, &store.KVPair{
+		Key:       key,
+		Value:     value,
+		LastIndex: uint64(version),
+	}, nil
+}
+
+func (s *Store) Watch(key string, stopCh <-chan struct{}) (<-chan *store.KVPair, error) {
+	return nil, store.ErrCallNotSupported
+}
+
+func (s *Store) WatchTree(directory string, stopCh <-chan struct{}) (<-chan []*store.KVPair, error) {
+	return nil, store.ErrCallNotSupported
+}
+
+func (s *Store) NewLock(key string, options *store.LockOptions) (store.Locker, error) {
+	return nil, store.ErrCallNotSupported
+}
+
+func (s *Store) DeleteTree(directory string) error {
+	return store.ErrCallNotSupported
+}
+
+func (s *Store) AtomicDelete(key string, previous *store.KVPair) (bool, error) {
+	return false, store.ErrCallNotSupported
+}
+
+func (s *Store) Close() {}
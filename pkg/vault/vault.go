@@ -0,0 +1,301 @@
+// Package vault is a client of the HashiCorp Vault KV version 2 secrets
+// engine using token or AppRole authentication
+package vault
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAppRolePath = "approle"
+	defaultTimeout     = time.Second * 10
+)
+
+var (
+	// ErrNotFound is returned when the secret does not exist
+	ErrNotFound = errors.New("secret not found")
+	// ErrCheckAndSet is returned when the version of the secret does not
+	// match the check-and-set version of a write
+	ErrCheckAndSet = errors.New("check-and-set version does not match")
+)
+
+// Auth is the authentication of the client; the token is used if set
+// otherwise the client logs in with the AppRole role and secret ids
+type Auth struct {
+	Token    string
+	RoleID   string
+	SecretID string
+	// AppRolePath is the mount of the AppRole auth method (default approle)
+	AppRolePath string
+}
+
+// Client reads and writes secrets of KV version 2 mounts
+type Client struct {
+	addr   string
+	auth   *Auth
+	client *http.Client
+
+	lock  sync.Mutex
+	token string
+}
+
+// NewClient returns a client of the Vault server at the address (i.e.
+// https://127.0.0.1:8200)
+func NewClient(addr string, auth *Auth, tlsConfig *tls.Config) (*Client, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid vault address %q; expected http or https", addr)
+	}
+
+	if auth == nil || (auth.Token == "" && (auth.RoleID == "" || auth.SecretID == "")) {
+		return nil, fmt.Errorf("vault token or approle role id and secret id are required")
+	}
+
+	return &Client{
+		addr: strings.TrimRight(addr, "/"),
+		auth: auth,
+		client: &http.Client{
+			Timeout: defaultTimeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
+		},
+		token: auth.Token,
+	}, nil
+}
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Auth   *authResponse   `json:"auth"`
+	Errors []string        `json:"errors"`
+}
+
+type authResponse struct {
+	ClientToken string `json:"client_token"`
+}
+
+type kvData struct {
+	Data     map[string]interface{} `json:"data"`
+	Metadata struct {
+		Version int `json:"version"`
+	} `json:"metadata"`
+}
+
+// login gets a token using the AppRole role and secret ids
+func (c *Client) login() (string, error) {
+	path := c.auth.AppRolePath
+	if path == "" {
+		path = defaultAppRolePath
+	}
+
+	body := map[string]string{
+		"role_id":   c.auth.RoleID,
+		"secret_id": c.auth.SecretID,
+	}
+
+	var resp response
+	if err := c.do("POST", "/v1/auth/"+path+"/login", "", body, &resp); err != nil {
+		return "", fmt.Errorf("vault approle login failed: %s", err)
+	}
+
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return "", fmt.Errorf("vault approle login failed: missing token")
+	}
+
+	return resp.Auth.ClientToken, nil
+}
+
+// request sends the authenticated request; an AppRole token is renewed by
+// logging in again when it is denied
+func (c *Client) request(method, path string, body interface{}, out *response) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	approle := c.auth.Token == ""
+	if c.token == "" {
+		t, err := c.login()
+		if err != nil {
+			return err
+		}
+		c.token = t
+	}
+
+	err := c.do(method, path, c.token, body, out)
+	if err == errPermissionDenied && approle {
+		t, lerr := c.login()
+		if lerr != nil {
+			return lerr
+		}
+		c.token = t
+
+		err = c.do(method, path, c.token, body, out)
+	}
+
+	return err
+}
+
+var errPermissionDenied = errors.New("permission denied")
+
+func (c *Client) do(method, path, token string, body interface{}, out *response) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.addr+path, r)
+	if err != nil {
+		return err
+	}
+
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var res response
+	if resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil && err != io.EOF {
+			return fmt.Errorf("invalid vault response: %s", err)
+		}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode == http.StatusForbidden:
+		return errPermissionDenied
+	case resp.StatusCode == http.StatusBadRequest && len(res.Errors) > 0 && strings.Contains(res.Errors[0], "check-and-set"):
+		return ErrCheckAndSet
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("vault error: status=%d errors=%s", resp.StatusCode, strings.Join(res.Errors, "; "))
+	}
+
+	if out != nil {
+		*out = res
+	}
+
+	return nil
+}
+
+// Read returns the data and version of the latest version of the secret
+func (c *Client) Read(mount, path string) (map[string]interface{}, int, error) {
+	var resp response
+	if err := c.request("GET", fmt.Sprintf("/v1/%s/data/%s", mount, path), nil, &resp); err != nil {
+		return nil, 0, err
+	}
+
+	var kv kvData
+	if err := json.Unmarshal(resp.Data, &kv); err != nil {
+		return nil, 0, fmt.Errorf("invalid vault secret: %s", err)
+	}
+
+	// deleted versions have no data
+	if kv.Data == nil {
+		return nil, 0, ErrNotFound
+	}
+
+	return kv.Data, kv.Metadata.Version, nil
+}
+
+// Write writes a new version of the secret and returns the version; if cas
+// is not negative the write only succeeds if the current version is cas
+// (0 for a new secret)
+func (c *Client) Write(mount, path string, data map[string]interface{}, cas int) (int, error) {
+	body := map[string]interface{}{
+		"data": data,
+	}
+	if cas >= 0 {
+		body["options"] = map[string]interface{}{
+			"cas": cas,
+		}
+	}
+
+	var resp response
+	if err := c.request("POST", fmt.Sprintf("/v1/%s/data/%s", mount, path), body, &resp); err != nil {
+		return 0, err
+	}
+
+	var meta struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(resp.Data, &meta); err != nil {
+		return 0, fmt.Errorf("invalid vault response: %s", err)
+	}
+
+	return meta.Version, nil
+}
+
+// Delete removes all of the versions of the secret
+func (c *Client) Delete(mount, path string) error {
+	return c.request("DELETE", fmt.Sprintf("/v1/%s/metadata/%s", mount, path), nil, nil)
+}
+
+// List returns the keys under the path; directories end with a slash
+func (c *Client) List(mount, path string) ([]string, error) {
+	var resp response
+	if err := c.request("GET", fmt.Sprintf("/v1/%s/metadata/%s?list=true", mount, path), nil, &resp); err != nil {
+		return nil, err
+	}
+
+	var keys struct {
+		Keys []string `json:"keys"`
+	}
+	if err := json.Unmarshal(resp.Data, &keys); err != nil {
+		return nil, fmt.Errorf("invalid vault response: %s", err)
+	}
+
+	return keys.Keys, nil
+}
+
+// Secret returns the field of the secret at the path of the mount (i.e.
+// secret/interlock/haproxy)
+func (c *Client) Secret(path, field string) (string, error) {
+	parts := strings.SplitN(strings.Trim(path, "/"), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("invalid vault path %q; expected mount/path", path)
+	}
+
+	data, _, err := c.Read(parts[0], parts[1])
+	if err != nil {
+		return "", err
+	}
+
+	v, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("field %s not found in %s", field, path)
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("field %s of %s is not a string", field, path)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,146 @@
+package vault
+
+import (
+	"testing"
+
+	"github.com/docker/libkv/store"
+	"github.com/ehazlett/interlock/pkg/vault/vaulttest"
+)
+
+func TestClientToken(t *testing.T) {
+	srv := vaulttest.NewServer()
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, &Auth{Token: vaulttest.Token}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Write(vaulttest.Mount, "interlock/haproxy", map[string]interface{}{"admin_pass": "s3cret"}, -1); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := c.Secret("secret/interlock/haproxy", "admin_pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v != "s3cret" {
+		t.Fatalf("expected s3cret; received %q", v)
+	}
+
+	if _, err := c.Secret("secret/interlock/haproxy", "missing"); err == nil {
+		t.Fatal("expected error for missing field")
+	}
+
+	if _, _, err := c.Read(vaulttest.Mount, "interlock/missing"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound; received %v", err)
+	}
+}
+
+func TestClientAppRole(t *testing.T) {
+	srv := vaulttest.NewServer()
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, &Auth{RoleID: vaulttest.RoleID, SecretID: vaulttest.SecretID}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Write(vaulttest.Mount, "interlock/haproxy", map[string]interface{}{"admin_pass": "s3cret"}, -1); err != nil {
+		t.Fatal(err)
+	}
+
+	if srv.Logins() != 1 {
+		t.Fatalf("expected 1 login; received %d", srv.Logins())
+	}
+
+	// the expired token is renewed by logging in again
+	srv.RevokeTokens()
+
+	if _, err := c.Secret("secret/interlock/haproxy", "admin_pass"); err != nil {
+		t.Fatal(err)
+	}
+
+	if srv.Logins() != 2 {
+		t.Fatalf("expected 2 logins; received %d", srv.Logins())
+	}
+}
+
+func TestClientInvalidAuth(t *testing.T) {
+	srv := vaulttest.NewServer()
+	defer srv.Close()
+
+	if _, err := NewClient(srv.URL, &Auth{RoleID: vaulttest.RoleID}, nil); err == nil {
+		t.Fatal("expected error without a secret id")
+	}
+
+	c, err := NewClient(srv.URL, &Auth{Token: "bogus"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := c.Read(vaulttest.Mount, "interlock/haproxy"); err == nil {
+		t.Fatal("expected error for invalid token")
+	}
+}
+
+func TestStore(t *testing.T) {
+	srv := vaulttest.NewServer()
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, &Auth{Token: vaulttest.Token}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewStore(c, vaulttest.Mount, "cluster")
+
+	if ok, err := s.Exists("interlock/v1/config"); err != nil || ok {
+		t.Fatalf("expected missing key; received %v %v", ok, err)
+	}
+
+	if err := s.Put("interlock/v1/config", []byte(`ListenAddr = ":8080"`), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	kv, err := s.Get("interlock/v1/config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(kv.Value) != `ListenAddr = ":8080"` || kv.LastIndex != 1 {
+		t.Fatalf("unexpected pair: %s %d", kv.Value, kv.LastIndex)
+	}
+
+	// check-and-set
+	if _, _, err := s.AtomicPut("interlock/v1/config", []byte("a"), nil, nil); err != store.ErrKeyExists {
+		t.Fatalf("expected ErrKeyExists; received %v", err)
+	}
+
+	ok, kv, err := s.AtomicPut("interlock/v1/config", []byte("b"), kv, nil)
+	if err != nil || !ok || kv.LastIndex != 2 {
+		t.Fatalf("unexpected atomic put: %v %v", ok, err)
+	}
+
+	if _, _, err := s.AtomicPut("interlock/v1/config", []byte("c"), &store.KVPair{LastIndex: 1}, nil); err != store.ErrKeyModified {
+		t.Fatalf("expected ErrKeyModified; received %v", err)
+	}
+
+	pairs, err := s.List("interlock/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pairs) != 1 || pairs[0].Key != "interlock/v1/config" {
+		t.Fatalf("unexpected pairs: %v", pairs)
+	}
+
+	if err := s.Delete("interlock/v1/config"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Get("interlock/v1/config"); err != store.ErrKeyNotFound {
+		t.Fatalf("expected ErrKeyNotFound; received %v", err)
+	}
+}
@@ -0,0 +1,221 @@
+// Package vaulttest provides an in-memory Vault server with a KV version 2
+// mount and the AppRole auth method for tests
+package vaulttest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// Mount is the KV version 2 mount of the server
+	Mount = "secret"
+	// Token is the root token of the server
+	Token = "root"
+	// RoleID and SecretID are the AppRole credentials of the server
+	RoleID   = "interlock-role"
+	SecretID = "interlock-secret"
+)
+
+// Server is a fake Vault server
+type Server struct {
+	*httptest.Server
+
+	lock    sync.Mutex
+	tokens  map[string]bool
+	logins  int
+	secrets map[string][]map[string]interface{}
+}
+
+// NewServer starts a server accepting the root token and the AppRole
+// credentials
+func NewServer() *Server {
+	s := &Server{
+		tokens: map[string]bool{
+			Token: true,
+		},
+		secrets: map[string][]map[string]interface{}{},
+	}
+	s.Server = httptest.NewServer(s)
+
+	return s
+}
+
+// Logins returns the number of AppRole logins
+func (s *Server) Logins() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.logins
+}
+
+// RevokeTokens revokes the AppRole tokens (i.e. to test a token expiry)
+func (s *Server) RevokeTokens() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.tokens = map[string]bool{
+		Token: true,
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if r.URL.Path == "/v1/auth/approle/login" && r.Method == "POST" {
+		s.login(w, r)
+		return
+	}
+
+	if !s.tokens[r.Header.Get("X-Vault-Token")] {
+		writeError(w, http.StatusForbidden, "permission denied")
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/v1/"), "/", 3)
+	if len(parts) != 3 || parts[0] != Mount {
+		writeError(w, http.StatusNotFound, "no handler for route")
+		return
+	}
+
+	path := strings.Trim(parts[2], "/")
+	switch {
+	case parts[1] == "data" && r.Method == "GET":
+		s.read(w, path)
+	case parts[1] == "data" && (r.Method == "POST" || r.Method == "PUT"):
+		s.write(w, r, path)
+	case parts[1] == "metadata" && r.Method == "DELETE":
+		delete(s.secrets, path)
+		w.WriteHeader(http.StatusNoContent)
+	case parts[1] == "metadata" && r.Method == "GET" && r.URL.Query().Get("list") == "true":
+		s.list(w, path)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "unsupported operation")
+	}
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RoleID   string `json:"role_id"`
+		SecretID string `json:"secret_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RoleID != RoleID || req.SecretID != SecretID {
+		writeError(w, http.StatusBadRequest, "invalid role or secret ID")
+		return
+	}
+
+	s.logins++
+	token := fmt.Sprintf("approle-%d", s.logins)
+	s.tokens[token] = true
+
+	writeJSON(w, map[string]interface{}{
+		"auth": map[string]interface{}{
+			"client_token": token,
+		},
+	})
+}
+
+func (s *Server) read(w http.ResponseWriter, path string) {
+	versions := s.secrets[path]
+	if len(versions) == 0 {
+		writeError(w, http.StatusNotFound, "")
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"data": map[string]interface{}{
+			"data": versions[len(versions)-1],
+			"metadata": map[string]interface{}{
+				"version": len(versions),
+			},
+		},
+	})
+}
+
+func (s *Server) write(w http.ResponseWriter, r *http.Request, path string) {
+	var req struct {
+		Data    map[string]interface{} `json:"data"`
+		Options struct {
+			CAS *int `json:"cas"`
+		} `json:"options"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	versions := s.secrets[path]
+	if req.Options.CAS != nil && *req.Options.CAS != len(versions) {
+		writeError(w, http.StatusBadRequest, "check-and-set parameter did not match the current version")
+		return
+	}
+
+	s.secrets[path] = append(versions, req.Data)
+
+	writeJSON(w, map[string]interface{}{
+		"data": map[string]interface{}{
+			"version": len(s.secrets[path]),
+		},
+	})
+}
+
+func (s *Server) list(w http.ResponseWriter, path string) {
+	prefix := path + "/"
+	if path == "" {
+		prefix = ""
+	}
+
+	found := map[string]bool{}
+	for k := range s.secrets {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+
+		rest := strings.TrimPrefix(k, prefix)
+		if i := strings.Index(rest, "/"); i >= 0 {
+			rest = rest[:i+1]
+		}
+		found[rest] = true
+	}
+
+	if len(found) == 0 {
+		writeError(w, http.StatusNotFound, "")
+		return
+	}
+
+	keys := []string{}
+	for k := range found {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	writeJSON(w, map[string]interface{}{
+		"data": map[string]interface{}{
+			"keys": keys,
+		},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	errors := []string{}
+	if msg != "" {
+		errors = append(errors, msg)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": errors,
+	})
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ehazlett/interlock/ext/lb/utils"
+)
+
+// linter is an extension reporting the problems of the container labels
+type linter interface {
+	Lint() []*utils.Report
+}
+
+// lintHandler serves the label diagnostics of the containers (/lint) or of
+// a container by id, id prefix or name (/lint/<container>)
+func (s *Server) lintHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	container := strings.Trim(strings.TrimPrefix(r.URL.Path, "/lint"), "/")
+
+	reports := []*utils.Report{}
+	for _, x := range s.extensions {
+		l, ok := x.(linter)
+		if !ok {
+			continue
+		}
+
+		for _, report := range l.Lint() {
+			if container == "" || report.Name == container || strings.HasPrefix(report.Container, container) {
+				reports = append(reports, report)
+			}
+		}
+	}
+
+	if container != "" && len(reports) == 0 {
+		http.Error(w, fmt.Sprintf("container %s is not handled by interlock", container), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(reports); err != nil {
+		log.Errorf("error encoding label diagnostics: %s", err)
+	}
+}
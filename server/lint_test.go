@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ehazlett/interlock/ext"
+	"github.com/ehazlett/interlock/ext/lb/utils"
+)
+
+type fakeLinter struct {
+	fakeExtension
+	reports []*utils.Report
+}
+
+func (l *fakeLinter) Lint() []*utils.Report {
+	return l.reports
+}
+
+func TestLintHandler(t *testing.T) {
+	s := &Server{
+		extensions: []ext.Extension{
+			&fakeExtension{},
+			&fakeLinter{reports: []*utils.Report{
+				{Container: "0123456789ab", Name: "web", Diagnostics: []utils.Diagnostic{
+					{Label: ext.InterlockPortLabel, Severity: utils.SeverityError, Message: "invalid port"},
+				}},
+				{Container: "abcdef012345", Name: "api", Diagnostics: []utils.Diagnostic{}},
+			}},
+		},
+	}
+
+	for path, expected := range map[string][]string{
+		"/lint":        {"web", "api"},
+		"/lint/web":    {"web"},
+		"/lint/abcdef": {"api"},
+	} {
+		w := httptest.NewRecorder()
+		s.lintHandler(w, httptest.NewRequest("GET", path, nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200; received %d", path, w.Code)
+		}
+
+		var reports []*utils.Report
+		if err := json.NewDecoder(w.Body).Decode(&reports); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(reports) != len(expected) {
+			t.Fatalf("%s: expected %v; received %+v", path, expected, reports)
+		}
+
+		for i, r := range reports {
+			if r.Name != expected[i] {
+				t.Fatalf("%s: expected %v; received %+v", path, expected, reports)
+			}
+		}
+	}
+
+	w := httptest.NewRecorder()
+	s.lintHandler(w, httptest.NewRequest("GET", "/lint/db", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown container; received %d", w.Code)
+	}
+}
@@ -14,26 +14,37 @@ import (
 	"github.com/docker/docker/api/types"
 	etypes "github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
-	"github.com/docker/docker/client"
+	"github.com/docker/libkv/store"
+	"github.com/ehazlett/interlock/client"
 	"github.com/ehazlett/interlock/config"
 	"github.com/ehazlett/interlock/events"
 	"github.com/ehazlett/interlock/ext"
 	"github.com/ehazlett/interlock/ext/beacon"
 	"github.com/ehazlett/interlock/ext/lb"
+	"github.com/ehazlett/interlock/metrics"
+	"github.com/ehazlett/interlock/trace"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"golang.org/x/net/context"
 )
 
 const (
 	defaultPollInterval = time.Millisecond * 2000
+	// stopTimeout is the time to finish the api requests and export the
+	// pending spans when stopping
+	stopTimeout = time.Second * 10
 )
 
 type Server struct {
 	cfg           *config.Config
+	kv            store.Store
 	client        *client.Client
 	extensions    []ext.Extension
 	metrics       *Metrics
 	containerHash string
+	httpServer    *http.Server
+	tracer        *sdktrace.TracerProvider
 }
 
 var (
@@ -45,11 +56,15 @@ var (
 	recoverChan  chan (bool)
 )
 
-func NewServer(cfg *config.Config) (*Server, error) {
+// NewServer returns the server of the config; kv is the discovery key value
+// store used to publish the proxy configs (nil if not using discovery)
+func NewServer(cfg *config.Config, kv store.Store) (*Server, error) {
 	s := &Server{
 		cfg:           cfg,
+		kv:            kv,
 		metrics:       NewMetrics(),
 		containerHash: "",
+		httpServer:    &http.Server{Addr: cfg.ListenAddr},
 	}
 
 	client, err := s.getDockerClient()
@@ -66,12 +81,22 @@ func NewServer(cfg *config.Config) (*Server, error) {
 
 	s.client = client
 
+	if cfg.TracingEndpoint != "" {
+		log.Infof("exporting traces: endpoint=%s", cfg.TracingEndpoint)
+		tp, err := trace.Init("interlock", cfg.TracingEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		s.tracer = tp
+	}
+
 	// eventErrChan handler
 	// this handles event stream errors
 	go func() {
 		for range eventErrChan {
 			// error from swarm event stream; attempt to restart
 			log.Error("event stream fail; attempting to reconnect")
+			s.metrics.EventStreamReconnects.Inc()
 			s.waitForSwarm()
 			restartChan <- true
 		}
@@ -89,6 +114,7 @@ func NewServer(cfg *config.Config) (*Server, error) {
 			if strings.Index(err.Error(), "500 Internal Server Error") > -1 {
 				log.Debug("swarm error detected")
 
+				s.metrics.EventStreamReconnects.Inc()
 				s.waitForSwarm()
 
 				restartChan <- true
@@ -123,7 +149,7 @@ func NewServer(cfg *config.Config) (*Server, error) {
 					for {
 						msg := <-ch
 						m := &events.Message{
-							msg,
+							Message: msg,
 						}
 
 						eventChan <- m
@@ -161,7 +187,7 @@ func NewServer(cfg *config.Config) (*Server, error) {
 
 			// trigger initial load
 			eventChan <- &events.Message{
-				etypes.Message{
+				Message: etypes.Message{
 					ID:     "0",
 					Status: "interlock-start",
 				},
@@ -175,23 +201,7 @@ func NewServer(cfg *config.Config) (*Server, error) {
 
 	go func() {
 		for e := range eventChan {
-			log.Debugf("event received: status=%s id=%s type=%s action=%s", e.Status, e.ID, e.Type, e.Action)
-
-			if e.ID == "" && e.Type == "" {
-				continue
-			}
-
-			// send the raw event for extension handling
-			for _, ext := range s.extensions {
-				log.Debugf("notifying extension: %s", ext.Name())
-				if err := ext.HandleEvent(e); err != nil {
-					errChan <- err
-					continue
-				}
-			}
-
-			// counter
-			s.metrics.EventsProcessed.Inc()
+			s.dispatch(e)
 		}
 	}()
 
@@ -209,6 +219,52 @@ func NewServer(cfg *config.Config) (*Server, error) {
 	return s, nil
 }
 
+// dispatch sends the event to the extensions.  The span of the dispatch is
+// set on the event so the extensions can link their work to it.
+func (s *Server) dispatch(e *events.Message) {
+	log.Debugf("event received: status=%s id=%s type=%s action=%s", e.Status, e.ID, e.Type, e.Action)
+
+	if e.ID == "" && e.Type == "" {
+		return
+	}
+
+	ctx, span := trace.StartKind(context.Background(), "interlock.event", trace.KindServer)
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("event.id", e.ID),
+		attribute.String("event.type", e.Type),
+		attribute.String("event.action", e.Action),
+		attribute.String("event.status", e.Status),
+	)
+
+	// time from the event in the engine until it is dispatched
+	if e.TimeNano > 0 {
+		span.SetAttributes(attribute.Float64("event.delay_ms", time.Since(time.Unix(0, e.TimeNano)).Seconds()*1000))
+	}
+
+	e.Trace = span.SpanContext()
+
+	// send the raw event for extension handling
+	for _, ext := range s.extensions {
+		log.Debugf("notifying extension: %s", ext.Name())
+
+		_, extSpan := trace.Start(ctx, "extension.handle_event")
+		extSpan.SetAttributes(attribute.String("extension", ext.Name()))
+		err := ext.HandleEvent(e)
+		trace.SetError(extSpan, err)
+		extSpan.End()
+
+		if err != nil {
+			errChan <- err
+			continue
+		}
+	}
+
+	// counter
+	s.metrics.EventsProcessed.Inc()
+}
+
 func (s *Server) waitForSwarm() {
 	log.Info("waiting for event stream to become ready")
 
@@ -225,6 +281,9 @@ func (s *Server) waitForSwarm() {
 }
 
 func (s *Server) loadExtensions(client *client.Client) {
+	// keys of the published proxy configs
+	published := map[string]bool{}
+
 	for _, x := range s.cfg.Extensions {
 		log.Debugf("loading extension: name=%s", x.Name)
 		switch strings.ToLower(x.Name) {
@@ -234,6 +293,18 @@ func (s *Server) loadExtensions(client *client.Client) {
 				log.Errorf("error loading load balancer extension: %s", err)
 				continue
 			}
+			if x.Proxy().PublishConfig {
+				key := lb.PublishKey(x.Name, x.Proxy().LabelPrefix)
+				switch {
+				case s.kv == nil:
+					log.Errorf("unable to publish %s config: discovery is not configured", x.Name)
+				case published[key]:
+					log.Errorf("unable to publish %s config: %s is published by another extension; use a different LabelPrefix", x.Name, key)
+				default:
+					published[key] = true
+					p.Publish(s.kv)
+				}
+			}
 			s.extensions = append(s.extensions, p)
 		case "beacon":
 			if !s.cfg.EnableMetrics {
@@ -267,6 +338,7 @@ func (s *Server) runPoller(d time.Duration) {
 			containers, err := s.client.ContainerList(context.Background(), opts)
 			if err != nil {
 				log.Warnf("poller: unable to get containers: %s", err)
+				metrics.APIError(metrics.ServerLabel)
 				continue
 			}
 
@@ -305,7 +377,7 @@ func (s *Server) runPoller(d time.Duration) {
 				s.containerHash = sum
 				// trigger update
 				eventChan <- &events.Message{
-					etypes.Message{
+					Message: etypes.Message{
 						ID:     fmt.Sprintf("%d", time.Now().UnixNano()),
 						Status: "interlock-restart",
 					},
@@ -321,6 +393,10 @@ func (s *Server) Run() error {
 		http.Handle("/metrics", prometheus.Handler())
 	}
 
+	// label diagnostics of the load balancers
+	http.HandleFunc("/lint", s.lintHandler)
+	http.HandleFunc("/lint/", s.lintHandler)
+
 	if s.cfg.PollInterval != "" {
 		// run background poller
 		d, err := time.ParseDuration(s.cfg.PollInterval)
@@ -337,9 +413,25 @@ func (s *Server) Run() error {
 		s.runPoller(d)
 	}
 
-	if err := http.ListenAndServe(s.cfg.ListenAddr, nil); err != nil {
+	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		return err
 	}
 
 	return nil
 }
+
+// Stop stops the api listener and exports the spans still batched
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+	defer cancel()
+
+	err := s.httpServer.Shutdown(ctx)
+
+	if s.tracer != nil {
+		if tErr := s.tracer.Shutdown(ctx); tErr != nil && err == nil {
+			err = tErr
+		}
+	}
+
+	return err
+}
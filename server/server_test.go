@@ -0,0 +1,110 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	etypes "github.com/docker/docker/api/types/events"
+	"github.com/ehazlett/interlock/events"
+	"github.com/ehazlett/interlock/ext"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+	"golang.org/x/net/context"
+)
+
+type fakeExtension struct {
+	received []*events.Message
+}
+
+func (e *fakeExtension) Name() string {
+	return "fake"
+}
+
+func (e *fakeExtension) HandleEvent(event *events.Message) error {
+	e.received = append(e.received, event)
+	return nil
+}
+
+func TestDispatchSpans(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	x := &fakeExtension{}
+	s := &Server{
+		extensions: []ext.Extension{x},
+		metrics:    NewMetrics(),
+	}
+
+	e := &events.Message{
+		Message: etypes.Message{ID: "abc", Type: "container", Status: "start"},
+	}
+	s.dispatch(e)
+
+	if len(x.received) != 1 || !x.received[0].Trace.IsValid() {
+		t.Fatalf("expected event with span context to be dispatched: %+v", x.received)
+	}
+
+	spans := exp.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans; received %d", len(spans))
+	}
+
+	handle, event := spans[0], spans[1]
+	if event.Name != "interlock.event" || !event.SpanContext.Equal(e.Trace) || event.Parent.IsValid() {
+		t.Fatalf("unexpected event span: %+v", event)
+	}
+
+	if handle.Name != "extension.handle_event" || handle.Parent.SpanID() != event.SpanContext.SpanID() || handle.SpanContext.TraceID() != event.SpanContext.TraceID() {
+		t.Fatalf("expected handle span to be a child of the event: %+v", handle)
+	}
+}
+
+// exporter records the exported spans; the tracetest exporter forgets them
+// on shutdown
+type exporter struct {
+	lock  sync.Mutex
+	names []string
+}
+
+func (e *exporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	for _, s := range spans {
+		e.names = append(e.names, s.Name())
+	}
+
+	return nil
+}
+
+func (e *exporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func TestStopExportsSpans(t *testing.T) {
+	exp := &exporter{}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp))
+
+	s := &Server{
+		httpServer: &http.Server{},
+		tracer:     tp,
+	}
+
+	_, span := tp.Tracer("test").Start(context.Background(), "test")
+	span.End()
+
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	exp.lock.Lock()
+	defer exp.lock.Unlock()
+
+	if len(exp.names) != 1 || exp.names[0] != "test" {
+		t.Fatalf("expected the batched span to be exported: %v", exp.names)
+	}
+}
@@ -3,11 +3,10 @@ package server
 import (
 	"strings"
 
-	engineClient "github.com/docker/docker/client"
 	"github.com/ehazlett/interlock/client"
 )
 
-func (s *Server) getDockerClient() (*engineClient.Client, error) {
+func (s *Server) getDockerClient() (*client.Client, error) {
 	return client.GetDockerClient(
 		s.cfg.DockerURL,
 		s.cfg.TLSCACert,
@@ -0,0 +1,92 @@
+package trace
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+)
+
+const (
+	KindInternal = oteltrace.SpanKindInternal
+	KindServer   = oteltrace.SpanKindServer
+	KindClient   = oteltrace.SpanKindClient
+
+	// tracerName is the instrumentation scope of the interlock spans
+	tracerName = "github.com/ehazlett/interlock"
+)
+
+type (
+	// Span is an operation being traced.  The spans started while tracing
+	// is disabled are not recorded.
+	Span = oteltrace.Span
+	// SpanContext identifies a span across process and goroutine boundaries
+	SpanContext = oteltrace.SpanContext
+)
+
+// Init configures the global tracer provider to batch and export the spans
+// to the OTLP/HTTP endpoint (i.e. http://collector:4318/v1/traces)
+func Init(service, endpoint string) (*sdktrace.TracerProvider, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("tracing endpoint is required")
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(service))),
+	)
+	otel.SetTracerProvider(tp)
+
+	return tp, nil
+}
+
+// Start starts a span which is a child of the span in the context (if any)
+// and returns a context containing the new span
+func Start(ctx context.Context, name string, opts ...oteltrace.SpanStartOption) (context.Context, Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, opts...)
+}
+
+// StartKind starts a span of the given kind
+func StartKind(ctx context.Context, name string, kind oteltrace.SpanKind) (context.Context, Span) {
+	return Start(ctx, name, oteltrace.WithSpanKind(kind))
+}
+
+// WithLinks links the span to other spans (i.e. the events which triggered
+// a reload)
+func WithLinks(scs ...SpanContext) oteltrace.SpanStartOption {
+	links := []oteltrace.Link{}
+	for _, sc := range scs {
+		if sc.IsValid() {
+			links = append(links, oteltrace.Link{SpanContext: sc})
+		}
+	}
+
+	return oteltrace.WithLinks(links...)
+}
+
+// ContextWithSpanContext returns a context with the span context as parent
+// for new spans
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return oteltrace.ContextWithSpanContext(ctx, sc)
+}
+
+// SetError records the error on the span and marks the span as failed
+func SetError(span Span, err error) {
+	if err == nil {
+		return
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,126 @@
+package trace_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ehazlett/interlock/trace"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+	"golang.org/x/net/context"
+)
+
+// record records the spans in memory until the returned func is called
+func record() (*tracetest.InMemoryExporter, func()) {
+	exp := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp)))
+
+	return exp, func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	}
+}
+
+func spansByName(exp *tracetest.InMemoryExporter) map[string]tracetest.SpanStub {
+	byName := map[string]tracetest.SpanStub{}
+	for _, s := range exp.GetSpans() {
+		byName[s.Name] = s
+	}
+
+	return byName
+}
+
+func TestInitNoEndpoint(t *testing.T) {
+	if _, err := trace.Init("interlock", ""); err == nil {
+		t.Fatal("expected error without endpoint")
+	}
+}
+
+func TestStartDisabled(t *testing.T) {
+	otel.SetTracerProvider(noop.NewTracerProvider())
+
+	ctx, span := trace.Start(context.Background(), "test")
+	span.SetAttributes(attribute.String("key", "value"))
+	trace.SetError(span, errors.New("error"))
+	span.End()
+
+	if span.SpanContext().IsValid() {
+		t.Fatal("expected no span context when tracing is disabled")
+	}
+
+	_, child := trace.Start(ctx, "child")
+	if child.SpanContext().IsValid() {
+		t.Fatal("expected no span context in context")
+	}
+}
+
+func TestSpans(t *testing.T) {
+	exp, done := record()
+	defer done()
+
+	ctx, root := trace.StartKind(context.Background(), "root", trace.KindServer)
+	root.SetAttributes(attribute.String("event.id", "abc"))
+
+	_, child := trace.Start(ctx, "child")
+	trace.SetError(child, errors.New("child failed"))
+	child.End()
+
+	_, other := trace.Start(context.Background(), "other", trace.WithLinks(root.SpanContext(), trace.SpanContext{}))
+	other.End()
+
+	root.End()
+
+	spans := spansByName(exp)
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans; received %d", len(spans))
+	}
+
+	r := spans["root"]
+	if r.Parent.IsValid() || r.SpanKind != trace.KindServer || !r.SpanContext.IsValid() {
+		t.Fatalf("unexpected root span: %+v", r)
+	}
+
+	if len(r.Attributes) != 1 || r.Attributes[0] != attribute.String("event.id", "abc") {
+		t.Fatalf("unexpected root attributes: %+v", r.Attributes)
+	}
+
+	ch := spans["child"]
+	if ch.SpanContext.TraceID() != r.SpanContext.TraceID() || ch.Parent.SpanID() != r.SpanContext.SpanID() {
+		t.Fatalf("expected child of root: %+v", ch)
+	}
+
+	if ch.Status.Code != codes.Error || ch.Status.Description != "child failed" {
+		t.Fatalf("expected error status: %+v", ch.Status)
+	}
+
+	o := spans["other"]
+	if o.SpanContext.TraceID() == r.SpanContext.TraceID() || o.Parent.IsValid() {
+		t.Fatalf("expected new trace: %+v", o)
+	}
+
+	// the invalid span context is not linked
+	if len(o.Links) != 1 || !o.Links[0].SpanContext.Equal(r.SpanContext) {
+		t.Fatalf("expected link to root: %+v", o.Links)
+	}
+}
+
+func TestContextWithSpanContext(t *testing.T) {
+	exp, done := record()
+	defer done()
+
+	_, parent := trace.Start(context.Background(), "parent")
+	parent.End()
+
+	// i.e. an event span handed to a different goroutine
+	ctx := trace.ContextWithSpanContext(context.Background(), parent.SpanContext())
+	_, span := trace.Start(ctx, "child")
+	span.End()
+
+	child, ok := spansByName(exp)["child"]
+	if !ok || child.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("expected child of parent: %+v", child)
+	}
+}